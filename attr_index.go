@@ -0,0 +1,428 @@
+/*
+  Attribute-based indexes of nodes and nests
+
+  An index maps values of a single string attribute to the nodes (or nests) that have the
+  attribute set to these values. Indexes are optional: they are created explicitly by
+  means of "Graph.NewNodeAttrIndex()" and "NestTree.NewNestAttrIndex()". Once created, an
+  index is kept up to date by all the mutations of the attribute values (including the
+  ones made by undo and redo) and by deletion of the elements
+
+  Lookups ("FindNodesByAttr()", "FindNodesByAttrPrefix()" and their nest counterparts)
+  work for non-indexed attributes as well. But for them the lookups fall back to the full
+  scan of the graph (or the nest tree)
+
+  Results of the lookups are ordered deterministically: the elements with the same value
+  are ordered by their IDs, and the prefix lookups additionally order the elements by the
+  attribute values
+
+  NOTE: lookups never modify the index. So, they are safe to be called simultaneously from
+        the readers of "ConcurrentGraph"
+  NOTE: an index is dropped automatically when the indexed attribute is released
+*/
+
+package graph
+
+import (
+	"sort"
+	"strings"
+)
+
+// Index of the elements (nodes or nests) by values of a string attribute
+type strAttrIndex[E any] struct {
+	// Indexed elements grouped by attribute values. Inside a group the elements are
+	// keyed by their IDs
+	elems map[string]map[int]E
+	// Distinct indexed values in the ascending order (needed for the prefix lookups)
+	vals []string
+}
+
+// Create an empty index
+func newStrAttrIndex[E any]() *strAttrIndex[E] {
+	return &strAttrIndex[E]{elems: make(map[string]map[int]E)}
+}
+
+// Add an element to the index
+func (idx *strAttrIndex[E]) add(val string, id int, elem E) {
+	group, ok := idx.elems[val]
+
+	if !ok {
+		group = make(map[int]E)
+		idx.elems[val] = group
+		pos := sort.SearchStrings(idx.vals, val)
+		idx.vals = append(idx.vals, "")
+		copy(idx.vals[pos+1:], idx.vals[pos:])
+		idx.vals[pos] = val
+	}
+
+	group[id] = elem
+}
+
+// Remove an element from the index. Does nothing if the element is not indexed
+func (idx *strAttrIndex[E]) remove(val string, id int) {
+	group, ok := idx.elems[val]
+
+	if !ok {
+		return
+	}
+
+	delete(group, id)
+
+	if len(group) == 0 {
+		delete(idx.elems, val)
+		pos := sort.SearchStrings(idx.vals, val)
+		idx.vals = append(idx.vals[:pos], idx.vals[pos+1:]...)
+	}
+}
+
+// Update the index after a change of the attribute value of an element
+func (idx *strAttrIndex[E]) update(id int, elem E, old_val strAttrVal,
+	new_val strAttrVal) {
+
+	if old_val.isSet {
+		idx.remove(old_val.data, id)
+	}
+
+	if new_val.isSet {
+		idx.add(new_val.data, id, elem)
+	}
+}
+
+// Append the elements having a specific value to a list (ordered by IDs)
+func (idx *strAttrIndex[E]) appendGroup(elems []E, val string) []E {
+	group := idx.elems[val]
+	ids := make([]int, 0, len(group))
+
+	for id := range group {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		elems = append(elems, group[id])
+	}
+
+	return elems
+}
+
+// Find the elements having a specific value
+func (idx *strAttrIndex[E]) find(val string) []E {
+	return idx.appendGroup(nil, val)
+}
+
+// Find the elements whose values start with a specific prefix
+func (idx *strAttrIndex[E]) findPrefix(prefix string) []E {
+	var elems []E
+
+	for pos := sort.SearchStrings(idx.vals, prefix); pos < len(idx.vals); pos++ {
+		if !strings.HasPrefix(idx.vals[pos], prefix) {
+			break
+		}
+
+		elems = idx.appendGroup(elems, idx.vals[pos])
+	}
+
+	return elems
+}
+
+// Check that a node string attribute is valid and belongs to a Graph
+func (graph *Graph) checkNodeStrAttr(op string, attr *NodeStrAttr) error {
+	if attr == nil || !attr.isValid {
+		return newAttrError(op, ATTR_ELEM_NODE, -1, -1, ErrAttrInvalid)
+	}
+
+	if attr.graph != graph {
+		return newAttrError(op, ATTR_ELEM_NODE, -1, attr.attrNum, ErrAttrForeign)
+	}
+
+	return nil
+}
+
+// Create an index of graph nodes by values of a node string attribute. If the index
+// already exists, nothing is done
+func (graph *Graph) NewNodeAttrIndex(attr *NodeStrAttr) (err error) {
+	defer recoverInternalError("NewNodeAttrIndex", &err)
+
+	if err := graph.checkNodeStrAttr("NewNodeAttrIndex", attr); err != nil {
+		return err
+	}
+
+	if graph.nodeIndexes == nil {
+		graph.nodeIndexes = make([]*strAttrIndex[*Node], graph.attrSpec.NodeStrAttrNum)
+	}
+
+	if graph.nodeIndexes[attr.attrNum] == nil {
+		graph.nodeIndexes[attr.attrNum] = graph.scanNodes(attr.attrNum)
+	}
+
+	return nil
+}
+
+// Drop an index of graph nodes. If the index doesn't exist, nothing is done
+func (graph *Graph) DropNodeAttrIndex(attr *NodeStrAttr) error {
+	if err := graph.checkNodeStrAttr("DropNodeAttrIndex", attr); err != nil {
+		return err
+	}
+
+	graph.dropNodeAttrIndex(attr.attrNum)
+
+	return nil
+}
+
+// Check whether graph nodes are indexed by a node string attribute
+func (graph *Graph) HasNodeAttrIndex(attr *NodeStrAttr) (bool, error) {
+	if err := graph.checkNodeStrAttr("HasNodeAttrIndex", attr); err != nil {
+		return false, err
+	}
+
+	return graph.nodeIndex(attr.attrNum) != nil, nil
+}
+
+// Find graph nodes having a specific value of a string attribute. The nodes are ordered
+// by their IDs
+func (graph *Graph) FindNodesByAttr(attr *NodeStrAttr, val string) (nodes []*Node,
+	err error) {
+
+	defer recoverInternalError("FindNodesByAttr", &err)
+
+	if err := graph.checkNodeStrAttr("FindNodesByAttr", attr); err != nil {
+		return nil, err
+	}
+
+	idx := graph.nodeIndex(attr.attrNum)
+
+	if idx == nil {
+		idx = graph.scanNodes(attr.attrNum)
+	}
+
+	return idx.find(val), nil
+}
+
+// Find graph nodes whose values of a string attribute start with a specific prefix. The
+// nodes are ordered by the attribute values and then by their IDs
+func (graph *Graph) FindNodesByAttrPrefix(attr *NodeStrAttr,
+	prefix string) (nodes []*Node, err error) {
+
+	defer recoverInternalError("FindNodesByAttrPrefix", &err)
+
+	if err := graph.checkNodeStrAttr("FindNodesByAttrPrefix", attr); err != nil {
+		return nil, err
+	}
+
+	idx := graph.nodeIndex(attr.attrNum)
+
+	if idx == nil {
+		idx = graph.scanNodes(attr.attrNum)
+	}
+
+	return idx.findPrefix(prefix), nil
+}
+
+// Get an index of graph nodes. Returns "nil" if the nodes are not indexed by the
+// attribute
+func (graph *Graph) nodeIndex(attr_num int) *strAttrIndex[*Node] {
+	if graph.nodeIndexes == nil {
+		return nil
+	}
+
+	return graph.nodeIndexes[attr_num]
+}
+
+// Drop an index of graph nodes (if it exists)
+func (graph *Graph) dropNodeAttrIndex(attr_num int) {
+	if graph.nodeIndexes != nil {
+		graph.nodeIndexes[attr_num] = nil
+	}
+}
+
+// Build an index of graph nodes by scanning all the nodes
+func (graph *Graph) scanNodes(attr_num int) *strAttrIndex[*Node] {
+	idx := newStrAttrIndex[*Node]()
+
+	for node := graph.GetFirstNode(); node != nil; node = node.GetNextNode() {
+		if val := node.strAttrs[attr_num]; val.isSet {
+			idx.add(val.data, node.id, node)
+		}
+	}
+
+	return idx
+}
+
+// Add a node (re-attached to the graph) to all node indexes
+func (graph *Graph) indexNode(node *Node) {
+	for attr_num, idx := range graph.nodeIndexes {
+		if idx != nil {
+			idx.update(node.id, node, strAttrVal{}, node.strAttrs[attr_num])
+		}
+	}
+}
+
+// Remove a node (detached from the graph) from all node indexes
+func (graph *Graph) unindexNode(node *Node) {
+	for attr_num, idx := range graph.nodeIndexes {
+		if idx != nil {
+			idx.update(node.id, node, node.strAttrs[attr_num], strAttrVal{})
+		}
+	}
+}
+
+// Check that a nest string attribute is valid and belongs to a nest tree
+func (nt *NestTree) checkNestStrAttr(op string, attr *NestStrAttr) error {
+	if attr == nil || !attr.is_valid {
+		return newAttrError(op, ATTR_ELEM_NEST, -1, -1, ErrAttrInvalid)
+	}
+
+	if attr.nestTree != nt {
+		return newAttrError(op, ATTR_ELEM_NEST, -1, attr.attr_num, ErrAttrForeign)
+	}
+
+	return nil
+}
+
+// Create an index of nests by values of a nest string attribute. If the index already
+// exists, nothing is done
+func (nt *NestTree) NewNestAttrIndex(attr *NestStrAttr) (err error) {
+	defer recoverInternalError("NewNestAttrIndex", &err)
+
+	if err := nt.checkNestStrAttr("NewNestAttrIndex", attr); err != nil {
+		return err
+	}
+
+	if nt.nestIndexes == nil {
+		nt.nestIndexes = make([]*strAttrIndex[*Nest],
+			nt.baseGraph.attrSpec.NestStrAttrNum)
+	}
+
+	if nt.nestIndexes[attr.attr_num] == nil {
+		nt.nestIndexes[attr.attr_num] = nt.scanNests(attr.attr_num)
+	}
+
+	return nil
+}
+
+// Drop an index of nests. If the index doesn't exist, nothing is done
+func (nt *NestTree) DropNestAttrIndex(attr *NestStrAttr) error {
+	if err := nt.checkNestStrAttr("DropNestAttrIndex", attr); err != nil {
+		return err
+	}
+
+	nt.dropNestAttrIndex(attr.attr_num)
+
+	return nil
+}
+
+// Check whether nests are indexed by a nest string attribute
+func (nt *NestTree) HasNestAttrIndex(attr *NestStrAttr) (bool, error) {
+	if err := nt.checkNestStrAttr("HasNestAttrIndex", attr); err != nil {
+		return false, err
+	}
+
+	return nt.nestIndex(attr.attr_num) != nil, nil
+}
+
+// Find nests having a specific value of a string attribute. The nests are ordered by
+// their IDs
+func (nt *NestTree) FindNestsByAttr(attr *NestStrAttr, val string) (nests []*Nest,
+	err error) {
+
+	defer recoverInternalError("FindNestsByAttr", &err)
+
+	if err := nt.checkNestStrAttr("FindNestsByAttr", attr); err != nil {
+		return nil, err
+	}
+
+	idx := nt.nestIndex(attr.attr_num)
+
+	if idx == nil {
+		idx = nt.scanNests(attr.attr_num)
+	}
+
+	return idx.find(val), nil
+}
+
+// Find nests whose values of a string attribute start with a specific prefix. The nests
+// are ordered by the attribute values and then by their IDs
+func (nt *NestTree) FindNestsByAttrPrefix(attr *NestStrAttr,
+	prefix string) (nests []*Nest, err error) {
+
+	defer recoverInternalError("FindNestsByAttrPrefix", &err)
+
+	if err := nt.checkNestStrAttr("FindNestsByAttrPrefix", attr); err != nil {
+		return nil, err
+	}
+
+	idx := nt.nestIndex(attr.attr_num)
+
+	if idx == nil {
+		idx = nt.scanNests(attr.attr_num)
+	}
+
+	return idx.findPrefix(prefix), nil
+}
+
+// Get an index of nests. Returns "nil" if the nests are not indexed by the attribute
+func (nt *NestTree) nestIndex(attr_num int) *strAttrIndex[*Nest] {
+	if nt.nestIndexes == nil {
+		return nil
+	}
+
+	return nt.nestIndexes[attr_num]
+}
+
+// Drop an index of nests (if it exists)
+func (nt *NestTree) dropNestAttrIndex(attr_num int) {
+	if nt.nestIndexes != nil {
+		nt.nestIndexes[attr_num] = nil
+	}
+}
+
+// Build an index of nests by scanning all the nests
+func (nt *NestTree) scanNests(attr_num int) *strAttrIndex[*Nest] {
+	idx := newStrAttrIndex[*Nest]()
+
+	for nest := nt.GetRootNest(); nest != nil; nest = nest.GetNextNest() {
+		if val := nest.strAttrs[attr_num]; val.isSet {
+			idx.add(val.data, nest.id, nest)
+		}
+	}
+
+	return idx
+}
+
+// Add a nest (re-attached to the tree) to all nest indexes
+func (nt *NestTree) indexNest(nest *Nest) {
+	for attr_num, idx := range nt.nestIndexes {
+		if idx != nil {
+			idx.update(nest.id, nest, strAttrVal{}, nest.strAttrs[attr_num])
+		}
+	}
+}
+
+// Remove a nest (detached from the tree) from all nest indexes
+func (nt *NestTree) unindexNest(nest *Nest) {
+	for attr_num, idx := range nt.nestIndexes {
+		if idx != nil {
+			idx.update(nest.id, nest, nest.strAttrs[attr_num], strAttrVal{})
+		}
+	}
+}
+
+// Update the indexes (if any) after a change of an attribute value of a node or a nest.
+// Elements detached from the graph (by undoing their creation) are not indexed. They are
+// indexed with their current values if they get re-attached
+func (graph *Graph) updateStrAttrIndexes(elem string, node *Node, nest *Nest,
+	attr_num int, old_val strAttrVal, new_val strAttrVal) {
+
+	switch elem {
+	case ATTR_ELEM_NODE:
+		if idx := graph.nodeIndex(attr_num); idx != nil && node.nest != nil {
+			idx.update(node.id, node, old_val, new_val)
+		}
+	case ATTR_ELEM_NEST:
+		idx := nest.nestTree.nestIndex(attr_num)
+
+		if idx != nil && nest.nestTree.NestByID(nest.id) == nest {
+			idx.update(nest.id, nest, old_val, new_val)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// Check the nodes found by an attribute value. The indexed lookup must agree with the
+// full scan of the graph
+func expectNodesFound(t *testing.T, graph *Graph, attr *NodeStrAttr, val string,
+	want []*Node, step string) {
+
+	t.Helper()
+
+	got, err := graph.FindNodesByAttr(attr, val)
+
+	if err != nil {
+		t.Fatalf("%s: FindNodesByAttr: %v", step, err)
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("%s: found %d nodes by %q, want %d", step, len(got), val, len(want))
+	}
+
+	if scanned := graph.scanNodes(attr.attrNum).find(val); !slices.Equal(got, scanned) {
+		t.Fatalf("%s: the index differs from the full scan", step)
+	}
+}
+
+// An index follows the attribute values through set, unset, undo, redo and release
+func TestNodeAttrIndex(t *testing.T) {
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 1})
+	attr, _ := graph.NewNodeStrAttr()
+	a := graph.NewNode()
+	b := graph.NewNode()
+	a.SetStrAttrVal(attr, "x")
+
+	if err := graph.NewNodeAttrIndex(attr); err != nil {
+		t.Fatalf("NewNodeAttrIndex: %v", err)
+	}
+
+	expectNodesFound(t, graph, attr, "x", []*Node{a}, "create")
+	b.SetStrAttrVal(attr, "x")
+	expectNodesFound(t, graph, attr, "x", []*Node{a, b}, "set")
+	a.SetStrAttrVal(attr, "y")
+	expectNodesFound(t, graph, attr, "x", []*Node{b}, "change")
+	expectNodesFound(t, graph, attr, "y", []*Node{a}, "change")
+	b.RemoveStrAttr(attr)
+	expectNodesFound(t, graph, attr, "x", nil, "unset")
+
+	journal := graph.EnableJournal()
+	c := graph.NewNode()
+	c.SetStrAttrVal(attr, "y")
+	a.RemoveStrAttr(attr)
+	expectNodesFound(t, graph, attr, "y", []*Node{c}, "journaled")
+
+	// Undo the removal, the value of "c" and then the creation of "c"
+	undo_all := func(step string) {
+		for journal.CanUndo() {
+			if err := journal.Undo(); err != nil {
+				t.Fatalf("%s: Undo: %v", step, err)
+			}
+		}
+	}
+
+	undo_all("undo")
+	expectNodesFound(t, graph, attr, "y", []*Node{a}, "undo")
+
+	for journal.CanRedo() {
+		if err := journal.Redo(); err != nil {
+			t.Fatalf("Redo: %v", err)
+		}
+	}
+
+	expectNodesFound(t, graph, attr, "y", []*Node{c}, "redo")
+	undo_all("undo again")
+
+	// A node detached by undo isn't indexed even if its value is changed
+	c.SetStrAttrVal(attr, "y")
+	expectNodesFound(t, graph, attr, "y", []*Node{a}, "detached")
+
+	attr_num := attr.attrNum
+
+	if err := graph.ReleaseNodeStrAttr(attr); err != nil {
+		t.Fatalf("ReleaseNodeStrAttr: %v", err)
+	}
+
+	if graph.nodeIndex(attr_num) != nil {
+		t.Fatalf("release: the index is not dropped")
+	}
+
+	// The released attribute can't be used for lookups anymore
+	if _, err := graph.FindNodesByAttr(attr, "y"); !errors.Is(err, ErrAttrInvalid) {
+		t.Fatalf("FindNodesByAttr with a released attribute: got error %v, want %v",
+			err, ErrAttrInvalid)
+	}
+}
+
+// Nest indexes don't include nests detached by undo
+func TestNestAttrIndex(t *testing.T) {
+	graph := NewGraph(AttrSpec{NestStrAttrNum: 1})
+	nest_tree := graph.GetNestTree()
+	attr, _ := nest_tree.NewNestStrAttr()
+	a := nest_tree.NewNest()
+	a.SetStrAttrVal(attr, "x")
+
+	if err := nest_tree.NewNestAttrIndex(attr); err != nil {
+		t.Fatalf("NewNestAttrIndex: %v", err)
+	}
+
+	journal := graph.EnableJournal()
+	b := nest_tree.NewNest()
+	b.SetStrAttrVal(attr, "x")
+
+	nests, _ := nest_tree.FindNestsByAttr(attr, "x")
+
+	if !slices.Equal(nests, []*Nest{a, b}) {
+		t.Fatalf("set: found %d nests, want 2", len(nests))
+	}
+
+	journal.Undo()
+	journal.Undo()
+	b.SetStrAttrVal(attr, "xy")
+
+	nests, _ = nest_tree.FindNestsByAttrPrefix(attr, "x")
+
+	if !slices.Equal(nests, []*Nest{a}) {
+		t.Fatalf("undo: found %d nests, want 1", len(nests))
+	}
+}
+
+// Lookups report misuse of attributes as errors
+func TestAttrIndexMisuse(t *testing.T) {
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 1, NestStrAttrNum: 1})
+	other := NewGraph(AttrSpec{NodeStrAttrNum: 1})
+	foreign, _ := other.NewNodeStrAttr()
+	nest_tree := graph.GetNestTree()
+
+	if _, err := graph.FindNodesByAttr(nil, "x"); !errors.Is(err, ErrAttrInvalid) {
+		t.Errorf("FindNodesByAttr(nil): got error %v, want %v", err, ErrAttrInvalid)
+	}
+
+	if err := graph.NewNodeAttrIndex(nil); !errors.Is(err, ErrAttrInvalid) {
+		t.Errorf("NewNodeAttrIndex(nil): got error %v, want %v", err, ErrAttrInvalid)
+	}
+
+	if _, err := graph.FindNodesByAttr(foreign, "x"); !errors.Is(err, ErrAttrForeign) {
+		t.Errorf("FindNodesByAttr(foreign): got error %v, want %v", err, ErrAttrForeign)
+	}
+
+	if _, err := nest_tree.FindNestsByAttrPrefix(nil, "x"); !errors.Is(err,
+		ErrAttrInvalid) {
+
+		t.Errorf("FindNestsByAttrPrefix(nil): got error %v, want %v", err,
+			ErrAttrInvalid)
+	}
+
+	if err := nest_tree.DropNestAttrIndex(nil); !errors.Is(err, ErrAttrInvalid) {
+		t.Errorf("DropNestAttrIndex(nil): got error %v, want %v", err, ErrAttrInvalid)
+	}
+}
@@ -0,0 +1,356 @@
+/*
+  Commands of graphtool
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AndreyNevolin/graph"
+)
+
+// Convert a graph to a different format
+func runConvert(args []string) error {
+	flags := newFlagSet("convert")
+	from := flags.String("from", "", "input format: "+formatNames(readers))
+	opts := &writeOpts{}
+	addWriteFlags(flags, opts)
+
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+
+	g, graph_emit_spec, err := readGraph(flags.Arg(0), *from)
+
+	if err != nil {
+		return err
+	}
+
+	return writeGraph(g, graph_emit_spec, flags.Arg(1), opts)
+}
+
+// Get printable label of a nest. An empty string is returned if the nest has no label
+func nestLabel(nest *graph.Nest, graph_emit_spec *graph.GraphEmitSpec) string {
+	if graph_emit_spec.Nest.LabelAttr == nil {
+		return ""
+	}
+
+	label, err := nest.GetStrAttrVal(graph_emit_spec.Nest.LabelAttr)
+
+	if err != nil {
+		return ""
+	}
+
+	return label
+}
+
+// Print the numbers of nodes, edges and nests and the depth of the nest tree
+func runStats(args []string) error {
+	flags := newFlagSet("stats")
+	from := flags.String("from", "", "input format: "+formatNames(readers))
+	tree := flags.Bool("tree", false, "print the nest tree")
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	g, graph_emit_spec, err := readGraph(flags.Arg(0), *from)
+
+	if err != nil {
+		return err
+	}
+
+	root_nest := g.GetNestTree().GetRootNest()
+	depth := 0
+
+	for nest := range root_nest.Descendants() {
+		depth = max(depth, nest.Depth())
+	}
+
+	// The root nest is not counted: it's present in every graph
+	fmt.Printf("nodes:      %d\n", g.NodeCount())
+	fmt.Printf("edges:      %d\n", g.EdgeCount())
+	fmt.Printf("nests:      %d\n", g.GetNestTree().NestCount()-1)
+	fmt.Printf("nest depth: %d\n", depth)
+	fmt.Printf("directed:   %t\n", g.IsDirected())
+
+	if !*tree {
+		return nil
+	}
+
+	fmt.Printf("\nnest tree (ID, label, number of nodes):\n")
+
+	for nest := range root_nest.Descendants() {
+		node_num := 0
+
+		for range nest.Nodes() {
+			node_num++
+		}
+
+		fmt.Printf("%s%d %s (%d)\n", strings.Repeat("  ", nest.Depth()), nest.GetID(),
+			strconv.Quote(nestLabel(nest, graph_emit_spec)), node_num)
+	}
+
+	return nil
+}
+
+// Read a graph and check its consistency
+func runValidate(args []string) error {
+	flags := newFlagSet("validate")
+	from := flags.String("from", "", "input format: "+formatNames(readers))
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	g, _, err := readGraph(flags.Arg(0), *from)
+
+	if err != nil {
+		return err
+	}
+
+	violations := g.Validate()
+
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("the graph is inconsistent (%d violations)", len(violations))
+	}
+
+	fmt.Println("OK")
+
+	return nil
+}
+
+// State of extracting a nest into a separate graph
+type extractor struct {
+	srcSpec  *graph.GraphEmitSpec
+	dst      *graph.Graph
+	dstSpec  *graph.GraphEmitSpec
+	dstNodes map[*graph.Node]*graph.Node
+}
+
+// Copy the label of a source node to a node of the extracted graph
+func (ext *extractor) copyNodeLabel(src_node *graph.Node, dst_node *graph.Node) error {
+	if ext.srcSpec.Node.LabelAttr == nil {
+		return nil
+	}
+
+	if is_set, err := src_node.IsStrAttrSet(ext.srcSpec.Node.LabelAttr); !is_set {
+		return err
+	}
+
+	label, err := src_node.GetStrAttrVal(ext.srcSpec.Node.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	return dst_node.SetStrAttrVal(ext.dstSpec.Node.LabelAttr, label)
+}
+
+// Copy the nodes of a source nest and the descendants of the nest into a nest of the
+// extracted graph
+func (ext *extractor) copyNest(src_nest *graph.Nest, dst_nest *graph.Nest) error {
+	for src_node := range src_nest.Nodes() {
+		dst_node, err := ext.dst.NewNodeChecked()
+
+		if err != nil {
+			return err
+		}
+
+		if err = ext.copyNodeLabel(src_node, dst_node); err != nil {
+			return err
+		}
+
+		if err = dst_node.MoveToNest(dst_nest); err != nil {
+			return err
+		}
+
+		ext.dstNodes[src_node] = dst_node
+	}
+
+	// A new child nest becomes the first child of its parent. So, the children are
+	// created in the reverse order to preserve the order of the source graph
+	var children []*graph.Nest
+
+	for child := range src_nest.Children() {
+		children = append(children, child)
+	}
+
+	dst_children := make([]*graph.Nest, len(children))
+
+	for i := len(children) - 1; i >= 0; i-- {
+		dst_child, err := dst_nest.NewChildNest()
+
+		if err != nil {
+			return err
+		}
+
+		if label := nestLabel(children[i], ext.srcSpec); label != "" {
+			err = dst_child.SetStrAttrVal(ext.dstSpec.Nest.LabelAttr, label)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		dst_children[i] = dst_child
+	}
+
+	for i, child := range children {
+		if err := ext.copyNest(child, dst_children[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write the contents of a nest as a separate graph
+func runExtract(args []string) error {
+	flags := newFlagSet("extract")
+	nest_id := flags.Int("nest", -1, "ID of the extracted nest (see \"stats -tree\")")
+	from := flags.String("from", "", "input format: "+formatNames(readers))
+	opts := &writeOpts{}
+	addWriteFlags(flags, opts)
+
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+
+	if *nest_id < 0 {
+		flags.Usage()
+
+		return &usageError{"nest ID is not specified"}
+	}
+
+	src, src_spec, err := readGraph(flags.Arg(0), *from)
+
+	if err != nil {
+		return err
+	}
+
+	src_nest := src.GetNestTree().NestByID(*nest_id)
+
+	if src_nest == nil {
+		return fmt.Errorf("the graph has no nest with ID %d", *nest_id)
+	}
+
+	// The source graph may have no spare attributes. So, the label attributes are
+	// added to the attributes of the source graph
+	dst_attr_spec := src.GetAttrSpec()
+	dst_attr_spec.GraphStrAttrNum++
+	dst_attr_spec.NodeStrAttrNum++
+	dst_attr_spec.NestStrAttrNum++
+
+	ext := &extractor{
+		srcSpec:  src_spec,
+		dst:      graph.NewGraph(dst_attr_spec),
+		dstSpec:  &graph.GraphEmitSpec{},
+		dstNodes: make(map[*graph.Node]*graph.Node),
+	}
+
+	ext.dst.SetDirected(src.IsDirected())
+
+	// Allocate label attributes. The graph is labeled with the label of the nest (if
+	// any). Otherwise, the label of the source graph is kept
+	if ext.dstSpec.Graph.LabelAttr, err = ext.dst.NewGraphStrAttr(); err != nil {
+		return err
+	}
+
+	if ext.dstSpec.Node.LabelAttr, err = ext.dst.NewNodeStrAttr(); err != nil {
+		return err
+	}
+
+	dst_nest_tree := ext.dst.GetNestTree()
+
+	if ext.dstSpec.Nest.LabelAttr, err = dst_nest_tree.NewNestStrAttr(); err != nil {
+		return err
+	}
+
+	label := nestLabel(src_nest, src_spec)
+
+	if label == "" && src_spec.Graph.LabelAttr != nil {
+		if is_set, _ := src.IsStrAttrSet(src_spec.Graph.LabelAttr); is_set {
+			if label, err = src.GetStrAttrVal(src_spec.Graph.LabelAttr); err != nil {
+				return err
+			}
+		}
+	}
+
+	if label != "" {
+		if err = ext.dst.SetStrAttrVal(ext.dstSpec.Graph.LabelAttr, label); err != nil {
+			return err
+		}
+	}
+
+	if err = ext.copyNest(src_nest, dst_nest_tree.GetRootNest()); err != nil {
+		return err
+	}
+
+	// Copy the edges connecting the extracted nodes
+	for edge := range src.Edges() {
+		dst_src_node, is_src_in := ext.dstNodes[edge.GetSrcNode()]
+		dst_dst_node, is_dst_in := ext.dstNodes[edge.GetDstNode()]
+
+		if !is_src_in || !is_dst_in {
+			continue
+		}
+
+		if _, err = ext.dst.NewEdge(dst_src_node, dst_dst_node); err != nil {
+			return err
+		}
+	}
+
+	return writeGraph(ext.dst, ext.dstSpec, flags.Arg(1), opts)
+}
+
+// Serve a graph as an interactive page
+func runServe(args []string) error {
+	flags := newFlagSet("serve")
+	from := flags.String("from", "", "input format: "+formatNames(readers))
+	addr := flags.String("addr", "localhost:8080", "address the viewer listens on")
+	opts := &writeOpts{}
+	addLayoutFlags(flags, opts)
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	g, graph_emit_spec, err := readGraph(flags.Arg(0), *from)
+
+	if err != nil {
+		return err
+	}
+
+	layout_spec, err := layoutGraph(g, opts)
+
+	if err != nil {
+		return err
+	}
+
+	viewer, err := graph.NewViewer(g, graph_emit_spec, layout_spec)
+
+	if err != nil {
+		return err
+	}
+
+	// The listener is created first to report the actual address (the port may be "0")
+	listener, err := net.Listen("tcp", *addr)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving \"%s\" at http://%s/\n", flags.Arg(0), listener.Addr())
+
+	return http.Serve(listener, viewer)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/AndreyNevolin/graph"
+)
+
+// Write a test input file and return its path
+func writeTestInput(t *testing.T, name string, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return path
+}
+
+// A nest is extracted from a graph whose label attributes leave no spare attributes
+func TestExtract(t *testing.T) {
+	in_path := writeTestInput(t, "in.dot", `digraph {
+	subgraph cluster_a {
+		label="a";
+		x; y;
+		subgraph cluster_b { label="b"; z; }
+	}
+	w;
+	x -> y; y -> z; z -> w;
+}
+`)
+	out_path := filepath.Join(filepath.Dir(in_path), "out.dot")
+
+	if err := runExtract([]string{"-nest", "1", in_path, out_path}); err != nil {
+		t.Fatalf("runExtract: %v", err)
+	}
+
+	g, spec, err := graph.ReadInGVFormat(out_path, graph.AttrSpec{})
+
+	if err != nil {
+		t.Fatalf("ReadInGVFormat: %v", err)
+	}
+
+	if label, _ := g.GetStrAttrVal(spec.Graph.LabelAttr); label != "a" {
+		t.Errorf("graph label = %q, want %q", label, "a")
+	}
+
+	var labels []string
+
+	for node := range g.Nodes() {
+		label, _ := node.GetStrAttrVal(spec.Node.LabelAttr)
+		labels = append(labels, label)
+	}
+
+	slices.Sort(labels)
+
+	if !slices.Equal(labels, []string{"x", "y", "z"}) {
+		t.Errorf("extracted nodes = %q, want x, y, z", labels)
+	}
+
+	// The edge leaving the nest is dropped
+	if g.EdgeCount() != 2 || g.GetNestTree().NestCount() != 2 {
+		t.Errorf("extracted graph has %d edges and %d nests, want 2 and 2",
+			g.EdgeCount(), g.GetNestTree().NestCount())
+	}
+}
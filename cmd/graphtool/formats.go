@@ -0,0 +1,202 @@
+/*
+  Input and output formats
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AndreyNevolin/graph"
+)
+
+// Number of node and nest attributes reserved for the layout (used by SVG drawings and
+// by the viewer)
+const LAYOUT_ATTR_NUM = 4
+
+// Function reading a graph (see "graph.ReadInGVFormat()")
+type readFunc func(in_path string, attr_spec graph.AttrSpec) (*graph.Graph,
+	*graph.GraphEmitSpec, error)
+
+// Function emitting a graph (see "graph.EmitInGVFormat()")
+type emitFunc func(g *graph.Graph, graph_emit_spec *graph.GraphEmitSpec,
+	out_path string) error
+
+// Readers by format names
+var readers = map[string]readFunc{
+	"dot":     graph.ReadInGVFormat,
+	"graphml": graph.ReadInGraphMLFormat,
+	"json":    graph.ReadInCytoscapeFormat,
+}
+
+// Emitters by format names. SVG drawings need a layout, so they are handled separately
+// (see "writeGraph()")
+var emitters = map[string]emitFunc{
+	"dot":      graph.EmitInGVFormat,
+	"yfiles":   graph.EmitInYFilesFormat,
+	"graphml":  graph.EmitInGraphMLFormat,
+	"mermaid":  graph.EmitInMermaidFormat,
+	"plantuml": graph.EmitInPlantUMLFormat,
+	"gexf":     graph.EmitInGEXFFormat,
+	"gml":      graph.EmitInGMLFormat,
+	"json":     graph.EmitInCytoscapeFormat,
+	"svg":      nil,
+}
+
+// Formats by file extensions
+var extension_formats = map[string]string{
+	".gv":       "dot",
+	".dot":      "dot",
+	".graphml":  "graphml",
+	".xml":      "graphml",
+	".json":     "json",
+	".mmd":      "mermaid",
+	".mermaid":  "mermaid",
+	".puml":     "plantuml",
+	".plantuml": "plantuml",
+	".gexf":     "gexf",
+	".gml":      "gml",
+	".svg":      "svg",
+}
+
+// Get sorted names of the formats supported by a table
+func formatNames[F any](table map[string]F) string {
+	names := make([]string, 0, len(table))
+
+	for name := range table {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// Determine the format of a file. "format" is the format given on the command line (if
+// any). Otherwise, the format is derived from the file extension
+func fileFormat[F any](path string, format string, table map[string]F) (string, error) {
+	if format == "" {
+		format = extension_formats[strings.ToLower(filepath.Ext(path))]
+
+		if format == "" {
+			return "", &usageError{fmt.Sprintf("cannot derive the format of \"%s\" from "+
+				"its extension (supported formats: %s)", path, formatNames(table))}
+		}
+	}
+
+	if _, ok := table[format]; !ok {
+		return "", &usageError{fmt.Sprintf("unsupported format \"%s\" of \"%s\" "+
+			"(supported formats: %s)", format, path, formatNames(table))}
+	}
+
+	return format, nil
+}
+
+// Read a graph. Attributes for the layout are reserved in the graph
+func readGraph(in_path string, format string) (*graph.Graph, *graph.GraphEmitSpec,
+	error) {
+
+	format, err := fileFormat(in_path, format, readers)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attr_spec := graph.AttrSpec{NodeStrAttrNum: LAYOUT_ATTR_NUM,
+		NestStrAttrNum: LAYOUT_ATTR_NUM}
+
+	return readers[format](in_path, attr_spec)
+}
+
+// Options of writing a graph
+type writeOpts struct {
+	// Output format (derived from the file extension if empty)
+	format string
+	// Whether parallel edges are collapsed
+	collapse bool
+	// Layout engine ("layered" or "force")
+	layout string
+	// Seed of the force-directed layout
+	seed int64
+}
+
+// Register options of laying out a graph
+func addLayoutFlags(flags *flag.FlagSet, opts *writeOpts) {
+	flags.StringVar(&opts.layout, "layout", "layered", "layout engine: layered, force")
+	flags.Int64Var(&opts.seed, "seed", 1, "seed of the force-directed layout")
+}
+
+// Register options of writing a graph
+func addWriteFlags(flags *flag.FlagSet, opts *writeOpts) {
+	flags.StringVar(&opts.format, "to", "", "output format: "+formatNames(emitters))
+	flags.BoolVar(&opts.collapse, "collapse", false, "collapse parallel edges")
+	addLayoutFlags(flags, opts)
+}
+
+// Lay out a graph. The graph must have attributes reserved for the layout
+func layoutGraph(g *graph.Graph, opts *writeOpts) (*graph.LayoutSpec, error) {
+	if opts.layout != "layered" && opts.layout != "force" {
+		return nil, &usageError{fmt.Sprintf("unknown layout engine \"%s\"", opts.layout)}
+	}
+
+	// Allocate the reserved attributes
+	layout_spec := &graph.LayoutSpec{}
+	node_attrs := []**graph.NodeStrAttr{&layout_spec.Node.XAttr, &layout_spec.Node.YAttr,
+		&layout_spec.Node.WidthAttr, &layout_spec.Node.HeightAttr}
+	nest_attrs := []**graph.NestStrAttr{&layout_spec.Nest.XAttr, &layout_spec.Nest.YAttr,
+		&layout_spec.Nest.WidthAttr, &layout_spec.Nest.HeightAttr}
+	var err error
+
+	for _, attr := range node_attrs {
+		if *attr, err = g.NewNodeStrAttr(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attr := range nest_attrs {
+		if *attr, err = g.GetNestTree().NewNestStrAttr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.layout == "force" {
+		err = graph.LayoutForceDirected(g, layout_spec, opts.seed)
+	} else {
+		err = graph.LayoutLayered(g, layout_spec)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return layout_spec, nil
+}
+
+// Write a graph. The graph must have attributes reserved for the layout
+func writeGraph(g *graph.Graph, graph_emit_spec *graph.GraphEmitSpec, out_path string,
+	opts *writeOpts) error {
+
+	format, err := fileFormat(out_path, opts.format, emitters)
+
+	if err != nil {
+		return err
+	}
+
+	graph_emit_spec.Edge.CollapseParallel = opts.collapse
+
+	if format != "svg" {
+		return emitters[format](g, graph_emit_spec, out_path)
+	}
+
+	layout_spec, err := layoutGraph(g, opts)
+
+	if err != nil {
+		return err
+	}
+
+	return graph.EmitInSVGFormat(g, graph_emit_spec, layout_spec, out_path)
+}
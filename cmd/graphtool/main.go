@@ -0,0 +1,175 @@
+/*
+  graphtool - convert and inspect graphs
+
+  Usage:
+    graphtool convert [-from FORMAT] [-to FORMAT] [-collapse] [-layout ENGINE] [-seed N]
+                      INPUT OUTPUT
+    graphtool stats [-from FORMAT] [-tree] INPUT
+    graphtool validate [-from FORMAT] INPUT
+    graphtool extract -nest ID [-from FORMAT] [-to FORMAT] [-collapse] [-layout ENGINE]
+                      [-seed N] INPUT OUTPUT
+    graphtool serve [-from FORMAT] [-addr ADDR] [-layout ENGINE] [-seed N] INPUT
+
+  Input formats: "dot" (Graphviz DOT), "graphml" (standard or yFiles GraphML), "json"
+  (Cytoscape.js JSON). Output formats: "dot", "yfiles", "graphml", "mermaid", "plantuml",
+  "gexf", "gml", "json", "svg". If a format is not specified, it's derived from the file
+  extension (see "formats.go")
+
+  SVG drawings and graphs shown by the viewer are laid out by the layered layout engine
+  ("layered", the default) or by the force-directed one ("force")
+
+  "serve" shows the graph as an interactive page on a local HTTP server (see "viewer.go"
+  of the graph package). The server runs until it's interrupted
+
+  Nest IDs used by "extract" are the IDs of the nests of the graph as read. They are
+  listed by "stats -tree"
+
+  Exit status: "0" on success, "1" if the command failed or the graph is invalid, "2" if
+  the command line is malformed
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Description of a subcommand
+type command struct {
+	// Synopsis of the command line
+	usage string
+	// Short description
+	descr string
+	// Function running the command. Gets the command line arguments following the
+	// command name
+	run func(args []string) error
+}
+
+// Commands by names. The table is filled by "init()": the commands refer to it (through
+// "newFlagSet()"), so it can't be initialized statically
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"convert": {
+			"convert [-from FORMAT] [-to FORMAT] [-collapse] [-layout ENGINE] [-seed N] " +
+				"INPUT OUTPUT",
+			"Convert a graph to a different format",
+			runConvert,
+		},
+		"stats": {
+			"stats [-from FORMAT] [-tree] INPUT",
+			"Print the numbers of nodes, edges and nests and the depth of the nest tree",
+			runStats,
+		},
+		"validate": {
+			"validate [-from FORMAT] INPUT",
+			"Read a graph and check its consistency",
+			runValidate,
+		},
+		"extract": {
+			"extract -nest ID [-from FORMAT] [-to FORMAT] [-collapse] [-layout ENGINE] " +
+				"[-seed N] INPUT OUTPUT",
+			"Write the contents of a nest as a separate graph",
+			runExtract,
+		},
+		"serve": {
+			"serve [-from FORMAT] [-addr ADDR] [-layout ENGINE] [-seed N] INPUT",
+			"Show a graph as an interactive page on a local HTTP server",
+			runServe,
+		},
+	}
+}
+
+// Order in which the commands are listed by the usage message
+var command_names = []string{"convert", "stats", "validate", "extract", "serve"}
+
+// Error caused by a malformed command line
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// Print the usage message
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: graphtool COMMAND [OPTIONS] ARGS\n\nCommands:\n")
+
+	for _, name := range command_names {
+		fmt.Fprintf(os.Stderr, "  %s\n        %s\n", commands[name].usage,
+			commands[name].descr)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRun \"graphtool COMMAND -h\" for the command options\n")
+}
+
+// Create a flag set of a command. Parse errors are reported as usage errors
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: graphtool %s\n", commands[name].usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// Parse options of a command and check the number of the remaining arguments
+func parseFlags(flags *flag.FlagSet, args []string, arg_num int) error {
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return err
+	} else if err != nil {
+		return &usageError{err.Error()}
+	}
+
+	if flags.NArg() != arg_num {
+		flags.Usage()
+
+		return &usageError{fmt.Sprintf("expected %d arguments, got %d", arg_num,
+			flags.NArg())}
+	}
+
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+
+	if !ok {
+		if os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "help" {
+			usage()
+			os.Exit(0)
+		}
+
+		fmt.Fprintf(os.Stderr, "graphtool: unknown command \"%s\"\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[2:])
+
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "graphtool %s: %v\n", os.Args[1], err)
+
+		if _, is_usage := err.(*usageError); is_usage {
+			os.Exit(2)
+		}
+
+		os.Exit(1)
+	}
+}
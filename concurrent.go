@@ -0,0 +1,345 @@
+/*
+  Thread-safe wrapper around Graph
+
+  The Graph package itself is NOT thread-safe. "ConcurrentGraph" makes it possible to use
+  a graph from multiple goroutines: many goroutines may query the graph simultaneously
+  while a single goroutine at a time updates it
+
+  Consistency model:
+    1) every method of "ConcurrentGraph" is atomic with respect to all other methods. A
+       mutation is either completely visible to a reader or not visible at all
+    2) each call observes the state produced by all the mutations that completed before
+       the call started. So, the calls are linearizable
+    3) a sequence of calls is NOT atomic. A reader that needs a consistent view across
+       several operations (for example, iterating over all graph nodes) must perform all
+       of them inside a single "Read()" callback. Similarly, a compound update must be
+       performed inside a single "Write()" callback
+    4) pointers to nodes, edges and nests may be kept outside the callbacks (they stay
+       valid as long as the graph exists). But their methods MUST NOT be called outside
+       the callbacks, because the methods access the shared graph structure
+    5) the graph passed to "NewConcurrentGraph()" must not be accessed directly anymore.
+       All accesses must go through the wrapper
+
+  NOTE: the callbacks must not call methods of the same "ConcurrentGraph". The locks are
+        not reentrant, so such calls would deadlock
+*/
+
+package graph
+
+import (
+	"sync"
+)
+
+// Thread-safe wrapper around a Graph
+type ConcurrentGraph struct {
+	// Reader/writer lock protecting the graph
+	lock sync.RWMutex
+	// The wrapped graph
+	graph *Graph
+}
+
+// Wrap a graph for concurrent use
+func NewConcurrentGraph(graph *Graph) *ConcurrentGraph {
+	return &ConcurrentGraph{graph: graph}
+}
+
+// Call a function that only reads the graph. The function is called under the shared
+// lock, so several readers can run simultaneously. The function MUST NOT modify the
+// graph. Panics raised inside the function are converted into "*InternalError"
+func (cg *ConcurrentGraph) Read(f func(graph *Graph) error) (err error) {
+	cg.lock.RLock()
+	defer cg.lock.RUnlock()
+	defer recoverInternalError("ConcurrentGraph.Read", &err)
+
+	return f(cg.graph)
+}
+
+// Call a function that modifies the graph. The function is called under the exclusive
+// lock. Panics raised inside the function are converted into "*InternalError"
+func (cg *ConcurrentGraph) Write(f func(graph *Graph) error) (err error) {
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+	defer recoverInternalError("ConcurrentGraph.Write", &err)
+
+	return f(cg.graph)
+}
+
+// Create new graph node (see "Graph.NewNode()")
+func (cg *ConcurrentGraph) NewNode() (*Node, error) {
+	var node *Node
+
+	err := cg.Write(func(graph *Graph) (err error) {
+		node, err = graph.NewNodeChecked()
+
+		return err
+	})
+
+	return node, err
+}
+
+// Create new edge between pre-existing nodes (see "Graph.NewEdge()")
+func (cg *ConcurrentGraph) NewEdge(src_node *Node, dst_node *Node) (*Edge, error) {
+	var edge *Edge
+
+	err := cg.Write(func(graph *Graph) (err error) {
+		edge, err = graph.NewEdge(src_node, dst_node)
+
+		return err
+	})
+
+	return edge, err
+}
+
+// Create a new nest (see "NestTree.NewNest()")
+func (cg *ConcurrentGraph) NewNest() (*Nest, error) {
+	var nest *Nest
+
+	err := cg.Write(func(graph *Graph) (err error) {
+		nest, err = graph.GetNestTree().NewNestChecked()
+
+		return err
+	})
+
+	return nest, err
+}
+
+// Create a new nest as a child of a given nest (see "Nest.NewChildNest()")
+func (cg *ConcurrentGraph) NewChildNest(parent *Nest) (*Nest, error) {
+	var nest *Nest
+
+	err := cg.Write(func(graph *Graph) (err error) {
+		if parent != nil && (parent.nestTree == nil || parent.nestTree.baseGraph != graph) {
+			return &ElemError{"ConcurrentGraph.NewChildNest", -1, parent.id, "The " +
+				"parent nest belongs to a different graph", ErrForeignNest}
+		}
+
+		nest, err = parent.NewChildNest()
+
+		return err
+	})
+
+	return nest, err
+}
+
+// Move graph node to a specific nest (see "Node.MoveToNest()")
+func (cg *ConcurrentGraph) MoveToNest(node *Node, nest *Nest) error {
+	return cg.Write(func(graph *Graph) error {
+		if node == nil {
+			return &ElemError{"ConcurrentGraph.MoveToNest", -1, -1, "Zero reference " +
+				"to the node", ErrNilNode}
+		}
+
+		return node.MoveToNest(nest)
+	})
+}
+
+// Set value of a node string attribute (see "Node.SetStrAttrVal()")
+func (cg *ConcurrentGraph) SetNodeStrAttrVal(node *Node, attr *NodeStrAttr,
+	val string) error {
+
+	return cg.Write(func(graph *Graph) error {
+		if node == nil {
+			return &ElemError{"ConcurrentGraph.SetNodeStrAttrVal", -1, -1, "Zero " +
+				"reference to the node", ErrNilNode}
+		}
+
+		return node.SetStrAttrVal(attr, val)
+	})
+}
+
+// Get value of a node string attribute (see "Node.GetStrAttrVal()")
+func (cg *ConcurrentGraph) GetNodeStrAttrVal(node *Node, attr *NodeStrAttr) (string,
+	error) {
+
+	var val string
+
+	err := cg.Read(func(graph *Graph) (err error) {
+		if node == nil {
+			return &ElemError{"ConcurrentGraph.GetNodeStrAttrVal", -1, -1, "Zero " +
+				"reference to the node", ErrNilNode}
+		}
+
+		val, err = node.GetStrAttrVal(attr)
+
+		return err
+	})
+
+	return val, err
+}
+
+// Remove string attribute from a node (see "Node.RemoveStrAttr()")
+func (cg *ConcurrentGraph) RemoveNodeStrAttr(node *Node, attr *NodeStrAttr) error {
+	return cg.Write(func(graph *Graph) error {
+		if node == nil {
+			return &ElemError{"ConcurrentGraph.RemoveNodeStrAttr", -1, -1, "Zero " +
+				"reference to the node", ErrNilNode}
+		}
+
+		return node.RemoveStrAttr(attr)
+	})
+}
+
+// Set value of a nest string attribute (see "Nest.SetStrAttrVal()")
+func (cg *ConcurrentGraph) SetNestStrAttrVal(nest *Nest, attr *NestStrAttr,
+	val string) error {
+
+	return cg.Write(func(graph *Graph) error {
+		if nest == nil {
+			return &ElemError{"ConcurrentGraph.SetNestStrAttrVal", -1, -1, "Zero " +
+				"reference to the nest", ErrNilNest}
+		}
+
+		return nest.SetStrAttrVal(attr, val)
+	})
+}
+
+// Get value of a nest string attribute (see "Nest.GetStrAttrVal()")
+func (cg *ConcurrentGraph) GetNestStrAttrVal(nest *Nest, attr *NestStrAttr) (string,
+	error) {
+
+	var val string
+
+	err := cg.Read(func(graph *Graph) (err error) {
+		if nest == nil {
+			return &ElemError{"ConcurrentGraph.GetNestStrAttrVal", -1, -1, "Zero " +
+				"reference to the nest", ErrNilNest}
+		}
+
+		val, err = nest.GetStrAttrVal(attr)
+
+		return err
+	})
+
+	return val, err
+}
+
+// Set value of a graph string attribute (see "Graph.SetStrAttrVal()")
+func (cg *ConcurrentGraph) SetGraphStrAttrVal(attr *GraphStrAttr, val string) error {
+	return cg.Write(func(graph *Graph) error {
+		return graph.SetStrAttrVal(attr, val)
+	})
+}
+
+// Get value of a graph string attribute (see "Graph.GetStrAttrVal()")
+func (cg *ConcurrentGraph) GetGraphStrAttrVal(attr *GraphStrAttr) (string, error) {
+	var val string
+
+	err := cg.Read(func(graph *Graph) (err error) {
+		val, err = graph.GetStrAttrVal(attr)
+
+		return err
+	})
+
+	return val, err
+}
+
+// Find graph nodes having a specific attribute value (see "Graph.FindNodesByAttr()")
+func (cg *ConcurrentGraph) FindNodesByAttr(attr *NodeStrAttr, val string) ([]*Node,
+	error) {
+
+	var nodes []*Node
+
+	err := cg.Read(func(graph *Graph) (err error) {
+		nodes, err = graph.FindNodesByAttr(attr, val)
+
+		return err
+	})
+
+	return nodes, err
+}
+
+// Find graph nodes whose attribute values start with a specific prefix (see
+// "Graph.FindNodesByAttrPrefix()")
+func (cg *ConcurrentGraph) FindNodesByAttrPrefix(attr *NodeStrAttr,
+	prefix string) ([]*Node, error) {
+
+	var nodes []*Node
+
+	err := cg.Read(func(graph *Graph) (err error) {
+		nodes, err = graph.FindNodesByAttrPrefix(attr, prefix)
+
+		return err
+	})
+
+	return nodes, err
+}
+
+// Subscribe an observer to the graph mutations (see "Graph.Subscribe()"). The observer is
+// called with the exclusive lock held, so it MUST NOT call methods of the wrapper
+func (cg *ConcurrentGraph) Subscribe(observer Observer) (*Subscription, error) {
+	var sub *Subscription
+
+	err := cg.Write(func(graph *Graph) (err error) {
+		sub, err = graph.Subscribe(observer)
+
+		return err
+	})
+
+	return sub, err
+}
+
+// Unsubscribe an observer (see "Subscription.Cancel()")
+func (cg *ConcurrentGraph) Unsubscribe(sub *Subscription) {
+	cg.Write(func(graph *Graph) error {
+		sub.Cancel()
+
+		return nil
+	})
+}
+
+// Check the graph against the package invariants (see "Graph.Validate()")
+func (cg *ConcurrentGraph) Validate() []Violation {
+	var violations []Violation
+
+	cg.Read(func(graph *Graph) error {
+		violations = graph.Validate()
+
+		return nil
+	})
+
+	return violations
+}
+
+// Emit the graph in Graphviz format (see "EmitInGVFormat()"). Emission holds the shared
+// lock, so it can run simultaneously with other readers
+func (cg *ConcurrentGraph) EmitInGVFormat(graph_emit_spec *GraphEmitSpec,
+	out_path string) error {
+
+	return cg.Read(func(graph *Graph) error {
+		return EmitInGVFormat(graph, graph_emit_spec, out_path)
+	})
+}
+
+// Emit the graph in yFiles GraphML format (see "EmitInYFilesFormat()"). Emission holds
+// the shared lock, so it can run simultaneously with other readers
+func (cg *ConcurrentGraph) EmitInYFilesFormat(graph_emit_spec *GraphEmitSpec,
+	out_path string) error {
+
+	return cg.Read(func(graph *Graph) error {
+		return EmitInYFilesFormat(graph, graph_emit_spec, out_path)
+	})
+}
+
+// Create an interactive viewer of the graph (see "NewViewer()"). The viewer reads the
+// graph under the shared lock, so the graph may be modified while it's viewed
+func (cg *ConcurrentGraph) NewViewer(graph_emit_spec *GraphEmitSpec,
+	layout_spec *LayoutSpec) (*Viewer, error) {
+
+	var viewer *Viewer
+
+	err := cg.Read(func(graph *Graph) error {
+		var err error
+
+		viewer, err = NewViewer(graph, graph_emit_spec, layout_spec)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	viewer.read = cg.Read
+
+	return viewer, nil
+}
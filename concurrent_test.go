@@ -0,0 +1,173 @@
+package graph
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Parallel readers and writers. The test is meant to be run with the race detector
+// ("go test -race")
+func TestConcurrentReadersAndWriters(t *testing.T) {
+	const (
+		WRITERS          = 4
+		READERS          = 4
+		NESTS_PER_WRITER = 10
+		NODES_PER_WRITER = 200
+		EDGES_PER_WRITER = 100
+		READS_PER_READER = 200
+		LABEL_PREFIX     = "node-"
+	)
+
+	g := NewGraph(AttrSpec{NodeStrAttrNum: 1})
+	attr, err := g.NewNodeStrAttr()
+
+	if err != nil {
+		t.Fatalf("NewNodeStrAttr: %v", err)
+	}
+
+	if err = g.NewNodeAttrIndex(attr); err != nil {
+		t.Fatalf("NewNodeAttrIndex: %v", err)
+	}
+
+	cg := NewConcurrentGraph(g)
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, WRITERS+READERS)
+
+	for w := 0; w < WRITERS; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			var nodes []*Node
+			var nests []*Nest
+
+			for i := 0; i < NESTS_PER_WRITER; i++ {
+				var nest *Nest
+				var err error
+
+				if i == 0 {
+					nest, err = cg.NewNest()
+				} else {
+					nest, err = cg.NewChildNest(nests[i-1])
+				}
+
+				if err != nil {
+					errs <- err
+
+					return
+				}
+
+				nests = append(nests, nest)
+			}
+
+			for i := 0; i < NODES_PER_WRITER; i++ {
+				node, err := cg.NewNode()
+
+				if err != nil {
+					errs <- err
+
+					return
+				}
+
+				label := fmt.Sprintf("%s%d-%d", LABEL_PREFIX, w, i)
+
+				if err = cg.SetNodeStrAttrVal(node, attr, label); err != nil {
+					errs <- err
+
+					return
+				}
+
+				if err = cg.MoveToNest(node, nests[i%len(nests)]); err != nil {
+					errs <- err
+
+					return
+				}
+
+				nodes = append(nodes, node)
+			}
+
+			for i := 0; i < EDGES_PER_WRITER; i++ {
+				src := nodes[i]
+				dst := nodes[len(nodes)-1-i]
+
+				if _, err := cg.NewEdge(src, dst); err != nil {
+					errs <- err
+
+					return
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < READERS; r++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < READS_PER_READER; i++ {
+				if _, err := cg.FindNodesByAttrPrefix(attr, LABEL_PREFIX); err != nil {
+					errs <- err
+
+					return
+				}
+
+				if violations := cg.Validate(); len(violations) != 0 {
+					errs <- fmt.Errorf("inconsistent graph: %v", violations)
+
+					return
+				}
+
+				err := cg.Read(func(graph *Graph) error {
+					_, err := graph.Freeze()
+
+					return err
+				})
+
+				if err != nil {
+					errs <- err
+
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	nodes, err := cg.FindNodesByAttrPrefix(attr, LABEL_PREFIX)
+
+	if err != nil {
+		t.Fatalf("FindNodesByAttrPrefix: %v", err)
+	}
+
+	if len(nodes) != WRITERS*NODES_PER_WRITER {
+		t.Errorf("got %d nodes, want %d", len(nodes), WRITERS*NODES_PER_WRITER)
+	}
+
+	err = cg.Read(func(graph *Graph) error {
+		if graph.EdgeCount() != WRITERS*EDGES_PER_WRITER {
+			return fmt.Errorf("got %d edges, want %d", graph.EdgeCount(),
+				WRITERS*EDGES_PER_WRITER)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if violations := cg.Validate(); len(violations) != 0 {
+		t.Errorf("inconsistent graph: %v", violations)
+	}
+}
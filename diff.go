@@ -0,0 +1,394 @@
+/*
+  Comparison of two versions of a graph
+
+  "Diff()" matches nodes of the old and of the new graph by keys computed by a key
+  function (for example, by values of a "name" attribute, see "NodeKeyByAttr()"). Nodes
+  and edges are compared by the keys only: node and edge IDs of the graphs are unrelated
+
+  Edges are matched by the keys of their ends. Parallel edges are matched one-to-one: if
+  the old graph has two edges from "a" to "b" and the new graph has three, one edge is
+  reported as added. Edge directions are ignored if the new graph is undirected
+
+  Nests are matched by their label paths: the labels of the nest and of all its ancestors
+  (except the root nest). A node whose nest label path changed is reported as moved.
+  Attribute values are compared for pairs of node attributes listed by the comparison
+  specification
+
+  The differences can be drawn as a colored Graphviz diagram (see "emit_diff.go")
+*/
+
+package graph
+
+import (
+	"slices"
+)
+
+// Function computing a key of a graph node. Nodes of different graphs having the same
+// key are considered to be the same node. Keys must be unique within a graph
+type NodeKeyFunc func(node *Node) (string, error)
+
+// Get a key function that reads keys from node attributes. Each graph has its own
+// attributes, so the attributes of all the involved graphs are passed. The attribute
+// belonging to the graph of a node is used to get the key of the node
+func NodeKeyByAttr(attrs ...*NodeStrAttr) NodeKeyFunc {
+	return func(node *Node) (string, error) {
+		for _, attr := range attrs {
+			if attr != nil && attr.graph == node.graph {
+				return node.GetStrAttrVal(attr)
+			}
+		}
+
+		return "", newAttrError("NodeKeyByAttr", ATTR_ELEM_NODE, node.id, -1,
+			ErrAttrForeign)
+	}
+}
+
+// Pair of node attributes holding the same property in the old and in the new graph
+type DiffNodeAttr struct {
+	// Name of the property (used to report changes)
+	Name string
+	Old  *NodeStrAttr
+	New  *NodeStrAttr
+}
+
+// Specification of a graph comparison
+type DiffSpec struct {
+	// Node attributes whose values are compared
+	NodeAttrs []DiffNodeAttr
+	// Attributes holding nest labels in the old and in the new graph. Nest membership is
+	// compared only if both attributes are defined
+	OldNestLabelAttr *NestStrAttr
+	NewNestLabelAttr *NestStrAttr
+}
+
+// Node that belongs to different nests in the old and in the new graph
+type DiffNodeMove struct {
+	Key     string
+	OldNode *Node
+	NewNode *Node
+	// Label paths of the nests (empty for the root nest)
+	OldNestPath []string
+	NewNestPath []string
+}
+
+// Change of a node attribute value
+type DiffAttrChange struct {
+	Key     string
+	OldNode *Node
+	NewNode *Node
+	// Name of the attribute (see "DiffNodeAttr")
+	Attr string
+	// Values of the attribute. "*IsSet" fields are "false" if the values are not set
+	OldVal   string
+	OldIsSet bool
+	NewVal   string
+	NewIsSet bool
+}
+
+// Status of an element of the diagram of differences
+const (
+	DIFF_STATUS_SAME = iota
+	DIFF_STATUS_ADDED
+	DIFF_STATUS_REMOVED
+)
+
+// Node of the diagram of differences
+type diffNode struct {
+	key    string
+	status int
+	// Label path of the nest the node is drawn in. The new nest is used for the nodes
+	// present in the new graph
+	nestPath []string
+	// Label path of the old nest of a moved node ("nil" if the node is not moved)
+	oldNestPath []string
+	// Changes of the node attributes
+	changes []DiffAttrChange
+}
+
+// Edge of the diagram of differences
+type diffEdge struct {
+	srcKey string
+	dstKey string
+	status int
+}
+
+// Differences between two graphs. Nodes and edges are listed in the order they are
+// visited by "Graph.Nodes()" and "Graph.Edges()"
+type GraphDiff struct {
+	// Nodes of the new graph having no match in the old graph
+	AddedNodes []*Node
+	// Nodes of the old graph having no match in the new graph
+	RemovedNodes []*Node
+	// Edges of the new graph having no match in the old graph
+	AddedEdges []*Edge
+	// Edges of the old graph having no match in the new graph
+	RemovedEdges []*Edge
+	// Matched nodes whose nests differ
+	MovedNodes []DiffNodeMove
+	// Changes of attribute values of matched nodes
+	AttrChanges []DiffAttrChange
+	// Whether nest membership was compared
+	nestsCompared bool
+	// Whether the new graph is directed
+	directed bool
+	// Contents of the diagram of differences. Nodes and edges of the new graph are
+	// followed by the removed ones
+	nodes []diffNode
+	edges []diffEdge
+}
+
+// Check whether the graphs are the same (up to node and edge IDs)
+func (diff *GraphDiff) IsEmpty() bool {
+	return len(diff.AddedNodes) == 0 && len(diff.RemovedNodes) == 0 &&
+		len(diff.AddedEdges) == 0 && len(diff.RemovedEdges) == 0 &&
+		len(diff.MovedNodes) == 0 && len(diff.AttrChanges) == 0
+}
+
+// Compute keys of all nodes of a graph. Returns the nodes by keys and the keys by nodes
+func diffNodeKeys(graph *Graph, key_fn NodeKeyFunc) (map[string]*Node, map[*Node]string,
+	error) {
+
+	nodes := make(map[string]*Node, graph.NodeCount())
+	keys := make(map[*Node]string, graph.NodeCount())
+
+	for node := range graph.Nodes() {
+		key, err := key_fn(node)
+
+		if err != nil {
+			return nil, nil, &ElemError{"Diff", node.id, -1, "Cannot compute the node " +
+				"key", err}
+		}
+
+		if _, is_dup := nodes[key]; is_dup {
+			return nil, nil, &ElemError{"Diff", node.id, -1, "Another node has the same " +
+				"key \"" + key + "\"", ErrDuplicateKey}
+		}
+
+		nodes[key] = node
+		keys[node] = key
+	}
+
+	return nodes, keys, nil
+}
+
+// Get label path of a nest: the labels of the nest and of all its ancestors except the
+// root nest. Unset labels are represented by empty strings
+func diffNestPath(nest *Nest, label_attr *NestStrAttr) ([]string, error) {
+	path := []string{}
+
+	for _, ancestor := range nest.Path()[1:] {
+		label := ""
+
+		if is_set, err := ancestor.IsStrAttrSet(label_attr); err != nil {
+			return nil, err
+		} else if is_set {
+			if label, err = ancestor.GetStrAttrVal(label_attr); err != nil {
+				return nil, err
+			}
+		}
+
+		path = append(path, label)
+	}
+
+	return path, nil
+}
+
+// Get value of a node attribute. "is_set" is "false" if the value is not set
+func diffAttrVal(node *Node, attr *NodeStrAttr) (val string, is_set bool, err error) {
+	if is_set, err = node.IsStrAttrSet(attr); err != nil || !is_set {
+		return "", false, err
+	}
+
+	val, err = node.GetStrAttrVal(attr)
+
+	return val, err == nil, err
+}
+
+// Compare two versions of a graph (see the description of the file). "diff_spec" can be
+// "nil": then only nodes and edges are compared
+//
+// The graphs must not be modified while they are compared
+func Diff(old_graph *Graph,
+	new_graph *Graph,
+	key_fn NodeKeyFunc,
+	diff_spec *DiffSpec) (diff *GraphDiff, err error) {
+
+	defer recoverInternalError("Diff", &err)
+
+	if old_graph == nil || new_graph == nil {
+		return nil, &ElemError{"Diff", -1, -1, "Zero reference to a compared graph",
+			ErrNilGraph}
+	}
+
+	if key_fn == nil {
+		return nil, &ElemError{"Diff", -1, -1, "Zero reference to the key function",
+			ErrNilKeyFunc}
+	}
+
+	// NOTE: here the function parameter "diff_spec" is intentionally re-assigned
+	if diff_spec == nil {
+		diff_spec = &DiffSpec{}
+	}
+
+	for _, attr := range diff_spec.NodeAttrs {
+		if attr.Old == nil || attr.New == nil {
+			return nil, &ElemError{"Diff", -1, -1, "An attribute of pair \"" +
+				attr.Name + "\" is not defined", ErrAttrInvalid}
+		}
+	}
+
+	old_nodes, old_keys, err := diffNodeKeys(old_graph, key_fn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	new_nodes, new_keys, err := diffNodeKeys(new_graph, key_fn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	diff = &GraphDiff{
+		nestsCompared: diff_spec.OldNestLabelAttr != nil &&
+			diff_spec.NewNestLabelAttr != nil,
+		directed: new_graph.IsDirected(),
+	}
+
+	// Nest paths are computed only if nests are compared
+	nest_path := func(node *Node, label_attr *NestStrAttr) ([]string, error) {
+		if !diff.nestsCompared {
+			return nil, nil
+		}
+
+		return diffNestPath(node.GetNest(), label_attr)
+	}
+
+	// Compare nodes
+	var removed_nodes []diffNode
+
+	for old_node := range old_graph.Nodes() {
+		if _, is_matched := new_nodes[old_keys[old_node]]; is_matched {
+			continue
+		}
+
+		path, err := nest_path(old_node, diff_spec.OldNestLabelAttr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		diff.RemovedNodes = append(diff.RemovedNodes, old_node)
+		removed_nodes = append(removed_nodes, diffNode{key: old_keys[old_node],
+			status: DIFF_STATUS_REMOVED, nestPath: path})
+	}
+
+	for new_node := range new_graph.Nodes() {
+		key := new_keys[new_node]
+		path, err := nest_path(new_node, diff_spec.NewNestLabelAttr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry := diffNode{key: key, status: DIFF_STATUS_SAME, nestPath: path}
+		old_node, is_matched := old_nodes[key]
+
+		if !is_matched {
+			diff.AddedNodes = append(diff.AddedNodes, new_node)
+			entry.status = DIFF_STATUS_ADDED
+			diff.nodes = append(diff.nodes, entry)
+
+			continue
+		}
+
+		old_path, err := nest_path(old_node, diff_spec.OldNestLabelAttr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Equal(old_path, path) {
+			diff.MovedNodes = append(diff.MovedNodes, DiffNodeMove{key, old_node,
+				new_node, old_path, path})
+			entry.oldNestPath = old_path
+		}
+
+		for _, attr := range diff_spec.NodeAttrs {
+			change := DiffAttrChange{Key: key, OldNode: old_node, NewNode: new_node,
+				Attr: attr.Name}
+
+			change.OldVal, change.OldIsSet, err = diffAttrVal(old_node, attr.Old)
+
+			if err != nil {
+				return nil, err
+			}
+
+			change.NewVal, change.NewIsSet, err = diffAttrVal(new_node, attr.New)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if change.OldIsSet != change.NewIsSet || change.OldVal != change.NewVal {
+				diff.AttrChanges = append(diff.AttrChanges, change)
+				entry.changes = append(entry.changes, change)
+			}
+		}
+
+		diff.nodes = append(diff.nodes, entry)
+	}
+
+	diff.nodes = append(diff.nodes, removed_nodes...)
+
+	// Compare edges. Edges of the old graph are grouped by the keys of their ends. Edges
+	// of the new graph consume matching edges of the groups. The edges left in the
+	// groups are the removed ones
+	edge_key := func(src_key string, dst_key string) [2]string {
+		if !diff.directed && dst_key < src_key {
+			return [2]string{dst_key, src_key}
+		}
+
+		return [2]string{src_key, dst_key}
+	}
+
+	old_edges := make(map[[2]string][]*Edge)
+
+	for edge := range old_graph.Edges() {
+		key := edge_key(old_keys[edge.srcNode], old_keys[edge.dstNode])
+		old_edges[key] = append(old_edges[key], edge)
+	}
+
+	for edge := range new_graph.Edges() {
+		src_key, dst_key := new_keys[edge.srcNode], new_keys[edge.dstNode]
+		key := edge_key(src_key, dst_key)
+		status := DIFF_STATUS_SAME
+
+		if matching := old_edges[key]; len(matching) > 0 {
+			old_edges[key] = matching[1:]
+		} else {
+			diff.AddedEdges = append(diff.AddedEdges, edge)
+			status = DIFF_STATUS_ADDED
+		}
+
+		diff.edges = append(diff.edges, diffEdge{src_key, dst_key, status})
+	}
+
+	removed_edges := make(map[*Edge]bool)
+
+	for _, edges := range old_edges {
+		for _, edge := range edges {
+			removed_edges[edge] = true
+		}
+	}
+
+	for edge := range old_graph.Edges() {
+		if removed_edges[edge] {
+			diff.RemovedEdges = append(diff.RemovedEdges, edge)
+			diff.edges = append(diff.edges, diffEdge{old_keys[edge.srcNode],
+				old_keys[edge.dstNode], DIFF_STATUS_REMOVED})
+		}
+	}
+
+	return diff, nil
+}
@@ -0,0 +1,206 @@
+package graph
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Node of a graph built by "newDiffTestGraph()"
+type diffTestNode struct {
+	name string
+	// Label of the nest of the node. Empty for the root nest
+	nest string
+	// Value of the "color" attribute. Not set if empty
+	color string
+}
+
+// Build a graph for comparison. Nodes are named by the "name" attribute, nests are
+// labeled by the nest label attribute. Edges are given by the indexes of their ends
+func newDiffTestGraph(t *testing.T, nodes []diffTestNode,
+	edges [][2]int) (*Graph, *NodeStrAttr, *NodeStrAttr, *NestStrAttr) {
+
+	t.Helper()
+
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 2, NestStrAttrNum: 1})
+	nest_tree := graph.GetNestTree()
+	name_attr, _ := graph.NewNodeStrAttr()
+	color_attr, _ := graph.NewNodeStrAttr()
+	label_attr, _ := nest_tree.NewNestStrAttr()
+	nests := make(map[string]*Nest)
+	created := make([]*Node, len(nodes))
+
+	for i, desc := range nodes {
+		node := graph.NewNode()
+		node.SetStrAttrVal(name_attr, desc.name)
+
+		if desc.color != "" {
+			node.SetStrAttrVal(color_attr, desc.color)
+		}
+
+		if desc.nest != "" {
+			if nests[desc.nest] == nil {
+				nests[desc.nest] = nest_tree.NewNest()
+				nests[desc.nest].SetStrAttrVal(label_attr, desc.nest)
+			}
+
+			node.MoveToNest(nests[desc.nest])
+		}
+
+		created[i] = node
+	}
+
+	for _, ends := range edges {
+		if _, err := graph.NewEdge(created[ends[0]], created[ends[1]]); err != nil {
+			t.Fatalf("NewEdge: %v", err)
+		}
+	}
+
+	return graph, name_attr, color_attr, label_attr
+}
+
+// Get the names of nodes
+func diffTestNames(nodes []*Node, name_attr *NodeStrAttr) []string {
+	var names []string
+
+	for _, node := range nodes {
+		name, _ := node.GetStrAttrVal(name_attr)
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Get the names of edge ends ("src->dst")
+func diffTestEdgeNames(edges []*Edge, name_attr *NodeStrAttr) []string {
+	var names []string
+
+	for _, edge := range edges {
+		src, _ := edge.GetSrcNode().GetStrAttrVal(name_attr)
+		dst, _ := edge.GetDstNode().GetStrAttrVal(name_attr)
+		names = append(names, src+"->"+dst)
+	}
+
+	return names
+}
+
+// Compare a graph with its new version: a node is added, a node is removed, a node is
+// moved, attribute values are changed and parallel edges are matched one-to-one
+func TestDiff(t *testing.T) {
+	old_graph, old_name, old_color, old_label := newDiffTestGraph(t, []diffTestNode{
+		{"a", "A", "red"}, {"b", "A", "blue"}, {`c "\`, "", ""}, {"d", "", ""},
+	}, [][2]int{{0, 1}, {1, 2}, {2, 3}, {2, 3}, {0, 2}})
+	new_graph, new_name, new_color, new_label := newDiffTestGraph(t, []diffTestNode{
+		{"a", "A", "green"}, {"b", "B", ""}, {`c "\`, "", ""}, {"e", "", "red"},
+	}, [][2]int{{0, 1}, {1, 2}, {2, 3}, {0, 2}, {0, 2}})
+
+	diff, err := Diff(old_graph, new_graph, NodeKeyByAttr(old_name, new_name),
+		&DiffSpec{
+			NodeAttrs:        []DiffNodeAttr{{"color", old_color, new_color}},
+			OldNestLabelAttr: old_label,
+			NewNestLabelAttr: new_label,
+		})
+
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+
+	checks := []struct {
+		what string
+		got  []string
+		want []string
+	}{
+		{"added nodes", diffTestNames(diff.AddedNodes, new_name), []string{"e"}},
+		{"removed nodes", diffTestNames(diff.RemovedNodes, old_name), []string{"d"}},
+		{"added edges", diffTestEdgeNames(diff.AddedEdges, new_name),
+			[]string{`c "\->e`, `a->c "\`}},
+		{"removed edges", diffTestEdgeNames(diff.RemovedEdges, old_name),
+			[]string{`c "\->d`, `c "\->d`}},
+	}
+
+	for _, check := range checks {
+		if !slices.Equal(check.got, check.want) {
+			t.Errorf("%s: got %q, want %q", check.what, check.got, check.want)
+		}
+	}
+
+	if len(diff.MovedNodes) != 1 || diff.MovedNodes[0].Key != "b" ||
+		!slices.Equal(diff.MovedNodes[0].OldNestPath, []string{"A"}) ||
+		!slices.Equal(diff.MovedNodes[0].NewNestPath, []string{"B"}) {
+
+		t.Errorf("moved nodes: got %+v, want b from A to B", diff.MovedNodes)
+	}
+
+	// Changes are listed in the order of "Graph.Nodes()" (the latest node goes first)
+	want_changes := []DiffAttrChange{
+		{Key: "b", Attr: "color", OldVal: "blue", OldIsSet: true},
+		{Key: "a", Attr: "color", OldVal: "red", OldIsSet: true, NewVal: "green",
+			NewIsSet: true},
+	}
+
+	if len(diff.AttrChanges) != len(want_changes) {
+		t.Fatalf("attribute changes: got %+v", diff.AttrChanges)
+	}
+
+	for i, change := range diff.AttrChanges {
+		change.OldNode, change.NewNode = nil, nil
+
+		if change != want_changes[i] {
+			t.Errorf("attribute change %d: got %+v, want %+v", i, change, want_changes[i])
+		}
+	}
+
+	// The diagram marks every difference. Labels are escaped as Graphviz strings
+	out_path := filepath.Join(t.TempDir(), "diff.gv")
+
+	if err := diff.EmitInGVFormat(out_path); err != nil {
+		t.Fatalf("EmitInGVFormat: %v", err)
+	}
+
+	expectGoldenFile(t, out_path, "diff.gv")
+}
+
+// Identical graphs have no differences. Edge directions are ignored for undirected graphs
+func TestDiffSame(t *testing.T) {
+	nodes := []diffTestNode{{"a", "A", "red"}, {"b", "", ""}}
+	old_graph, old_name, _, _ := newDiffTestGraph(t, nodes, [][2]int{{0, 1}})
+	new_graph, new_name, _, _ := newDiffTestGraph(t, nodes, [][2]int{{1, 0}})
+	key_fn := NodeKeyByAttr(old_name, new_name)
+
+	diff, err := Diff(old_graph, new_graph, key_fn, nil)
+
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+
+	if diff.IsEmpty() {
+		t.Fatalf("a reversed directed edge is not reported")
+	}
+
+	new_graph.SetDirected(false)
+
+	if diff, err = Diff(old_graph, new_graph, key_fn, nil); err != nil || !diff.IsEmpty() {
+		t.Fatalf("undirected graphs differ: %v, %v", diff, err)
+	}
+}
+
+// Keys must identify nodes unambiguously
+func TestDiffDuplicateKey(t *testing.T) {
+	old_graph, old_name, _, _ := newDiffTestGraph(t, []diffTestNode{{"a", "", ""}}, nil)
+	new_graph, new_name, _, _ := newDiffTestGraph(t, []diffTestNode{{"a", "", ""},
+		{"a", "A", ""}}, nil)
+
+	_, err := Diff(old_graph, new_graph, NodeKeyByAttr(old_name, new_name), nil)
+
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("Diff: got error %v, want %v", err, ErrDuplicateKey)
+	}
+
+	// Attributes of other graphs can't provide keys
+	_, err = Diff(old_graph, new_graph, NodeKeyByAttr(old_name), nil)
+
+	if !errors.Is(err, ErrAttrForeign) {
+		t.Fatalf("Diff: got error %v, want %v", err, ErrAttrForeign)
+	}
+}
@@ -5,6 +5,7 @@
 package graph
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/xml"
 	"errors"
@@ -33,6 +34,14 @@ type NodeEmitSpec struct {
 	LabelAttr *NodeStrAttr
 }
 
+// Variables of the below type define how graph edges are printed
+type EdgeEmitSpec struct {
+	// Whether parallel edges (i.e. edges having the same source and destination nodes)
+	// are collapsed into a single edge. The collapsed edge is labeled with the number of
+	// edges it represents (if there are more than one)
+	CollapseParallel bool
+}
+
 // Variables of the below type map printable properties of a graph and its elements into
 // actual attributes of the graph, its nodes, its edges
 type GraphEmitSpec struct {
@@ -40,226 +49,455 @@ type GraphEmitSpec struct {
 	Graph GlobalEmitSpec
 	// Per-node printable properties mapped into node attributes
 	Node NodeEmitSpec
+	// Printable properties of edges
+	Edge EdgeEmitSpec
 	// Per-nest printable properties mapped into nest attributes
 	Nest NestEmitSpec
 }
 
-// Emit nodes and edges of a nest in Graphviz format
-func emitGVSubgraphNodesAndEdges(nest *Nest,
-	graph_emit_spec *GraphEmitSpec,
-	out_file *os.File,
-	indent string) error {
+// Group of parallel edges that are emitted as a single edge
+type emitEdgeGroup[E any] struct {
+	// The first edge of the group (in the order the edges were provided)
+	edge E
+	// Number of edges in the group
+	count int
+}
 
-	if nest.GetNestTree() == nil {
-		return errors.New("The nest is not linked to any nest tree")
+// Split edges into groups of parallel edges. The groups are ordered by their first
+// edges. If "collapse" is "false", every edge forms a group of its own. If "directed" is
+// "false", edges going in the opposite directions between the same nodes are parallel
+func groupParallelEdges[E any](edges []E, collapse bool, directed bool,
+	ends func(edge E) (int, int)) []emitEdgeGroup[E] {
+
+	groups := make([]emitEdgeGroup[E], 0, len(edges))
+	group_idx := make(map[[2]int]int)
+
+	for _, edge := range edges {
+		if collapse {
+			src_id, dst_id := ends(edge)
+
+			if !directed && src_id > dst_id {
+				src_id, dst_id = dst_id, src_id
+			}
+
+			if idx, ok := group_idx[[2]int{src_id, dst_id}]; ok {
+				groups[idx].count++
+
+				continue
+			}
+
+			group_idx[[2]int{src_id, dst_id}] = len(groups)
+		}
+
+		groups = append(groups, emitEdgeGroup[E]{edge, 1})
 	}
 
-	graph := nest.GetNestTree().GetBaseGraph()
+	return groups
+}
 
-	if graph == nil {
-		return errors.New("The nest tree to which the nest belongs is not linked to " +
-			"any graph")
+// Get IDs of the source and destination nodes of an edge
+func emitEdgeEnds(edge *Edge) (int, int) {
+	return edge.srcNode.id, edge.dstNode.id
+}
+
+// Get the Graphviz keyword that opens a graph description and the operator that connects
+// ends of an edge
+func emitGVGraphSyntax(directed bool) (string, string) {
+	if directed {
+		return "digraph", "->"
 	}
 
-	// Emit graph nodes belonging to the nest
-	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
-		var node_label string
+	return "graph", "--"
+}
 
-		node_label_attr := graph_emit_spec.Node.LabelAttr
-		node_desc_line := fmt.Sprintf(indent+"%d", node.GetID())
+// Escape a string for use inside a quoted Graphviz ID. Backslashes are escaped too, so
+// that Graphviz doesn't treat label text like "\N" as escape sequences
+func emitEscapeGV(str string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(str)
+}
+
+// Get label of a graph. "has_label" is "false" if no label attribute is specified or the
+// attribute is not set
+func emitGraphLabel(format string, graph *Graph,
+	attr *GraphStrAttr) (label string, has_label bool, err error) {
+
+	if attr == nil {
+		return "", false, nil
+	}
 
-		if node_label_attr != nil {
-			if is_set, err := node.IsStrAttrSet(node_label_attr); err != nil {
-				err_msg := fmt.Sprintf("Error checking whether node label attribute is "+
-					"set [node ID = %d]: ", node.GetID())
+	if has_label, err = graph.IsStrAttrSet(attr); err != nil {
+		return "", false, newEmitError(format, nil, nil, nil, "Error checking whether "+
+			"graph label attribute is set", err)
+	}
 
-				return errors.New(err_msg + err.Error())
-			} else if is_set {
-				node_label, err = node.GetStrAttrVal(node_label_attr)
+	if !has_label {
+		return "", false, nil
+	}
 
-				if err != nil {
-					err_msg := fmt.Sprintf("Error retrieving node label attribute "+
-						"[node ID = %d]: ", node.GetID())
+	if label, err = graph.GetStrAttrVal(attr); err != nil {
+		return "", false, newEmitError(format, nil, nil, nil, "Error getting value of "+
+			"an attribute that keeps the graph label", err)
+	}
 
-					return errors.New(err_msg + err.Error())
-				}
+	return label, true, nil
+}
 
-				node_desc_line += " [label=\"" + node_label + "\"]"
-			}
-		}
+// Get label of a node. "has_label" is "false" if no label attribute is specified or the
+// attribute is not set for the node
+func emitNodeLabel(format string, node *Node,
+	attr *NodeStrAttr) (label string, has_label bool, err error) {
 
-		node_desc_line += ";\n"
+	if attr == nil {
+		return "", false, nil
+	}
 
-		if _, err := out_file.WriteString(node_desc_line); err != nil {
-			return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
-		}
+	if has_label, err = node.IsStrAttrSet(attr); err != nil {
+		return "", false, newEmitError(format, node.nest, node, nil, "Error checking "+
+			"whether node label attribute is set", err)
 	}
 
-	// Emit graph edges belonging to the nest
+	if !has_label {
+		return "", false, nil
+	}
+
+	if label, err = node.GetStrAttrVal(attr); err != nil {
+		return "", false, newEmitError(format, node.nest, node, nil, "Error retrieving "+
+			"node label attribute", err)
+	}
+
+	return label, true, nil
+}
+
+// Get label of a nest. "has_label" is "false" if no label attribute is specified or the
+// attribute is not set for the nest
+func emitNestLabel(format string, nest *Nest,
+	attr *NestStrAttr) (label string, has_label bool, err error) {
+
+	if attr == nil {
+		return "", false, nil
+	}
+
+	if has_label, err = nest.IsStrAttrSet(attr); err != nil {
+		return "", false, newEmitError(format, nest, nil, nil, "Error while checking "+
+			"whether a value of the nest string attribute is set", err)
+	}
+
+	if !has_label {
+		return "", false, nil
+	}
+
+	if label, err = nest.GetStrAttrVal(attr); err != nil {
+		return "", false, newEmitError(format, nest, nil, nil, "Error retrieving nest "+
+			"label attribute", err)
+	}
+
+	return label, true, nil
+}
+
+// Get the root nest of a graph that is about to be emitted
+func emitRootNest(format string, graph *Graph) (*Nest, error) {
+	if graph.GetNestTree() == nil {
+		return nil, newEmitError(format, nil, nil, nil, "The graph doesn't have a nest "+
+			"tree", ErrNoNestTree)
+	}
+
+	root_nest := graph.GetNestTree().GetRootNest()
+
+	if root_nest == nil {
+		return nil, newEmitError(format, nil, nil, nil, "The graph doesn't have a root "+
+			"nest", ErrNoNestTree)
+	}
+
+	return root_nest, nil
+}
+
+// Get the graph to which a nest belongs and check that the nest is properly linked to it
+func emitNestGraph(format string, nest *Nest) (*Graph, error) {
+	if nest.GetNestTree() == nil {
+		return nil, newEmitError(format, nest, nil, nil, "The nest is not linked to any "+
+			"nest tree", ErrInconsistent)
+	}
+
+	graph := nest.GetNestTree().GetBaseGraph()
+
+	if graph == nil {
+		return nil, newEmitError(format, nest, nil, nil, "The nest tree to which the nest "+
+			"belongs is not linked to any graph", ErrInconsistent)
+	}
+
+	return graph, nil
+}
+
+// Check that a child nest belongs to the same nest tree as its parent
+func emitCheckChildNest(format string, nest *Nest, child_nest *Nest) error {
+	if nest.GetNestTree() != child_nest.GetNestTree() {
+		return newEmitError(format, child_nest, nil, nil, "A child nest belongs to a "+
+			"different nest tree or is not linked to any nest tree at all",
+			ErrInconsistent)
+	}
+
+	return nil
+}
+
+// Collect graph edges belonging to a nest. Every edge is checked to connect nodes of the
+// graph to which the nest belongs
+func emitNestEdges(format string, nest *Nest, graph *Graph) ([]*Edge, error) {
+	var edges []*Edge
+
 	for edge := nest.GetFirstEdge(); edge != nil; edge = edge.GetNextEdgeInNest() {
 		if edge.GetSrcNode() == nil || edge.GetDstNode() == nil {
-			return errors.New("At least one end of an edge belonging to the nest is " +
-				"not connected to any graph node")
+			return nil, newEmitError(format, nest, nil, edge, "At least one end of an "+
+				"edge belonging to the nest is not connected to any graph node",
+				ErrInconsistent)
 		}
 
-		src_node := edge.GetSrcNode()
-		dst_node := edge.GetDstNode()
-
 		if edge.GetGraph() != graph {
-			return errors.New("An edge belonging to the nest is attributed to a " +
-				"different graph than the nest itself")
+			return nil, newEmitError(format, nest, nil, edge, "An edge belonging to the "+
+				"nest is attributed to a different graph than the nest itself",
+				ErrInconsistent)
 		}
 
-		if src_node.GetGraph() != graph || dst_node.GetGraph() != graph {
-			return errors.New("At least one of the nodes connected by an edge " +
-				"belonging to the nest is attributed to a different graph (than the " +
-				"edge itself)")
+		if edge.GetSrcNode().GetGraph() != graph || edge.GetDstNode().GetGraph() != graph {
+			return nil, newEmitError(format, nest, nil, edge, "At least one of the nodes "+
+				"connected by an edge belonging to the nest is attributed to a different "+
+				"graph (than the edge itself)", ErrInconsistent)
 		}
 
-		edge_desc_line := fmt.Sprintf(indent+"%d -> %d;\n", src_node.GetID(),
-			dst_node.GetID())
-
-		if _, err := out_file.WriteString(edge_desc_line); err != nil {
-			return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
-		}
+		edges = append(edges, edge)
 	}
 
-	return nil
+	return edges, nil
 }
 
-// Emit a nested sub-graph in Graphviz format
-func emitGVSubgraph(nest *Nest,
-	graph_emit_spec *GraphEmitSpec,
-	out_file *os.File,
-	indent string) error {
+// Node of a graph or of a graph snapshot as seen by the Graphviz emitter
+type emitGVNode[D any] interface {
+	comparable
+	GetID() int
+	GetNextNodeInNest() D
+	// Get label of the node. "has_label" is "false" if no label attribute is specified or
+	// the attribute is not set for the node
+	emitLabel(format string, attr *NodeStrAttr) (label string, has_label bool, err error)
+}
 
-	panic_msg_prefix := "Panic while emitting a nest in Graphviz format: "
+// Edge of a graph or of a graph snapshot as seen by the Graphviz emitter
+type emitGVEdge[D any] interface {
+	GetSrcNode() D
+	GetDstNode() D
+}
 
-	if graph_emit_spec == nil {
-		panic(panic_msg_prefix + "zero reference to graph emit specification")
-	}
+// Nest of a graph or of a graph snapshot as seen by the Graphviz emitter. Graph nests
+// and snapshot nests expose the same navigation methods. So, graphs and snapshots are
+// emitted by the same code
+type emitGVNest[N any, D any, E any] interface {
+	comparable
+	GetID() int
+	GetFirstChildNest() N
+	GetNextSiblingNest() N
+	GetFirstNode() D
+	// Get label of the nest. "has_label" is "false" if no label attribute is specified or
+	// the attribute is not set for the nest
+	emitLabel(format string, attr *NestStrAttr) (label string, has_label bool, err error)
+	// Get edges belonging to the nest
+	emitEdges(format string) ([]E, error)
+	// Check that a child nest can be emitted as a part of the nest
+	emitCheckChild(format string, child N) error
+	// Get the graph nest reported by emit errors ("nil" for snapshot nests)
+	emitErrNest() *Nest
+}
 
-	if out_file == nil {
-		panic(panic_msg_prefix + "zero reference to output file")
-	}
+func (node *Node) emitLabel(format string,
+	attr *NodeStrAttr) (label string, has_label bool, err error) {
 
-	// Emit subgraph opening clause
-	nest_id_as_str := fmt.Sprintf("%d", nest.GetID())
-	_, err := out_file.WriteString(indent + "subgraph cluster_" + nest_id_as_str + " {\n")
+	return emitNodeLabel(format, node, attr)
+}
+
+func (nest *Nest) emitLabel(format string,
+	attr *NestStrAttr) (label string, has_label bool, err error) {
+
+	return emitNestLabel(format, nest, attr)
+}
+
+func (nest *Nest) emitEdges(format string) ([]*Edge, error) {
+	graph, err := emitNestGraph(format, nest)
 
 	if err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return nil, err
 	}
 
-	// Emit subgraph label (if exists)
-	nest_label_attr := graph_emit_spec.Nest.LabelAttr
+	return emitNestEdges(format, nest, graph)
+}
 
-	if nest_label_attr != nil {
-		is_set, err := nest.IsStrAttrSet(nest_label_attr)
+func (nest *Nest) emitCheckChild(format string, child *Nest) error {
+	return emitCheckChildNest(format, nest, child)
+}
+
+func (nest *Nest) emitErrNest() *Nest {
+	return nest
+}
+
+// Emit nodes and edges of a nest in Graphviz format
+func emitGVNodesAndEdges[N emitGVNest[N, D, E], D emitGVNode[D], E emitGVEdge[D]](nest N,
+	graph_emit_spec *GraphEmitSpec,
+	directed bool,
+	out *bufio.Writer,
+	indent string) error {
+
+	var no_node D
+
+	// Emit graph nodes belonging to the nest
+	for node := nest.GetFirstNode(); node != no_node; node = node.GetNextNodeInNest() {
+		node_desc_line := fmt.Sprintf(indent+"%d", node.GetID())
+		label, has_label, err := node.emitLabel(EMIT_FORMAT_GV,
+			graph_emit_spec.Node.LabelAttr)
 
 		if err != nil {
-			return errors.New("Error while checking whether a value of the nest string " +
-				"attribute is set: " + err.Error())
+			return wrapEmitError(EMIT_FORMAT_GV, nest.emitErrNest(), "Couldn't get "+
+				"label of a node", err)
 		}
 
-		if is_set {
-			nest_label, _ := nest.GetStrAttrVal(nest_label_attr)
-			_, err := out_file.WriteString(indent + EMIT_INDENT + "label=\"" +
-				nest_label + "\";\n")
+		if has_label {
+			node_desc_line += " [label=\"" + emitEscapeGV(label) + "\"]"
+		}
 
-			if err != nil {
-				return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
-			}
+		if _, err := out.WriteString(node_desc_line + ";\n"); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GV, nest.emitErrNest(), err)
 		}
 	}
 
+	edges, err := nest.emitEdges(EMIT_FORMAT_GV)
+
 	if err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return err
 	}
 
-	// Emit nested subgraphs. Nodes and edges of the current nest will be emitted after
-	// that
-	child_nest := nest.GetFirstChildNest()
+	// Emit graph edges belonging to the nest
+	_, edge_op := emitGVGraphSyntax(directed)
+	groups := groupParallelEdges(edges, graph_emit_spec.Edge.CollapseParallel, directed,
+		func(edge E) (int, int) {
+			return edge.GetSrcNode().GetID(), edge.GetDstNode().GetID()
+		})
+
+	for _, group := range groups {
+		edge_desc_line := fmt.Sprintf(indent+"%d %s %d", group.edge.GetSrcNode().GetID(),
+			edge_op, group.edge.GetDstNode().GetID())
+
+		if group.count > 1 {
+			edge_desc_line += fmt.Sprintf(" [label=\"%d\"]", group.count)
+		}
 
-	for ; child_nest != nil; child_nest = child_nest.GetNextSiblingNest() {
-		if nest.GetNestTree() != child_nest.GetNestTree() {
-			return errors.New("A child nest belongs to a different nest tree or is not " +
-				"linked to any nest tree at all")
+		if _, err := out.WriteString(edge_desc_line + ";\n"); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GV, nest.emitErrNest(), err)
 		}
+	}
 
-		err = emitGVSubgraph(child_nest, graph_emit_spec, out_file, indent+EMIT_INDENT)
+	return nil
+}
 
-		// Because of the recursive call in this loop, the prefix of the below error
-		// message may be repeated multiple times. It's considered ok for now. Because
-		// later, for example, an ID of each intermediate nest could be added to the
-		// message (hence, the chain of the exact nests would be reported)
+// Emit child nests of a nest as nested sub-graphs in Graphviz format
+func emitGVChildNests[N emitGVNest[N, D, E], D emitGVNode[D], E emitGVEdge[D]](nest N,
+	graph_emit_spec *GraphEmitSpec,
+	directed bool,
+	out *bufio.Writer,
+	indent string) error {
+
+	var no_nest N
+
+	child := nest.GetFirstChildNest()
+
+	for ; child != no_nest; child = child.GetNextSiblingNest() {
+		if err := nest.emitCheckChild(EMIT_FORMAT_GV, child); err != nil {
+			return err
+		}
+
+		err := emitGVSubgraph[N, D, E](child, graph_emit_spec, directed, out, indent)
+
+		// The error returned by the recursive call already carries the path to the exact
+		// nest that couldn't be emitted. So, it's propagated as is
 		if err != nil {
-			return errors.New("Couldn't emit a child nest: " + err.Error())
+			return wrapEmitError(EMIT_FORMAT_GV, child.emitErrNest(), "Couldn't emit a "+
+				"child nest", err)
 		}
 	}
 
-	err = emitGVSubgraphNodesAndEdges(nest, graph_emit_spec, out_file, indent+EMIT_INDENT)
+	return nil
+}
+
+// Emit a nested sub-graph in Graphviz format
+func emitGVSubgraph[N emitGVNest[N, D, E], D emitGVNode[D], E emitGVEdge[D]](nest N,
+	graph_emit_spec *GraphEmitSpec,
+	directed bool,
+	out *bufio.Writer,
+	indent string) error {
+
+	// Emit subgraph opening clause
+	_, err := out.WriteString(fmt.Sprintf("%ssubgraph cluster_%d {\n", indent,
+		nest.GetID()))
 
 	if err != nil {
-		return errors.New("Couldn't emit nodes and edges belonging to a nest: " +
-			err.Error())
+		return newEmitWriteError(EMIT_FORMAT_GV, nest.emitErrNest(), err)
 	}
 
-	// Emit sub-graph closing bracket
-	if _, err := out_file.WriteString(indent + "}\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+	// Emit subgraph label (if exists)
+	label, has_label, err := nest.emitLabel(EMIT_FORMAT_GV, graph_emit_spec.Nest.LabelAttr)
+
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_GV, nest.emitErrNest(), "Couldn't get label of "+
+			"a nest", err)
 	}
 
-	return nil
-}
+	if has_label {
+		_, err := out.WriteString(indent + EMIT_INDENT + "label=\"" + emitEscapeGV(label) +
+			"\";\n")
 
-// Print text description of a Graph in Graphviz DOT language.
-// The description can be further compiled by Graphviz "dot" tool
-// into Postscript file, PNG image, etc. For example, the following
-// command will produce a PNG drawing of the graph (assuming the text
-// description of the graph is stored in "graph.gv"):
-//    dot -Tpng graph.gv -o graph.png
-//
-// Input: full path to the output file (all parent directories should
-//        exist; the file itself must NOT exist)
-func EmitInGVFormat(graph *Graph, graph_emit_spec *GraphEmitSpec, out_path string) error {
-	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GV, nest.emitErrNest(), err)
+		}
+	}
+
+	// Emit nested subgraphs. Nodes and edges of the current nest will be emitted after
+	// that
+	err = emitGVChildNests[N, D, E](nest, graph_emit_spec, directed, out,
+		indent+EMIT_INDENT)
 
 	if err != nil {
-		return errors.New("Cannot create output file: " + err.Error())
+		return err
 	}
 
-	defer out_file.Close()
+	err = emitGVNodesAndEdges[N, D, E](nest, graph_emit_spec, directed, out,
+		indent+EMIT_INDENT)
 
-	// If no emit specification is provided, we create the default one. We do that to
-	// simplify the code, so that we don't need to check whether graph_emit_spec is
-	// "nil" every time we're going to use it
-	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
-	if graph_emit_spec == nil {
-		graph_emit_spec = &GraphEmitSpec{}
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_GV, nest.emitErrNest(), "Couldn't emit nodes "+
+			"and edges belonging to a nest", err)
 	}
 
-	EMIT_WRITE_ERR_MSG_PREFIX := "Cannot write to the output file: "
+	// Emit sub-graph closing bracket
+	if _, err := out.WriteString(indent + "}\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GV, nest.emitErrNest(), err)
+	}
 
-	// Get graph label (if any). It will be used as a header and as a label
-	var has_graph_label bool
-	var graph_label string
+	return nil
+}
 
-	graph_label_attr := graph_emit_spec.Graph.LabelAttr
+// Emit a graph (or a graph snapshot) given by its root nest in Graphviz format
+func emitGVDocument[N emitGVNest[N, D, E], D emitGVNode[D], E emitGVEdge[D]](root_nest N,
+	graph_label string,
+	has_graph_label bool,
+	graph_emit_spec *GraphEmitSpec,
+	directed bool,
+	out_path string) error {
 
-	if graph_label_attr != nil {
-		if is_set, err := graph.IsStrAttrSet(graph_label_attr); err != nil {
-			return errors.New("Error checking whether graph label attribute is set")
-		} else if is_set {
-			has_graph_label = true
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 
-			if graph_label, err = graph.GetStrAttrVal(graph_label_attr); err != nil {
-				return errors.New("Error getting value of an attribute that keeps the " +
-					"graph label")
-			}
-		}
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_GV, nil, nil, nil, "Cannot open the output path",
+			fmt.Errorf("%w: %w", ErrEmitCreate, err))
 	}
 
+	defer out_file.Close()
+
+	out := bufio.NewWriter(out_file)
+
 	// Emit Graph header
 	graph_name := "NO NAME"
 
@@ -267,76 +505,106 @@ func EmitInGVFormat(graph *Graph, graph_emit_spec *GraphEmitSpec, out_path strin
 		graph_name = graph_label
 	}
 
-	_, err = out_file.WriteString("digraph \"" + graph_name + "\" {\n")
+	graph_keyword, _ := emitGVGraphSyntax(directed)
+	_, err = out.WriteString(graph_keyword + " \"" + emitEscapeGV(graph_name) + "\" {\n")
 
 	if err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_GV, nil, err)
 	}
 
 	// Emit Graph global properties
 	// Drawing orientation property: left to right
-	if _, err := out_file.WriteString("\trankdir = LR\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+	if _, err := out.WriteString("\trankdir = LR\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GV, nil, err)
 	}
 
 	// Graph label propery
 	if has_graph_label {
-		_, err = out_file.WriteString("\tlabel = \"" + graph_label + "\"\n")
+		_, err = out.WriteString("\tlabel = \"" + emitEscapeGV(graph_label) + "\"\n")
+
+		if err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GV, nil, err)
+		}
 	}
 
+	// Emit nested subgraphs. Nodes and edges of the root nest will be emitted after that
+	err = emitGVChildNests[N, D, E](root_nest, graph_emit_spec, directed, out, EMIT_INDENT)
+
 	if err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return err
 	}
 
-	// Emit nested subgraphs. Nodes and edges of the root nest will be emitted after that
-	if graph.GetNestTree() == nil {
-		return errors.New("The graph doesn't have a nest tree")
+	// Emit Graph nodes
+	// Set shape for all the nodes
+	if _, err := out.WriteString("\tnode [shape=box];\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GV, nil, err)
 	}
 
-	root_nest := graph.GetNestTree().GetRootNest()
+	err = emitGVNodesAndEdges[N, D, E](root_nest, graph_emit_spec, directed, out,
+		EMIT_INDENT)
 
-	if root_nest == nil {
-		return errors.New("The graph doesn't have a root nest")
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_GV, root_nest.emitErrNest(), "Couldn't emit "+
+			"nodes and edges belonging to the root nest", err)
 	}
 
-	child_nest := root_nest.GetFirstChildNest()
+	// Emit Graph description closing bracket
+	if _, err := out.WriteString("}"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GV, nil, err)
+	}
 
-	for ; child_nest != nil; child_nest = child_nest.GetNextSiblingNest() {
-		if root_nest.GetNestTree() != child_nest.GetNestTree() {
-			return errors.New("A child nest belongs to a different nest tree or is not " +
-				"linked to any nest tree at all")
-		}
+	// Write errors of a buffered writer are sticky. So, the final "Flush()" reports any
+	// error that was not reported yet
+	if err := out.Flush(); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GV, nil, err)
+	}
 
-		err = emitGVSubgraph(child_nest, graph_emit_spec, out_file, EMIT_INDENT)
+	return nil
+}
 
-		// Because of the recursive call in this loop, the prefix of the below error
-		// message may be repeated multiple times. It's considered ok for now. Because
-		// later, for example, an ID of each intermediate nest could be added to the
-		// message (hence, the chain of the exact nests would be reported)
-		if err != nil {
-			return errors.New("Couldn't emit a child nest: " + err.Error())
-		}
+// Print text description of a Graph in Graphviz DOT language.
+// The description can be further compiled by Graphviz "dot" tool
+// into Postscript file, PNG image, etc. For example, the following
+// command will produce a PNG drawing of the graph (assuming the text
+// description of the graph is stored in "graph.gv"):
+//    dot -Tpng graph.gv -o graph.png
+//
+// Input: full path to the output file (all parent directories should
+//        exist; the file itself must NOT exist)
+func EmitInGVFormat(graph *Graph, graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInGVFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_GV, nil, nil, nil, "Zero reference to the graph",
+			ErrNilGraph)
 	}
 
-	// Emit Graph nodes
-	// Set shape for all the nodes
-	if _, err := out_file.WriteString("\tnode [shape=box];\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+	// If no emit specification is provided, we create the default one. We do that to
+	// simplify the code, so that we don't need to check whether graph_emit_spec is
+	// "nil" every time we're going to use it
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
 	}
 
-	err = emitGVSubgraphNodesAndEdges(root_nest, graph_emit_spec, out_file, EMIT_INDENT)
+	// Get graph label (if any). It will be used as a header and as a label
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_GV, graph,
+		graph_emit_spec.Graph.LabelAttr)
 
 	if err != nil {
-		return errors.New("Couldn't emit nodes and edges belonging to the root nest: " +
-			err.Error())
+		return err
 	}
 
-	// Emit Graph description closing bracket
-	if _, err := out_file.WriteString("}"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+	root_nest, err := emitRootNest(EMIT_FORMAT_GV, graph)
+
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return emitGVDocument[*Nest, *Node, *Edge](root_nest, graph_label, has_graph_label,
+		graph_emit_spec, graph.IsDirected(), out_path)
 }
 
 // GraphML extension families
@@ -349,21 +617,38 @@ const (
 const (
 	// Node attribute "nodegraphics"
 	yFILES_NATTR_NODEGRAPHICS = iota
+	// Edge attribute "edgegraphics"
+	yFILES_EATTR_EDGEGRAPHICS = iota
 	yFILES_ATTR_NUM           = iota
 )
 
 // Enumeration of yFiles attribute types (native types as well as extension types)
 const (
 	yFILES_ATTR_TYPE_NODEGRAPHICS = iota
+	yFILES_ATTR_TYPE_EDGEGRAPHICS = iota
 	yFILES_ATTR_TYPE_NUM          = iota
 )
 
 // Graph element types supported by yFiles
 const (
 	yFILES_ELEM_NODE = iota
+	yFILES_ELEM_EDGE = iota
 	yFILES_ELEM_NUM  = iota
 )
 
+// Enumeration of standard GraphML attribute types
+const (
+	gML_ATTR_TYPE_STRING = iota
+	gML_ATTR_TYPE_INT    = iota
+)
+
+// Graph element types to which standard GraphML attributes are applicable
+const (
+	gML_ELEM_GRAPH = iota
+	gML_ELEM_NODE  = iota
+	gML_ELEM_EDGE  = iota
+)
+
 type gMLAttr struct {
 	// Unique attribute identifier
 	id int
@@ -380,11 +665,126 @@ type gMLAttr struct {
 	// defining an attribute that would be applicable to "all" graph elements, one would
 	// need to define a separate attribute for each graph element type
 	elemType int
+	// Name of an attribute. Used by standard GraphML attributes only (extension
+	// attributes are identified by their types)
+	name string
 }
 
 var yFilesGMLAttrs = []gMLAttr{
 	{yFILES_NATTR_NODEGRAPHICS, gML_EXT_FAMILY_YFILES, yFILES_ATTR_TYPE_NODEGRAPHICS,
-		yFILES_ELEM_NODE},
+		yFILES_ELEM_NODE, ""},
+	{yFILES_EATTR_EDGEGRAPHICS, gML_EXT_FAMILY_YFILES, yFILES_ATTR_TYPE_EDGEGRAPHICS,
+		yFILES_ELEM_EDGE, ""},
+}
+
+// Mapping of logical attribute IDs to IDs that identify the attributes inside an emitted
+// document. Only the attributes used in a document get document IDs. The document IDs
+// are assigned in the order of the logical IDs and have no gaps. For example, if only
+// attributes with logical IDs 0, 5 and 7 are used in a document, they get document IDs
+// 0, 1 and 2
+type emitAttrKeyMap struct {
+	// Document IDs indexed by logical IDs. "-1" for attributes not used in a document
+	docIDs []int
+	// Logical IDs of the used attributes in the order of their document IDs
+	used []int
+}
+
+// Create a mapping of logical attribute IDs to document IDs. "is_used" is indexed by
+// logical IDs
+func newEmitAttrKeyMap(is_used []bool) *emitAttrKeyMap {
+	key_map := &emitAttrKeyMap{docIDs: make([]int, len(is_used))}
+
+	for attr_id, used := range is_used {
+		key_map.docIDs[attr_id] = -1
+
+		if used {
+			key_map.docIDs[attr_id] = len(key_map.used)
+			key_map.used = append(key_map.used, attr_id)
+		}
+	}
+
+	return key_map
+}
+
+// Get document ID of an attribute
+func (key_map *emitAttrKeyMap) docID(attr_id int) int {
+	if attr_id < 0 || attr_id >= len(key_map.docIDs) || key_map.docIDs[attr_id] < 0 {
+		panic("Panic while obtaining a document ID for an attribute: the attribute " +
+			"is not used in the document")
+	}
+
+	return key_map.docIDs[attr_id]
+}
+
+// Create a mapping of string attributes of nodes and nests to document IDs. Document
+// formats that don't distinguish nests from nodes (GEXF, GML and others) represent both
+// kinds of attributes the same way. The logical IDs of node string attributes coincide
+// with the attribute numbers. The logical IDs of nest string attributes follow them. Only
+// allocated attributes are used in documents
+func emitStrAttrKeyMap(graph *Graph) *emitAttrKeyMap {
+	is_used := append([]bool{}, graph.nodeStrAttrAllocMap...)
+
+	return newEmitAttrKeyMap(append(is_used, graph.nestTree.nestStrAttrAllocMap...))
+}
+
+// Get the name under which a string attribute with a given logical ID (see
+// "emitStrAttrKeyMap()") is exported
+func emitStrAttrName(graph *Graph, attr_id int) string {
+	node_attr_num := len(graph.nodeStrAttrAllocMap)
+
+	if attr_id < node_attr_num {
+		return fmt.Sprintf("node_attr_%d", attr_id)
+	}
+
+	return fmt.Sprintf("nest_attr_%d", attr_id-node_attr_num)
+}
+
+// Value of a string attribute exported to a document
+type emitStrAttrVal struct {
+	// Document ID of the attribute
+	docID int
+	// The value
+	val string
+}
+
+// Collect values of the string attributes that are set for a node or a nest. "attrs" are
+// the string attributes of the element. "first_attr_id" is the logical ID of its first
+// attribute (see "emitStrAttrKeyMap()")
+func emitCollectStrAttrVals(key_map *emitAttrKeyMap, attrs []strAttrVal,
+	first_attr_id int) []emitStrAttrVal {
+
+	var vals []emitStrAttrVal
+
+	for attr_num, attr := range attrs {
+		attr_id := first_attr_id + attr_num
+
+		if attr.isSet && key_map.docIDs[attr_id] >= 0 {
+			vals = append(vals, emitStrAttrVal{key_map.docID(attr_id), attr.data})
+		}
+	}
+
+	return vals
+}
+
+// Collect values of the string attributes that are set for a node
+func emitNodeStrAttrVals(key_map *emitAttrKeyMap, node *Node) []emitStrAttrVal {
+	return emitCollectStrAttrVals(key_map, node.strAttrs, 0)
+}
+
+// Collect values of the string attributes that are set for a nest
+func emitNestStrAttrVals(key_map *emitAttrKeyMap, nest *Nest) []emitStrAttrVal {
+	first_attr_id := len(nest.nestTree.baseGraph.nodeStrAttrAllocMap)
+
+	return emitCollectStrAttrVals(key_map, nest.strAttrs, first_attr_id)
+}
+
+// Escape a string for use in XML character data or in an attribute value
+func emitEscapeXML(str string) string {
+	var buf bytes.Buffer
+
+	xml.Escape(&buf, []byte(str))
+
+	return buf.String()
 }
 
 func checkYFilesAttrArrayConsistency() error {
@@ -420,7 +820,7 @@ func getYFilesAttrDocumentId(attr_id int) int {
 	// those attributes. But there would be "gaps" in the document attribute IDs in this
 	// case, because, for example, there will be no attribute with the document ID 1. So,
 	// to avoid such gaps, it might be reasonable to assign document IDs 0, 1, 2 to
-	// logical attributes 0, 5 and 7
+	// logical attributes 0, 5 and 7 (see "emitAttrKeyMap")
 	return attr_id
 }
 
@@ -433,8 +833,10 @@ func getYFilesAttrDocumentType(attr_type int) string {
 	var document_type string
 
 	switch attr_type {
-	case yFILES_NATTR_NODEGRAPHICS:
+	case yFILES_ATTR_TYPE_NODEGRAPHICS:
 		document_type = "nodegraphics"
+	case yFILES_ATTR_TYPE_EDGEGRAPHICS:
+		document_type = "edgegraphics"
 	default:
 		panic(panic_msg_prefix + "the provided logical attribute type is unexpected " +
 			"for yFiles documents")
@@ -454,6 +856,8 @@ func getYFilesAttrDocumentElem(elem_type int) string {
 	switch elem_type {
 	case yFILES_ELEM_NODE:
 		document_elem = "node"
+	case yFILES_ELEM_EDGE:
+		document_elem = "edge"
 	default:
 		panic(panic_msg_prefix + "the provided logical element type is unexpected " +
 			"for yFiles documents")
@@ -462,31 +866,73 @@ func getYFilesAttrDocumentElem(elem_type int) string {
 	return document_elem
 }
 
+// For a given standard GraphML attribute type return a string representation of that type
+func getGMLAttrDocumentType(attr_type int) string {
+	switch attr_type {
+	case gML_ATTR_TYPE_STRING:
+		return "string"
+	case gML_ATTR_TYPE_INT:
+		return "int"
+	}
+
+	panic("Panic while obtaining a document type for a GraphML attribute: the provided " +
+		"logical attribute type is unexpected for GraphML documents")
+}
+
+// For a given type of graph element return a printable name of that element as it is used
+// in standard GraphML documents
+func getGMLAttrDocumentElem(elem_type int) string {
+	switch elem_type {
+	case gML_ELEM_GRAPH:
+		return "graph"
+	case gML_ELEM_NODE:
+		return "node"
+	case gML_ELEM_EDGE:
+		return "edge"
+	}
+
+	panic("Panic while obtaining a printable name of a GraphML graph element: the " +
+		"provided logical element type is unexpected for GraphML documents")
+}
+
+// Describe declaration of a GraphML attribute (i.e. "<key>" element). Both standard
+// attributes and attributes of GraphML extensions are supported
+func emitGMLKeyDecl(attr gMLAttr, attr_document_id int) string {
+	var attr_desc string
+
+	switch attr.extFamily {
+	case gML_EXT_FAMILY_STANDARD:
+		attr_desc = fmt.Sprintf("for=\"%s\" attr.name=\"%s\" attr.type=\"%s\"",
+			getGMLAttrDocumentElem(attr.elemType), emitEscapeXML(attr.name),
+			getGMLAttrDocumentType(attr.attrType))
+	case gML_EXT_FAMILY_YFILES:
+		attr_desc = fmt.Sprintf("yfiles.type=\"%s\" for=\"%s\"",
+			getYFilesAttrDocumentType(attr.attrType),
+			getYFilesAttrDocumentElem(attr.elemType))
+	default:
+		panic("Panic while emitting GraphML attribute to a document: the attribute " +
+			"belongs to an unknown extension family")
+	}
+
+	return fmt.Sprintf("<key id=\"d%d\" %s/>\n", attr_document_id, attr_desc)
+}
+
 func emitYFilesAttrDecls(out_file *os.File, indent string) error {
 	// Emit all known attribute declarations. Later this function can be optimized to emit
 	// only those attributes that will actually be used
 	for i := 0; i < len(yFilesGMLAttrs); i++ {
 		attr := yFilesGMLAttrs[i]
-		attr_document_id := getYFilesAttrDocumentId(attr.id)
-
-		var attr_type_family string
 
-		switch attr.extFamily {
-		case gML_EXT_FAMILY_YFILES:
-			attr_type_family = "yfiles.type"
-		default:
+		if attr.extFamily != gML_EXT_FAMILY_YFILES {
 			panic("Panic while emitting yFiles GraphML attribute to a document: the " +
 				"attribute belongs to extension family that is not expected in yFiles " +
 				"documents")
 		}
 
-		attr_document_type := getYFilesAttrDocumentType(attr.attrType)
-		attr_document_elem := getYFilesAttrDocumentElem(attr.elemType)
-		str_to_emit := fmt.Sprintf(indent+"<key id=\"d%d\" %s=\"%s\" for=\"%s\"/>\n",
-			attr_document_id, attr_type_family, attr_document_type, attr_document_elem)
+		str_to_emit := indent + emitGMLKeyDecl(attr, getYFilesAttrDocumentId(attr.id))
 
 		if _, err := out_file.WriteString(str_to_emit); err != nil {
-			return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+			return newEmitWriteError(EMIT_FORMAT_YFILES, nil, err)
 		}
 	}
 
@@ -518,7 +964,7 @@ func emitYFilesGroup(nest *Nest,
 	node_open_tag := fmt.Sprintf("<node id=\"%s\" yfiles.foldertype=\"folder\">", node_id)
 
 	if _, err := out_file.WriteString(indent + node_open_tag + "\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit attribute that defines graphical representation of the group node
@@ -529,14 +975,14 @@ func emitYFilesGroup(nest *Nest,
 	emit_str := indent + EMIT_INDENT + ng_open_tag + "\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit "y:ProxyAutoBoundsNode" open tag
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 2) + "<y:ProxyAutoBoundsNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit "realizers" of the group node. At the moment the code of this function was
@@ -574,7 +1020,7 @@ func emitYFilesGroup(nest *Nest,
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 3) + "<y:Realizers active=\"1\">\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Get group node label
@@ -585,18 +1031,14 @@ func emitYFilesGroup(nest *Nest,
 
 	if nest_label_attr != nil {
 		if is_set, err := nest.IsStrAttrSet(nest_label_attr); err != nil {
-			err_msg := fmt.Sprintf("Error checking whether nest label attribute is "+
-				"set [nest ID = %d]: ", nest.GetID())
-
-			return errors.New(err_msg + err.Error())
+			return newEmitError(EMIT_FORMAT_YFILES, nest, nil, nil, "Error checking "+
+				"whether nest label attribute is set", err)
 		} else if is_set {
 			nest_label, err = nest.GetStrAttrVal(nest_label_attr)
 
 			if err != nil {
-				err_msg := fmt.Sprintf("Error retrieving nest label attribute "+
-					"[nest ID = %d]: ", nest.GetID())
-
-				return errors.New(err_msg + err.Error())
+				return newEmitError(EMIT_FORMAT_YFILES, nest, nil, nil, "Error "+
+					"retrieving nest label attribute", err)
 			}
 
 			is_emit_label = true
@@ -608,7 +1050,7 @@ func emitYFilesGroup(nest *Nest,
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 4) + "<y:GroupNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit group node label (if any)
@@ -617,10 +1059,10 @@ func emitYFilesGroup(nest *Nest,
 		// labels are shown inside the group bounds and at the very top of the group area
 		emit_str = indent + strings.Repeat(EMIT_INDENT, 5) +
 			"<y:NodeLabel modelName=\"internal\" modelPosition=\"t\">" +
-			nest_label + "</y:NodeLabel>\n"
+			emitEscapeXML(nest_label) + "</y:NodeLabel>\n"
 
 		if _, err := out_file.WriteString(emit_str); err != nil {
-			return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+			return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 		}
 	}
 
@@ -628,7 +1070,7 @@ func emitYFilesGroup(nest *Nest,
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 5) + "<y:State closed=\"false\"/>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Force node to adjust its size to accomodate the label
@@ -636,14 +1078,14 @@ func emitYFilesGroup(nest *Nest,
 		"<y:NodeBounds considerNodeLabelSize=\"true\"/>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit close tag for a realizer of an unfolded state
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 4) + "</y:GroupNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit realizer of a folded state
@@ -651,16 +1093,16 @@ func emitYFilesGroup(nest *Nest,
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 4) + "<y:GroupNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit group node label (if any)
 	if is_emit_label {
 		emit_str = indent + strings.Repeat(EMIT_INDENT, 5) + "<y:NodeLabel>" +
-			nest_label + "</y:NodeLabel>\n"
+			emitEscapeXML(nest_label) + "</y:NodeLabel>\n"
 
 		if _, err := out_file.WriteString(emit_str); err != nil {
-			return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+			return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 		}
 	}
 
@@ -668,7 +1110,7 @@ func emitYFilesGroup(nest *Nest,
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 5) + "<y:State closed=\"true\"/>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// NOTE: "considerNodeLabelSize" property of "y:NodeBounds" tag is not emitted for
@@ -680,26 +1122,26 @@ func emitYFilesGroup(nest *Nest,
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 4) + "</y:GroupNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit close tag for "y:Realizers"
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 3) + "</y:Realizers>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit close tag for "y:ProxyAutoBoundsNode"
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 2) + "</y:ProxyAutoBoundsNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit close tag for "nodegraphics" attribute
 	if _, err := out_file.WriteString(indent + EMIT_INDENT + "</data>\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit subgraph contained inside the node. This is a - potentially - recursive
@@ -708,17 +1150,16 @@ func emitYFilesGroup(nest *Nest,
 	err := emitYFilesSubgraph(nest, graph_emit_spec, out_file, id_prefix,
 		indent+EMIT_INDENT)
 
-	// Because the above function call is recursive, the prefix of the below error
-	// message may be repeated multiple times. It's considered ok for now. Because
-	// later, for example, an ID of each intermediate nest could be added to the
-	// message (hence, the chain of the exact nests would be reported)
+	// The error returned by the recursive call already carries the path to the exact
+	// nest that couldn't be emitted. So, it's propagated as is
 	if err != nil {
-		return errors.New("Couldn't emit a nested subgraph")
+		return wrapEmitError(EMIT_FORMAT_YFILES, nest, "Couldn't emit a nested subgraph",
+			err)
 	}
 
 	// Emit group node close tag
 	if _, err := out_file.WriteString(indent + "</node>\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	return nil
@@ -746,7 +1187,7 @@ func emitYFilesRegularNode(node *Node,
 	emit_str := fmt.Sprintf(indent+"<node id=\"%sn%d\">\n", id_prefix, node.GetID())
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, node.GetNest(), err)
 	}
 
 	// Emit attribute that defines graphical representation of a regular node
@@ -757,14 +1198,14 @@ func emitYFilesRegularNode(node *Node,
 	emit_str = indent + EMIT_INDENT + ng_open_tag + "\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, node.GetNest(), err)
 	}
 
 	// Emit "y:ShapeNode" open tag
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 2) + "<y:ShapeNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, node.GetNest(), err)
 	}
 
 	// Emit label of a regular node
@@ -776,18 +1217,14 @@ func emitYFilesRegularNode(node *Node,
 
 	if node_label_attr != nil {
 		if is_set, err := node.IsStrAttrSet(node_label_attr); err != nil {
-			err_msg := fmt.Sprintf("Error checking whether a node label attribute is "+
-				"set [node ID = %d]: ", node.GetID())
-
-			return errors.New(err_msg + err.Error())
+			return newEmitError(EMIT_FORMAT_YFILES, node.GetNest(), node, nil, "Error "+
+				"checking whether a node label attribute is set", err)
 		} else if is_set {
 			node_label, err = node.GetStrAttrVal(node_label_attr)
 
 			if err != nil {
-				err_msg := fmt.Sprintf("Error retrieving a node label attribute "+
-					"[node ID = %d]: ", node.GetID())
-
-				return errors.New(err_msg + err.Error())
+				return newEmitError(EMIT_FORMAT_YFILES, node.GetNest(), node, nil,
+					"Error retrieving a node label attribute", err)
 			}
 
 			is_emit_label = true
@@ -796,15 +1233,11 @@ func emitYFilesRegularNode(node *Node,
 
 	// Emit the label (if any)
 	if is_emit_label {
-		// Escape the label for XML
-		var buf bytes.Buffer
-
-		xml.Escape(&buf, []byte(node_label))
 		emit_str = indent + strings.Repeat(EMIT_INDENT, 3) + "<y:NodeLabel>" +
-			buf.String() + "</y:NodeLabel>\n"
+			emitEscapeXML(node_label) + "</y:NodeLabel>\n"
 
 		if _, err := out_file.WriteString(emit_str); err != nil {
-			return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+			return newEmitWriteError(EMIT_FORMAT_YFILES, node.GetNest(), err)
 		}
 	}
 
@@ -812,17 +1245,17 @@ func emitYFilesRegularNode(node *Node,
 	emit_str = indent + strings.Repeat(EMIT_INDENT, 2) + "</y:ShapeNode>\n"
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, node.GetNest(), err)
 	}
 
 	// Emit close tag for "nodegraphics" attribute
 	if _, err := out_file.WriteString(indent + EMIT_INDENT + "</data>\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, node.GetNest(), err)
 	}
 
 	// Emit node close tag
 	if _, err := out_file.WriteString(indent + "</node>\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, node.GetNest(), err)
 	}
 
 	return nil
@@ -876,8 +1309,10 @@ func emitCalcYFilesNodeDocumentId(node *Node) (string, error) {
 	return node_doc_id, nil
 }
 
-// Emit an yFiles edge
+// Emit an yFiles edge. If the edge represents several collapsed parallel edges (i.e.
+// "multiplicity" is greater than one), the edge is labeled with their number
 func emitYFilesEdge(edge *Edge,
+	multiplicity int,
 	id_prefix string,
 	out_file *os.File,
 	indent string) error {
@@ -885,8 +1320,8 @@ func emitYFilesEdge(edge *Edge,
 	panic_msg_str := "Panic while emitting an yFiles edge: "
 
 	if edge.GetSrcNode() == nil || edge.GetDstNode() == nil {
-		return errors.New("At least one end of the edge is not connected to any " +
-			"graph node")
+		return newEmitError(EMIT_FORMAT_YFILES, edge.nest, nil, edge, "At least one end "+
+			"of the edge is not connected to any graph node", ErrInconsistent)
 	}
 
 	src_node := edge.GetSrcNode()
@@ -902,13 +1337,13 @@ func emitYFilesEdge(edge *Edge,
 	var err error
 
 	if src_node_doc_id, err = emitCalcYFilesNodeDocumentId(src_node); err != nil {
-		return errors.New("Couldn't calculate the source node's yFiles GraphML " +
-			"document id")
+		return newEmitError(EMIT_FORMAT_YFILES, edge.nest, src_node, edge, "Couldn't "+
+			"calculate the source node's yFiles GraphML document id", err)
 	}
 
 	if dst_node_doc_id, err = emitCalcYFilesNodeDocumentId(dst_node); err != nil {
-		return errors.New("Couldn't calculate the destination node's yFiles GraphML " +
-			"document id")
+		return newEmitError(EMIT_FORMAT_YFILES, edge.nest, dst_node, edge, "Couldn't "+
+			"calculate the destination node's yFiles GraphML document id", err)
 	}
 
 	// Emit edge open tag
@@ -916,12 +1351,28 @@ func emitYFilesEdge(edge *Edge,
 		id_prefix, edge.GetID(), src_node_doc_id, dst_node_doc_id)
 
 	if _, err := out_file.WriteString(emit_str); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, edge.nest, err)
+	}
+
+	// Emit the multiplicity label (if needed) by means of "edgegraphics" attribute
+	if multiplicity > 1 {
+		eg_attr := yFilesGMLAttrs[yFILES_EATTR_EDGEGRAPHICS]
+		emit_str = fmt.Sprintf(indent+EMIT_INDENT+"<data key=\"d%d\">\n",
+			getYFilesAttrDocumentId(eg_attr.id)) +
+			indent + strings.Repeat(EMIT_INDENT, 2) + "<y:PolyLineEdge>\n" +
+			fmt.Sprintf(indent+strings.Repeat(EMIT_INDENT, 3)+
+				"<y:EdgeLabel>%d</y:EdgeLabel>\n", multiplicity) +
+			indent + strings.Repeat(EMIT_INDENT, 2) + "</y:PolyLineEdge>\n" +
+			indent + EMIT_INDENT + "</data>\n"
+
+		if _, err := out_file.WriteString(emit_str); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_YFILES, edge.nest, err)
+		}
 	}
 
 	// Emit edge close tag
 	if _, err := out_file.WriteString(indent + "</edge>\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, edge.nest, err)
 	}
 
 	return nil
@@ -953,21 +1404,32 @@ func emitYFilesSubgraphNodesAndEdges(nest *Nest,
 		err := emitYFilesRegularNode(node, id_prefix, graph_emit_spec, out_file, indent)
 
 		if err != nil {
-			return errors.New("Error emitting an yFiles regular node: " + err.Error())
+			return wrapEmitError(EMIT_FORMAT_YFILES, nest, "Error emitting an yFiles "+
+				"regular node", err)
 		}
 	}
 
 	// Emit graph edges belonging to the nest
+	var edges []*Edge
+
 	for edge := nest.GetFirstEdge(); edge != nil; edge = edge.GetNextEdgeInNest() {
 		if edge.GetGraph() != graph {
 			panic(panic_msg_str + "an edge belonging to a nest representing the " +
 				"subgraph is attributed to a different graph than the nest itself")
 		}
 
-		err := emitYFilesEdge(edge, id_prefix, out_file, indent)
+		edges = append(edges, edge)
+	}
+
+	groups := groupParallelEdges(edges, graph_emit_spec.Edge.CollapseParallel,
+		graph.IsDirected(), emitEdgeEnds)
+
+	for _, group := range groups {
+		err := emitYFilesEdge(group.edge, group.count, id_prefix, out_file, indent)
 
 		if err != nil {
-			return errors.New("Error emitting an yFiles edge: " + err.Error())
+			return wrapEmitError(EMIT_FORMAT_YFILES, nest, "Error emitting an yFiles "+
+				"edge", err)
 		}
 	}
 
@@ -1006,10 +1468,17 @@ func emitYFilesSubgraph(nest *Nest,
 	}
 
 	// Emit "graph" open tag
-	graph_open_tag := fmt.Sprintf("<graph id=\"%s\" edgedefault=\"directed\">", graph_id)
+	edge_default := "directed"
+
+	if !nest.GetNestTree().GetBaseGraph().IsDirected() {
+		edge_default = "undirected"
+	}
+
+	graph_open_tag := fmt.Sprintf("<graph id=\"%s\" edgedefault=\"%s\">", graph_id,
+		edge_default)
 
 	if _, err := out_file.WriteString(indent + graph_open_tag + "\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	// Emit contained node groups first. After that nodes and edges of the current
@@ -1025,12 +1494,11 @@ func emitYFilesSubgraph(nest *Nest,
 		err := emitYFilesGroup(child_nest, graph_emit_spec, out_file, &new_id_prefix,
 			indent+EMIT_INDENT)
 
-		// Because of the recursive call in this loop, the prefix of the below error
-		// message may be repeated multiple times. It's considered ok for now. Because
-		// later, for example, an ID of each intermediate nest could be added to the
-		// message (hence, the chain of the exact nests would be reported)
+		// The error returned by the recursive call already carries the path to the exact
+		// nest that couldn't be emitted. So, it's propagated as is
 		if err != nil {
-			return errors.New("Couldn't emit a nested node group: " + err.Error())
+			return wrapEmitError(EMIT_FORMAT_YFILES, child_nest, "Couldn't emit a "+
+				"nested node group", err)
 		}
 	}
 
@@ -1038,13 +1506,13 @@ func emitYFilesSubgraph(nest *Nest,
 		indent+EMIT_INDENT)
 
 	if err != nil {
-		return errors.New("Error while emitting nodes and edges of an yFiles subgraph: " +
-			err.Error())
+		return wrapEmitError(EMIT_FORMAT_YFILES, nest, "Error while emitting nodes and "+
+			"edges of an yFiles subgraph", err)
 	}
 
 	// Emit "graph" close tag
 	if _, err := out_file.WriteString(indent + "</graph>\n"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nest, err)
 	}
 
 	return nil
@@ -1052,10 +1520,17 @@ func emitYFilesSubgraph(nest *Nest,
 
 func EmitInYFilesFormat(graph *Graph,
 	graph_emit_spec *GraphEmitSpec,
-	out_path string) error {
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInYFilesFormat", &err)
 
 	panic_msg_prefix := "Panic while emitting a graph in yFiles format: "
 
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_YFILES, nil, nil, nil, "Zero reference to the "+
+			"graph", ErrNilGraph)
+	}
+
 	if err := checkYFilesAttrArrayConsistency(); err != nil {
 		panic(panic_msg_prefix + "consistency check on an array describing yFiles " +
 			"GraphML attributes has failed: " + err.Error())
@@ -1064,7 +1539,8 @@ func EmitInYFilesFormat(graph *Graph,
 	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 
 	if err != nil {
-		return errors.New("Cannot create output file: " + err.Error())
+		return newEmitError(EMIT_FORMAT_YFILES, nil, nil, nil, "Cannot open the output "+
+			"path", fmt.Errorf("%w: %w", ErrEmitCreate, err))
 	}
 
 	defer out_file.Close()
@@ -1077,13 +1553,12 @@ func EmitInYFilesFormat(graph *Graph,
 		graph_emit_spec = &GraphEmitSpec{}
 	}
 
-	EMIT_WRITE_ERR_MSG_PREFIX := "Cannot write to the output file: "
 	// Emit "xml" clause
 	_, err = out_file.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" " +
 		"standalone=\"no\"?>\n")
 
 	if err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nil, err)
 	}
 
 	// Emit "graphml" open tag
@@ -1097,39 +1572,46 @@ func EmitInYFilesFormat(graph *Graph,
 		"http://www.yworks.com/xml/schema/graphml/1.1/ygraphml.xsd\">\n")
 
 	if err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nil, err)
 	}
 
 	// Emit declarations of YFiles GraphML attributes
 	if err := emitYFilesAttrDecls(out_file, EMIT_INDENT); err != nil {
-		return errors.New("Error while emitting yFiles GraphML attribute declarations: " +
-			err.Error())
+		return wrapEmitError(EMIT_FORMAT_YFILES, nil, "Error while emitting yFiles "+
+			"GraphML attribute declarations", err)
 	}
 
 	// Obtain a reference to the root nest
 	if graph.GetNestTree() == nil {
-		panic(panic_msg_prefix + "the graph doesn't have a nest tree")
+		return newEmitError(EMIT_FORMAT_YFILES, nil, nil, nil, "The graph doesn't have "+
+			"a nest tree", ErrNoNestTree)
 	}
 
 	root_nest := graph.GetNestTree().GetRootNest()
 
 	if root_nest == nil {
-		panic(panic_msg_prefix + "the graph doesn't have a root nest")
+		return newEmitError(EMIT_FORMAT_YFILES, nil, nil, nil, "The graph doesn't have "+
+			"a root nest", ErrNoNestTree)
 	}
 
 	// Emit the entire graph
 	err = emitYFilesSubgraph(root_nest, graph_emit_spec, out_file, nil, EMIT_INDENT)
 
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_YFILES, root_nest, "Couldn't emit the graph",
+			err)
+	}
+
 	// Emit "graphml" close tag
 	if _, err := out_file.WriteString("</graphml>"); err != nil {
-		return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+		return newEmitWriteError(EMIT_FORMAT_YFILES, nil, err)
 	}
 
 	/*
 		// Emit Graph global properties
 		// Drawing orientation property: left to right
 		if _, err := out_file.WriteString("\trankdir = LR\n"); err != nil {
-			return errors.New(EMIT_WRITE_ERR_MSG_PREFIX + err.Error())
+			return newEmitWriteError(EMIT_FORMAT_YFILES, nil, err)
 		}
 
 		err = emitGVSubgraphNodesAndEdges(root_nest, graph_emit_spec, out_file, EMIT_INDENT)
@@ -0,0 +1,232 @@
+/*
+  Emit graph in Cytoscape.js JSON format
+
+  The document has the same structure as the one produced by "cy.json()", so it can be
+  loaded by "cytoscape({elements: doc.elements, ...})" or by "cy.json(doc)":
+
+	{
+	  "data": {...},
+	  "elements": {
+	    "nodes": [{"data": {"id": "n0", "parent": "nest1", ...}}, ...],
+	    "edges": [{"data": {"id": "e0", "source": "n0", "target": "n1", ...}}, ...]
+	  }
+	}
+
+  Nests are represented by compound nodes. Their "data" has a "nest" field equal to
+  "true", so that empty nests can be told apart from graph nodes. Graph nodes and nests
+  refer to the nests they belong to by the "parent" field. Elements that belong to the
+  root nest have no parent. The root nest itself is not emitted. Graph nodes, nests and
+  edges get IDs "n<node ID>", "nest<nest ID>" and "e<edge ID>" respectively
+
+  All set values of string attributes are placed into "data" under names
+  "graph_attr_<attribute number>", "node_attr_<attribute number>" and
+  "nest_attr_<attribute number>". Labels defined by the emit specification are placed
+  under name "label". If parallel edges are collapsed, every emitted edge gets a "weight"
+  field equal to the number of edges it represents. The graph "data" has a "directed"
+  field telling whether the graph is directed
+*/
+
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Element (node or edge) of a Cytoscape.js document
+type cytoscapeElem struct {
+	Data map[string]any `json:"data"`
+}
+
+// Cytoscape.js document
+type cytoscapeDoc struct {
+	Data     map[string]any `json:"data"`
+	Elements struct {
+		Nodes []cytoscapeElem `json:"nodes"`
+		Edges []cytoscapeElem `json:"edges"`
+	} `json:"elements"`
+}
+
+// Put values of string attributes into "data" of a Cytoscape.js element. "attrs" are the
+// string attributes of the element, "name_prefix" is the common prefix of their names
+func emitCytoscapeStrAttrs(data map[string]any, attrs []strAttrVal, name_prefix string) {
+	for attr_num, attr := range attrs {
+		if attr.isSet {
+			data[fmt.Sprintf("%s%d", name_prefix, attr_num)] = attr.data
+		}
+	}
+}
+
+// Add Cytoscape.js elements representing a nest, the graph nodes belonging to it and the
+// graph edges belonging to it
+func emitCytoscapeNest(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	doc *cytoscapeDoc) error {
+
+	graph, err := emitNestGraph(EMIT_FORMAT_CYTOSCAPE, nest)
+
+	if err != nil {
+		return err
+	}
+
+	// Add the compound node representing the nest
+	if nest.GetParentNest() != nil {
+		data := map[string]any{"id": fmt.Sprintf("nest%d", nest.GetID()), "nest": true}
+		nest_label, has_label, err := emitNestLabel(EMIT_FORMAT_CYTOSCAPE, nest,
+			graph_emit_spec.Nest.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		if has_label {
+			data["label"] = nest_label
+		}
+
+		if parent := nest.GetParentNest(); parent.GetParentNest() != nil {
+			data["parent"] = fmt.Sprintf("nest%d", parent.GetID())
+		}
+
+		emitCytoscapeStrAttrs(data, nest.strAttrs, "nest_attr_")
+		doc.Elements.Nodes = append(doc.Elements.Nodes, cytoscapeElem{data})
+	}
+
+	// Add graph nodes belonging to the nest
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		data := map[string]any{"id": fmt.Sprintf("n%d", node.GetID())}
+		node_label, has_label, err := emitNodeLabel(EMIT_FORMAT_CYTOSCAPE, node,
+			graph_emit_spec.Node.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		if has_label {
+			data["label"] = node_label
+		}
+
+		if nest.GetParentNest() != nil {
+			data["parent"] = fmt.Sprintf("nest%d", nest.GetID())
+		}
+
+		emitCytoscapeStrAttrs(data, node.strAttrs, "node_attr_")
+		doc.Elements.Nodes = append(doc.Elements.Nodes, cytoscapeElem{data})
+	}
+
+	// Add graph edges belonging to the nest
+	edges, err := emitNestEdges(EMIT_FORMAT_CYTOSCAPE, nest, graph)
+
+	if err != nil {
+		return err
+	}
+
+	collapse := graph_emit_spec.Edge.CollapseParallel
+	groups := groupParallelEdges(edges, collapse, graph.IsDirected(), emitEdgeEnds)
+
+	for _, group := range groups {
+		data := map[string]any{
+			"id":     fmt.Sprintf("e%d", group.edge.GetID()),
+			"source": fmt.Sprintf("n%d", group.edge.GetSrcNode().GetID()),
+			"target": fmt.Sprintf("n%d", group.edge.GetDstNode().GetID()),
+		}
+
+		if collapse {
+			data["weight"] = group.count
+		}
+
+		doc.Elements.Edges = append(doc.Elements.Edges, cytoscapeElem{data})
+	}
+
+	return nil
+}
+
+// Print description of a Graph in Cytoscape.js JSON format
+//
+// Input: full path to the output file (all parent directories should exist)
+func EmitInCytoscapeFormat(graph *Graph, graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInCytoscapeFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_CYTOSCAPE, nil, nil, nil, "Zero reference to "+
+			"the graph", ErrNilGraph)
+	}
+
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	// Build the document
+	doc := &cytoscapeDoc{Data: map[string]any{"directed": graph.IsDirected()}}
+	doc.Elements.Nodes = []cytoscapeElem{}
+	doc.Elements.Edges = []cytoscapeElem{}
+
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_CYTOSCAPE, graph,
+		graph_emit_spec.Graph.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	if has_graph_label {
+		doc.Data["label"] = graph_label
+	}
+
+	emitCytoscapeStrAttrs(doc.Data, graph.strAttrs, "graph_attr_")
+
+	root_nest, err := emitRootNest(EMIT_FORMAT_CYTOSCAPE, graph)
+
+	if err != nil {
+		return err
+	}
+
+	// Nests are visited in pre-order. So, every compound node precedes its children
+	if err := emitCytoscapeNest(root_nest, graph_emit_spec, doc); err != nil {
+		return wrapEmitError(EMIT_FORMAT_CYTOSCAPE, root_nest, "Couldn't emit elements "+
+			"belonging to the root nest", err)
+	}
+
+	for nest := range root_nest.Descendants() {
+		if err := emitCheckChildNest(EMIT_FORMAT_CYTOSCAPE, root_nest, nest); err != nil {
+			return err
+		}
+
+		if err := emitCytoscapeNest(nest, graph_emit_spec, doc); err != nil {
+			return wrapEmitError(EMIT_FORMAT_CYTOSCAPE, nest, "Couldn't emit elements "+
+				"belonging to a nest", err)
+		}
+	}
+
+	// Labels are kept readable: HTML-sensitive characters are not escaped, since the
+	// document is not meant to be embedded into HTML as is
+	var doc_json bytes.Buffer
+
+	encoder := json.NewEncoder(&doc_json)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", EMIT_INDENT)
+
+	if err := encoder.Encode(doc); err != nil {
+		return newEmitError(EMIT_FORMAT_CYTOSCAPE, nil, nil, nil, "Cannot encode the "+
+			"document", fmt.Errorf("%w: %w", ErrEmitWrite, err))
+	}
+
+	// Write the document
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_CYTOSCAPE, nil, nil, nil, "Cannot open the "+
+			"output path", fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	if _, err := out_file.Write(doc_json.Bytes()); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_CYTOSCAPE, nil, err)
+	}
+
+	return nil
+}
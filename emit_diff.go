@@ -0,0 +1,220 @@
+/*
+  Draw differences between two graphs as a Graphviz diagram
+
+  The diagram shows the nodes and edges of the new graph together with the removed ones:
+    - added nodes and edges are green
+    - removed nodes and edges are red and dashed. Removed nodes are drawn in their old
+      nests
+    - moved nodes are blue. Their labels name the old nests
+    - nodes whose attribute values changed are orange. Their labels list the changes
+  Nodes are labeled with their keys. If nest membership was compared, nests are drawn as
+  clusters labeled with the nest labels. The diagram label summarizes the differences
+*/
+
+package graph
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Colors of the diagram elements
+const (
+	DIFF_COLOR_ADDED   = "#2f9e44"
+	DIFF_COLOR_REMOVED = "#e03131"
+	DIFF_COLOR_MOVED   = "#1971c2"
+	DIFF_COLOR_CHANGED = "#f08c00"
+)
+
+// Cluster of the diagram (a nest identified by its label path)
+type diffCluster struct {
+	label    string
+	children []*diffCluster
+	// Indexes of the nodes drawn in the cluster (see "GraphDiff.nodes")
+	nodes []int
+}
+
+// Get a child cluster by label. The cluster is created if it doesn't exist yet
+func (cluster *diffCluster) child(label string) *diffCluster {
+	for _, child := range cluster.children {
+		if child.label == label {
+			return child
+		}
+	}
+
+	child := &diffCluster{label: label}
+	cluster.children = append(cluster.children, child)
+
+	return child
+}
+
+// Quote lines of text as a Graphviz string literal. The lines are separated by Graphviz
+// line breaks
+func diffQuoteGV(lines ...string) string {
+	escaped := make([]string, len(lines))
+
+	for i, line := range lines {
+		escaped[i] = emitEscapeGV(line)
+	}
+
+	return "\"" + strings.Join(escaped, "\\n") + "\""
+}
+
+// Get printable representation of a nest label path
+func diffNestPathStr(path []string) string {
+	return "/" + strings.Join(path, "/")
+}
+
+// Get printable representation of an attribute value
+func diffAttrValStr(val string, is_set bool) string {
+	if !is_set {
+		return "(not set)"
+	}
+
+	return "\"" + val + "\""
+}
+
+// Get a short summary of the differences
+func (diff *GraphDiff) String() string {
+	return fmt.Sprintf("nodes: +%d -%d, edges: +%d -%d, moved nodes: %d, attribute "+
+		"changes: %d", len(diff.AddedNodes), len(diff.RemovedNodes), len(diff.AddedEdges),
+		len(diff.RemovedEdges), len(diff.MovedNodes), len(diff.AttrChanges))
+}
+
+// Get the Graphviz statement declaring a node of the diagram
+func (diff *GraphDiff) gvNode(idx int) string {
+	node := &diff.nodes[idx]
+	lines := []string{node.key}
+	color := ""
+
+	switch {
+	case node.status == DIFF_STATUS_ADDED:
+		color = DIFF_COLOR_ADDED
+	case node.status == DIFF_STATUS_REMOVED:
+		color = DIFF_COLOR_REMOVED
+	case node.oldNestPath != nil:
+		color = DIFF_COLOR_MOVED
+	case len(node.changes) > 0:
+		color = DIFF_COLOR_CHANGED
+	}
+
+	if node.oldNestPath != nil {
+		lines = append(lines, "moved from "+diffNestPathStr(node.oldNestPath))
+	}
+
+	for _, change := range node.changes {
+		lines = append(lines, change.Attr+": "+diffAttrValStr(change.OldVal,
+			change.OldIsSet)+" -> "+diffAttrValStr(change.NewVal, change.NewIsSet))
+	}
+
+	stmt := fmt.Sprintf("n%d [label=%s", idx, diffQuoteGV(lines...))
+
+	if color != "" {
+		stmt += ", color=\"" + color + "\", fontcolor=\"" + color + "\", penwidth=2"
+	}
+
+	if node.status == DIFF_STATUS_REMOVED {
+		stmt += ", style=dashed"
+	}
+
+	return stmt + "];\n"
+}
+
+// Write the Graphviz description of a cluster (with its nodes and child clusters)
+func (diff *GraphDiff) gvCluster(cluster *diffCluster,
+	cluster_num *int,
+	indent string,
+	out *strings.Builder) {
+
+	out.WriteString(fmt.Sprintf("%ssubgraph cluster_%d {\n", indent, *cluster_num))
+	out.WriteString(indent + EMIT_INDENT + "label=" + diffQuoteGV(cluster.label) + ";\n")
+	*cluster_num++
+
+	for _, child := range cluster.children {
+		diff.gvCluster(child, cluster_num, indent+EMIT_INDENT, out)
+	}
+
+	for _, idx := range cluster.nodes {
+		out.WriteString(indent + EMIT_INDENT + diff.gvNode(idx))
+	}
+
+	out.WriteString(indent + "}\n")
+}
+
+// Draw the differences as a Graphviz diagram (see the description of the file)
+//
+// Input: full path to the output file (all parent directories should exist)
+func (diff *GraphDiff) EmitInGVFormat(out_path string) (err error) {
+	defer recoverInternalError("GraphDiff.EmitInGVFormat", &err)
+
+	if diff == nil {
+		return newEmitError(EMIT_FORMAT_GV, nil, nil, nil, "Zero reference to the "+
+			"differences", ErrNilGraph)
+	}
+
+	graph_keyword, edge_op := emitGVGraphSyntax(diff.directed)
+	var out strings.Builder
+
+	out.WriteString(graph_keyword + " \"Differences\" {\n")
+	out.WriteString(EMIT_INDENT + "rankdir = LR\n")
+	out.WriteString(EMIT_INDENT + "label = " + diffQuoteGV(diff.String()) + "\n")
+	out.WriteString(EMIT_INDENT + "node [shape=box];\n")
+
+	// Place the nodes into clusters. Nodes of the root nest are placed into the root
+	// cluster, which is not drawn
+	root_cluster := &diffCluster{}
+	node_idxs := make(map[string]int, len(diff.nodes))
+
+	for idx, node := range diff.nodes {
+		cluster := root_cluster
+
+		for _, label := range node.nestPath {
+			cluster = cluster.child(label)
+		}
+
+		cluster.nodes = append(cluster.nodes, idx)
+		node_idxs[node.key] = idx
+	}
+
+	cluster_num := 0
+
+	for _, cluster := range root_cluster.children {
+		diff.gvCluster(cluster, &cluster_num, EMIT_INDENT, &out)
+	}
+
+	for _, idx := range root_cluster.nodes {
+		out.WriteString(EMIT_INDENT + diff.gvNode(idx))
+	}
+
+	for _, edge := range diff.edges {
+		stmt := fmt.Sprintf("n%d %s n%d", node_idxs[edge.srcKey], edge_op,
+			node_idxs[edge.dstKey])
+
+		switch edge.status {
+		case DIFF_STATUS_ADDED:
+			stmt += " [color=\"" + DIFF_COLOR_ADDED + "\", penwidth=2]"
+		case DIFF_STATUS_REMOVED:
+			stmt += " [color=\"" + DIFF_COLOR_REMOVED + "\", style=dashed]"
+		}
+
+		out.WriteString(EMIT_INDENT + stmt + ";\n")
+	}
+
+	out.WriteString("}\n")
+
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_GV, nil, nil, nil, "Cannot open the output path",
+			fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	if _, err := out_file.WriteString(out.String()); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GV, nil, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+/*
+  Emit graph in GEXF format
+
+  GEXF is the native format of Gephi. Nests are represented by GEXF nodes as well. The
+  hierarchy is described by the "pid" (parent ID) attribute: graph nodes and nests refer
+  to the nests they belong to. Elements that belong to the root nest have no parent. The
+  root nest itself is not emitted
+
+  Graph nodes keep their IDs. Nests get IDs "nest_<nest ID>". String attributes of nodes
+  and nests are declared as GEXF node attributes named "node_attr_<attribute number>" and
+  "nest_attr_<attribute number>"
+
+  If parallel edges are collapsed, every emitted edge gets a weight equal to the number of
+  edges it represents
+*/
+
+package graph
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Describe a GEXF node (representing either a graph node or a nest)
+func emitGEXFNode(id string, label string, has_label bool, parent *Nest,
+	attr_vals []emitStrAttrVal, indent string) string {
+
+	var node_desc strings.Builder
+
+	node_desc.WriteString(indent + "<node id=\"" + id + "\"")
+
+	if has_label {
+		node_desc.WriteString(" label=\"" + emitEscapeXML(label) + "\"")
+	}
+
+	if parent != nil && parent.GetParentNest() != nil {
+		node_desc.WriteString(fmt.Sprintf(" pid=\"nest_%d\"", parent.GetID()))
+	}
+
+	if len(attr_vals) == 0 {
+		node_desc.WriteString("/>\n")
+
+		return node_desc.String()
+	}
+
+	node_desc.WriteString(">\n" + indent + EMIT_INDENT + "<attvalues>\n")
+
+	for _, attr_val := range attr_vals {
+		node_desc.WriteString(fmt.Sprintf(indent+strings.Repeat(EMIT_INDENT, 2)+
+			"<attvalue for=\"%d\" value=\"%s\"/>\n", attr_val.docID,
+			emitEscapeXML(attr_val.val)))
+	}
+
+	node_desc.WriteString(indent + EMIT_INDENT + "</attvalues>\n" + indent + "</node>\n")
+
+	return node_desc.String()
+}
+
+// Emit GEXF nodes representing a nest and the graph nodes belonging to it
+func emitGEXFNestNodes(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	key_map *emitAttrKeyMap,
+	out_file *os.File,
+	indent string) error {
+
+	if nest.GetParentNest() != nil {
+		nest_label, has_label, err := emitNestLabel(EMIT_FORMAT_GEXF, nest,
+			graph_emit_spec.Nest.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		nest_desc := emitGEXFNode(fmt.Sprintf("nest_%d", nest.GetID()), nest_label,
+			has_label, nest.GetParentNest(), emitNestStrAttrVals(key_map, nest), indent)
+
+		if _, err := out_file.WriteString(nest_desc); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GEXF, nest, err)
+		}
+	}
+
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		node_label, has_label, err := emitNodeLabel(EMIT_FORMAT_GEXF, node,
+			graph_emit_spec.Node.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		node_desc := emitGEXFNode(fmt.Sprintf("%d", node.GetID()), node_label, has_label,
+			nest, emitNodeStrAttrVals(key_map, node), indent)
+
+		if _, err := out_file.WriteString(node_desc); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GEXF, nest, err)
+		}
+	}
+
+	return nil
+}
+
+// Emit GEXF edges representing graph edges belonging to a nest
+func emitGEXFNestEdges(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	graph, err := emitNestGraph(EMIT_FORMAT_GEXF, nest)
+
+	if err != nil {
+		return err
+	}
+
+	edges, err := emitNestEdges(EMIT_FORMAT_GEXF, nest, graph)
+
+	if err != nil {
+		return err
+	}
+
+	collapse := graph_emit_spec.Edge.CollapseParallel
+	groups := groupParallelEdges(edges, collapse, graph.IsDirected(), emitEdgeEnds)
+
+	for _, group := range groups {
+		edge_desc := fmt.Sprintf(indent+"<edge id=\"%d\" source=\"%d\" target=\"%d\"",
+			group.edge.GetID(), group.edge.GetSrcNode().GetID(),
+			group.edge.GetDstNode().GetID())
+
+		if collapse {
+			edge_desc += fmt.Sprintf(" weight=\"%d\"", group.count)
+		}
+
+		if _, err := out_file.WriteString(edge_desc + "/>\n"); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GEXF, nest, err)
+		}
+	}
+
+	return nil
+}
+
+// Emit declarations of GEXF node attributes
+func emitGEXFAttrDecls(graph *Graph,
+	key_map *emitAttrKeyMap,
+	out_file *os.File,
+	indent string) error {
+
+	if len(key_map.used) == 0 {
+		return nil
+	}
+
+	decls := indent + "<attributes class=\"node\">\n"
+
+	for doc_id, attr_id := range key_map.used {
+		decls += fmt.Sprintf(indent+EMIT_INDENT+"<attribute id=\"%d\" title=\"%s\" "+
+			"type=\"string\"/>\n", doc_id, emitStrAttrName(graph, attr_id))
+	}
+
+	decls += indent + "</attributes>\n"
+
+	if _, err := out_file.WriteString(decls); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GEXF, nil, err)
+	}
+
+	return nil
+}
+
+// Print description of a Graph in GEXF format. The description can be opened by Gephi or
+// read by NetworkX ("networkx.read_gexf()"). The graph label (if any) is emitted as the
+// description of the document
+//
+// Input: full path to the output file (all parent directories should exist)
+func EmitInGEXFFormat(graph *Graph, graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInGEXFFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_GEXF, nil, nil, nil, "Zero reference to the "+
+			"graph", ErrNilGraph)
+	}
+
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_GEXF, nil, nil, nil, "Cannot open the output "+
+			"path", fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_GEXF, graph,
+		graph_emit_spec.Graph.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	root_nest, err := emitRootNest(EMIT_FORMAT_GEXF, graph)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit document header
+	header := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+		"<gexf xmlns=\"http://www.gexf.net/1.2draft\" version=\"1.2\">\n"
+
+	if has_graph_label {
+		header += EMIT_INDENT + "<meta>\n" + strings.Repeat(EMIT_INDENT, 2) +
+			"<description>" + emitEscapeXML(graph_label) + "</description>\n" +
+			EMIT_INDENT + "</meta>\n"
+	}
+
+	edge_type := "directed"
+
+	if !graph.IsDirected() {
+		edge_type = "undirected"
+	}
+
+	header += EMIT_INDENT + "<graph mode=\"static\" defaultedgetype=\"" + edge_type +
+		"\">\n"
+
+	if _, err := out_file.WriteString(header); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GEXF, nil, err)
+	}
+
+	// Emit declarations of node attributes
+	key_map := emitStrAttrKeyMap(graph)
+	indent := strings.Repeat(EMIT_INDENT, 2)
+
+	if err := emitGEXFAttrDecls(graph, key_map, out_file, indent); err != nil {
+		return err
+	}
+
+	// Emit nodes. Nests are visited in pre-order. So, every nest is emitted before the
+	// elements that refer to it as a parent
+	nests := []*Nest{root_nest}
+
+	for nest := range root_nest.Descendants() {
+		if err := emitCheckChildNest(EMIT_FORMAT_GEXF, root_nest, nest); err != nil {
+			return err
+		}
+
+		nests = append(nests, nest)
+	}
+
+	if _, err := out_file.WriteString(indent + "<nodes>\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GEXF, nil, err)
+	}
+
+	for _, nest := range nests {
+		err := emitGEXFNestNodes(nest, graph_emit_spec, key_map, out_file,
+			indent+EMIT_INDENT)
+
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_GEXF, nest, "Couldn't emit nodes belonging "+
+				"to a nest", err)
+		}
+	}
+
+	// Emit edges
+	_, err = out_file.WriteString(indent + "</nodes>\n" + indent + "<edges>\n")
+
+	if err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GEXF, nil, err)
+	}
+
+	for _, nest := range nests {
+		err := emitGEXFNestEdges(nest, graph_emit_spec, out_file, indent+EMIT_INDENT)
+
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_GEXF, nest, "Couldn't emit edges belonging "+
+				"to a nest", err)
+		}
+	}
+
+	// Emit document footer
+	footer := indent + "</edges>\n" + EMIT_INDENT + "</graph>\n</gexf>\n"
+
+	if _, err := out_file.WriteString(footer); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GEXF, nil, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package graph
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A nested graph is emitted as a GEXF document with nests as parent nodes. Labels are
+// escaped
+func TestEmitInGEXFFormat(t *testing.T) {
+	graph, spec := newEmitTestGraph(t)
+	out_path := filepath.Join(t.TempDir(), "graph.gexf")
+
+	if err := EmitInGEXFFormat(graph, spec, out_path); err != nil {
+		t.Fatalf("EmitInGEXFFormat: %v", err)
+	}
+
+	expectGoldenFile(t, out_path, "graph.gexf")
+}
@@ -0,0 +1,271 @@
+/*
+  Emit graph in GML format
+
+  GML is supported by NetworkX, igraph, yEd and many other tools. Nests are represented
+  by GML nodes as well. Following the convention used by yEd, such nodes are marked by
+  "isGroup 1", and every node that belongs to a nest (other than the root nest) refers to
+  it by the "gid" key. The root nest itself is not emitted
+
+  GML requires node IDs to be integers. Graph nodes keep their IDs. Nests get IDs that
+  follow the IDs of all graph nodes ever created: "<number of graph nodes> + <nest ID>".
+  String attributes of nodes and nests are emitted as node keys named
+  "node_attr_<attribute number>" and "nest_attr_<attribute number>"
+
+  If parallel edges are collapsed, every emitted edge gets a weight equal to the number of
+  edges it represents. Otherwise, the graph is marked by "multigraph 1", since it may
+  contain parallel edges
+*/
+
+package graph
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Escape a string so that it can be placed inside a double-quoted GML string. GML strings
+// cannot contain double quotes and are expected to be ASCII. Such characters (as well as
+// ampersands) are replaced by character references
+func escapeGMLString(str string) string {
+	var escaped strings.Builder
+
+	for _, char := range str {
+		switch {
+		case char == '&':
+			escaped.WriteString("&amp;")
+		case char == '"':
+			escaped.WriteString("&quot;")
+		case char > '~' || (char < ' ' && char != '\n' && char != '\t'):
+			escaped.WriteString(fmt.Sprintf("&#%d;", char))
+		default:
+			escaped.WriteRune(char)
+		}
+	}
+
+	return escaped.String()
+}
+
+// Get GML ID of a nest
+func emitGMLNestID(nest *Nest) int {
+	return nest.GetNestTree().GetBaseGraph().nodeCount + nest.GetID()
+}
+
+// Describe a GML node (representing either a graph node or a nest)
+func emitGMLNode(id int, label string, has_label bool, is_group bool, parent *Nest,
+	attr_vals []emitStrAttrVal, attr_names []string, indent string) string {
+
+	key_indent := indent + EMIT_INDENT
+	node_desc := fmt.Sprintf(indent+"node [\n"+key_indent+"id %d\n", id)
+
+	if has_label {
+		node_desc += key_indent + "label \"" + escapeGMLString(label) + "\"\n"
+	}
+
+	if is_group {
+		node_desc += key_indent + "isGroup 1\n"
+	}
+
+	if parent != nil && parent.GetParentNest() != nil {
+		node_desc += fmt.Sprintf(key_indent+"gid %d\n", emitGMLNestID(parent))
+	}
+
+	for _, attr_val := range attr_vals {
+		node_desc += key_indent + attr_names[attr_val.docID] + " \"" +
+			escapeGMLString(attr_val.val) + "\"\n"
+	}
+
+	return node_desc + indent + "]\n"
+}
+
+// Emit GML nodes representing a nest and the graph nodes belonging to it
+func emitGMLNestNodes(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	key_map *emitAttrKeyMap,
+	attr_names []string,
+	out_file *os.File,
+	indent string) error {
+
+	if nest.GetParentNest() != nil {
+		nest_label, has_label, err := emitNestLabel(EMIT_FORMAT_GML, nest,
+			graph_emit_spec.Nest.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		nest_desc := emitGMLNode(emitGMLNestID(nest), nest_label, has_label, true,
+			nest.GetParentNest(), emitNestStrAttrVals(key_map, nest), attr_names, indent)
+
+		if _, err := out_file.WriteString(nest_desc); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GML, nest, err)
+		}
+	}
+
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		node_label, has_label, err := emitNodeLabel(EMIT_FORMAT_GML, node,
+			graph_emit_spec.Node.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		node_desc := emitGMLNode(node.GetID(), node_label, has_label, false, nest,
+			emitNodeStrAttrVals(key_map, node), attr_names, indent)
+
+		if _, err := out_file.WriteString(node_desc); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GML, nest, err)
+		}
+	}
+
+	return nil
+}
+
+// Emit GML edges representing graph edges belonging to a nest
+func emitGMLNestEdges(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	graph, err := emitNestGraph(EMIT_FORMAT_GML, nest)
+
+	if err != nil {
+		return err
+	}
+
+	edges, err := emitNestEdges(EMIT_FORMAT_GML, nest, graph)
+
+	if err != nil {
+		return err
+	}
+
+	collapse := graph_emit_spec.Edge.CollapseParallel
+	groups := groupParallelEdges(edges, collapse, graph.IsDirected(), emitEdgeEnds)
+	key_indent := indent + EMIT_INDENT
+
+	for _, group := range groups {
+		edge_desc := fmt.Sprintf(indent+"edge [\n"+key_indent+"id %d\n"+key_indent+
+			"source %d\n"+key_indent+"target %d\n", group.edge.GetID(),
+			group.edge.GetSrcNode().GetID(), group.edge.GetDstNode().GetID())
+
+		if collapse {
+			edge_desc += fmt.Sprintf(key_indent+"weight %d\n", group.count)
+		}
+
+		if _, err := out_file.WriteString(edge_desc + indent + "]\n"); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GML, nest, err)
+		}
+	}
+
+	return nil
+}
+
+// Print description of a Graph in GML format. The description can be read by NetworkX
+// ("networkx.read_gml(path, label='id')"), igraph, yEd and other tools
+//
+// Input: full path to the output file (all parent directories should exist)
+func EmitInGMLFormat(graph *Graph, graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInGMLFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_GML, nil, nil, nil, "Zero reference to the graph",
+			ErrNilGraph)
+	}
+
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_GML, nil, nil, nil, "Cannot open the output path",
+			fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_GML, graph,
+		graph_emit_spec.Graph.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	root_nest, err := emitRootNest(EMIT_FORMAT_GML, graph)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit graph header and global properties
+	header := "graph [\n"
+
+	if graph.IsDirected() {
+		header += EMIT_INDENT + "directed 1\n"
+	} else {
+		header += EMIT_INDENT + "directed 0\n"
+	}
+
+	if !graph_emit_spec.Edge.CollapseParallel {
+		header += EMIT_INDENT + "multigraph 1\n"
+	}
+
+	if has_graph_label {
+		header += EMIT_INDENT + "label \"" + escapeGMLString(graph_label) + "\"\n"
+	}
+
+	if _, err := out_file.WriteString(header); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GML, nil, err)
+	}
+
+	// Names of the string attributes indexed by their document IDs
+	key_map := emitStrAttrKeyMap(graph)
+	attr_names := make([]string, len(key_map.used))
+
+	for doc_id, attr_id := range key_map.used {
+		attr_names[doc_id] = emitStrAttrName(graph, attr_id)
+	}
+
+	// Emit nodes. Nests are visited in pre-order. So, every nest is emitted before the
+	// elements that refer to it as a group
+	nests := []*Nest{root_nest}
+
+	for nest := range root_nest.Descendants() {
+		if err := emitCheckChildNest(EMIT_FORMAT_GML, root_nest, nest); err != nil {
+			return err
+		}
+
+		nests = append(nests, nest)
+	}
+
+	for _, nest := range nests {
+		err := emitGMLNestNodes(nest, graph_emit_spec, key_map, attr_names, out_file,
+			EMIT_INDENT)
+
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_GML, nest, "Couldn't emit nodes belonging "+
+				"to a nest", err)
+		}
+	}
+
+	// Emit edges
+	for _, nest := range nests {
+		err := emitGMLNestEdges(nest, graph_emit_spec, out_file, EMIT_INDENT)
+
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_GML, nest, "Couldn't emit edges belonging "+
+				"to a nest", err)
+		}
+	}
+
+	// Emit graph closing bracket
+	if _, err := out_file.WriteString("]\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GML, nil, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package graph
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A nested graph is emitted as a GML document with nests as group nodes. Labels are
+// escaped
+func TestEmitInGMLFormat(t *testing.T) {
+	graph, spec := newEmitTestGraph(t)
+	out_path := filepath.Join(t.TempDir(), "graph.gml")
+
+	if err := EmitInGMLFormat(graph, spec, out_path); err != nil {
+		t.Fatalf("EmitInGMLFormat: %v", err)
+	}
+
+	expectGoldenFile(t, out_path, "graph.gml")
+}
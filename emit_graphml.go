@@ -0,0 +1,363 @@
+/*
+  Emit graph in standard GraphML format
+
+  In contrast to "EmitInYFilesFormat()", no GraphML extensions are used. So, the document
+  can be consumed by any GraphML-aware tool (NetworkX, igraph, Gephi, etc.)
+
+  Nests are represented by GraphML nodes that contain nested graphs. Graph nodes, nests,
+  edges and nested graphs get IDs "n<node ID>", "nest<nest ID>", "e<edge ID>" and
+  "nest<nest ID>:" respectively. The graph as a whole has ID "G"
+
+  Every allocated string attribute of the graph, of nodes and of nests is declared as a
+  GraphML attribute named "graph_attr_<attribute number>", "node_attr_<attribute number>"
+  or "nest_attr_<attribute number>". Labels defined by the emit specification are
+  additionally emitted as "label" attributes. If parallel edges are collapsed, every
+  emitted edge gets an integer "weight" attribute equal to the number of edges it
+  represents
+
+  NOTE: edges have no string attributes. So, "weight" is the only edge attribute
+*/
+
+package graph
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Attributes declared in a standard GraphML document. Logical attribute IDs are laid out
+// as follows: graph string attributes, node string attributes, nest string attributes,
+// the graph label, the node label (shared by nodes and nests) and the edge weight
+type gMLDocAttrs struct {
+	// Descriptions of the attributes indexed by their logical IDs
+	attrs []gMLAttr
+	// Mapping of the logical IDs to the document IDs
+	keyMap *emitAttrKeyMap
+	// Logical IDs of the first node string attribute and the first nest string attribute
+	nodeAttrBase int
+	nestAttrBase int
+	// Logical IDs of the label and weight attributes
+	graphLabelID int
+	nodeLabelID  int
+	edgeWeightID int
+}
+
+// Describe attributes that are declared in a standard GraphML document
+func newGMLDocAttrs(graph *Graph, graph_emit_spec *GraphEmitSpec) *gMLDocAttrs {
+	doc_attrs := &gMLDocAttrs{}
+
+	var is_used []bool
+
+	add_attr := func(attr_type int, elem_type int, name string, used bool) int {
+		id := len(doc_attrs.attrs)
+		doc_attrs.attrs = append(doc_attrs.attrs,
+			gMLAttr{id, gML_EXT_FAMILY_STANDARD, attr_type, elem_type, name})
+		is_used = append(is_used, used)
+
+		return id
+	}
+
+	for attr_num, is_alloc := range graph.graphStrAttrAllocMap {
+		add_attr(gML_ATTR_TYPE_STRING, gML_ELEM_GRAPH,
+			fmt.Sprintf("graph_attr_%d", attr_num), is_alloc)
+	}
+
+	doc_attrs.nodeAttrBase = len(doc_attrs.attrs)
+
+	for attr_num, is_alloc := range graph.nodeStrAttrAllocMap {
+		add_attr(gML_ATTR_TYPE_STRING, gML_ELEM_NODE,
+			fmt.Sprintf("node_attr_%d", attr_num), is_alloc)
+	}
+
+	doc_attrs.nestAttrBase = len(doc_attrs.attrs)
+
+	for attr_num, is_alloc := range graph.nestTree.nestStrAttrAllocMap {
+		add_attr(gML_ATTR_TYPE_STRING, gML_ELEM_NODE,
+			fmt.Sprintf("nest_attr_%d", attr_num), is_alloc)
+	}
+
+	doc_attrs.graphLabelID = add_attr(gML_ATTR_TYPE_STRING, gML_ELEM_GRAPH, "label",
+		graph_emit_spec.Graph.LabelAttr != nil)
+	doc_attrs.nodeLabelID = add_attr(gML_ATTR_TYPE_STRING, gML_ELEM_NODE, "label",
+		graph_emit_spec.Node.LabelAttr != nil || graph_emit_spec.Nest.LabelAttr != nil)
+	doc_attrs.edgeWeightID = add_attr(gML_ATTR_TYPE_INT, gML_ELEM_EDGE, "weight",
+		graph_emit_spec.Edge.CollapseParallel)
+	doc_attrs.keyMap = newEmitAttrKeyMap(is_used)
+
+	return doc_attrs
+}
+
+// Describe a GraphML attribute value ("<data>" element)
+func emitGraphMLData(attr_document_id int, val string, indent string) string {
+	return fmt.Sprintf(indent+"<data key=\"d%d\">%s</data>\n", attr_document_id,
+		emitEscapeXML(val))
+}
+
+// Describe GraphML attribute values of an element: the label (if any) and the values of
+// string attributes
+func emitGraphMLElemData(doc_attrs *gMLDocAttrs, label_id int, label string,
+	has_label bool, attr_vals []emitStrAttrVal, indent string) string {
+
+	var data strings.Builder
+
+	if has_label {
+		data.WriteString(emitGraphMLData(doc_attrs.keyMap.docID(label_id), label, indent))
+	}
+
+	for _, attr_val := range attr_vals {
+		data.WriteString(emitGraphMLData(attr_val.docID, attr_val.val, indent))
+	}
+
+	return data.String()
+}
+
+// Emit nodes and edges of a nest in standard GraphML format
+func emitGraphMLNestNodesAndEdges(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	doc_attrs *gMLDocAttrs,
+	out_file *os.File,
+	indent string) error {
+
+	graph, err := emitNestGraph(EMIT_FORMAT_GRAPHML, nest)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit graph nodes belonging to the nest
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		node_label, has_label, err := emitNodeLabel(EMIT_FORMAT_GRAPHML, node,
+			graph_emit_spec.Node.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		attr_vals := emitCollectStrAttrVals(doc_attrs.keyMap, node.strAttrs,
+			doc_attrs.nodeAttrBase)
+		node_desc := fmt.Sprintf(indent+"<node id=\"n%d\"", node.GetID())
+		node_data := emitGraphMLElemData(doc_attrs, doc_attrs.nodeLabelID, node_label,
+			has_label, attr_vals, indent+EMIT_INDENT)
+
+		if node_data == "" {
+			node_desc += "/>\n"
+		} else {
+			node_desc += ">\n" + node_data + indent + "</node>\n"
+		}
+
+		if _, err := out_file.WriteString(node_desc); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GRAPHML, nest, err)
+		}
+	}
+
+	// Emit graph edges belonging to the nest
+	edges, err := emitNestEdges(EMIT_FORMAT_GRAPHML, nest, graph)
+
+	if err != nil {
+		return err
+	}
+
+	collapse := graph_emit_spec.Edge.CollapseParallel
+	groups := groupParallelEdges(edges, collapse, graph.IsDirected(), emitEdgeEnds)
+
+	for _, group := range groups {
+		edge_desc := fmt.Sprintf(indent+"<edge id=\"e%d\" source=\"n%d\" target=\"n%d\"",
+			group.edge.GetID(), group.edge.GetSrcNode().GetID(),
+			group.edge.GetDstNode().GetID())
+
+		if collapse {
+			edge_desc += ">\n" + emitGraphMLData(doc_attrs.keyMap.docID(
+				doc_attrs.edgeWeightID), fmt.Sprintf("%d", group.count),
+				indent+EMIT_INDENT) + indent + "</edge>\n"
+		} else {
+			edge_desc += "/>\n"
+		}
+
+		if _, err := out_file.WriteString(edge_desc); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GRAPHML, nest, err)
+		}
+	}
+
+	return nil
+}
+
+// Emit a nest as a GraphML node containing a nested graph
+func emitGraphMLNest(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	doc_attrs *gMLDocAttrs,
+	out_file *os.File,
+	indent string) error {
+
+	nest_label, has_label, err := emitNestLabel(EMIT_FORMAT_GRAPHML, nest,
+		graph_emit_spec.Nest.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	attr_vals := emitCollectStrAttrVals(doc_attrs.keyMap, nest.strAttrs,
+		doc_attrs.nestAttrBase)
+	nest_desc := fmt.Sprintf(indent+"<node id=\"nest%d\">\n", nest.GetID()) +
+		emitGraphMLElemData(doc_attrs, doc_attrs.nodeLabelID, nest_label, has_label,
+			attr_vals, indent+EMIT_INDENT)
+
+	if _, err := out_file.WriteString(nest_desc); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GRAPHML, nest, err)
+	}
+
+	graph_id := fmt.Sprintf("nest%d:", nest.GetID())
+	err = emitGraphMLGraph(nest, graph_id, "", graph_emit_spec, doc_attrs, out_file,
+		indent+EMIT_INDENT)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := out_file.WriteString(indent + "</node>\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GRAPHML, nest, err)
+	}
+
+	return nil
+}
+
+// Emit GraphML graph representing contents of a nest. "graph_data" holds descriptions of
+// the graph attribute values (empty for nested graphs)
+func emitGraphMLGraph(nest *Nest,
+	graph_id string,
+	graph_data string,
+	graph_emit_spec *GraphEmitSpec,
+	doc_attrs *gMLDocAttrs,
+	out_file *os.File,
+	indent string) error {
+
+	edge_default := "directed"
+
+	if !nest.GetNestTree().GetBaseGraph().IsDirected() {
+		edge_default = "undirected"
+	}
+
+	graph_open_tag := fmt.Sprintf(indent+"<graph id=\"%s\" edgedefault=\"%s\">\n",
+		graph_id, edge_default)
+
+	if _, err := out_file.WriteString(graph_open_tag + graph_data); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GRAPHML, nest, err)
+	}
+
+	// Emit nested graphs first. After that nodes and edges of the current graph will be
+	// emitted
+	child_nest := nest.GetFirstChildNest()
+
+	for ; child_nest != nil; child_nest = child_nest.GetNextSiblingNest() {
+		if err := emitCheckChildNest(EMIT_FORMAT_GRAPHML, nest, child_nest); err != nil {
+			return err
+		}
+
+		err := emitGraphMLNest(child_nest, graph_emit_spec, doc_attrs, out_file,
+			indent+EMIT_INDENT)
+
+		// The error returned by the recursive call already carries the path to the exact
+		// nest that couldn't be emitted. So, it's propagated as is
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_GRAPHML, child_nest, "Couldn't emit a child "+
+				"nest", err)
+		}
+	}
+
+	err := emitGraphMLNestNodesAndEdges(nest, graph_emit_spec, doc_attrs, out_file,
+		indent+EMIT_INDENT)
+
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_GRAPHML, nest, "Couldn't emit nodes and edges "+
+			"belonging to a nest", err)
+	}
+
+	if _, err := out_file.WriteString(indent + "</graph>\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GRAPHML, nest, err)
+	}
+
+	return nil
+}
+
+// Print description of a Graph in standard GraphML format (without any extensions)
+//
+// Input: full path to the output file (all parent directories should exist)
+func EmitInGraphMLFormat(graph *Graph, graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInGraphMLFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_GRAPHML, nil, nil, nil, "Zero reference to the "+
+			"graph", ErrNilGraph)
+	}
+
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_GRAPHML, nil, nil, nil, "Cannot open the output "+
+			"path", fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_GRAPHML, graph,
+		graph_emit_spec.Graph.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	root_nest, err := emitRootNest(EMIT_FORMAT_GRAPHML, graph)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit document header
+	header := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+		"<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\" " +
+		"xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" " +
+		"xsi:schemaLocation=\"http://graphml.graphdrawing.org/xmlns " +
+		"http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd\">\n"
+
+	if _, err := out_file.WriteString(header); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GRAPHML, nil, err)
+	}
+
+	// Emit declarations of the attributes used in the document
+	doc_attrs := newGMLDocAttrs(graph, graph_emit_spec)
+
+	for doc_id, attr_id := range doc_attrs.keyMap.used {
+		key_decl := EMIT_INDENT + emitGMLKeyDecl(doc_attrs.attrs[attr_id], doc_id)
+
+		if _, err := out_file.WriteString(key_decl); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_GRAPHML, nil, err)
+		}
+	}
+
+	// Emit the entire graph. Values of the graph attributes precede its contents
+	attr_vals := emitCollectStrAttrVals(doc_attrs.keyMap, graph.strAttrs, 0)
+	graph_data := emitGraphMLElemData(doc_attrs, doc_attrs.graphLabelID, graph_label,
+		has_graph_label, attr_vals, EMIT_INDENT+EMIT_INDENT)
+
+	err = emitGraphMLGraph(root_nest, "G", graph_data, graph_emit_spec, doc_attrs,
+		out_file, EMIT_INDENT)
+
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_GRAPHML, root_nest, "Couldn't emit the graph",
+			err)
+	}
+
+	// Emit document footer
+	if _, err := out_file.WriteString("</graphml>\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_GRAPHML, nil, err)
+	}
+
+	return nil
+}
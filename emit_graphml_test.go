@@ -0,0 +1,19 @@
+package graph
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A nested graph is emitted as a standard GraphML document with nests as nested graphs.
+// Labels are escaped
+func TestEmitInGraphMLFormat(t *testing.T) {
+	graph, spec := newEmitTestGraph(t)
+	out_path := filepath.Join(t.TempDir(), "graph.graphml")
+
+	if err := EmitInGraphMLFormat(graph, spec, out_path); err != nil {
+		t.Fatalf("EmitInGraphMLFormat: %v", err)
+	}
+
+	expectGoldenFile(t, out_path, "graph.graphml")
+}
@@ -0,0 +1,267 @@
+/*
+  Emit graph in Mermaid format
+
+  The graph is described as a Mermaid flowchart. Nests are represented by subgraphs, so
+  the description can be rendered by any Markdown viewer that supports Mermaid diagrams:
+
+	```mermaid
+	flowchart LR
+	  ...
+	```
+
+  Graph nodes get identifiers "n<node ID>", nests get identifiers "nest_<nest ID>". Nodes
+  without a label are labeled with their IDs (same as in Graphviz output)
+*/
+
+package graph
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Escape a label so that it can be placed inside a double-quoted Mermaid string.
+// Characters that are special for Mermaid or for HTML are replaced by Mermaid entity
+// codes. Line breaks are replaced by HTML line breaks
+func escapeMermaidLabel(label string) string {
+	var escaped strings.Builder
+
+	for _, char := range label {
+		switch char {
+		case '"', '#', '&', '<', '>', '`', '|':
+			escaped.WriteString(fmt.Sprintf("#%d;", char))
+		case '\n':
+			escaped.WriteString("<br>")
+		case '\r':
+			// Dropped. "\r\n" line breaks are represented by "\n" alone
+		default:
+			escaped.WriteRune(char)
+		}
+	}
+
+	return escaped.String()
+}
+
+// Get the Mermaid link that connects ends of an edge
+func emitMermaidLink(directed bool) string {
+	if directed {
+		return "-->"
+	}
+
+	return "---"
+}
+
+// Emit nodes and edges of a nest in Mermaid format
+func emitMermaidSubgraphNodesAndEdges(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	graph, err := emitNestGraph(EMIT_FORMAT_MERMAID, nest)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit graph nodes belonging to the nest
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		node_label, has_label, err := emitNodeLabel(EMIT_FORMAT_MERMAID, node,
+			graph_emit_spec.Node.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		if !has_label {
+			node_label = fmt.Sprintf("%d", node.GetID())
+		}
+
+		node_desc_line := fmt.Sprintf(indent+"n%d[\"%s\"]\n", node.GetID(),
+			escapeMermaidLabel(node_label))
+
+		if _, err := out_file.WriteString(node_desc_line); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_MERMAID, nest, err)
+		}
+	}
+
+	// Emit graph edges belonging to the nest
+	edges, err := emitNestEdges(EMIT_FORMAT_MERMAID, nest, graph)
+
+	if err != nil {
+		return err
+	}
+
+	link := emitMermaidLink(graph.IsDirected())
+	groups := groupParallelEdges(edges, graph_emit_spec.Edge.CollapseParallel,
+		graph.IsDirected(), emitEdgeEnds)
+
+	for _, group := range groups {
+		edge_link := link
+
+		if group.count > 1 {
+			edge_link += fmt.Sprintf("|\"%d\"|", group.count)
+		}
+
+		edge_desc_line := fmt.Sprintf(indent+"n%d %s n%d\n",
+			group.edge.GetSrcNode().GetID(), edge_link, group.edge.GetDstNode().GetID())
+
+		if _, err := out_file.WriteString(edge_desc_line); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_MERMAID, nest, err)
+		}
+	}
+
+	return nil
+}
+
+// Emit child nests of a nest as Mermaid subgraphs
+func emitMermaidChildNests(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	child_nest := nest.GetFirstChildNest()
+
+	for ; child_nest != nil; child_nest = child_nest.GetNextSiblingNest() {
+		if err := emitCheckChildNest(EMIT_FORMAT_MERMAID, nest, child_nest); err != nil {
+			return err
+		}
+
+		err := emitMermaidSubgraph(child_nest, graph_emit_spec, out_file, indent)
+
+		// The error returned by the recursive call already carries the path to the exact
+		// nest that couldn't be emitted. So, it's propagated as is
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_MERMAID, child_nest, "Couldn't emit a child "+
+				"nest", err)
+		}
+	}
+
+	return nil
+}
+
+// Emit a nested sub-graph in Mermaid format
+func emitMermaidSubgraph(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	// Emit subgraph opening clause. Unlabeled nests get a blank title. Otherwise Mermaid
+	// would display the subgraph identifier
+	nest_label, has_label, err := emitNestLabel(EMIT_FORMAT_MERMAID, nest,
+		graph_emit_spec.Nest.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	if !has_label {
+		nest_label = " "
+	}
+
+	subgraph_line := fmt.Sprintf(indent+"subgraph nest_%d [\"%s\"]\n", nest.GetID(),
+		escapeMermaidLabel(nest_label))
+
+	if _, err := out_file.WriteString(subgraph_line); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_MERMAID, nest, err)
+	}
+
+	// Emit nested subgraphs. Nodes and edges of the current nest will be emitted after
+	// that
+	err = emitMermaidChildNests(nest, graph_emit_spec, out_file, indent+EMIT_INDENT)
+
+	if err != nil {
+		return err
+	}
+
+	err = emitMermaidSubgraphNodesAndEdges(nest, graph_emit_spec, out_file,
+		indent+EMIT_INDENT)
+
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_MERMAID, nest, "Couldn't emit nodes and edges "+
+			"belonging to a nest", err)
+	}
+
+	// Emit subgraph closing clause
+	if _, err := out_file.WriteString(indent + "end\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_MERMAID, nest, err)
+	}
+
+	return nil
+}
+
+// Print text description of a Graph as a Mermaid flowchart. The graph label (if any) is
+// emitted as the diagram title
+//
+// Input: full path to the output file (all parent directories should exist)
+func EmitInMermaidFormat(graph *Graph, graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInMermaidFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_MERMAID, nil, nil, nil, "Zero reference to the "+
+			"graph", ErrNilGraph)
+	}
+
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_MERMAID, nil, nil, nil, "Cannot open the output "+
+			"path", fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_MERMAID, graph,
+		graph_emit_spec.Graph.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit the diagram title. It's placed into YAML front matter and so is quoted
+	// according to YAML rules
+	if has_graph_label {
+		_, err = out_file.WriteString("---\ntitle: " + strconv.Quote(graph_label) +
+			"\n---\n")
+
+		if err != nil {
+			return newEmitWriteError(EMIT_FORMAT_MERMAID, nil, err)
+		}
+	}
+
+	// Emit diagram header. Drawing orientation: left to right
+	if _, err := out_file.WriteString("flowchart LR\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_MERMAID, nil, err)
+	}
+
+	// Emit nested subgraphs. Nodes and edges of the root nest will be emitted after that
+	root_nest, err := emitRootNest(EMIT_FORMAT_MERMAID, graph)
+
+	if err != nil {
+		return err
+	}
+
+	err = emitMermaidChildNests(root_nest, graph_emit_spec, out_file, EMIT_INDENT)
+
+	if err != nil {
+		return err
+	}
+
+	err = emitMermaidSubgraphNodesAndEdges(root_nest, graph_emit_spec, out_file,
+		EMIT_INDENT)
+
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_MERMAID, root_nest, "Couldn't emit nodes and "+
+			"edges belonging to the root nest", err)
+	}
+
+	return nil
+}
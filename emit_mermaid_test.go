@@ -0,0 +1,18 @@
+package graph
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A nested graph is emitted as a Mermaid flowchart with subgraphs. Labels are escaped
+func TestEmitInMermaidFormat(t *testing.T) {
+	graph, spec := newEmitTestGraph(t)
+	out_path := filepath.Join(t.TempDir(), "graph.mmd")
+
+	if err := EmitInMermaidFormat(graph, spec, out_path); err != nil {
+		t.Fatalf("EmitInMermaidFormat: %v", err)
+	}
+
+	expectGoldenFile(t, out_path, "graph.mmd")
+}
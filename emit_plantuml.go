@@ -0,0 +1,270 @@
+/*
+  Emit graph in PlantUML format
+
+  The graph is described as a PlantUML component diagram. Graph nodes are represented by
+  components, graph edges by arrows between them. Nests are represented by nested blocks:
+  nests that are children of the root nest become packages, deeper nests become
+  rectangles inside them
+
+  Graph nodes get aliases "n<node ID>", nests get aliases "nest_<nest ID>". Nodes without
+  a label are labeled with their IDs (same as in Graphviz output). The description can be
+  rendered by the "plantuml" tool:
+
+	plantuml -tpng graph.puml
+*/
+
+package graph
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Escape a label so that it can be placed inside a double-quoted PlantUML string. Quotes
+// and characters that start Creole markup are replaced by Unicode escapes. Line breaks
+// are replaced by PlantUML line break sequences
+func escapePlantUMLLabel(label string) string {
+	var escaped strings.Builder
+
+	for _, char := range label {
+		switch char {
+		case '"', '<', '>', '~':
+			escaped.WriteString(fmt.Sprintf("<U+%04X>", char))
+		case '\\':
+			escaped.WriteString("\\\\")
+		case '\n':
+			escaped.WriteString("\\n")
+		case '\r':
+			// Dropped. "\r\n" line breaks are represented by "\n" alone
+		default:
+			escaped.WriteRune(char)
+		}
+	}
+
+	return escaped.String()
+}
+
+// Get the PlantUML arrow that connects ends of an edge
+func emitPlantUMLArrow(directed bool) string {
+	if directed {
+		return "-->"
+	}
+
+	return "--"
+}
+
+// Emit nodes and edges of a nest in PlantUML format
+func emitPlantUMLNestNodesAndEdges(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	graph, err := emitNestGraph(EMIT_FORMAT_PLANTUML, nest)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit graph nodes belonging to the nest
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		node_label, has_label, err := emitNodeLabel(EMIT_FORMAT_PLANTUML, node,
+			graph_emit_spec.Node.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		if !has_label {
+			node_label = fmt.Sprintf("%d", node.GetID())
+		}
+
+		node_desc_line := fmt.Sprintf(indent+"component \"%s\" as n%d\n",
+			escapePlantUMLLabel(node_label), node.GetID())
+
+		if _, err := out_file.WriteString(node_desc_line); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_PLANTUML, nest, err)
+		}
+	}
+
+	// Emit graph edges belonging to the nest
+	edges, err := emitNestEdges(EMIT_FORMAT_PLANTUML, nest, graph)
+
+	if err != nil {
+		return err
+	}
+
+	arrow := emitPlantUMLArrow(graph.IsDirected())
+	groups := groupParallelEdges(edges, graph_emit_spec.Edge.CollapseParallel,
+		graph.IsDirected(), emitEdgeEnds)
+
+	for _, group := range groups {
+		edge_desc_line := fmt.Sprintf(indent+"n%d %s n%d", group.edge.GetSrcNode().GetID(),
+			arrow, group.edge.GetDstNode().GetID())
+
+		if group.count > 1 {
+			edge_desc_line += fmt.Sprintf(" : %d", group.count)
+		}
+
+		if _, err := out_file.WriteString(edge_desc_line + "\n"); err != nil {
+			return newEmitWriteError(EMIT_FORMAT_PLANTUML, nest, err)
+		}
+	}
+
+	return nil
+}
+
+// Emit child nests of a nest as PlantUML blocks
+func emitPlantUMLChildNests(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	child_nest := nest.GetFirstChildNest()
+
+	for ; child_nest != nil; child_nest = child_nest.GetNextSiblingNest() {
+		if err := emitCheckChildNest(EMIT_FORMAT_PLANTUML, nest, child_nest); err != nil {
+			return err
+		}
+
+		err := emitPlantUMLNest(child_nest, graph_emit_spec, out_file, indent)
+
+		// The error returned by the recursive call already carries the path to the exact
+		// nest that couldn't be emitted. So, it's propagated as is
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_PLANTUML, child_nest, "Couldn't emit a "+
+				"child nest", err)
+		}
+	}
+
+	return nil
+}
+
+// Emit a nest as a PlantUML block
+func emitPlantUMLNest(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	out_file *os.File,
+	indent string) error {
+
+	// Emit block opening clause. Unlabeled nests get a blank name. Otherwise PlantUML
+	// would display the alias
+	nest_label, has_label, err := emitNestLabel(EMIT_FORMAT_PLANTUML, nest,
+		graph_emit_spec.Nest.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	if !has_label {
+		nest_label = " "
+	}
+
+	block_kind := "rectangle"
+
+	if nest.Depth() == 1 {
+		block_kind = "package"
+	}
+
+	block_line := fmt.Sprintf(indent+"%s \"%s\" as nest_%d {\n", block_kind,
+		escapePlantUMLLabel(nest_label), nest.GetID())
+
+	if _, err := out_file.WriteString(block_line); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_PLANTUML, nest, err)
+	}
+
+	// Emit nested blocks. Nodes and edges of the current nest will be emitted after that
+	err = emitPlantUMLChildNests(nest, graph_emit_spec, out_file, indent+EMIT_INDENT)
+
+	if err != nil {
+		return err
+	}
+
+	err = emitPlantUMLNestNodesAndEdges(nest, graph_emit_spec, out_file,
+		indent+EMIT_INDENT)
+
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_PLANTUML, nest, "Couldn't emit nodes and edges "+
+			"belonging to a nest", err)
+	}
+
+	// Emit block closing bracket
+	if _, err := out_file.WriteString(indent + "}\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_PLANTUML, nest, err)
+	}
+
+	return nil
+}
+
+// Print text description of a Graph as a PlantUML component diagram. The graph label (if
+// any) is emitted as the diagram title
+//
+// Input: full path to the output file (all parent directories should exist)
+func EmitInPlantUMLFormat(graph *Graph, graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInPlantUMLFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_PLANTUML, nil, nil, nil, "Zero reference to the "+
+			"graph", ErrNilGraph)
+	}
+
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_PLANTUML, nil, nil, nil, "Cannot open the output "+
+			"path", fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_PLANTUML, graph,
+		graph_emit_spec.Graph.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	// Emit diagram header and global properties. Drawing orientation: left to right
+	header := "@startuml\nleft to right direction\n"
+
+	if has_graph_label {
+		header += "title " + escapePlantUMLLabel(graph_label) + "\n"
+	}
+
+	if _, err := out_file.WriteString(header); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_PLANTUML, nil, err)
+	}
+
+	// Emit nested blocks. Nodes and edges of the root nest will be emitted after that
+	root_nest, err := emitRootNest(EMIT_FORMAT_PLANTUML, graph)
+
+	if err != nil {
+		return err
+	}
+
+	err = emitPlantUMLChildNests(root_nest, graph_emit_spec, out_file, "")
+
+	if err != nil {
+		return err
+	}
+
+	err = emitPlantUMLNestNodesAndEdges(root_nest, graph_emit_spec, out_file, "")
+
+	if err != nil {
+		return wrapEmitError(EMIT_FORMAT_PLANTUML, root_nest, "Couldn't emit nodes and "+
+			"edges belonging to the root nest", err)
+	}
+
+	// Emit diagram footer
+	if _, err := out_file.WriteString("@enduml\n"); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_PLANTUML, nil, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package graph
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A nested graph is emitted as a PlantUML component diagram with packages and
+// rectangles. Labels are escaped
+func TestEmitInPlantUMLFormat(t *testing.T) {
+	graph, spec := newEmitTestGraph(t)
+	out_path := filepath.Join(t.TempDir(), "graph.puml")
+
+	if err := EmitInPlantUMLFormat(graph, spec, out_path); err != nil {
+		t.Fatalf("EmitInPlantUMLFormat: %v", err)
+	}
+
+	expectGoldenFile(t, out_path, "graph.puml")
+}
@@ -0,0 +1,543 @@
+/*
+  Render graph to SVG
+
+  The drawing is built from a layout stored in node and nest attributes (see "layout.go").
+  The layout can be computed by any of the layout engines ("LayoutLayered",
+  "LayoutForceDirected") or set by the caller. No external tools are needed
+
+  Nests are drawn as labeled rounded rectangles, nodes as rectangles with labels in the
+  middle (nodes without labels are labeled with their IDs, like in Graphviz). Edges are
+  drawn as straight lines clipped by node borders; edges of a directed graph get
+  arrowheads. Parallel edges are drawn as curves bent apart, loops are drawn at the
+  top-right corners of the nodes. If parallel edges are collapsed, an edge representing
+  more than one edge is labeled with their number. The graph label (if any) is drawn as a
+  title above the drawing
+
+  The root nest is not drawn: its rectangle (if stored) defines the drawing area. Nests
+  whose rectangles are not stored are drawn around their contents. Empty nests without
+  stored rectangles are not drawn
+
+  The elements are grouped by "<g>" elements of classes "nest", "edge" and "node" and get
+  IDs "nest_<nest ID>", "e<edge ID>" and "n<node ID>". Colors are set by a stylesheet
+  embedded into the document, so they are easy to override
+*/
+
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Geometry of SVG drawings
+const (
+	// Margin around the drawing
+	SVG_MARGIN = 10
+	// Height of the graph title
+	SVG_TITLE_HEIGHT = 30
+	// Distance between curves representing parallel edges
+	SVG_EDGE_SPREAD = 16
+	// Size of a loop
+	SVG_LOOP_SIZE = 30
+	// Corner radii of nest and node rectangles
+	SVG_NEST_RADIUS = 8
+	SVG_NODE_RADIUS = 4
+)
+
+// Stylesheet embedded into SVG drawings
+const SVG_STYLE = `
+.nest rect { fill: #e8edf5; fill-opacity: 0.5; stroke: #7a8699; }
+.nest text { fill: #3d4654; font-weight: bold; }
+.node rect { fill: #ffffff; stroke: #333333; }
+.node text { fill: #000000; }
+.edge path { fill: none; stroke: #555555; }
+.edge text { fill: #555555; }
+.title { font-size: 16px; }
+`
+
+// Bounding box of the elements drawn so far
+type svgBounds struct {
+	left   float64
+	top    float64
+	right  float64
+	bottom float64
+}
+
+// Extend a bounding box so that it contains a rectangle
+func (bounds *svgBounds) add(rect LayoutRect) {
+	bounds.left = min(bounds.left, rect.X)
+	bounds.top = min(bounds.top, rect.Y)
+	bounds.right = max(bounds.right, rect.X+rect.Width)
+	bounds.bottom = max(bounds.bottom, rect.Y+rect.Height)
+}
+
+// Rectangles of the drawn nodes and nests
+type svgLayout struct {
+	// Format reported by errors (one of EMIT_FORMAT_* constants). The layout is read by
+	// the interactive viewer as well
+	format    string
+	spec      *LayoutSpec
+	nodeRects map[*Node]LayoutRect
+	nestRects map[*Nest]LayoutRect
+}
+
+// Read rectangles of the nodes belonging to a nest (and to all its descendants) and
+// compute the nest rectangle. Returns "false" if the nest is not drawn
+func (layout *svgLayout) readNest(nest *Nest) (LayoutRect, bool, error) {
+	bounds := svgBounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		rect, err := readNodeLayout(node, layout.spec)
+
+		if err != nil {
+			return rect, false, newEmitError(layout.format, nest, node, nil, "Cannot "+
+				"read the node layout", err)
+		}
+
+		layout.nodeRects[node] = rect
+		bounds.add(rect)
+	}
+
+	for child := range nest.Children() {
+		if err := emitCheckChildNest(layout.format, nest, child); err != nil {
+			return LayoutRect{}, false, err
+		}
+
+		rect, is_drawn, err := layout.readNest(child)
+
+		if err != nil {
+			return rect, false, err
+		}
+
+		if is_drawn {
+			bounds.add(rect)
+		}
+	}
+
+	// The stored rectangle takes precedence over the computed one
+	rect, is_stored, err := readNestLayout(nest, layout.spec)
+
+	if err != nil {
+		return rect, false, newEmitError(layout.format, nest, nil, nil, "Cannot read "+
+			"the nest layout", err)
+	}
+
+	if !is_stored {
+		if math.IsInf(bounds.left, 1) {
+			return rect, false, nil
+		}
+
+		padding := layout.spec.NestPadding
+		rect = LayoutRect{
+			X:      bounds.left - padding,
+			Y:      bounds.top - padding,
+			Width:  bounds.right - bounds.left + 2*padding,
+			Height: bounds.bottom - bounds.top + 2*padding,
+		}
+	}
+
+	layout.nestRects[nest] = rect
+
+	return rect, true, nil
+}
+
+// Get the point where the segment from the center of a rectangle to point (x, y) crosses
+// the rectangle border. If the point is inside the rectangle, it's returned as is
+func svgClipToRect(rect LayoutRect, x float64, y float64) (float64, float64) {
+	center_x, center_y := rect.X+rect.Width/2, rect.Y+rect.Height/2
+	dx, dy := x-center_x, y-center_y
+	scale := math.Inf(1)
+
+	if dx != 0 {
+		scale = min(scale, rect.Width/2/math.Abs(dx))
+	}
+
+	if dy != 0 {
+		scale = min(scale, rect.Height/2/math.Abs(dy))
+	}
+
+	if scale >= 1 {
+		return x, y
+	}
+
+	return center_x + dx*scale, center_y + dy*scale
+}
+
+// Draw a graph edge. "offset" is the distance by which the edge is bent apart from the
+// straight line (for loops - the number of the loop). Loops stick out of the nodes, so
+// they extend the bounding box of the drawing
+func svgDrawEdge(body *strings.Builder,
+	layout *svgLayout,
+	group emitEdgeGroup[*Edge],
+	offset float64,
+	directed bool,
+	bounds *svgBounds) {
+
+	edge := group.edge
+	src_rect := layout.nodeRects[edge.srcNode]
+	dst_rect := layout.nodeRects[edge.dstNode]
+	val := formatLayoutVal
+	var path string
+	var label_x, label_y float64
+
+	if edge.srcNode == edge.dstNode {
+		// Loops grow with their number, so that they don't coincide
+		size := SVG_LOOP_SIZE + offset*SVG_EDGE_SPREAD
+		start_x, start_y := src_rect.X+src_rect.Width*3/4, src_rect.Y
+		end_x, end_y := src_rect.X+src_rect.Width, src_rect.Y+src_rect.Height/4
+		path = fmt.Sprintf("M %s %s C %s %s %s %s %s %s", val(start_x), val(start_y),
+			val(start_x), val(start_y-size), val(end_x+size), val(end_y), val(end_x),
+			val(end_y))
+		label_x, label_y = end_x+size/2, start_y-size/2
+		bounds.add(LayoutRect{start_x, start_y - size, end_x + size - start_x, size})
+	} else {
+		src_x, src_y := src_rect.X+src_rect.Width/2, src_rect.Y+src_rect.Height/2
+		dst_x, dst_y := dst_rect.X+dst_rect.Width/2, dst_rect.Y+dst_rect.Height/2
+
+		// The normal is computed for the direction from the node with the smaller ID.
+		// So, edges going in the opposite directions are bent consistently
+		dx, dy := dst_x-src_x, dst_y-src_y
+
+		if edge.srcNode.id > edge.dstNode.id {
+			dx, dy = -dx, -dy
+		}
+
+		normal_x, normal_y := 0.0, 0.0
+
+		if length := math.Hypot(dx, dy); length > 0 {
+			normal_x, normal_y = -dy/length, dx/length
+		}
+
+		// The apex of a quadratic curve is half way to its control point
+		mid_x, mid_y := (src_x+dst_x)/2, (src_y+dst_y)/2
+		ctrl_x, ctrl_y := mid_x+2*offset*normal_x, mid_y+2*offset*normal_y
+		start_x, start_y := svgClipToRect(src_rect, ctrl_x, ctrl_y)
+		end_x, end_y := svgClipToRect(dst_rect, ctrl_x, ctrl_y)
+		label_x, label_y = mid_x+offset*normal_x, mid_y+offset*normal_y
+
+		if offset == 0 {
+			start_x, start_y = svgClipToRect(src_rect, dst_x, dst_y)
+			end_x, end_y = svgClipToRect(dst_rect, src_x, src_y)
+			path = fmt.Sprintf("M %s %s L %s %s", val(start_x), val(start_y),
+				val(end_x), val(end_y))
+		} else {
+			path = fmt.Sprintf("M %s %s Q %s %s %s %s", val(start_x), val(start_y),
+				val(ctrl_x), val(ctrl_y), val(end_x), val(end_y))
+		}
+	}
+
+	marker := ""
+
+	if directed {
+		marker = " marker-end=\"url(#arrow)\""
+	}
+
+	body.WriteString(fmt.Sprintf(EMIT_INDENT+"<g class=\"edge\" id=\"e%d\">\n",
+		edge.GetID()))
+	body.WriteString(strings.Repeat(EMIT_INDENT, 2) + "<path d=\"" + path + "\"" + marker +
+		"/>\n")
+
+	if group.count > 1 {
+		body.WriteString(fmt.Sprintf(strings.Repeat(EMIT_INDENT, 2)+"<text x=\"%s\" "+
+			"y=\"%s\" text-anchor=\"middle\" dominant-baseline=\"central\">%d</text>\n",
+			val(label_x), val(label_y), group.count))
+	}
+
+	body.WriteString(EMIT_INDENT + "</g>\n")
+}
+
+// Draw the edges belonging to a nest
+func svgDrawNestEdges(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	layout *svgLayout,
+	body *strings.Builder,
+	bounds *svgBounds) error {
+
+	graph, err := emitNestGraph(EMIT_FORMAT_SVG, nest)
+
+	if err != nil {
+		return err
+	}
+
+	edges, err := emitNestEdges(EMIT_FORMAT_SVG, nest, graph)
+
+	if err != nil {
+		return err
+	}
+
+	groups := groupParallelEdges(edges, graph_emit_spec.Edge.CollapseParallel,
+		graph.IsDirected(), emitEdgeEnds)
+
+	// Count the drawn edges connecting every pair of nodes (regardless of the direction)
+	// to bend them apart. Parallel edges always belong to the same nest
+	pair_key := func(edge *Edge) [2]int {
+		return [2]int{min(edge.srcNode.id, edge.dstNode.id),
+			max(edge.srcNode.id, edge.dstNode.id)}
+	}
+
+	pair_count := make(map[[2]int]int)
+
+	for _, group := range groups {
+		pair_count[pair_key(group.edge)]++
+	}
+
+	pair_drawn := make(map[[2]int]int)
+
+	for _, group := range groups {
+		key := pair_key(group.edge)
+		offset := float64(pair_drawn[key])
+
+		if key[0] != key[1] {
+			offset = (offset - float64(pair_count[key]-1)/2) * SVG_EDGE_SPREAD
+		}
+
+		pair_drawn[key]++
+		svgDrawEdge(body, layout, group, offset, graph.IsDirected(), bounds)
+	}
+
+	return nil
+}
+
+// Draw the nodes belonging to a nest
+func svgDrawNestNodes(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	layout *svgLayout,
+	body *strings.Builder) error {
+
+	val := formatLayoutVal
+
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		node_label, has_label, err := emitNodeLabel(EMIT_FORMAT_SVG, node,
+			graph_emit_spec.Node.LabelAttr)
+
+		if err != nil {
+			return err
+		}
+
+		if !has_label {
+			node_label = fmt.Sprintf("%d", node.GetID())
+		}
+
+		rect := layout.nodeRects[node]
+		body.WriteString(fmt.Sprintf(EMIT_INDENT+"<g class=\"node\" id=\"n%d\">\n",
+			node.GetID()))
+		body.WriteString(fmt.Sprintf(strings.Repeat(EMIT_INDENT, 2)+"<rect x=\"%s\" "+
+			"y=\"%s\" width=\"%s\" height=\"%s\" rx=\"%d\"/>\n", val(rect.X), val(rect.Y),
+			val(rect.Width), val(rect.Height), SVG_NODE_RADIUS))
+		body.WriteString(fmt.Sprintf(strings.Repeat(EMIT_INDENT, 2)+"<text x=\"%s\" "+
+			"y=\"%s\" text-anchor=\"middle\" dominant-baseline=\"central\">%s</text>\n",
+			val(rect.X+rect.Width/2), val(rect.Y+rect.Height/2),
+			emitEscapeXML(node_label)))
+		body.WriteString(EMIT_INDENT + "</g>\n")
+	}
+
+	return nil
+}
+
+// Draw a nest rectangle with the nest label
+func svgDrawNest(nest *Nest,
+	graph_emit_spec *GraphEmitSpec,
+	layout *svgLayout,
+	body *strings.Builder) error {
+
+	rect, is_drawn := layout.nestRects[nest]
+
+	if !is_drawn {
+		return nil
+	}
+
+	nest_label, has_label, err := emitNestLabel(EMIT_FORMAT_SVG, nest,
+		graph_emit_spec.Nest.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	val := formatLayoutVal
+	body.WriteString(fmt.Sprintf(EMIT_INDENT+"<g class=\"nest\" id=\"nest_%d\">\n",
+		nest.GetID()))
+	body.WriteString(fmt.Sprintf(strings.Repeat(EMIT_INDENT, 2)+"<rect x=\"%s\" "+
+		"y=\"%s\" width=\"%s\" height=\"%s\" rx=\"%d\"/>\n", val(rect.X), val(rect.Y),
+		val(rect.Width), val(rect.Height), SVG_NEST_RADIUS))
+
+	// The label is placed into the top padding of the nest
+	if has_label {
+		body.WriteString(fmt.Sprintf(strings.Repeat(EMIT_INDENT, 2)+"<text x=\"%s\" "+
+			"y=\"%s\" dominant-baseline=\"central\">%s</text>\n",
+			val(rect.X+SVG_NEST_RADIUS), val(rect.Y+layout.spec.NestPadding/2),
+			emitEscapeXML(nest_label)))
+	}
+
+	body.WriteString(EMIT_INDENT + "</g>\n")
+
+	return nil
+}
+
+// Render a Graph to SVG. The layout is read from the attributes defined by the layout
+// specification: positions of nodes must be defined and set, other attributes are
+// optional (see "readNodeLayout" and "readNestLayout"). Sizes from the specification
+// are used for missing sizes (zero sizes are replaced by the defaults)
+//
+// Input: full path to the output file (all parent directories should exist)
+func EmitInSVGFormat(graph *Graph,
+	graph_emit_spec *GraphEmitSpec,
+	layout_spec *LayoutSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitInSVGFormat", &err)
+
+	if graph == nil {
+		return newEmitError(EMIT_FORMAT_SVG, nil, nil, nil, "Zero reference to the "+
+			"graph", ErrNilGraph)
+	}
+
+	// NOTE: here the function parameter "graph_emit_spec" is intentionally re-assigned
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	spec, err := checkLayoutSpec("EmitInSVGFormat", graph, layout_spec)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_SVG, nil, nil, nil, "Invalid layout "+
+			"specification", err)
+	}
+
+	if spec.Node.XAttr == nil || spec.Node.YAttr == nil {
+		return newEmitError(EMIT_FORMAT_SVG, nil, nil, nil, "The layout specification "+
+			"doesn't define attributes holding node positions", ErrNoLayoutAttrs)
+	}
+
+	graph_label, has_graph_label, err := emitGraphLabel(EMIT_FORMAT_SVG, graph,
+		graph_emit_spec.Graph.LabelAttr)
+
+	if err != nil {
+		return err
+	}
+
+	root_nest, err := emitRootNest(EMIT_FORMAT_SVG, graph)
+
+	if err != nil {
+		return err
+	}
+
+	// Read the layout
+	layout := &svgLayout{
+		format:    EMIT_FORMAT_SVG,
+		spec:      &spec,
+		nodeRects: make(map[*Node]LayoutRect),
+		nestRects: make(map[*Nest]LayoutRect),
+	}
+
+	root_rect, has_root_rect, err := layout.readNest(root_nest)
+
+	if err != nil {
+		return err
+	}
+
+	if !has_root_rect {
+		root_rect = LayoutRect{}
+	}
+
+	// The root nest is not drawn
+	delete(layout.nestRects, root_nest)
+
+	// Draw nests first (parents before children), then edges and then nodes. So, edges
+	// are drawn over nests and nodes are drawn over edges
+	var body strings.Builder
+
+	bounds := svgBounds{root_rect.X, root_rect.Y, root_rect.X + root_rect.Width,
+		root_rect.Y + root_rect.Height}
+	nests := []*Nest{root_nest}
+
+	for nest := range root_nest.Descendants() {
+		nests = append(nests, nest)
+	}
+
+	for _, nest := range nests[1:] {
+		if err := svgDrawNest(nest, graph_emit_spec, layout, &body); err != nil {
+			return wrapEmitError(EMIT_FORMAT_SVG, nest, "Couldn't draw a nest", err)
+		}
+	}
+
+	for _, nest := range nests {
+		err := svgDrawNestEdges(nest, graph_emit_spec, layout, &body, &bounds)
+
+		if err != nil {
+			return wrapEmitError(EMIT_FORMAT_SVG, nest, "Couldn't draw edges belonging "+
+				"to a nest", err)
+		}
+	}
+
+	for _, nest := range nests {
+		if err := svgDrawNestNodes(nest, graph_emit_spec, layout, &body); err != nil {
+			return wrapEmitError(EMIT_FORMAT_SVG, nest, "Couldn't draw nodes belonging "+
+				"to a nest", err)
+		}
+	}
+
+	// Compose the document. The drawing area encloses the root nest and all the edges
+	bounds.left -= SVG_MARGIN
+	bounds.top -= SVG_MARGIN
+	bounds.right += SVG_MARGIN
+	bounds.bottom += SVG_MARGIN
+
+	if has_graph_label {
+		bounds.top -= SVG_TITLE_HEIGHT
+	}
+
+	val := formatLayoutVal
+	width, height := bounds.right-bounds.left, bounds.bottom-bounds.top
+	var doc bytes.Buffer
+
+	doc.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	doc.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" "+
+		"width=\"%s\" height=\"%s\" viewBox=\"%s %s %s %s\" font-family=\"sans-serif\" "+
+		"font-size=\"12px\">\n", val(width), val(height), val(bounds.left),
+		val(bounds.top), val(width), val(height)))
+
+	if has_graph_label {
+		doc.WriteString(EMIT_INDENT + "<title>" + emitEscapeXML(graph_label) +
+			"</title>\n")
+	}
+
+	doc.WriteString(EMIT_INDENT + "<style>" + SVG_STYLE + EMIT_INDENT + "</style>\n")
+
+	if graph.IsDirected() {
+		doc.WriteString(EMIT_INDENT + "<defs>\n" + strings.Repeat(EMIT_INDENT, 2) +
+			"<marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" " +
+			"markerWidth=\"8\" markerHeight=\"8\" orient=\"auto\">\n" +
+			strings.Repeat(EMIT_INDENT, 3) + "<path d=\"M 0 0 L 10 5 L 0 10 z\" " +
+			"fill=\"#555555\"/>\n" + strings.Repeat(EMIT_INDENT, 2) + "</marker>\n" +
+			EMIT_INDENT + "</defs>\n")
+	}
+
+	if has_graph_label {
+		doc.WriteString(fmt.Sprintf(EMIT_INDENT+"<text class=\"title\" x=\"%s\" y=\"%s\" "+
+			"text-anchor=\"middle\" dominant-baseline=\"central\">%s</text>\n",
+			val(bounds.left+width/2), val(bounds.top+SVG_MARGIN+SVG_TITLE_HEIGHT/2),
+			emitEscapeXML(graph_label)))
+	}
+
+	doc.WriteString(body.String())
+	doc.WriteString("</svg>\n")
+
+	// Write the document
+	out_file, err := os.OpenFile(out_path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return newEmitError(EMIT_FORMAT_SVG, nil, nil, nil, "Cannot open the output "+
+			"path", fmt.Errorf("%w: %w", ErrEmitCreate, err))
+	}
+
+	defer out_file.Close()
+
+	if _, err := out_file.Write(doc.Bytes()); err != nil {
+		return newEmitWriteError(EMIT_FORMAT_SVG, nil, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Build a nested graph with node positions set by hand. Nests:
+//
+//	outer {a, inner {b, c}}
+//
+// Node "d" belongs to the root nest and has no label. The stored layout doesn't define
+// nest rectangles, so nests are drawn around their contents
+func newSVGTestGraph(t *testing.T) (*Graph, *GraphEmitSpec, *LayoutSpec) {
+	t.Helper()
+
+	graph := NewGraph(AttrSpec{GraphStrAttrNum: 1, NodeStrAttrNum: 3, NestStrAttrNum: 1})
+	nest_tree := graph.GetNestTree()
+	spec := &GraphEmitSpec{}
+	layout_spec := &LayoutSpec{}
+	spec.Graph.LabelAttr, _ = graph.NewGraphStrAttr()
+	spec.Node.LabelAttr, _ = graph.NewNodeStrAttr()
+	spec.Nest.LabelAttr, _ = nest_tree.NewNestStrAttr()
+	layout_spec.Node.XAttr, _ = graph.NewNodeStrAttr()
+	layout_spec.Node.YAttr, _ = graph.NewNodeStrAttr()
+
+	graph.SetStrAttrVal(spec.Graph.LabelAttr, "svg <test>")
+	outer := nest_tree.NewNest()
+	inner, _ := outer.NewChildNest()
+	outer.SetStrAttrVal(spec.Nest.LabelAttr, "outer")
+	inner.SetStrAttrVal(spec.Nest.LabelAttr, "inner & co")
+
+	nodes := []struct {
+		label string
+		nest  *Nest
+		x     int
+		y     int
+	}{
+		{"a", outer, 20, 40},
+		{"b \"1\"", inner, 160, 60},
+		{"c", inner, 160, 160},
+		{"", nil, 360, 100},
+	}
+
+	var created []*Node
+
+	for _, desc := range nodes {
+		node := graph.NewNode()
+
+		if desc.label != "" {
+			node.SetStrAttrVal(spec.Node.LabelAttr, desc.label)
+		}
+
+		if desc.nest != nil {
+			node.MoveToNest(desc.nest)
+		}
+
+		node.SetStrAttrVal(layout_spec.Node.XAttr, strconv.Itoa(desc.x))
+		node.SetStrAttrVal(layout_spec.Node.YAttr, strconv.Itoa(desc.y))
+		created = append(created, node)
+	}
+
+	// Parallel edges and a loop
+	for _, ends := range [][2]int{{0, 1}, {1, 2}, {1, 2}, {2, 3}, {3, 3}} {
+		graph.NewEdge(created[ends[0]], created[ends[1]])
+	}
+
+	return graph, spec, layout_spec
+}
+
+// A nested graph is drawn from the stored layout. Collapsed parallel edges are labeled
+// with their number
+func TestEmitInSVGFormat(t *testing.T) {
+	graph, spec, layout_spec := newSVGTestGraph(t)
+	dir := t.TempDir()
+	golden_names := map[bool]string{false: "graph.svg", true: "graph_collapsed.svg"}
+
+	for _, collapse := range []bool{false, true} {
+		spec.Edge.CollapseParallel = collapse
+		out_path := filepath.Join(dir, golden_names[collapse])
+
+		if err := EmitInSVGFormat(graph, spec, layout_spec, out_path); err != nil {
+			t.Fatalf("EmitInSVGFormat: %v", err)
+		}
+
+		expectGoldenFile(t, out_path, golden_names[collapse])
+	}
+
+	// Node positions are required
+	graph.NodeByID(0).RemoveStrAttr(layout_spec.Node.XAttr)
+	err := EmitInSVGFormat(graph, spec, layout_spec, filepath.Join(dir, "bad.svg"))
+
+	if !errors.Is(err, ErrAttrNotSet) {
+		t.Fatalf("EmitInSVGFormat without a node position: got error %v, want %v", err,
+			ErrAttrNotSet)
+	}
+}
@@ -0,0 +1,357 @@
+/*
+  Errors returned by the Graph package
+
+  Every error returned by the package either is one of the sentinel errors below or wraps
+  one of them. So, the callers can distinguish the error cases by means of "errors.Is()".
+  Typed errors ("*AttrError", "*ElemError", "*EmitError", "*ReadError") carry the context
+  of an error (IDs of the involved elements, the nest path, the input line, etc.) and can
+  be extracted by means of "errors.As()". Underlying causes (for example, I/O errors) are
+  wrapped, not discarded
+
+  Panic policy:
+    1) misuse of the public API (zero references, elements or attributes belonging to a
+       different graph, invalid attributes, etc.) is reported by returning an error. The
+       public methods never panic because of misuse
+    2) violation of the package's internal invariants (inconsistent linked lists, nest
+       levels, etc.) is considered a programmer error inside the package itself. Such
+       violations are detected by sanity checks that panic
+    3) every public function and method that returns an error converts the panics from
+       (2) into "*InternalError" (which wraps "ErrInconsistent") by means of "recover()".
+       Methods that don't return an error (for example, "Graph.NewNode()") have
+       error-returning variants with the "Checked" suffix. Any other call can be wrapped
+       into "Safely()"
+*/
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors
+var (
+	// An attribute is invalid (for example, it was already released)
+	ErrAttrInvalid = errors.New("The attribute is invalid")
+	// An attribute belongs to a different graph (or nest tree) than the element it's
+	// used with
+	ErrAttrForeign = errors.New("The attribute and the element belong to different " +
+		"graphs")
+	// An attribute value is not set for an element
+	ErrAttrNotSet = errors.New("The attribute is not set")
+	// All attributes of a requested kind are already allocated
+	ErrNoAvailableAttrs = errors.New("No available attributes")
+	// Zero reference to a graph node was provided
+	ErrNilNode = errors.New("Zero reference to a graph node")
+	// Zero reference to a nest was provided
+	ErrNilNest = errors.New("Zero reference to a nest")
+	// A graph node belongs to a different graph than expected
+	ErrForeignNode = errors.New("The node belongs to a different graph")
+	// A nest belongs to a different graph than expected
+	ErrForeignNest = errors.New("The nest belongs to a different graph")
+	// The graph has no nest tree or the nest tree has no root nest
+	ErrNoNestTree = errors.New("The graph doesn't have a nest tree or a root nest")
+	// Zero reference to a graph was provided
+	ErrNilGraph = errors.New("Zero reference to a graph")
+	// An element is detached from its graph (for example, its creation was undone by
+	// means of the journal)
+	ErrElemDetached = errors.New("The element is detached from the graph")
+	// The graph data is inconsistent (some of the package invariants are violated)
+	ErrInconsistent = errors.New("Inconsistent graph data")
+	// The output file cannot be created
+	ErrEmitCreate = errors.New("Cannot create output file")
+	// The output cannot be written
+	ErrEmitWrite = errors.New(strings.TrimSuffix(EMIT_WRITE_ERR_MSG_PREFIX, ": "))
+	// The input file cannot be read
+	ErrReadOpen = errors.New("Cannot read input file")
+	// The input is malformed or uses features that are not supported
+	ErrParse = errors.New("Cannot parse the input")
+	// Zero reference to a function computing node keys was provided
+	ErrNilKeyFunc = errors.New("Zero reference to a node key function")
+	// Several nodes of a graph have the same key
+	ErrDuplicateKey = errors.New("Duplicate node key")
+	// A graph is merged into itself
+	ErrSameGraph = errors.New("The source and the destination graphs are the same")
+	// Merged nodes have different values of an attribute and the conflict is not resolved
+	ErrMergeConflict = errors.New("Conflicting attribute values")
+	// The layout specification doesn't define any attribute to store the layout
+	ErrNoLayoutAttrs = errors.New("No attributes to store the layout are specified")
+	// The layout specification defines an invalid size
+	ErrLayoutSize = errors.New("Layout sizes must be non-negative finite numbers")
+	// A layout attribute holds a value that is not a finite number
+	ErrLayoutVal = errors.New("The layout attribute value is not a finite number")
+)
+
+// Kinds of elements that can have attributes
+const (
+	ATTR_ELEM_GRAPH = "graph"
+	ATTR_ELEM_NODE  = "node"
+	ATTR_ELEM_NEST  = "nest"
+)
+
+// Error related to a graph, node or nest attribute
+type AttrError struct {
+	// Operation that failed (the name of the method)
+	Op string
+	// Kind of the element the attribute relates to (one of ATTR_ELEM_* constants)
+	Elem string
+	// ID of the node or nest the attribute is used with. "-1" for graph attributes and
+	// for operations that don't involve any specific element
+	ElemID int
+	// Number of the attribute. "-1" if the attribute is invalid
+	AttrNum int
+	// Underlying error (one of the sentinel errors)
+	Err error
+}
+
+func (e *AttrError) Error() string {
+	str := e.Op + ": " + e.Err.Error()
+
+	if e.ElemID >= 0 {
+		str += fmt.Sprintf(" [%s ID = %d]", e.Elem, e.ElemID)
+	} else {
+		str += " [" + e.Elem + "]"
+	}
+
+	if e.AttrNum >= 0 {
+		str += fmt.Sprintf(" [attribute number = %d]", e.AttrNum)
+	}
+
+	return str
+}
+
+func (e *AttrError) Unwrap() error {
+	return e.Err
+}
+
+// Create an attribute error
+func newAttrError(op string, elem string, elem_id int, attr_num int,
+	err error) *AttrError {
+
+	return &AttrError{Op: op, Elem: elem, ElemID: elem_id, AttrNum: attr_num, Err: err}
+}
+
+// Error related to misuse of graph nodes, edges or nests
+type ElemError struct {
+	// Operation that failed (the name of the method)
+	Op string
+	// ID of the node involved. "-1" if no specific node is involved
+	NodeID int
+	// ID of the nest involved. "-1" if no specific nest is involved
+	NestID int
+	// Human-readable details
+	Msg string
+	// Underlying error (one of the sentinel errors)
+	Err error
+}
+
+func (e *ElemError) Error() string {
+	str := e.Op + ": " + e.Msg
+
+	if e.NestID >= 0 {
+		str += fmt.Sprintf(" [nest ID = %d]", e.NestID)
+	}
+
+	if e.NodeID >= 0 {
+		str += fmt.Sprintf(" [node ID = %d]", e.NodeID)
+	}
+
+	return str + ": " + e.Err.Error()
+}
+
+func (e *ElemError) Unwrap() error {
+	return e.Err
+}
+
+// Supported emit formats (used to report emit errors and read errors)
+const (
+	EMIT_FORMAT_GV        = "Graphviz"
+	EMIT_FORMAT_YFILES    = "yFiles GraphML"
+	EMIT_FORMAT_MERMAID   = "Mermaid"
+	EMIT_FORMAT_PLANTUML  = "PlantUML"
+	EMIT_FORMAT_GEXF      = "GEXF"
+	EMIT_FORMAT_GML       = "GML"
+	EMIT_FORMAT_GRAPHML   = "GraphML"
+	EMIT_FORMAT_CYTOSCAPE = "Cytoscape.js JSON"
+	EMIT_FORMAT_SVG       = "SVG"
+	EMIT_FORMAT_VIEWER    = "viewer JSON"
+)
+
+// Error that occurred while emitting a graph
+type EmitError struct {
+	// Format in which the graph was emitted (one of EMIT_FORMAT_* constants)
+	Format string
+	// IDs of the nests from the root nest down to the nest being emitted when the error
+	// occurred. Empty if the error is not related to any specific nest
+	NestPath []int
+	// ID of the node being emitted. "-1" if no specific node is involved
+	NodeID int
+	// ID of the edge being emitted. "-1" if no specific edge is involved
+	EdgeID int
+	// Human-readable details
+	Msg string
+	// Underlying error. It wraps one of the sentinel errors and - optionally - the
+	// original cause (for example, an I/O error)
+	Err error
+}
+
+func (e *EmitError) Error() string {
+	str := "Error emitting a graph in " + e.Format + " format: " + e.Msg
+
+	if len(e.NestPath) > 0 {
+		path := make([]string, len(e.NestPath))
+
+		for i, id := range e.NestPath {
+			path[i] = fmt.Sprintf("%d", id)
+		}
+
+		str += " [nest path = " + strings.Join(path, "/") + "]"
+	}
+
+	if e.NodeID >= 0 {
+		str += fmt.Sprintf(" [node ID = %d]", e.NodeID)
+	}
+
+	if e.EdgeID >= 0 {
+		str += fmt.Sprintf(" [edge ID = %d]", e.EdgeID)
+	}
+
+	if e.Err != nil {
+		str += ": " + e.Err.Error()
+	}
+
+	return str
+}
+
+func (e *EmitError) Unwrap() error {
+	return e.Err
+}
+
+// Calculate IDs of the nests from the root nest down to a given nest
+func emitNestPath(nest *Nest) []int {
+	var path []int
+
+	for ; nest != nil; nest = nest.parentNest {
+		path = append([]int{nest.id}, path...)
+	}
+
+	return path
+}
+
+// Create an emit error related to a given nest, node and edge (any of them may be "nil")
+func newEmitError(format string, nest *Nest, node *Node, edge *Edge, msg string,
+	err error) *EmitError {
+
+	emit_err := &EmitError{
+		Format:   format,
+		NestPath: emitNestPath(nest),
+		NodeID:   -1,
+		EdgeID:   -1,
+		Msg:      msg,
+		Err:      err,
+	}
+
+	if node != nil {
+		emit_err.NodeID = node.id
+	}
+
+	if edge != nil {
+		emit_err.EdgeID = edge.id
+	}
+
+	return emit_err
+}
+
+// Create an emit error caused by a failed write. The original I/O error is preserved
+func newEmitWriteError(format string, nest *Nest, err error) *EmitError {
+	return newEmitError(format, nest, nil, nil, "Cannot write the output",
+		fmt.Errorf("%w: %w", ErrEmitWrite, err))
+}
+
+// Propagate an error returned by a nested emit function. Errors that are already of
+// "*EmitError" type are returned as is, so that the context of the innermost error (the
+// nest path, in particular) is preserved
+func wrapEmitError(format string, nest *Nest, msg string, err error) error {
+	var emit_err *EmitError
+
+	if errors.As(err, &emit_err) {
+		return err
+	}
+
+	return newEmitError(format, nest, nil, nil, msg, err)
+}
+
+// Error produced from a panic that was raised inside the package (see the panic policy
+// described at the top of this file)
+type InternalError struct {
+	// Operation during which the panic was raised
+	Op string
+	// Value passed to "panic()"
+	PanicVal interface{}
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.Op, ErrInconsistent, e.PanicVal)
+}
+
+func (e *InternalError) Unwrap() error {
+	return ErrInconsistent
+}
+
+// Convert a panic (if any) into "*InternalError". Must be called by means of "defer"
+// directly from a function with a named error result
+func recoverInternalError(op string, err *error) {
+	if panic_val := recover(); panic_val != nil {
+		*err = &InternalError{Op: op, PanicVal: panic_val}
+	}
+}
+
+// Call a function and convert a panic raised by it into "*InternalError". Intended for
+// the package clients that cannot tolerate panics and need to call methods that don't
+// return errors. For example:
+//
+//	var node *Node
+//	err := graph.Safely(func() error { node = node.GetNextNode(); return nil })
+func Safely(f func() error) (err error) {
+	defer recoverInternalError("Safely", &err)
+
+	return f()
+}
+
+// Error that occurred while reading a graph
+type ReadError struct {
+	// Format in which the graph was described (one of EMIT_FORMAT_* constants)
+	Format string
+	// Number of the input line at which the error was detected (starting from 1). "0" if
+	// the error is not related to any specific line
+	Line int
+	// Human-readable details
+	Msg string
+	// Underlying error. It wraps one of the sentinel errors and - optionally - the
+	// original cause (for example, an I/O error)
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	str := "Error reading a graph in " + e.Format + " format: " + e.Msg
+
+	if e.Line > 0 {
+		str += fmt.Sprintf(" [line %d]", e.Line)
+	}
+
+	if e.Err != nil {
+		str += ": " + e.Err.Error()
+	}
+
+	return str
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// Create a read error caused by malformed input
+func newParseError(format string, line int, msg string) *ReadError {
+	return &ReadError{Format: format, Line: line, Msg: msg, Err: ErrParse}
+}
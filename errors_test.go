@@ -0,0 +1,126 @@
+package graph
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStrAttrMisuse(t *testing.T) {
+	graph := NewGraph(AttrSpec{GraphStrAttrNum: 1, NodeStrAttrNum: 1, NestStrAttrNum: 1})
+	node := graph.NewNode()
+	nest := graph.GetNestTree().NewNest()
+
+	var nil_node *Node
+	var nil_nest *Nest
+	var nil_graph *Graph
+
+	node_attr, err := graph.NewNodeStrAttr()
+
+	if err != nil {
+		t.Fatalf("NewNodeStrAttr: %v", err)
+	}
+
+	nest_attr, err := graph.GetNestTree().NewNestStrAttr()
+
+	if err != nil {
+		t.Fatalf("NewNestStrAttr: %v", err)
+	}
+
+	graph_attr, err := graph.NewGraphStrAttr()
+
+	if err != nil {
+		t.Fatalf("NewGraphStrAttr: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"Node.SetStrAttrVal", func() error { return node.SetStrAttrVal(nil, "x") },
+			ErrAttrInvalid},
+		{"Node.GetStrAttrVal", func() error {
+			_, err := node.GetStrAttrVal(nil)
+			return err
+		}, ErrAttrInvalid},
+		{"Node.IsStrAttrSet", func() error {
+			_, err := node.IsStrAttrSet(nil)
+			return err
+		}, ErrAttrInvalid},
+		{"Node.RemoveStrAttr", func() error { return node.RemoveStrAttr(nil) },
+			ErrAttrInvalid},
+		{"Nest.SetStrAttrVal", func() error { return nest.SetStrAttrVal(nil, "x") },
+			ErrAttrInvalid},
+		{"Nest.GetStrAttrVal", func() error {
+			_, err := nest.GetStrAttrVal(nil)
+			return err
+		}, ErrAttrInvalid},
+		{"Graph.SetStrAttrVal", func() error { return graph.SetStrAttrVal(nil, "x") },
+			ErrAttrInvalid},
+		{"Graph.GetStrAttrVal", func() error {
+			_, err := graph.GetStrAttrVal(nil)
+			return err
+		}, ErrAttrInvalid},
+		{"ReleaseGraphStrAttr", func() error { return graph.ReleaseGraphStrAttr(nil) },
+			ErrAttrInvalid},
+		{"ReleaseNodeStrAttr", func() error { return graph.ReleaseNodeStrAttr(nil) },
+			ErrAttrInvalid},
+		{"ReleaseNestStrAttr", func() error {
+			return graph.GetNestTree().ReleaseNestStrAttr(nil)
+		}, ErrAttrInvalid},
+		{"nil node", func() error { return nil_node.SetStrAttrVal(node_attr, "x") },
+			ErrNilNode},
+		{"nil nest", func() error { return nil_nest.SetStrAttrVal(nest_attr, "x") },
+			ErrNilNest},
+		{"nil graph", func() error { return nil_graph.SetStrAttrVal(graph_attr, "x") },
+			ErrNilGraph},
+	}
+
+	for _, c := range cases {
+		if err := c.call(); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestDetachedNode(t *testing.T) {
+	graph := NewGraph(DefaultAttrSpec())
+	journal := graph.EnableJournal()
+	node := graph.NewNode()
+	other := graph.NewNode()
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	// "other" is detached now
+	if next_node := other.GetNextNode(); next_node != nil {
+		t.Errorf("GetNextNode of a detached node: got node %d", next_node.GetID())
+	}
+
+	if prev_node := other.GetPrevNode(); prev_node != nil {
+		t.Errorf("GetPrevNode of a detached node: got node %d", prev_node.GetID())
+	}
+
+	if _, err := other.GetNextNodeChecked(); !errors.Is(err, ErrElemDetached) {
+		t.Errorf("GetNextNodeChecked: got %v", err)
+	}
+
+	if _, err := other.GetPrevNodeChecked(); !errors.Is(err, ErrElemDetached) {
+		t.Errorf("GetPrevNodeChecked: got %v", err)
+	}
+
+	nest := graph.GetNestTree().NewNest()
+
+	if err := other.MoveToNest(nest); !errors.Is(err, ErrElemDetached) {
+		t.Errorf("MoveToNest: got %v", err)
+	}
+
+	if _, err := graph.NewEdge(node, other); !errors.Is(err, ErrElemDetached) {
+		t.Errorf("NewEdge: got %v", err)
+	}
+
+	if next_node, err := node.GetNextNodeChecked(); err != nil || next_node != nil {
+		t.Errorf("GetNextNodeChecked of the only node: got %v, %v", next_node, err)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/AndreyNevolin/graph
+
+go 1.23
@@ -2,7 +2,8 @@
   Structures for representing arbitrary graphs
 
   The package is NOT thread-safe. It was designed to be used in a single-threaded
-  code. Additional efforts are required to make the code below thread-safe
+  code. Additional efforts are required to make the code below thread-safe. See
+  "ConcurrentGraph" for a thread-safe wrapper
 
   NOTE: whole-graph attributes are implemented in the same way as node and edge
         attributes. They could be implemented differently because for graph attributes
@@ -13,16 +14,17 @@
 		options - the used one and the alternative described here - seem to not have
 		significant benefits one over the other. But the implemented option has a
 		benefit of unification with node and edge attributes
-  NOTE: a separate iterator for all graph edges is NOT provided. Since every edge is
-  		connected to two nodes, it's possible to iterate over all graph edges by
-		iterating over all graph nodes and for each node iterating over all its
-		successors (or predecessors). The iterator for all graph nodes IS provided
+  NOTE: there are no "GetFirstEdge()"/"GetNextEdge()" navigation methods for all graph
+  		edges. Since every edge is connected to two nodes, all graph edges can be
+		visited by iterating over all graph nodes and for each node iterating over all
+		its outcoming edges. "Graph.Edges()" does exactly that (see "iterators.go")
 */
 
 package graph
 
 import (
-	"errors"
+	"fmt"
+	"sort"
 )
 
 /**
@@ -89,6 +91,10 @@ type Node struct {
 	firstIncomingEdge *Edge
 	// First outcoming edge
 	firstOutcomingEdge *Edge
+	// Number of incoming edges
+	inDegree int
+	// Number of outcoming edges
+	outDegree int
 	// Node ID
 	id int
 	// Nest to which a node belongs
@@ -139,6 +145,14 @@ type Graph struct {
 	// Edge count (must always increase and never decrease. Even if edges get deleted).
 	// Creation of a new edge increments the counter
 	edgeCount int
+	// Nodes indexed by their IDs. Deleted nodes are represented by "nil"
+	nodesByID []*Node
+	// Edges indexed by their IDs. Deleted edges are represented by "nil"
+	edgesByID []*Edge
+	// Number of live (i.e. not deleted) nodes
+	liveNodeCount int
+	// Number of live (i.e. not deleted) edges
+	liveEdgeCount int
 	// Specification of graph's attributes
 	attrSpec AttrSpec
 	// Allocation map for graph string attributes
@@ -151,6 +165,16 @@ type Graph struct {
 	nodeStrAttrAllocMap []bool
 	// Array of graph string attributes
 	strAttrs []strAttrVal
+	// Journal recording graph mutations (see "EnableJournal()"). "nil" if the mutations
+	// are not recorded
+	journal *Journal
+	// Observers of graph mutations (see "Subscribe()")
+	observers []*Subscription
+	// Whether the graph is undirected (see "SetDirected()")
+	undirected bool
+	// Indexes of nodes by values of node string attributes (see "NewNodeAttrIndex()").
+	// Indexed by attribute numbers. "nil" if no index was ever created
+	nodeIndexes []*strAttrIndex[*Node]
 }
 
 // Create new Graph
@@ -177,9 +201,28 @@ func (graph *Graph) GetNestTree() *NestTree {
 
 // Get first Graph node in a list of all nodes
 func (graph *Graph) GetFirstNode() *Node {
-	// Some sanity checks first
+	node, err := graph.GetFirstNodeChecked()
+
+	if err != nil {
+		panic("Panic while getting the first graph node: " + err.Error())
+	}
+
+	return node
+}
+
+// Get first Graph node in a list of all nodes. In contrast to "GetFirstNode()", an error
+// is returned instead of panicking if the graph is inconsistent
+func (graph *Graph) GetFirstNodeChecked() (first_node *Node, err error) {
+	defer recoverInternalError("GetFirstNodeChecked", &err)
+
+	if graph == nil {
+		return nil, &ElemError{"GetFirstNodeChecked", -1, -1, "Zero reference to the " +
+			"graph", ErrNilGraph}
+	}
+
 	if graph.nestTree == nil || graph.nestTree.rootNest == nil {
-		panic("Graph without a nest tree or the nest tree without the root nest")
+		return nil, &ElemError{"GetFirstNodeChecked", -1, -1, "Graph without a nest " +
+			"tree or the nest tree without the root nest", ErrNoNestTree}
 	}
 
 	nest := graph.nestTree.rootNest
@@ -189,10 +232,10 @@ func (graph *Graph) GetFirstNode() *Node {
 	}
 
 	if nest == nil {
-		return nil
+		return nil, nil
 	}
 
-	return nest.firstNode
+	return nest.firstNode, nil
 }
 
 // Allocate new Graph string attribute
@@ -207,32 +250,25 @@ func (graph *Graph) NewGraphStrAttr() (*GraphStrAttr, error) {
 		}
 	}
 
-	return &graph_str_attr_invalid, errors.New("No available graph string attributes")
+	return &graph_str_attr_invalid, newAttrError("NewGraphStrAttr", ATTR_ELEM_GRAPH, -1,
+		-1, ErrNoAvailableAttrs)
 }
 
 // Remove string attribute from a Graph
 func (graph *Graph) RemoveStrAttr(attr *GraphStrAttr) error {
-	if !attr.isValid {
-		errors.New("The attribute is invalid")
+	if err := graph.checkStrAttr("RemoveStrAttr", attr); err != nil {
+		return err
 	}
 
-	if attr.graph != graph {
-		errors.New("The attribute doesn't belong to the graph")
-	}
-
-	graph.strAttrs[attr.attrNum].isSet = false
+	graph.changeStrAttr(ATTR_ELEM_GRAPH, nil, nil, attr.attrNum, strAttrVal{})
 
 	return nil
 }
 
 // Check wheter a string attribute is set for a Graph
 func (graph *Graph) IsStrAttrSet(attr *GraphStrAttr) (bool, error) {
-	if !attr.isValid {
-		return false, errors.New("The attribute is invalid")
-	}
-
-	if attr.graph != graph {
-		return false, errors.New("The attribute doesn't belong to the graph")
+	if err := graph.checkStrAttr("IsStrAttrSet", attr); err != nil {
+		return false, err
 	}
 
 	return graph.strAttrs[attr.attrNum].isSet, nil
@@ -240,32 +276,24 @@ func (graph *Graph) IsStrAttrSet(attr *GraphStrAttr) (bool, error) {
 
 // Set value of a Graph string attribute
 func (graph *Graph) SetStrAttrVal(attr *GraphStrAttr, val string) error {
-	if attr.isValid == false {
-		return errors.New("The attribute is invalid")
+	if err := graph.checkStrAttr("SetStrAttrVal", attr); err != nil {
+		return err
 	}
 
-	if attr.graph != graph {
-		return errors.New("The attribute doesn't belong to the graph")
-	}
-
-	graph.strAttrs[attr.attrNum].isSet = true
-	graph.strAttrs[attr.attrNum].data = val
+	graph.changeStrAttr(ATTR_ELEM_GRAPH, nil, nil, attr.attrNum, strAttrVal{true, val})
 
 	return nil
 }
 
 // Get value of a Graph string attribute
 func (graph *Graph) GetStrAttrVal(attr *GraphStrAttr) (string, error) {
-	if !attr.isValid {
-		return "", errors.New("The attribute is invalid")
-	}
-
-	if attr.graph != graph {
-		return "", errors.New("The attribute doesn't belong to the graph")
+	if err := graph.checkStrAttr("GetStrAttrVal", attr); err != nil {
+		return "", err
 	}
 
 	if !graph.strAttrs[attr.attrNum].isSet {
-		return "", errors.New("The attribute is not set for the graph")
+		return "", newAttrError("GetStrAttrVal", ATTR_ELEM_GRAPH, -1, attr.attrNum,
+			ErrAttrNotSet)
 	}
 
 	return graph.strAttrs[attr.attrNum].data, nil
@@ -273,26 +301,85 @@ func (graph *Graph) GetStrAttrVal(attr *GraphStrAttr) (string, error) {
 
 // Release Graph string attribute
 func (graph *Graph) ReleaseGraphStrAttr(attr *GraphStrAttr) error {
-	if !attr.isValid {
-		return errors.New("The attribute cannot be released. It's invalid")
-	}
-
-	if attr.graph != graph {
-		return errors.New("The attribute doesn't belong to the graph")
+	if err := graph.checkStrAttr("ReleaseGraphStrAttr", attr); err != nil {
+		return err
 	}
 
 	attr_num := attr.attrNum
 
-	// Remove the attribute from the graph
-	graph.RemoveStrAttr(attr)
+	// Remove the attribute from the graph. The removal is not recorded into the journal:
+	// the recorded changes of the attribute are dropped below anyway
+	graph.withoutJournal(func() { graph.RemoveStrAttr(attr) })
 
 	// Finally, deallocate the attribute (remove it from the attribute allocation map)
 	graph.graphStrAttrAllocMap[attr_num] = false
+	graph.journalForgetStrAttr(ATTR_ELEM_GRAPH, attr_num)
 	*attr = graph_str_attr_invalid
 
 	return nil
 }
 
+// Check that a string attribute is valid and belongs to a Graph
+func (graph *Graph) checkStrAttr(op string, attr *GraphStrAttr) error {
+	if graph == nil {
+		return &ElemError{op, -1, -1, "Zero reference to the graph", ErrNilGraph}
+	}
+
+	if attr == nil || !attr.isValid {
+		return newAttrError(op, ATTR_ELEM_GRAPH, -1, -1, ErrAttrInvalid)
+	}
+
+	if attr.graph != graph {
+		return newAttrError(op, ATTR_ELEM_GRAPH, -1, attr.attrNum, ErrAttrForeign)
+	}
+
+	return nil
+}
+
+// Get location of a string attribute value of a graph, node or nest. "node" must be set
+// for node attributes and "nest" - for nest attributes
+func (graph *Graph) strAttrSlot(elem string, node *Node, nest *Nest,
+	attr_num int) *strAttrVal {
+
+	switch elem {
+	case ATTR_ELEM_GRAPH:
+		return &graph.strAttrs[attr_num]
+	case ATTR_ELEM_NODE:
+		return &node.strAttrs[attr_num]
+	case ATTR_ELEM_NEST:
+		return &nest.strAttrs[attr_num]
+	}
+
+	panic("Panic while accessing a string attribute: unknown element kind \"" + elem +
+		"\"")
+}
+
+// Change value of a string attribute of a graph, node or nest. The change is recorded
+// into the journal (if any) and the observers are notified about it
+func (graph *Graph) changeStrAttr(elem string, node *Node, nest *Nest, attr_num int,
+	new_val strAttrVal) {
+
+	if graph.isJournalRecording() {
+		graph.journalAdd(&journalStrAttr{graph: graph, elem: elem, node: node, nest: nest,
+			attrNum: attr_num, oldVal: *graph.strAttrSlot(elem, node, nest, attr_num),
+			newVal: new_val})
+	}
+
+	graph.assignStrAttr(elem, node, nest, attr_num, new_val)
+}
+
+// Assign value of a string attribute and notify the observers. The assignment is not
+// recorded into the journal
+func (graph *Graph) assignStrAttr(elem string, node *Node, nest *Nest, attr_num int,
+	new_val strAttrVal) {
+
+	slot := graph.strAttrSlot(elem, node, nest, attr_num)
+	old_val := *slot
+	*slot = new_val
+	graph.updateStrAttrIndexes(elem, node, nest, attr_num, old_val, new_val)
+	graph.notifyStrAttr(elem, node, nest, attr_num, old_val, new_val)
+}
+
 // Allocate new node string attribute for a Graph
 func (graph *Graph) NewNodeStrAttr() (*NodeStrAttr, error) {
 	// Find non-allocated attribute
@@ -305,30 +392,39 @@ func (graph *Graph) NewNodeStrAttr() (*NodeStrAttr, error) {
 		}
 	}
 
-	return &node_str_attr_invalid, errors.New("No available node string attributes")
+	return &node_str_attr_invalid, newAttrError("NewNodeStrAttr", ATTR_ELEM_NODE, -1, -1,
+		ErrNoAvailableAttrs)
 }
 
 // Release node string attribute for a Graph
-func (graph *Graph) ReleaseNodeStrAttr(attr *NodeStrAttr) error {
-	if !attr.isValid {
-		return errors.New("The attribute cannot be released. It's invalid")
+func (graph *Graph) ReleaseNodeStrAttr(attr *NodeStrAttr) (err error) {
+	defer recoverInternalError("ReleaseNodeStrAttr", &err)
+
+	if attr == nil || !attr.isValid {
+		return newAttrError("ReleaseNodeStrAttr", ATTR_ELEM_NODE, -1, -1, ErrAttrInvalid)
 	}
 
 	if attr.graph != graph {
-		return errors.New("The attribute doesn't belong to the graph")
+		return newAttrError("ReleaseNodeStrAttr", ATTR_ELEM_NODE, -1, attr.attrNum,
+			ErrAttrForeign)
 	}
 
 	attr_num := attr.attrNum
 
-	// Remove the attribute from all existing nodes
-	for node := graph.GetFirstNode(); node != nil; node = node.GetNextNode() {
-		// Explicitly ingnore error that may be returned by the below call
-		// (since no error is expected)
-		node.RemoveStrAttr(attr)
-	}
+	// Remove the attribute from all existing nodes. The removals are not recorded into the
+	// journal: the recorded changes of the attribute are dropped below anyway
+	graph.withoutJournal(func() {
+		for node := graph.GetFirstNode(); node != nil; node = node.GetNextNode() {
+			// Explicitly ingnore error that may be returned by the below call
+			// (since no error is expected)
+			node.RemoveStrAttr(attr)
+		}
+	})
 
 	// Finally, deallocate the attribute (remove it from the attribute allocation map)
 	graph.nodeStrAttrAllocMap[attr_num] = false
+	graph.dropNodeAttrIndex(attr_num)
+	graph.journalForgetStrAttr(ATTR_ELEM_NODE, attr_num)
 	*attr = node_str_attr_invalid
 
 	return nil
@@ -337,11 +433,30 @@ func (graph *Graph) ReleaseNodeStrAttr(attr *NodeStrAttr) error {
 // Create new Graph node
 //
 // A newly created Graph node is assigned to the root nest. Later it can be assigned to
-// a different nest by calling "SetNest()" method of the node
+// a different nest by calling "MoveToNest()" method of the node
 func (graph *Graph) NewNode() *Node {
-	// Some sanity checks first
+	node, err := graph.NewNodeChecked()
+
+	if err != nil {
+		panic("Panic while creating a new graph node: " + err.Error())
+	}
+
+	return node
+}
+
+// Create new Graph node. In contrast to "NewNode()", an error is returned instead of
+// panicking if the graph is inconsistent
+func (graph *Graph) NewNodeChecked() (new_node *Node, err error) {
+	defer recoverInternalError("NewNodeChecked", &err)
+
+	if graph == nil {
+		return nil, &ElemError{"NewNodeChecked", -1, -1, "Zero reference to the graph",
+			ErrNilGraph}
+	}
+
 	if graph.nestTree == nil || graph.nestTree.rootNest == nil {
-		panic("Graph without a nest tree or the nest tree without the root nest")
+		return nil, &ElemError{"NewNodeChecked", -1, -1, "Graph without a nest tree or " +
+			"the nest tree without the root nest", ErrNoNestTree}
 	}
 
 	node_p := &Node{
@@ -357,61 +472,161 @@ func (graph *Graph) NewNode() *Node {
 
 	graph.nestTree.rootNest.addNode(node_p)
 	graph.nodeCount++
+	graph.registerNode(node_p)
+	graph.journalAdd(&journalNewNode{node_p})
+	graph.notifyElem(EVENT_NODE_NEW, node_p, nil, node_p.nest, nil)
 
-	return node_p
+	return node_p, nil
 }
 
 // Create new edge between pre-existing nodes in a Graph
 //
 // Multiple edges in the same direction between two given nodes ARE allowed
-func (graph *Graph) NewEdge(src_node *Node, dst_node *Node) (*Edge, error) {
+func (graph *Graph) NewEdge(src_node *Node, dst_node *Node) (new_edge *Edge, err error) {
+	defer recoverInternalError("NewEdge", &err)
+
+	if err := graph.checkEdgeEnds("NewEdge", src_node, dst_node); err != nil {
+		return nil, err
+	}
 
+	edge_p := &Edge{
+		id:                graph.edgeCount,
+		srcNode:           src_node,
+		dstNode:           dst_node,
+		nextOutcomingEdge: nil,
+		prevOutcomingEdge: nil,
+		nextIncomingEdge:  nil,
+		prevIncomingEdge:  nil,
+		graph:             graph,
+	}
+
+	edge_p.linkToNodes()
+	edge_p.calcNestAndMoveToIt()
+	graph.edgeCount++
+	graph.registerEdge(edge_p)
+	graph.journalAdd(&journalNewEdge{edge_p})
+	graph.notifyElem(EVENT_EDGE_NEW, nil, edge_p, edge_p.nest, nil)
+
+	return edge_p, nil
+}
+
+// Check that the nodes can be the source and the destination nodes of an edge in a Graph
+func (graph *Graph) checkEdgeEnds(op string, src_node *Node, dst_node *Node) error {
 	if src_node == nil {
-		return nil, errors.New("Pointer to the source node cannot be \"nil\"")
+		return &ElemError{op, -1, -1, "Pointer to the source node cannot be \"nil\"",
+			ErrNilNode}
 	}
 
 	if dst_node == nil {
-		return nil, errors.New("Pointer to the destination node cannot be \"nil\"")
+		return &ElemError{op, -1, -1, "Pointer to the destination node cannot be \"nil\"",
+			ErrNilNode}
 	}
 
 	if src_node.graph != graph {
-		return nil, errors.New("Source node doesn't belong to the graph for " +
-			"which the method is called")
+		return &ElemError{op, src_node.id, -1, "Source node doesn't belong to the graph " +
+			"for which the method is called", ErrForeignNode}
 	}
 
 	if dst_node.graph != graph {
-		return nil, errors.New("Destination node doesn't belong to the graph " +
-			"for which the method is called")
+		return &ElemError{op, dst_node.id, -1, "Destination node doesn't belong to the " +
+			"graph for which the method is called", ErrForeignNode}
 	}
 
-	src_first_out_edge := src_node.firstOutcomingEdge
-	dst_first_in_edge := dst_node.firstIncomingEdge
-	edge_p := &Edge{
-		id:                graph.edgeCount,
-		srcNode:           src_node,
-		dstNode:           dst_node,
-		nextOutcomingEdge: src_first_out_edge,
-		prevOutcomingEdge: nil,
-		nextIncomingEdge:  dst_first_in_edge,
-		prevIncomingEdge:  nil,
-		graph:             graph,
+	if src_node.nest == nil {
+		return &ElemError{op, src_node.id, -1, "Source node is detached from the graph",
+			ErrElemDetached}
 	}
 
-	if src_first_out_edge != nil {
-		src_first_out_edge.prevOutcomingEdge = edge_p
+	if dst_node.nest == nil {
+		return &ElemError{op, dst_node.id, -1, "Destination node is detached from the " +
+			"graph", ErrElemDetached}
 	}
 
-	src_node.firstOutcomingEdge = edge_p
+	return nil
+}
 
-	if dst_first_in_edge != nil {
-		dst_first_in_edge.prevIncomingEdge = edge_p
+// Get all edges connecting two nodes. In a directed graph only the edges going from
+// "src_node" to "dst_node" are returned. In an undirected graph the edges going in the
+// opposite direction are returned as well. The edges are ordered by their IDs
+func (graph *Graph) EdgesBetween(src_node *Node, dst_node *Node) ([]*Edge, error) {
+	if err := graph.checkEdgeEnds("EdgesBetween", src_node, dst_node); err != nil {
+		return nil, err
 	}
 
-	dst_node.firstIncomingEdge = edge_p
-	edge_p.calcNestAndMoveToIt()
-	graph.edgeCount++
+	edges := collectEdges(src_node, dst_node, nil, false)
 
-	return edge_p, nil
+	if graph.undirected && src_node != dst_node {
+		edges = collectEdges(dst_node, src_node, edges, false)
+	}
+
+	sort.Slice(edges, func(i, j int) bool { return edges[i].id < edges[j].id })
+
+	return edges, nil
+}
+
+// Check whether two nodes are connected by at least one edge. In a directed graph the
+// edge must go from "src_node" to "dst_node". In an undirected graph the direction
+// doesn't matter
+func (graph *Graph) HasEdge(src_node *Node, dst_node *Node) (bool, error) {
+	if err := graph.checkEdgeEnds("HasEdge", src_node, dst_node); err != nil {
+		return false, err
+	}
+
+	if len(collectEdges(src_node, dst_node, nil, true)) > 0 {
+		return true, nil
+	}
+
+	if graph.undirected && len(collectEdges(dst_node, src_node, nil, true)) > 0 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Append the edges going from one node to another to a list. If "first_only" is "true",
+// at most one edge is appended
+func collectEdges(src_node *Node, dst_node *Node, edges []*Edge,
+	first_only bool) []*Edge {
+
+	// Scan the shorter of the two adjacency lists
+	if src_node.outDegree <= dst_node.inDegree {
+		for edge := src_node.firstOutcomingEdge; edge != nil; edge = edge.nextOutcomingEdge {
+			if edge.dstNode == dst_node {
+				if edges = append(edges, edge); first_only {
+					break
+				}
+			}
+		}
+	} else {
+		for edge := dst_node.firstIncomingEdge; edge != nil; edge = edge.nextIncomingEdge {
+			if edge.srcNode == src_node {
+				if edges = append(edges, edge); first_only {
+					break
+				}
+			}
+		}
+	}
+
+	return edges
+}
+
+// Set whether a graph is directed. Graphs are directed by default
+//
+// Edges are stored in the same way in both modes: an edge of an undirected graph still
+// has the "source" and the "destination" nodes. The mode only affects how the edges are
+// interpreted by the mode-aware methods ("EdgesBetween()", "HasEdge()", "Node.Degree()",
+// "Node.Neighbors()", "Node.IncidentEdges()", etc.) and by the emitters. So, the mode can
+// be switched at any moment. The methods that explicitly refer to the direction of edges
+// ("GetFirstOutcomingEdge()", "OutDegree()", "Successors()", etc.) are not affected
+//
+// NOTE: the mode switch is not recorded by the journal and is not reported to observers
+func (graph *Graph) SetDirected(directed bool) {
+	graph.undirected = !directed
+}
+
+// Check whether a graph is directed
+func (graph *Graph) IsDirected() bool {
+	return !graph.undirected
 }
 
 // Get attribute specification of a Graph
@@ -419,6 +634,78 @@ func (graph *Graph) GetAttrSpec() AttrSpec {
 	return graph.attrSpec
 }
 
+// Get graph node by its ID. Returns "nil" if there is no such node (or it was deleted)
+func (graph *Graph) NodeByID(id int) *Node {
+	if id < 0 || id >= len(graph.nodesByID) {
+		return nil
+	}
+
+	return graph.nodesByID[id]
+}
+
+// Get graph edge by its ID. Returns "nil" if there is no such edge (or it was deleted)
+func (graph *Graph) EdgeByID(id int) *Edge {
+	if id < 0 || id >= len(graph.edgesByID) {
+		return nil
+	}
+
+	return graph.edgesByID[id]
+}
+
+// Get number of live graph nodes. Node IDs are NOT guaranteed to be less than the
+// returned number, since IDs of deleted nodes are never reused
+func (graph *Graph) NodeCount() int {
+	return graph.liveNodeCount
+}
+
+// Get number of live graph edges (see the note for "NodeCount()")
+func (graph *Graph) EdgeCount() int {
+	return graph.liveEdgeCount
+}
+
+// Add a node (newly created or re-attached to the graph) to the ID lookup table
+func (graph *Graph) registerNode(node *Node) {
+	graph.nodesByID = registerByID(graph.nodesByID, node.id, node, "node")
+	graph.liveNodeCount++
+}
+
+// Remove a node (detached from the graph) from the ID lookup table
+func (graph *Graph) unregisterNode(node *Node) {
+	graph.nodesByID[node.id] = nil
+	graph.liveNodeCount--
+}
+
+// Add an edge (newly created or re-attached to the graph) to the ID lookup table
+func (graph *Graph) registerEdge(edge *Edge) {
+	graph.edgesByID = registerByID(graph.edgesByID, edge.id, edge, "edge")
+	graph.liveEdgeCount++
+}
+
+// Remove an edge (detached from the graph) from the ID lookup table
+func (graph *Graph) unregisterEdge(edge *Edge) {
+	graph.edgesByID[edge.id] = nil
+	graph.liveEdgeCount--
+}
+
+// Put an element to an ID lookup table. IDs are allocated sequentially, so the element
+// is either re-attached (and its slot already exists) or the newest one (and its slot
+// immediately follows the last existing one)
+func registerByID[E comparable](table []E, id int, elem E, kind string) []E {
+	var zero E
+
+	switch {
+	case id == len(table):
+		return append(table, elem)
+	case id >= 0 && id < len(table) && table[id] == zero:
+		table[id] = elem
+
+		return table
+	}
+
+	panic(fmt.Sprintf("Panic while registering a %s: ID %d is already taken or out of "+
+		"sequence", kind, id))
+}
+
 // Get node ID
 func (node *Node) GetID() int {
 	return node.id
@@ -444,14 +731,29 @@ func (node *Node) GetGraph() *Graph {
 	return node.graph
 }
 
-// Get next node in a list of all Graph nodes
+// Get next node in a list of all Graph nodes. "nil" is returned for the last node and for
+// a node detached from the graph (see "GetNextNodeChecked()")
 func (node *Node) GetNextNode() *Node {
-	if node.nextNodeInNest != nil {
-		return node.nextNodeInNest
+	next_node, err := node.GetNextNodeChecked()
+
+	if err != nil {
+		return nil
 	}
 
-	if node.nest == nil {
-		panic("Graph node not assigned to any nest")
+	return next_node
+}
+
+// Get next node in a list of all Graph nodes. In contrast to "GetNextNode()", an error is
+// returned if the node is detached from the graph
+func (node *Node) GetNextNodeChecked() (next_node *Node, err error) {
+	defer recoverInternalError("GetNextNodeChecked", &err)
+
+	if err := node.checkAttached("GetNextNodeChecked"); err != nil {
+		return nil, err
+	}
+
+	if node.nextNodeInNest != nil {
+		return node.nextNodeInNest, nil
 	}
 
 	nest := node.nest.GetNextNest()
@@ -461,20 +763,35 @@ func (node *Node) GetNextNode() *Node {
 	}
 
 	if nest == nil {
-		return nil
+		return nil, nil
 	}
 
-	return nest.firstNode
+	return nest.firstNode, nil
 }
 
-// Get previous node in a list of all Graph nodes
+// Get previous node in a list of all Graph nodes. "nil" is returned for the first node
+// and for a node detached from the graph (see "GetPrevNodeChecked()")
 func (node *Node) GetPrevNode() *Node {
-	if node.prevNodeInNest != nil {
-		return node.prevNodeInNest
+	prev_node, err := node.GetPrevNodeChecked()
+
+	if err != nil {
+		return nil
 	}
 
-	if node.nest == nil {
-		panic("Graph node not assigned to any nest")
+	return prev_node
+}
+
+// Get previous node in a list of all Graph nodes. In contrast to "GetPrevNode()", an
+// error is returned if the node is detached from the graph
+func (node *Node) GetPrevNodeChecked() (prev_node *Node, err error) {
+	defer recoverInternalError("GetPrevNodeChecked", &err)
+
+	if err := node.checkAttached("GetPrevNodeChecked"); err != nil {
+		return nil, err
+	}
+
+	if node.prevNodeInNest != nil {
+		return node.prevNodeInNest, nil
 	}
 
 	nest := node.nest.GetPrevNest()
@@ -484,10 +801,24 @@ func (node *Node) GetPrevNode() *Node {
 	}
 
 	if nest == nil {
-		return nil
+		return nil, nil
+	}
+
+	return nest.lastNode, nil
+}
+
+// Check that a node is not "nil" and is attached to a graph (i.e. belongs to some nest)
+func (node *Node) checkAttached(op string) error {
+	if node == nil {
+		return &ElemError{op, -1, -1, "Zero reference to the node", ErrNilNode}
 	}
 
-	return nest.lastNode
+	if node.nest == nil {
+		return &ElemError{op, node.id, -1, "The node is not assigned to any nest",
+			ErrElemDetached}
+	}
+
+	return nil
 }
 
 // Get first outcoming edge of a Basic Node
@@ -500,34 +831,48 @@ func (node *Node) GetFirstIncomingEdge() *Edge {
 	return node.firstIncomingEdge
 }
 
-// Set value of a Basic Node string attribute
-func (node *Node) SetStrAttrVal(attr *NodeStrAttr, val string) error {
-	if attr.isValid == false {
-		return errors.New("The attribute is invalid")
+// Get number of edges incoming to a node (parallel edges are counted separately)
+func (node *Node) InDegree() int {
+	return node.inDegree
+}
+
+// Get number of edges outcoming from a node (parallel edges are counted separately)
+func (node *Node) OutDegree() int {
+	return node.outDegree
+}
+
+// Get degree of a node. In a directed graph it's the out-degree of the node. In an
+// undirected graph it's the number of edges incident to the node (a loop is counted
+// twice)
+func (node *Node) Degree() int {
+	if node.graph.undirected {
+		return node.inDegree + node.outDegree
 	}
 
-	if attr.graph != node.graph {
-		return errors.New("The attribute and the node belong to different graphs")
+	return node.outDegree
+}
+
+// Set value of a Basic Node string attribute
+func (node *Node) SetStrAttrVal(attr *NodeStrAttr, val string) error {
+	if err := node.checkStrAttr("SetStrAttrVal", attr); err != nil {
+		return err
 	}
 
-	node.strAttrs[attr.attrNum].isSet = true
-	node.strAttrs[attr.attrNum].data = val
+	node.graph.changeStrAttr(ATTR_ELEM_NODE, node, nil, attr.attrNum,
+		strAttrVal{true, val})
 
 	return nil
 }
 
 // Get value of a Basic Node string attribute
 func (node *Node) GetStrAttrVal(attr *NodeStrAttr) (string, error) {
-	if !attr.isValid {
-		return "", errors.New("The attribute is invalid")
-	}
-
-	if attr.graph != node.graph {
-		return "", errors.New("The attribute and the node belong to different graphs")
+	if err := node.checkStrAttr("GetStrAttrVal", attr); err != nil {
+		return "", err
 	}
 
 	if !node.strAttrs[attr.attrNum].isSet {
-		return "", errors.New("The attribute is not set for the node")
+		return "", newAttrError("GetStrAttrVal", ATTR_ELEM_NODE, node.id, attr.attrNum,
+			ErrAttrNotSet)
 	}
 
 	return node.strAttrs[attr.attrNum].data, nil
@@ -535,72 +880,124 @@ func (node *Node) GetStrAttrVal(attr *NodeStrAttr) (string, error) {
 
 // Remove string attribute from a specific Basic Node
 func (node *Node) RemoveStrAttr(attr *NodeStrAttr) error {
-	if !attr.isValid {
-		errors.New("The attribute is invalid")
+	if err := node.checkStrAttr("RemoveStrAttr", attr); err != nil {
+		return err
 	}
 
-	if attr.graph != node.graph {
-		errors.New("The attribute and the node belong to different graphs")
-	}
-
-	node.strAttrs[attr.attrNum].isSet = false
+	node.graph.changeStrAttr(ATTR_ELEM_NODE, node, nil, attr.attrNum, strAttrVal{})
 
 	return nil
 }
 
 // Check wheter a string attribute is set for a Basic Node
 func (node *Node) IsStrAttrSet(attr *NodeStrAttr) (bool, error) {
-	if !attr.isValid {
-		return false, errors.New("The attribute is invalid")
+	if err := node.checkStrAttr("IsStrAttrSet", attr); err != nil {
+		return false, err
+	}
+
+	return node.strAttrs[attr.attrNum].isSet, nil
+}
+
+// Check that a string attribute is valid and can be used with a Basic Node
+func (node *Node) checkStrAttr(op string, attr *NodeStrAttr) error {
+	if node == nil {
+		return &ElemError{op, -1, -1, "Zero reference to the node", ErrNilNode}
+	}
+
+	if attr == nil || !attr.isValid {
+		return newAttrError(op, ATTR_ELEM_NODE, node.id, -1, ErrAttrInvalid)
 	}
 
 	if attr.graph != node.graph {
-		return false, errors.New("The attribute and the node belong to different graphs")
+		return newAttrError(op, ATTR_ELEM_NODE, node.id, attr.attrNum, ErrAttrForeign)
 	}
 
-	return node.strAttrs[attr.attrNum].isSet, nil
+	return nil
 }
 
 // Move graph node to a specific nest
 //
 // Nests get automatically recalculated for edges incoming to and outcoming from the node
-func (node *Node) MoveToNest(nest *Nest) error {
-	panic_msg_prefix := "Panic while moving a graph node to a different nest: "
+func (node *Node) MoveToNest(nest *Nest) (err error) {
+	defer recoverInternalError("MoveToNest", &err)
 
-	if nest.nestTree.baseGraph != node.graph {
-		return errors.New("Attempt to move a graph node to a nest that belongs to a " +
-			"different graph")
+	if err := node.checkAttached("MoveToNest"); err != nil {
+		return err
 	}
 
-	if node.nest == nil {
-		panic(panic_msg_prefix + "the node is not assigned to any \"source\" nest")
+	if nest == nil {
+		return &ElemError{"MoveToNest", node.id, -1, "Pointer to the destination nest " +
+			"cannot be \"nil\"", ErrNilNest}
+	}
+
+	if nest.nestTree == nil || nest.nestTree.baseGraph != node.graph {
+		return &ElemError{"MoveToNest", node.id, nest.id, "Attempt to move a graph " +
+			"node to a nest that belongs to a different graph", ErrForeignNest}
 	}
 
+	if nest.nestTree.NestByID(nest.id) != nest {
+		return &ElemError{"MoveToNest", node.id, nest.id, "The destination nest was " +
+			"removed from the nest tree", ErrElemDetached}
+	}
+
+	var move_rec *journalMoveNode
+	src_nest := node.nest
+
+	if node.graph.isJournalRecording() {
+		move_rec = &journalMoveNode{node: node, srcNest: node.nest, dstNest: nest,
+			prevNode: node.prevNodeInNest}
+	}
+
+	node.moveToNest(nest, move_rec)
+
+	if move_rec != nil {
+		node.graph.journalAdd(move_rec)
+	}
+
+	node.graph.notifyElem(EVENT_NODE_MOVE, node, nil, nest, src_nest)
+
+	return nil
+}
+
+// Move graph node to a specific nest and recalculate nests of the adjacent edges
+//
+// If "move_rec" is not "nil", the previous placement of the adjacent edges is recorded
+// into it (so that the move can be undone later). The placement of the node itself must
+// be recorded by the caller
+func (node *Node) moveToNest(nest *Nest, move_rec *journalMoveNode) {
+	panic_msg_prefix := "Panic while moving a graph node to a different nest: "
+
 	node.nest.removeNode(node)
 	node.nest = nest
 	nest.addNode(node)
 
 	// Fix nest attribution for edges incoming to the node
-	for edge := node.GetFirstIncomingEdge(); edge != nil; edge.GetNextIncomingEdge() {
-		if edge.nest == nil {
+	in_edge := node.GetFirstIncomingEdge()
+
+	for ; in_edge != nil; in_edge = in_edge.GetNextIncomingEdge() {
+		if in_edge.nest == nil {
 			panic(panic_msg_prefix + "the node has in incoming edge that is not " +
 				"assigned to any nest")
 		}
 
-		edge.calcNestAndMoveToIt()
+		move_rec.recordEdge(in_edge)
+		in_edge.calcNestAndMoveToIt()
 	}
 
 	// Fix nest attribution for edges outcoming from the node
-	for edge := node.GetFirstOutcomingEdge(); edge != nil; edge.GetNextOutcomingEdge() {
-		if edge.nest == nil {
+	out_edge := node.GetFirstOutcomingEdge()
+
+	for ; out_edge != nil; out_edge = out_edge.GetNextOutcomingEdge() {
+		if out_edge.nest == nil {
 			panic(panic_msg_prefix + "the node has in outcoming edge that is not " +
 				"assigned to any nest")
 		}
 
-		edge.calcNestAndMoveToIt()
+		move_rec.recordEdge(out_edge)
+		out_edge.calcNestAndMoveToIt()
 	}
 
-	return nil
+	return
 }
 
 // Get Basic Edge ID
@@ -653,6 +1050,69 @@ func (edge *Edge) GetPrevEdgeInNest() *Edge {
 	return edge.prevEdgeInNest
 }
 
+// Link an edge into the lists of outcoming edges of its source node and incoming edges of
+// its destination node. The edge becomes the first one in both lists
+func (edge *Edge) linkToNodes() {
+	src_node := edge.srcNode
+	dst_node := edge.dstNode
+	src_first_out_edge := src_node.firstOutcomingEdge
+	dst_first_in_edge := dst_node.firstIncomingEdge
+
+	edge.nextOutcomingEdge = src_first_out_edge
+	edge.prevOutcomingEdge = nil
+	edge.nextIncomingEdge = dst_first_in_edge
+	edge.prevIncomingEdge = nil
+
+	if src_first_out_edge != nil {
+		src_first_out_edge.prevOutcomingEdge = edge
+	}
+
+	src_node.firstOutcomingEdge = edge
+	src_node.outDegree++
+
+	if dst_first_in_edge != nil {
+		dst_first_in_edge.prevIncomingEdge = edge
+	}
+
+	dst_node.firstIncomingEdge = edge
+	dst_node.inDegree++
+
+	return
+}
+
+// Unlink an edge from the lists of outcoming edges of its source node and incoming edges
+// of its destination node
+func (edge *Edge) unlinkFromNodes() {
+	if edge.nextOutcomingEdge != nil {
+		edge.nextOutcomingEdge.prevOutcomingEdge = edge.prevOutcomingEdge
+	}
+
+	if edge.prevOutcomingEdge != nil {
+		edge.prevOutcomingEdge.nextOutcomingEdge = edge.nextOutcomingEdge
+	} else {
+		edge.srcNode.firstOutcomingEdge = edge.nextOutcomingEdge
+	}
+
+	if edge.nextIncomingEdge != nil {
+		edge.nextIncomingEdge.prevIncomingEdge = edge.prevIncomingEdge
+	}
+
+	if edge.prevIncomingEdge != nil {
+		edge.prevIncomingEdge.nextIncomingEdge = edge.nextIncomingEdge
+	} else {
+		edge.dstNode.firstIncomingEdge = edge.nextIncomingEdge
+	}
+
+	edge.nextOutcomingEdge = nil
+	edge.prevOutcomingEdge = nil
+	edge.nextIncomingEdge = nil
+	edge.prevIncomingEdge = nil
+	edge.srcNode.outDegree--
+	edge.dstNode.inDegree--
+
+	return
+}
+
 // Calculate nest to which an edge should belong. Add the edge to this nest
 //
 // This method must not be visible outside the Graph package. Only graph nodes can be
@@ -700,39 +1160,7 @@ func (edge *Edge) calcNestAndMoveToIt() {
 
 	// Find the closest nest that contains both the source and destination nest. The edge
 	// will be added to the found nest
-	panic_msg_inconsistent_nt := ": either the nest tree has disconnected components " +
-		"or the nests have inconsistent levels"
-
-	// If the source nest is "deeper" than the destination nest in the nest hierarchy,
-	// then find a nest which contains the source nest but belongs to the same
-	// level in the nest hierarchy as the destination nest
-	for src_nest.level > dst_nest.level {
-		src_nest = src_nest.parentNest
-
-		if src_nest == nil {
-			panic(panic_msg_prefix + panic_msg_inconsistent_nt)
-		}
-	}
-
-	// If the destination nest is "deeper" than the source nest in the nest hierarchy,
-	// then find a nest which contains the destination nest but belongs to the same level
-	// in the nest hierarchy as the source nest
-	for dst_nest.level > src_nest.level {
-		dst_nest = dst_nest.parentNest
-
-		if dst_nest == nil {
-			panic(panic_msg_prefix + panic_msg_inconsistent_nt)
-		}
-	}
-
-	for dst_nest != src_nest {
-		dst_nest = dst_nest.parentNest
-		src_nest = src_nest.parentNest
-
-		if dst_nest == nil || src_nest == nil {
-			panic(panic_msg_prefix + panic_msg_inconsistent_nt)
-		}
-	}
+	src_nest = lowestCommonNest(src_nest, dst_nest, panic_msg_prefix)
 
 	// Newly created edges may not be assigned to any nest
 	if edge.nest != nil {
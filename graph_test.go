@@ -0,0 +1,234 @@
+package graph
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Check the numbers of live nodes, edges and nests of a graph
+func expectCounts(t *testing.T, graph *Graph, nodes int, edges int, nests int,
+	step string) {
+
+	t.Helper()
+
+	if graph.NodeCount() != nodes || graph.EdgeCount() != edges ||
+		graph.GetNestTree().NestCount() != nests {
+
+		t.Fatalf("%s: got %d nodes, %d edges and %d nests, want %d, %d and %d", step,
+			graph.NodeCount(), graph.EdgeCount(), graph.GetNestTree().NestCount(), nodes,
+			edges, nests)
+	}
+}
+
+// Elements are found by their IDs until their creation is undone
+func TestLookupByID(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	nest_tree := graph.GetNestTree()
+	a := graph.NewNode()
+	b := graph.NewNode()
+	edge, _ := graph.NewEdge(a, b)
+	nest := nest_tree.NewNest()
+
+	if graph.NodeByID(a.GetID()) != a || graph.NodeByID(b.GetID()) != b ||
+		graph.EdgeByID(edge.GetID()) != edge || nest_tree.NestByID(nest.GetID()) != nest ||
+		nest_tree.NestByID(0) != nest_tree.GetRootNest() {
+
+		t.Fatalf("elements are not found by their IDs")
+	}
+
+	if graph.NodeByID(-1) != nil || graph.NodeByID(2) != nil || graph.EdgeByID(1) != nil ||
+		nest_tree.NestByID(2) != nil {
+
+		t.Fatalf("unknown IDs are found")
+	}
+
+	expectCounts(t, graph, 2, 1, 2, "create")
+
+	journal := graph.EnableJournal()
+	journal.Begin()
+	c := graph.NewNode()
+	loop, _ := graph.NewEdge(c, c)
+	child, _ := nest.NewChildNest()
+	journal.Commit()
+	expectCounts(t, graph, 3, 2, 3, "journaled")
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	if graph.NodeByID(c.GetID()) != nil || graph.EdgeByID(loop.GetID()) != nil ||
+		nest_tree.NestByID(child.GetID()) != nil {
+
+		t.Fatalf("undone elements are found by their IDs")
+	}
+
+	expectCounts(t, graph, 2, 1, 2, "undo")
+
+	if err := journal.Redo(); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+
+	if graph.NodeByID(c.GetID()) != c || graph.EdgeByID(loop.GetID()) != loop ||
+		nest_tree.NestByID(child.GetID()) != child {
+
+		t.Fatalf("redone elements are not found by their IDs")
+	}
+
+	expectCounts(t, graph, 3, 2, 3, "redo")
+
+	// IDs of undone elements are not reused
+	journal.Undo()
+
+	if d := graph.NewNode(); d.GetID() == c.GetID() || graph.NodeByID(d.GetID()) != d {
+		t.Fatalf("the ID of an undone node is reused")
+	}
+}
+
+// Check the in-degree, the out-degree and the degree of a node
+func expectDegrees(t *testing.T, node *Node, in int, out int, degree int, step string) {
+	t.Helper()
+
+	if node.InDegree() != in || node.OutDegree() != out || node.Degree() != degree {
+		t.Fatalf("%s: node %d has degrees in=%d, out=%d, total=%d, want %d, %d and %d",
+			step, node.GetID(), node.InDegree(), node.OutDegree(), node.Degree(), in, out,
+			degree)
+	}
+}
+
+// Parallel edges are counted separately and are all found by the edge queries
+func TestDegreesAndParallelEdges(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	a := graph.NewNode()
+	b := graph.NewNode()
+	c := graph.NewNode()
+	ab1, _ := graph.NewEdge(a, b)
+	graph.NewEdge(a, c)
+	ab2, _ := graph.NewEdge(a, b)
+	graph.NewEdge(c, b)
+	ba, _ := graph.NewEdge(b, a)
+
+	expectDegrees(t, a, 1, 3, 3, "a")
+	expectDegrees(t, b, 3, 1, 1, "b")
+	expectDegrees(t, c, 1, 1, 1, "c")
+
+	// "a" has more outcoming edges than "b" has incoming ones and vice versa. So, both
+	// adjacency lists are scanned by the queries
+	cases := []struct {
+		src  *Node
+		dst  *Node
+		want []*Edge
+	}{
+		{a, b, []*Edge{ab1, ab2}},
+		{b, a, []*Edge{ba}},
+		{c, a, nil},
+	}
+
+	for _, query := range cases {
+		edges, err := graph.EdgesBetween(query.src, query.dst)
+
+		if err != nil {
+			t.Fatalf("EdgesBetween: %v", err)
+		}
+
+		has_edge, _ := graph.HasEdge(query.src, query.dst)
+
+		if !slices.Equal(edges, query.want) || has_edge != (len(query.want) > 0) {
+			t.Errorf("edges from %d to %d: got %d, want %d", query.src.GetID(),
+				query.dst.GetID(), len(edges), len(query.want))
+		}
+	}
+
+	// Degrees follow undo of edge creation
+	journal := graph.EnableJournal()
+	graph.NewEdge(a, a)
+	expectDegrees(t, a, 2, 4, 4, "loop")
+	journal.Undo()
+	expectDegrees(t, a, 1, 3, 3, "undo")
+
+	other := NewGraph(AttrSpec{})
+
+	if _, err := graph.EdgesBetween(a, other.NewNode()); !errors.Is(err, ErrForeignNode) {
+		t.Errorf("EdgesBetween with a foreign node: got error %v, want %v", err,
+			ErrForeignNode)
+	}
+
+	if _, err := graph.HasEdge(nil, a); !errors.Is(err, ErrNilNode) {
+		t.Errorf("HasEdge with a nil node: got error %v, want %v", err, ErrNilNode)
+	}
+}
+
+// In an undirected graph edges connect their ends in both directions
+func TestUndirectedGraph(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	a := graph.NewNode()
+	b := graph.NewNode()
+	c := graph.NewNode()
+	ab, _ := graph.NewEdge(a, b)
+	ba, _ := graph.NewEdge(b, a)
+	loop, _ := graph.NewEdge(a, a)
+	graph.NewEdge(c, a)
+
+	if has_edge, _ := graph.HasEdge(a, c); has_edge {
+		t.Fatalf("a directed edge is found in the opposite direction")
+	}
+
+	expectDegrees(t, a, 3, 2, 2, "directed")
+	graph.SetDirected(false)
+
+	if graph.IsDirected() {
+		t.Fatalf("the graph is still directed")
+	}
+
+	for _, ends := range [][2]*Node{{a, b}, {b, a}} {
+		edges, _ := graph.EdgesBetween(ends[0], ends[1])
+
+		if !slices.Equal(edges, []*Edge{ab, ba}) {
+			t.Errorf("edges between %d and %d: got %d, want 2", ends[0].GetID(),
+				ends[1].GetID(), len(edges))
+		}
+	}
+
+	// A loop is found once
+	if edges, _ := graph.EdgesBetween(a, a); !slices.Equal(edges, []*Edge{loop}) {
+		t.Errorf("loops of a: got %d, want 1", len(edges))
+	}
+
+	if has_edge, _ := graph.HasEdge(a, c); !has_edge {
+		t.Errorf("an edge is not found in the opposite direction")
+	}
+
+	// A loop adds 2 to the degree
+	expectDegrees(t, a, 3, 2, 5, "undirected")
+	expectDegrees(t, c, 0, 1, 1, "undirected")
+
+	if got := slices.Collect(b.Neighbors()); !slices.Equal(got, []*Node{a}) {
+		t.Errorf("neighbors of b: got %v, want [%d]", nodeIDs(got), a.GetID())
+	}
+
+	if got := slices.Collect(a.Neighbors()); len(got) != 3 {
+		t.Errorf("a has %d neighbors, want 3", len(got))
+	}
+
+	if got := slices.Collect(a.IncidentEdges()); len(got) != 4 {
+		t.Errorf("a has %d incident edges, want 4", len(got))
+	}
+
+	// Undirected graphs are emitted with undirected edges
+	out_path := filepath.Join(t.TempDir(), "graph.gv")
+
+	if err := EmitInGVFormat(graph, &GraphEmitSpec{}, out_path); err != nil {
+		t.Fatalf("EmitInGVFormat: %v", err)
+	}
+
+	if out := string(readTestFile(t, out_path)); !strings.HasPrefix(out, "graph ") ||
+		strings.Contains(out, "->") || strings.Count(out, " -- ") != 4 {
+
+		t.Errorf("the undirected graph is emitted as:\n%s", out)
+	}
+
+	graph.SetDirected(true)
+	expectDegrees(t, a, 3, 2, 2, "directed again")
+}
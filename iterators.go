@@ -0,0 +1,203 @@
+/*
+  Range-over-func iterators
+
+  The iterators below are thin wrappers around the "Get*()" navigation methods. They make
+  it possible to traverse the graph by means of the "for ... range" statement:
+
+	for edge := range graph.Edges() {
+		...
+	}
+
+  The elements are visited in the same order as by the corresponding navigation methods.
+  In particular, "Graph.Nodes()" visits the nodes in the order of "GetFirstNode()" and
+  "GetNextNode()", and "Graph.Edges()" visits the outcoming edges of each node in that
+  order. So, every edge is visited exactly once
+
+  NOTE: the graph MUST NOT be modified while an iteration is in progress. Modifications
+        (for example, moving a node to a different nest) may cause some elements to be
+        skipped or visited twice
+*/
+
+package graph
+
+import (
+	"iter"
+)
+
+// Iterate over all graph nodes
+func (graph *Graph) Nodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for node := graph.GetFirstNode(); node != nil; node = node.GetNextNode() {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over all graph edges
+func (graph *Graph) Edges() iter.Seq[*Edge] {
+	return func(yield func(*Edge) bool) {
+		for node := graph.GetFirstNode(); node != nil; node = node.GetNextNode() {
+			for edge := node.firstOutcomingEdge; edge != nil; edge = edge.nextOutcomingEdge {
+				if !yield(edge) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Iterate over outcoming edges of a node
+func (node *Node) OutEdges() iter.Seq[*Edge] {
+	return func(yield func(*Edge) bool) {
+		for edge := node.firstOutcomingEdge; edge != nil; edge = edge.nextOutcomingEdge {
+			if !yield(edge) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over incoming edges of a node
+func (node *Node) InEdges() iter.Seq[*Edge] {
+	return func(yield func(*Edge) bool) {
+		for edge := node.firstIncomingEdge; edge != nil; edge = edge.nextIncomingEdge {
+			if !yield(edge) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over successors of a node (i.e. destination nodes of its outcoming edges).
+// Each successor is visited once, even if it's connected to the node by parallel edges
+func (node *Node) Successors() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		seen := make(map[*Node]bool)
+
+		for edge := node.firstOutcomingEdge; edge != nil; edge = edge.nextOutcomingEdge {
+			if seen[edge.dstNode] {
+				continue
+			}
+
+			seen[edge.dstNode] = true
+
+			if !yield(edge.dstNode) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over edges incident to a node. In a directed graph these are the outcoming
+// edges of the node. In an undirected graph these are the outcoming edges followed by the
+// incoming ones (a loop is visited once)
+func (node *Node) IncidentEdges() iter.Seq[*Edge] {
+	return func(yield func(*Edge) bool) {
+		for edge := range node.OutEdges() {
+			if !yield(edge) {
+				return
+			}
+		}
+
+		if !node.graph.undirected {
+			return
+		}
+
+		for edge := range node.InEdges() {
+			if edge.srcNode != node && !yield(edge) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over neighbors of a node, i.e. over the opposite ends of the edges incident to
+// the node (see "IncidentEdges()"). In a directed graph the neighbors are the successors
+// of the node. Each neighbor is visited once
+func (node *Node) Neighbors() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		seen := make(map[*Node]bool)
+
+		for edge := range node.IncidentEdges() {
+			neighbor := edge.dstNode
+
+			if neighbor == node {
+				neighbor = edge.srcNode
+			}
+
+			if seen[neighbor] {
+				continue
+			}
+
+			seen[neighbor] = true
+
+			if !yield(neighbor) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over child nests of a nest (without their descendants)
+func (nest *Nest) Children() iter.Seq[*Nest] {
+	return func(yield func(*Nest) bool) {
+		for child := nest.firstChildNest; child != nil; child = child.nextSiblingNest {
+			if !yield(child) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over all descendants of a nest in pre-order (a nest is visited before its
+// child nests). The nest itself is not visited
+func (nest *Nest) Descendants() iter.Seq[*Nest] {
+	return func(yield func(*Nest) bool) {
+		nest.yieldDescendants(yield)
+	}
+}
+
+// Pass all descendants of a nest to "yield" in pre-order. Returns "false" if "yield"
+// requested to stop the iteration
+func (nest *Nest) yieldDescendants(yield func(*Nest) bool) bool {
+	for child := nest.firstChildNest; child != nil; child = child.nextSiblingNest {
+		if !yield(child) || !child.yieldDescendants(yield) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Iterate over graph nodes belonging to a nest (without the nodes of its descendants)
+func (nest *Nest) Nodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for node := nest.firstNode; node != nil; node = node.nextNodeInNest {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over graph nodes belonging to a nest and to all its descendants. The nodes of
+// the nest are visited first, then the nodes of the descendants (in pre-order)
+func (nest *Nest) AllNodes() iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for node := range nest.Nodes() {
+			if !yield(node) {
+				return
+			}
+		}
+
+		for desc := range nest.Descendants() {
+			for node := range desc.Nodes() {
+				if !yield(node) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"iter"
+	"slices"
+	"testing"
+)
+
+// Check that an iterator stops as soon as the loop body breaks. The iterator panics if
+// it calls "yield" after the loop is over
+func expectBreak[E any](t *testing.T, seq iter.Seq[E], after int, what string) {
+	t.Helper()
+
+	count := 0
+
+	for range seq {
+		if count++; count == after {
+			break
+		}
+	}
+
+	if count != after {
+		t.Fatalf("%s: the loop stopped after %d elements, want %d", what, count, after)
+	}
+}
+
+// Get IDs of nodes
+func nodeIDs(nodes []*Node) []int {
+	ids := []int{}
+
+	for _, node := range nodes {
+		ids = append(ids, node.GetID())
+	}
+
+	return ids
+}
+
+// Iterators visit the elements in the order of the navigation methods
+func TestIterators(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	root := graph.GetNestTree().GetRootNest()
+	n1 := graph.GetNestTree().NewNest()
+	n11, _ := n1.NewChildNest()
+	n12, _ := n1.NewChildNest()
+	n2 := graph.GetNestTree().NewNest()
+	x := graph.NewNode()
+	a := graph.NewNode()
+	b := graph.NewNode()
+	c := graph.NewNode()
+	d := graph.NewNode()
+	a.MoveToNest(n1)
+	b.MoveToNest(n11)
+	c.MoveToNest(n12)
+	d.MoveToNest(n2)
+
+	// Two parallel edges and a loop
+	var edges []*Edge
+
+	for _, ends := range [][2]*Node{{a, b}, {a, b}, {a, c}, {b, c}, {d, d}, {x, a}} {
+		edge, _ := graph.NewEdge(ends[0], ends[1])
+		edges = append(edges, edge)
+	}
+
+	var want_nodes []*Node
+
+	for node := graph.GetFirstNode(); node != nil; node = node.GetNextNode() {
+		want_nodes = append(want_nodes, node)
+	}
+
+	if got := slices.Collect(graph.Nodes()); !slices.Equal(got, want_nodes) ||
+		len(got) != 5 {
+
+		t.Fatalf("Graph.Nodes() visits %d nodes in a wrong order", len(got))
+	}
+
+	// Every edge is visited once
+	got_edges := slices.Collect(graph.Edges())
+	slices.SortFunc(got_edges, func(e1 *Edge, e2 *Edge) int {
+		return e1.GetID() - e2.GetID()
+	})
+
+	if !slices.Equal(got_edges, edges) {
+		t.Fatalf("Graph.Edges() visits %d edges, want %d", len(got_edges), len(edges))
+	}
+
+	// Child nests and edges are prepended to the lists they belong to
+	checks := []struct {
+		what string
+		got  []*Node
+		want []*Node
+	}{
+		{"root nodes", slices.Collect(root.Nodes()), []*Node{x}},
+		{"all nodes of n1", slices.Collect(n1.AllNodes()), []*Node{a, c, b}},
+		{"all nodes of n11", slices.Collect(n11.AllNodes()), []*Node{b}},
+		{"successors", slices.Collect(a.Successors()), []*Node{c, b}},
+		{"no successors", slices.Collect(c.Successors()), nil},
+	}
+
+	for _, check := range checks {
+		if !slices.Equal(check.got, check.want) {
+			t.Errorf("%s: got nodes %v, want %v", check.what, nodeIDs(check.got),
+				nodeIDs(check.want))
+		}
+	}
+
+	if got := slices.Collect(root.Descendants()); !slices.Equal(got,
+		[]*Nest{n2, n1, n12, n11}) {
+
+		t.Errorf("descendants are not visited in pre-order")
+	}
+
+	if got := slices.Collect(n1.Children()); !slices.Equal(got, []*Nest{n12, n11}) {
+		t.Errorf("n1 has %d children, want 2", len(got))
+	}
+
+	if got := slices.Collect(c.InEdges()); len(got) != 2 || got[0].GetDstNode() != c ||
+		len(slices.Collect(a.OutEdges())) != 3 {
+
+		t.Errorf("incident edges of nodes are not visited")
+	}
+
+	expectBreak(t, graph.Nodes(), 2, "Graph.Nodes()")
+	expectBreak(t, graph.Edges(), 3, "Graph.Edges()")
+	expectBreak(t, a.OutEdges(), 1, "Node.OutEdges()")
+	expectBreak(t, c.InEdges(), 1, "Node.InEdges()")
+	expectBreak(t, a.Successors(), 1, "Node.Successors()")
+	expectBreak(t, n1.Children(), 1, "Nest.Children()")
+	expectBreak(t, root.Descendants(), 2, "Nest.Descendants()")
+	expectBreak(t, n1.Nodes(), 1, "Nest.Nodes()")
+	expectBreak(t, n1.AllNodes(), 2, "Nest.AllNodes()")
+}
@@ -0,0 +1,451 @@
+/*
+  Journal of graph mutations with transactions and undo/redo
+
+  A journal is an optional layer attached to a graph (see "Graph.EnableJournal()"). Once
+  attached, it records the following mutations:
+    - creation of nodes ("Graph.NewNode()"), edges ("Graph.NewEdge()") and nests
+      ("NestTree.NewNest()")
+    - moves of nodes across nests ("Node.MoveToNest()")
+    - changes of attribute values ("SetStrAttrVal()" and "RemoveStrAttr()" of graphs,
+      nodes and nests)
+
+  The recorded mutations are grouped into transactions. A transaction is either explicit
+  (opened by "Begin()" and closed by "Commit()" or "Rollback()") or implicit: a mutation
+  made outside of an explicit transaction forms a transaction of its own
+
+  Undoing a transaction restores the exact prior state of the graph, including the order
+  of elements in all the linked lists (nodes and edges of nests, adjacency lists of nodes,
+  child nests). Elements created by an undone transaction get detached from the graph:
+  they are not reachable from the graph anymore and must not be used until the
+  transaction is redone. Redoing a transaction re-attaches the very same elements (with
+  the same IDs)
+
+  NOTE: allocation and release of attributes are NOT recorded. When an attribute is
+        released, all recorded changes of its values are dropped from the journal. So, the
+        values of a released attribute are never resurrected by undo or redo
+  NOTE: creation of a new transaction clears the redo stack (as in any text editor)
+*/
+
+package graph
+
+import (
+	"errors"
+)
+
+// Journal errors
+var (
+	// "Begin()" was called while a transaction is already open
+	ErrTxActive = errors.New("A transaction is already open")
+	// "Commit()" or "Rollback()" was called while no transaction is open
+	ErrNoTx = errors.New("No transaction is open")
+	// Undo or redo was requested while a transaction is open
+	ErrTxOpen = errors.New("Undo and redo are not possible while a transaction is open")
+	// There is nothing to undo
+	ErrNothingToUndo = errors.New("Nothing to undo")
+	// There is nothing to redo
+	ErrNothingToRedo = errors.New("Nothing to redo")
+)
+
+// A single recorded mutation
+type journalEntry interface {
+	// Revert the mutation. The graph must be in the state right after the mutation
+	undo()
+	// Re-apply the mutation. The graph must be in the state right before the mutation
+	redo()
+}
+
+// Journal of graph mutations
+type Journal struct {
+	// Graph whose mutations are recorded
+	graph *Graph
+	// Mutations of the currently open transaction. "nil" if no transaction is open
+	current []journalEntry
+	// Whether an explicit transaction is open
+	inTx bool
+	// Committed transactions that can be undone (the last one is undone first)
+	undoStack [][]journalEntry
+	// Undone transactions that can be redone (the last one is redone first)
+	redoStack [][]journalEntry
+	// Whether the journal is replaying mutations at the moment. Mutations made while
+	// replaying are not recorded
+	replaying bool
+}
+
+// Attach a journal to a graph. If a journal is already attached, it's returned as is
+func (graph *Graph) EnableJournal() *Journal {
+	if graph.journal == nil {
+		graph.journal = &Journal{graph: graph}
+	}
+
+	return graph.journal
+}
+
+// Detach the journal from a graph. All recorded history is discarded
+func (graph *Graph) DisableJournal() {
+	graph.journal = nil
+}
+
+// Get the journal attached to a graph. Returns "nil" if no journal is attached
+func (graph *Graph) GetJournal() *Journal {
+	return graph.journal
+}
+
+// Check whether mutations of a graph are recorded at the moment
+func (graph *Graph) isJournalRecording() bool {
+	return graph.journal != nil && !graph.journal.replaying
+}
+
+// Record a mutation (if a journal is attached to the graph)
+func (graph *Graph) journalAdd(entry journalEntry) {
+	if !graph.isJournalRecording() {
+		return
+	}
+
+	j := graph.journal
+	j.current = append(j.current, entry)
+
+	// A mutation made outside of an explicit transaction forms a transaction of its own
+	if !j.inTx {
+		j.commitCurrent()
+	}
+}
+
+// Call a function that mutates the graph without recording the mutations (if a journal is
+// attached to the graph)
+func (graph *Graph) withoutJournal(f func()) {
+	j := graph.journal
+
+	if j == nil || j.replaying {
+		f()
+
+		return
+	}
+
+	j.replaying = true
+	defer func() { j.replaying = false }()
+
+	f()
+}
+
+// Drop all recorded changes of a released attribute. Transactions that become empty are
+// dropped as well. Values of the attribute are also cleared for detached elements (which
+// are not reachable from the graph and thus were not cleared when the attribute was
+// released)
+func (graph *Graph) journalForgetStrAttr(elem string, attr_num int) {
+	j := graph.journal
+
+	if j == nil {
+		return
+	}
+
+	forget := func(entries []journalEntry) []journalEntry {
+		kept := entries[:0]
+
+		for _, entry := range entries {
+			switch e := entry.(type) {
+			case *journalStrAttr:
+				if e.elem == elem && e.attrNum == attr_num {
+					continue
+				}
+			case *journalNewNode:
+				if elem == ATTR_ELEM_NODE {
+					e.node.strAttrs[attr_num] = strAttrVal{}
+				}
+			case *journalNewNest:
+				if elem == ATTR_ELEM_NEST {
+					e.nest.strAttrs[attr_num] = strAttrVal{}
+				}
+			}
+
+			kept = append(kept, entry)
+		}
+
+		return kept
+	}
+
+	forget_all := func(stack [][]journalEntry) [][]journalEntry {
+		kept := stack[:0]
+
+		for _, entries := range stack {
+			if entries = forget(entries); len(entries) > 0 {
+				kept = append(kept, entries)
+			}
+		}
+
+		return kept
+	}
+
+	j.current = forget(j.current)
+	j.undoStack = forget_all(j.undoStack)
+	j.redoStack = forget_all(j.redoStack)
+}
+
+// Push the current transaction to the undo stack
+func (j *Journal) commitCurrent() {
+	if len(j.current) > 0 {
+		j.undoStack = append(j.undoStack, j.current)
+		j.redoStack = nil
+	}
+
+	j.current = nil
+}
+
+// Revert a list of mutations (in the reverse order)
+func (j *Journal) undoEntries(entries []journalEntry) {
+	j.replaying = true
+	defer func() { j.replaying = false }()
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i].undo()
+	}
+}
+
+// Re-apply a list of mutations (in the original order)
+func (j *Journal) redoEntries(entries []journalEntry) {
+	j.replaying = true
+	defer func() { j.replaying = false }()
+
+	for _, entry := range entries {
+		entry.redo()
+	}
+}
+
+// Open a transaction. All mutations made until "Commit()" or "Rollback()" will be undone
+// and redone as a whole
+func (j *Journal) Begin() error {
+	if j.inTx {
+		return ErrTxActive
+	}
+
+	j.inTx = true
+	j.current = nil
+
+	return nil
+}
+
+// Close the open transaction and push it to the undo stack
+func (j *Journal) Commit() error {
+	if !j.inTx {
+		return ErrNoTx
+	}
+
+	j.inTx = false
+	j.commitCurrent()
+
+	return nil
+}
+
+// Revert all mutations made by the open transaction and close it. The reverted
+// transaction can't be redone
+func (j *Journal) Rollback() (err error) {
+	defer recoverInternalError("Journal.Rollback", &err)
+
+	if !j.inTx {
+		return ErrNoTx
+	}
+
+	entries := j.current
+	j.inTx = false
+	j.current = nil
+	j.undoEntries(entries)
+
+	return nil
+}
+
+// Check whether a transaction is open
+func (j *Journal) InTx() bool {
+	return j.inTx
+}
+
+// Check whether there is a transaction to undo
+func (j *Journal) CanUndo() bool {
+	return !j.inTx && len(j.undoStack) > 0
+}
+
+// Check whether there is a transaction to redo
+func (j *Journal) CanRedo() bool {
+	return !j.inTx && len(j.redoStack) > 0
+}
+
+// Undo the last committed transaction
+func (j *Journal) Undo() (err error) {
+	defer recoverInternalError("Journal.Undo", &err)
+
+	if j.inTx {
+		return ErrTxOpen
+	}
+
+	if len(j.undoStack) == 0 {
+		return ErrNothingToUndo
+	}
+
+	entries := j.undoStack[len(j.undoStack)-1]
+	j.undoStack = j.undoStack[:len(j.undoStack)-1]
+	j.undoEntries(entries)
+	j.redoStack = append(j.redoStack, entries)
+
+	return nil
+}
+
+// Redo the last undone transaction
+func (j *Journal) Redo() (err error) {
+	defer recoverInternalError("Journal.Redo", &err)
+
+	if j.inTx {
+		return ErrTxOpen
+	}
+
+	if len(j.redoStack) == 0 {
+		return ErrNothingToRedo
+	}
+
+	entries := j.redoStack[len(j.redoStack)-1]
+	j.redoStack = j.redoStack[:len(j.redoStack)-1]
+	j.redoEntries(entries)
+	j.undoStack = append(j.undoStack, entries)
+
+	return nil
+}
+
+// Creation of a graph node
+type journalNewNode struct {
+	node *Node
+}
+
+func (e *journalNewNode) undo() {
+	// All later mutations are already undone. So, the node is in the nest where it was
+	// created and has no adjacent edges
+	nest := e.node.nest
+	nest.removeNode(e.node)
+	e.node.nest = nil
+	e.node.graph.unindexNode(e.node)
+	e.node.graph.unregisterNode(e.node)
+	e.node.graph.notifyElem(EVENT_NODE_DELETE, e.node, nil, nest, nil)
+}
+
+func (e *journalNewNode) redo() {
+	root_nest := e.node.graph.nestTree.rootNest
+	e.node.nest = root_nest
+	root_nest.addNode(e.node)
+	e.node.graph.registerNode(e.node)
+	e.node.graph.indexNode(e.node)
+	e.node.graph.notifyElem(EVENT_NODE_NEW, e.node, nil, root_nest, nil)
+}
+
+// Creation of a graph edge
+type journalNewEdge struct {
+	edge *Edge
+}
+
+func (e *journalNewEdge) undo() {
+	nest := e.edge.nest
+	e.edge.unlinkFromNodes()
+	nest.removeEdge(e.edge)
+	e.edge.nest = nil
+	e.edge.graph.unregisterEdge(e.edge)
+	e.edge.graph.notifyElem(EVENT_EDGE_DELETE, nil, e.edge, nest, nil)
+}
+
+func (e *journalNewEdge) redo() {
+	e.edge.linkToNodes()
+	e.edge.calcNestAndMoveToIt()
+	e.edge.graph.registerEdge(e.edge)
+	e.edge.graph.notifyElem(EVENT_EDGE_NEW, nil, e.edge, e.edge.nest, nil)
+}
+
+// Creation of a nest
+type journalNewNest struct {
+	nest *Nest
+	// Parent of the created nest
+	parent *Nest
+}
+
+func (e *journalNewNest) undo() {
+	e.nest.parentNest.removeChildNest(e.nest)
+	e.nest.nestTree.unindexNest(e.nest)
+	e.nest.nestTree.unregisterNest(e.nest)
+	e.nest.nestTree.baseGraph.notifyElem(EVENT_NEST_DELETE, nil, nil, e.nest, nil)
+}
+
+func (e *journalNewNest) redo() {
+	e.parent.addChildNest(e.nest)
+	e.nest.nestTree.registerNest(e.nest)
+	e.nest.nestTree.indexNest(e.nest)
+	e.nest.nestTree.baseGraph.notifyElem(EVENT_NEST_NEW, nil, nil, e.nest, nil)
+}
+
+// Previous placement of an edge whose nest was recalculated
+type journalEdgePlacement struct {
+	edge     *Edge
+	nest     *Nest
+	prevEdge *Edge
+}
+
+// Move of a graph node to a different nest
+type journalMoveNode struct {
+	node *Node
+	// Nest from which the node was moved
+	srcNest *Nest
+	// Nest to which the node was moved
+	dstNest *Nest
+	// Node that preceded the moved node in the source nest
+	prevNode *Node
+	// Previous placement of the adjacent edges (in the order their nests were
+	// recalculated)
+	edges []journalEdgePlacement
+}
+
+// Record the current placement of an edge (before its nest is recalculated). Does
+// nothing if called for "nil" record
+func (e *journalMoveNode) recordEdge(edge *Edge) {
+	if e == nil {
+		return
+	}
+
+	e.edges = append(e.edges, journalEdgePlacement{edge, edge.nest, edge.prevEdgeInNest})
+}
+
+func (e *journalMoveNode) undo() {
+	for i := len(e.edges) - 1; i >= 0; i-- {
+		placement := e.edges[i]
+		edge := placement.edge
+
+		edge.nest.removeEdge(edge)
+		edge.nest = placement.nest
+		placement.nest.insertEdgeAfter(edge, placement.prevEdge)
+	}
+
+	e.node.nest.removeNode(e.node)
+	e.node.nest = e.srcNest
+	e.srcNest.insertNodeAfter(e.node, e.prevNode)
+	e.node.graph.notifyElem(EVENT_NODE_MOVE, e.node, nil, e.srcNest, e.dstNest)
+}
+
+func (e *journalMoveNode) redo() {
+	e.node.moveToNest(e.dstNest, nil)
+	e.node.graph.notifyElem(EVENT_NODE_MOVE, e.node, nil, e.dstNest, e.srcNest)
+}
+
+// Change of a string attribute value
+type journalStrAttr struct {
+	// Kind of the element the attribute relates to (one of ATTR_ELEM_* constants)
+	elem string
+	// Graph whose attribute (or whose node's or nest's attribute) was changed
+	graph *Graph
+	// The node or the nest whose attribute was changed ("nil" if not applicable)
+	node *Node
+	nest *Nest
+	// Number of the attribute
+	attrNum int
+	// Values before and after the change
+	oldVal strAttrVal
+	newVal strAttrVal
+}
+
+func (e *journalStrAttr) undo() {
+	e.graph.assignStrAttr(e.elem, e.node, e.nest, e.attrNum, e.oldVal)
+}
+
+func (e *journalStrAttr) redo() {
+	e.graph.assignStrAttr(e.elem, e.node, e.nest, e.attrNum, e.newVal)
+}
@@ -0,0 +1,243 @@
+package graph
+
+import (
+	"errors"
+	"testing"
+)
+
+// Check that the graph is consistent after a step of a test
+func expectConsistent(t *testing.T, graph *Graph, step string) {
+	t.Helper()
+
+	if violations := graph.Validate(); len(violations) != 0 {
+		t.Fatalf("%s: inconsistent graph: %v", step, violations)
+	}
+}
+
+func expectAttrVal(t *testing.T, node *Node, attr *NodeStrAttr, want_set bool,
+	want_val string, step string) {
+
+	t.Helper()
+
+	is_set, err := node.IsStrAttrSet(attr)
+
+	if err != nil {
+		t.Fatalf("%s: IsStrAttrSet: %v", step, err)
+	}
+
+	if is_set != want_set {
+		t.Fatalf("%s: attribute is set = %t, want %t", step, is_set, want_set)
+	}
+
+	if !is_set {
+		return
+	}
+
+	if val, _ := node.GetStrAttrVal(attr); val != want_val {
+		t.Fatalf("%s: attribute value = %q, want %q", step, val, want_val)
+	}
+}
+
+func TestJournalUndoRedo(t *testing.T) {
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 1})
+	journal := graph.EnableJournal()
+	attr, _ := graph.NewNodeStrAttr()
+	nest := graph.GetNestTree().NewNest()
+	a := graph.NewNode()
+	b := graph.NewNode()
+
+	if err := journal.Begin(); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+
+	graph.NewEdge(a, b)
+	a.MoveToNest(nest)
+	a.SetStrAttrVal(attr, "a")
+
+	if err := journal.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	expectConsistent(t, graph, "commit")
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	expectConsistent(t, graph, "undo")
+	expectAttrVal(t, a, attr, false, "", "undo")
+
+	if a.GetNest() != graph.GetNestTree().GetRootNest() || graph.EdgeCount() != 0 {
+		t.Fatalf("undo: the transaction is not undone")
+	}
+
+	if err := journal.Redo(); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+
+	expectConsistent(t, graph, "redo")
+	expectAttrVal(t, a, attr, true, "a", "redo")
+
+	if a.GetNest() != nest || graph.EdgeCount() != 1 {
+		t.Fatalf("redo: the transaction is not redone")
+	}
+
+	// Undo everything: the transaction, the nodes and the nest
+	for journal.CanUndo() {
+		if err := journal.Undo(); err != nil {
+			t.Fatalf("Undo: %v", err)
+		}
+
+		expectConsistent(t, graph, "undo all")
+	}
+
+	if graph.NodeCount() != 0 || graph.GetNestTree().NestCount() != 1 {
+		t.Fatalf("undo all: %d nodes and %d nests are left", graph.NodeCount(),
+			graph.GetNestTree().NestCount())
+	}
+}
+
+// Releasing an attribute doesn't add history and doesn't drop unrelated history
+func TestJournalReleaseAttr(t *testing.T) {
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 2})
+	attr_a, _ := graph.NewNodeStrAttr()
+	attr_b, _ := graph.NewNodeStrAttr()
+	journal := graph.EnableJournal()
+	node := graph.NewNode()
+
+	for i := 0; i < 3; i++ {
+		graph.NewNode().SetStrAttrVal(attr_a, "other")
+	}
+
+	node.SetStrAttrVal(attr_a, "a")
+	node.SetStrAttrVal(attr_b, "b")
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	expectConsistent(t, graph, "undo")
+	expectAttrVal(t, node, attr_b, false, "", "undo")
+
+	undo_num := len(journal.undoStack)
+
+	if err := graph.ReleaseNodeStrAttr(attr_a); err != nil {
+		t.Fatalf("ReleaseNodeStrAttr: %v", err)
+	}
+
+	expectConsistent(t, graph, "release")
+
+	// The transactions that only changed "attr_a" are dropped. Nothing is added
+	if len(journal.undoStack) != undo_num-4 {
+		t.Errorf("release: %d transactions to undo, want %d", len(journal.undoStack),
+			undo_num-4)
+	}
+
+	for i, entries := range journal.undoStack {
+		if len(entries) == 0 {
+			t.Errorf("release: transaction %d is empty", i)
+		}
+	}
+
+	if !journal.CanRedo() {
+		t.Fatalf("release: the redo history is lost")
+	}
+
+	if err := journal.Redo(); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+
+	expectConsistent(t, graph, "redo")
+	expectAttrVal(t, node, attr_b, true, "b", "redo")
+
+	// Every remaining transaction does something
+	for journal.CanUndo() {
+		node_num, edge_num := graph.NodeCount(), graph.EdgeCount()
+		is_set, _ := node.IsStrAttrSet(attr_b)
+
+		if err := journal.Undo(); err != nil {
+			t.Fatalf("Undo: %v", err)
+		}
+
+		expectConsistent(t, graph, "undo after release")
+
+		is_still_set, _ := node.IsStrAttrSet(attr_b)
+
+		if graph.NodeCount() == node_num && graph.EdgeCount() == edge_num &&
+			is_set == is_still_set {
+
+			t.Fatalf("undo after release: the undone transaction did nothing")
+		}
+	}
+
+	if graph.NodeCount() != 0 {
+		t.Errorf("undo after release: %d nodes are left", graph.NodeCount())
+	}
+}
+
+// Releasing an attribute inside an open transaction doesn't break the transaction
+func TestJournalReleaseAttrInTx(t *testing.T) {
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 1})
+	attr, _ := graph.NewNodeStrAttr()
+	journal := graph.EnableJournal()
+	journal.Begin()
+	node := graph.NewNode()
+	node.SetStrAttrVal(attr, "a")
+
+	if err := graph.ReleaseNodeStrAttr(attr); err != nil {
+		t.Fatalf("ReleaseNodeStrAttr: %v", err)
+	}
+
+	if !journal.InTx() || len(journal.current) != 1 {
+		t.Fatalf("release: the open transaction is changed")
+	}
+
+	if err := journal.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	expectConsistent(t, graph, "rollback")
+
+	if graph.NodeCount() != 0 || journal.CanUndo() || journal.CanRedo() {
+		t.Errorf("rollback: the transaction is not rolled back")
+	}
+}
+
+// A nest whose creation was undone can't be used as a destination or as a parent
+func TestJournalUndoneNest(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	node := graph.NewNode()
+	journal := graph.EnableJournal()
+	nest := graph.GetNestTree().NewNest()
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	if err := node.MoveToNest(nest); !errors.Is(err, ErrElemDetached) {
+		t.Fatalf("MoveToNest: got error %v, want %v", err, ErrElemDetached)
+	}
+
+	if _, err := nest.NewChildNest(); !errors.Is(err, ErrElemDetached) {
+		t.Fatalf("NewChildNest: got error %v, want %v", err, ErrElemDetached)
+	}
+
+	expectConsistent(t, graph, "undo")
+
+	if node.GetNest() != graph.GetNestTree().GetRootNest() ||
+		graph.GetNestTree().NestCount() != 1 {
+
+		t.Fatalf("the graph is modified by the rejected calls")
+	}
+
+	// The nest can be used again once its creation is redone
+	if err := journal.Redo(); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+
+	if err := node.MoveToNest(nest); err != nil {
+		t.Fatalf("MoveToNest after redo: %v", err)
+	}
+
+	expectConsistent(t, graph, "redo")
+}
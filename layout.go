@@ -0,0 +1,338 @@
+/*
+  Graph layout
+
+  Layout engines compute geometry of graph nodes and nests: every node and every nest
+  (including the root nest) gets a rectangle. The geometry is stored into node and nest
+  string attributes specified by a "LayoutSpec". So, it can be consumed by emitters and
+  saved together with the graph
+
+  Rectangles are described by coordinates of their top-left corners, their widths and
+  heights. The "x" axis is directed to the right, the "y" axis is directed down. The
+  root nest rectangle has its top-left corner at (0, 0) and encloses the entire drawing.
+  Units are abstract (they can be treated as SVG user units, for example)
+
+  Values are stored as decimal numbers rounded to 2 digits after the point (for example,
+  "12.5")
+
+  Renderers read the geometry back from the attributes. They don't depend on the engine
+  that computed it: the attributes can be filled by the caller as well
+
+  NOTE: setting the attributes is an ordinary graph mutation. It's reported to observers.
+        If the journal is enabled, the whole layout is recorded as a single transaction
+        (unless a transaction is already open)
+*/
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Default sizes used by layout engines (see "LayoutSpec")
+const (
+	LAYOUT_DEFAULT_NODE_WIDTH   = 80
+	LAYOUT_DEFAULT_NODE_HEIGHT  = 40
+	LAYOUT_DEFAULT_NODE_SEP     = 20
+	LAYOUT_DEFAULT_RANK_SEP     = 60
+	LAYOUT_DEFAULT_NEST_PADDING = 20
+)
+
+// Variables of the below type map node geometry to node attributes. Geometry properties
+// mapped to "nil" are not stored
+type NodeLayoutSpec struct {
+	// X coordinate of the top-left corner
+	XAttr *NodeStrAttr
+	// Y coordinate of the top-left corner
+	YAttr *NodeStrAttr
+	// Width of a node
+	WidthAttr *NodeStrAttr
+	// Height of a node
+	HeightAttr *NodeStrAttr
+}
+
+// Variables of the below type map nest geometry to nest attributes. Geometry properties
+// mapped to "nil" are not stored
+type NestLayoutSpec struct {
+	// X coordinate of the top-left corner
+	XAttr *NestStrAttr
+	// Y coordinate of the top-left corner
+	YAttr *NestStrAttr
+	// Width of a nest
+	WidthAttr *NestStrAttr
+	// Height of a nest
+	HeightAttr *NestStrAttr
+}
+
+// Variables of the below type define where a layout is stored and what sizes are used to
+// compute it. Zero sizes are replaced by the defaults (LAYOUT_DEFAULT_* constants)
+type LayoutSpec struct {
+	// Attributes to store node geometry
+	Node NodeLayoutSpec
+	// Attributes to store nest geometry
+	Nest NestLayoutSpec
+	// Size of every node
+	NodeWidth  float64
+	NodeHeight float64
+	// Minimal horizontal distance between neighboring nodes and nests
+	NodeSep float64
+	// Vertical distance between layers of nodes (layered layout). Also used as the ideal
+	// edge length by force-directed layout
+	RankSep float64
+	// Distance between the border of a nest and its contents. The top padding also leaves
+	// room for the nest label
+	NestPadding float64
+}
+
+// Rectangle occupied by a node or a nest
+type LayoutRect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Geometry computed by a layout engine
+type layoutResult struct {
+	nodeRects map[*Node]LayoutRect
+	nestRects map[*Nest]LayoutRect
+}
+
+// Check a layout specification and return its copy with zero sizes replaced by defaults
+func checkLayoutSpec(op string,
+	graph *Graph,
+	layout_spec *LayoutSpec) (LayoutSpec, error) {
+
+	if layout_spec == nil {
+		return LayoutSpec{}, &ElemError{op, -1, -1, "Zero reference to the layout " +
+			"specification", ErrNoLayoutAttrs}
+	}
+
+	spec := *layout_spec
+	node_attrs := []*NodeStrAttr{spec.Node.XAttr, spec.Node.YAttr, spec.Node.WidthAttr,
+		spec.Node.HeightAttr}
+	nest_attrs := []*NestStrAttr{spec.Nest.XAttr, spec.Nest.YAttr, spec.Nest.WidthAttr,
+		spec.Nest.HeightAttr}
+	has_attrs := false
+
+	for _, attr := range node_attrs {
+		if attr != nil {
+			if err := graph.checkNodeStrAttr(op, attr); err != nil {
+				return spec, err
+			}
+
+			has_attrs = true
+		}
+	}
+
+	for _, attr := range nest_attrs {
+		if attr != nil {
+			if err := graph.nestTree.checkNestStrAttr(op, attr); err != nil {
+				return spec, err
+			}
+
+			has_attrs = true
+		}
+	}
+
+	if !has_attrs {
+		return spec, &ElemError{op, -1, -1, "The layout specification doesn't map any " +
+			"geometry property to an attribute", ErrNoLayoutAttrs}
+	}
+
+	sizes := []struct {
+		val *float64
+		def float64
+	}{
+		{&spec.NodeWidth, LAYOUT_DEFAULT_NODE_WIDTH},
+		{&spec.NodeHeight, LAYOUT_DEFAULT_NODE_HEIGHT},
+		{&spec.NodeSep, LAYOUT_DEFAULT_NODE_SEP},
+		{&spec.RankSep, LAYOUT_DEFAULT_RANK_SEP},
+		{&spec.NestPadding, LAYOUT_DEFAULT_NEST_PADDING},
+	}
+
+	for _, size := range sizes {
+		if *size.val < 0 || math.IsNaN(*size.val) || math.IsInf(*size.val, 0) {
+			return spec, &ElemError{op, -1, -1, "The layout specification defines an " +
+				"invalid size", ErrLayoutSize}
+		}
+
+		if *size.val == 0 {
+			*size.val = size.def
+		}
+	}
+
+	return spec, nil
+}
+
+// Format a coordinate or a size for storing into an attribute
+func formatLayoutVal(val float64) string {
+	return strconv.FormatFloat(math.Round(val*100)/100, 'f', -1, 64)
+}
+
+// Store computed geometry into the attributes defined by a layout specification. "op" is
+// the name of the layout engine function reported in errors
+func storeLayout(op string, graph *Graph, spec *LayoutSpec,
+	result *layoutResult) (err error) {
+
+	// The layout is stored as a single journal transaction, unless the caller has opened
+	// one. The transaction is rolled back if storing fails. A panic is recovered here:
+	// otherwise the transaction would be committed while the panic is being propagated
+	if journal := graph.GetJournal(); journal != nil && !journal.InTx() {
+		if err = journal.Begin(); err != nil {
+			return &ElemError{op, -1, -1, "Cannot open a journal transaction", err}
+		}
+
+		defer func() {
+			if panic_val := recover(); panic_val != nil {
+				err = &InternalError{Op: op, PanicVal: panic_val}
+			}
+
+			if err != nil {
+				journal.Rollback()
+			} else {
+				journal.Commit()
+			}
+		}()
+	}
+
+	for node := range graph.Nodes() {
+		rect := result.nodeRects[node]
+		vals := []struct {
+			attr *NodeStrAttr
+			val  float64
+		}{
+			{spec.Node.XAttr, rect.X},
+			{spec.Node.YAttr, rect.Y},
+			{spec.Node.WidthAttr, rect.Width},
+			{spec.Node.HeightAttr, rect.Height},
+		}
+
+		for _, val := range vals {
+			if val.attr == nil {
+				continue
+			}
+
+			if err = node.SetStrAttrVal(val.attr, formatLayoutVal(val.val)); err != nil {
+				return err
+			}
+		}
+	}
+
+	root_nest := graph.nestTree.rootNest
+	nests := []*Nest{root_nest}
+
+	for nest := range root_nest.Descendants() {
+		nests = append(nests, nest)
+	}
+
+	for _, nest := range nests {
+		rect := result.nestRects[nest]
+		vals := []struct {
+			attr *NestStrAttr
+			val  float64
+		}{
+			{spec.Nest.XAttr, rect.X},
+			{spec.Nest.YAttr, rect.Y},
+			{spec.Nest.WidthAttr, rect.Width},
+			{spec.Nest.HeightAttr, rect.Height},
+		}
+
+		for _, val := range vals {
+			if val.attr == nil {
+				continue
+			}
+
+			if err = nest.SetStrAttrVal(val.attr, formatLayoutVal(val.val)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Parse a coordinate or a size read from an attribute
+func parseLayoutVal(str string) (float64, error) {
+	val, err := strconv.ParseFloat(str, 64)
+
+	if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+		return 0, fmt.Errorf("%w: \"%s\"", ErrLayoutVal, str)
+	}
+
+	return val, nil
+}
+
+// Read the rectangle of a node from the attributes defined by a layout specification.
+// The position attributes must be defined and set. The size attributes are optional: if
+// an attribute is not defined, the size from the specification is used
+func readNodeLayout(node *Node, spec *LayoutSpec) (LayoutRect, error) {
+	rect := LayoutRect{Width: spec.NodeWidth, Height: spec.NodeHeight}
+	vals := []struct {
+		attr *NodeStrAttr
+		val  *float64
+	}{
+		{spec.Node.XAttr, &rect.X},
+		{spec.Node.YAttr, &rect.Y},
+		{spec.Node.WidthAttr, &rect.Width},
+		{spec.Node.HeightAttr, &rect.Height},
+	}
+
+	for _, val := range vals {
+		if val.attr == nil {
+			continue
+		}
+
+		str, err := node.GetStrAttrVal(val.attr)
+
+		if err != nil {
+			return rect, err
+		}
+
+		if *val.val, err = parseLayoutVal(str); err != nil {
+			return rect, err
+		}
+	}
+
+	return rect, nil
+}
+
+// Read the rectangle of a nest from the attributes defined by a layout specification.
+// Returns "false" if some of the attributes are not defined or not set
+func readNestLayout(nest *Nest, spec *LayoutSpec) (LayoutRect, bool, error) {
+	var rect LayoutRect
+
+	vals := []struct {
+		attr *NestStrAttr
+		val  *float64
+	}{
+		{spec.Nest.XAttr, &rect.X},
+		{spec.Nest.YAttr, &rect.Y},
+		{spec.Nest.WidthAttr, &rect.Width},
+		{spec.Nest.HeightAttr, &rect.Height},
+	}
+
+	for _, val := range vals {
+		if val.attr == nil {
+			return rect, false, nil
+		}
+
+		str, err := nest.GetStrAttrVal(val.attr)
+
+		if errors.Is(err, ErrAttrNotSet) {
+			return rect, false, nil
+		}
+
+		if err != nil {
+			return rect, false, err
+		}
+
+		if *val.val, err = parseLayoutVal(str); err != nil {
+			return rect, false, err
+		}
+	}
+
+	return rect, true, nil
+}
@@ -0,0 +1,455 @@
+/*
+  Force-directed layout
+
+  The layout is computed bottom-up over the nest hierarchy. Every nest is laid out as a
+  set of items: the nodes directly belonging to the nest and the rectangles of the child
+  nests (which are laid out first). Items are positioned by the Fruchterman-Reingold
+  algorithm: all items repel each other, while items connected by edges attract each
+  other. An edge connects the items that contain its ends inside the lowest common nest
+  of the ends. Parallel edges make the attraction stronger. A weak gravity pulls items to
+  the center of the nest, so disconnected parts of the nest stay close to each other
+
+  Once the simulation is over, overlapping items are pushed apart. So, every nest occupies
+  a separate rectangle inside its parent: nest rectangles never overlap unless one of them
+  contains the other, and a node never overlaps a nest it doesn't belong to. A nest
+  rectangle encloses its items with padding
+
+  Distances between items are measured between their borders (approximately). The ideal
+  distance is "LayoutSpec.RankSep". The minimal distance between items is
+  "LayoutSpec.NodeSep"
+
+  Initial positions of items are random. The random generator is seeded by the caller and
+  items are always visited in the same order. So, the same graph and the same seed produce
+  the same layout
+
+  NOTE: every simulation step is quadratic in the number of items of a nest
+
+  NOTE: edges are not routed. Consumers of the layout are expected to draw them as
+        straight lines between the nodes
+*/
+
+package graph
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Parameters of the force-directed simulation
+const (
+	// Number of simulation steps performed for every nest
+	FORCE_LAYOUT_ITERATIONS = 300
+	// Strength of the gravity pulling items to the center of a nest
+	FORCE_LAYOUT_GRAVITY = 0.1
+	// Number of overlap removal sweeps performed before the items are spread further
+	FORCE_LAYOUT_OVERLAP_SWEEPS = 50
+	// Factor by which distances between items grow if overlaps can't be removed by sweeps
+	FORCE_LAYOUT_SPREAD = 1.25
+)
+
+// Item laid out inside a nest: either a node or a child nest
+type forceItem struct {
+	// Node. "nil" for a child nest
+	node *Node
+	// Child nest. "nil" for a node
+	nest *Nest
+	// Size of the item
+	width  float64
+	height float64
+	// Center of the item. Relative to the top-left corner of the enclosing nest once the
+	// nest is laid out
+	x float64
+	y float64
+	// Displacement computed at the current simulation step
+	dx float64
+	dy float64
+}
+
+// Attraction between two items of a nest
+type forceSpring struct {
+	a *forceItem
+	b *forceItem
+	// Number of edges connecting the items
+	weight int
+}
+
+// State of a force-directed layout computation
+type forceLayout struct {
+	// Layout specification (with the defaults applied)
+	spec LayoutSpec
+	// Root nest of the graph
+	rootNest *Nest
+	// Source of the initial positions
+	rand *rand.Rand
+	// Items of the nests
+	items map[*Nest][]*forceItem
+	// Attraction between items of the nests
+	springs map[*Nest][]*forceSpring
+	// Items representing nodes and non-root nests
+	nodeItems map[*Node]*forceItem
+	nestItems map[*Nest]*forceItem
+}
+
+// Compute a force-directed layout of a graph and store it into the attributes defined by
+// the layout specification. Nests are laid out as non-overlapping rectangular clusters.
+// The layout is fully determined by the graph and the seed
+func LayoutForceDirected(graph *Graph, layout_spec *LayoutSpec, seed int64) (err error) {
+	defer recoverInternalError("LayoutForceDirected", &err)
+
+	if graph == nil {
+		return &ElemError{"LayoutForceDirected", -1, -1, "Zero reference to the graph",
+			ErrNilGraph}
+	}
+
+	spec, err := checkLayoutSpec("LayoutForceDirected", graph, layout_spec)
+
+	if err != nil {
+		return err
+	}
+
+	layout := newForceLayout(graph, spec, seed)
+	layout.layoutNest(layout.rootNest)
+
+	result := &layoutResult{
+		nodeRects: make(map[*Node]LayoutRect),
+		nestRects: make(map[*Nest]LayoutRect),
+	}
+
+	root_item := layout.nestItems[layout.rootNest]
+	layout.placeNest(layout.rootNest, 0, 0, root_item.width, root_item.height, result)
+
+	return storeLayout("LayoutForceDirected", graph, &spec, result)
+}
+
+// Prepare a force-directed layout computation: create items of all nests and springs
+// between them
+func newForceLayout(graph *Graph, spec LayoutSpec, seed int64) *forceLayout {
+	layout := &forceLayout{
+		spec:      spec,
+		rootNest:  graph.nestTree.rootNest,
+		rand:      rand.New(rand.NewSource(seed)),
+		items:     make(map[*Nest][]*forceItem),
+		springs:   make(map[*Nest][]*forceSpring),
+		nodeItems: make(map[*Node]*forceItem),
+		nestItems: make(map[*Nest]*forceItem),
+	}
+
+	layout.buildItems(layout.rootNest)
+
+	// The root nest is represented by an item as well. The item only keeps the root nest
+	// size
+	layout.nestItems[layout.rootNest] = &forceItem{nest: layout.rootNest}
+
+	// Nodes are sorted by IDs to make the order of springs deterministic
+	var nodes []*Node
+
+	for node := range graph.Nodes() {
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].id < nodes[j].id
+	})
+
+	type itemPair struct {
+		a *forceItem
+		b *forceItem
+	}
+
+	spring_index := make(map[itemPair]*forceSpring)
+
+	for _, node := range nodes {
+		for edge := range node.OutEdges() {
+			if edge.dstNode == node {
+				continue
+			}
+
+			nest := lowestCommonNest(node.nest, edge.dstNode.nest,
+				"Panic while computing a force-directed layout")
+			a, b := layout.itemInNest(node, nest), layout.itemInNest(edge.dstNode, nest)
+
+			if spring, ok := spring_index[itemPair{a, b}]; ok {
+				spring.weight++
+
+				continue
+			}
+
+			if spring, ok := spring_index[itemPair{b, a}]; ok {
+				spring.weight++
+
+				continue
+			}
+
+			spring := &forceSpring{a, b, 1}
+			spring_index[itemPair{a, b}] = spring
+			layout.springs[nest] = append(layout.springs[nest], spring)
+		}
+	}
+
+	return layout
+}
+
+// Create items of a nest and of all its descendants
+func (layout *forceLayout) buildItems(nest *Nest) {
+	var items []*forceItem
+
+	for node := nest.GetFirstNode(); node != nil; node = node.GetNextNodeInNest() {
+		item := &forceItem{node: node, width: layout.spec.NodeWidth,
+			height: layout.spec.NodeHeight}
+		layout.nodeItems[node] = item
+		items = append(items, item)
+	}
+
+	for child := range nest.Children() {
+		layout.buildItems(child)
+		item := &forceItem{nest: child}
+		layout.nestItems[child] = item
+		items = append(items, item)
+	}
+
+	layout.items[nest] = items
+}
+
+// Get the item of a nest that contains a node. The nest must contain the node
+func (layout *forceLayout) itemInNest(node *Node, nest *Nest) *forceItem {
+	if node.nest == nest {
+		return layout.nodeItems[node]
+	}
+
+	child := node.nest
+
+	for child.parentNest != nest {
+		child = child.parentNest
+	}
+
+	return layout.nestItems[child]
+}
+
+// Lay out a nest and all its descendants. Computes the size of the nest and positions of
+// its items relative to the top-left corner of the nest
+func (layout *forceLayout) layoutNest(nest *Nest) {
+	items := layout.items[nest]
+
+	// Child nests are laid out first, since their sizes are needed
+	for _, item := range items {
+		if item.nest != nil {
+			layout.layoutNest(item.nest)
+		}
+	}
+
+	layout.simulate(items, layout.springs[nest])
+	layout.removeOverlaps(items)
+
+	// Compute the bounding box of the items and move it to the nest origin
+	left, top := math.Inf(1), math.Inf(1)
+	right, bottom := math.Inf(-1), math.Inf(-1)
+
+	for _, item := range items {
+		left = min(left, item.x-item.width/2)
+		top = min(top, item.y-item.height/2)
+		right = max(right, item.x+item.width/2)
+		bottom = max(bottom, item.y+item.height/2)
+	}
+
+	if len(items) == 0 {
+		left, top, right, bottom = 0, 0, 0, 0
+	}
+
+	padding := layout.spec.NestPadding
+
+	for _, item := range items {
+		item.x += padding - left
+		item.y += padding - top
+	}
+
+	nest_item := layout.nestItems[nest]
+	nest_item.width = right - left + 2*padding
+	nest_item.height = bottom - top + 2*padding
+}
+
+// Radius of an item used to measure distances between item borders
+func (item *forceItem) radius() float64 {
+	return (item.width + item.height) / 4
+}
+
+// Position items by the Fruchterman-Reingold algorithm
+func (layout *forceLayout) simulate(items []*forceItem, springs []*forceSpring) {
+	if len(items) == 0 {
+		return
+	}
+
+	// Scatter the items over a square big enough to hold them
+	ideal := layout.spec.RankSep
+	side := 0.0
+
+	for _, item := range items {
+		side += 2*item.radius() + ideal
+	}
+
+	side /= math.Sqrt(float64(len(items)))
+
+	for _, item := range items {
+		item.x = layout.rand.Float64() * side
+		item.y = layout.rand.Float64() * side
+	}
+
+	if len(items) == 1 {
+		return
+	}
+
+	// The maximum displacement ("temperature") decreases linearly
+	start_temp := side / 10
+
+	for iter := 0; iter < FORCE_LAYOUT_ITERATIONS; iter++ {
+		center_x, center_y := 0.0, 0.0
+
+		for _, item := range items {
+			item.dx, item.dy = 0, 0
+			center_x += item.x / float64(len(items))
+			center_y += item.y / float64(len(items))
+		}
+
+		// Repulsion between all items
+		for i, a := range items {
+			for _, b := range items[i+1:] {
+				dx, dy, dist := layout.itemDistance(a, b)
+				gap := max(dist-a.radius()-b.radius(), ideal/100)
+				force := ideal * ideal / gap
+				a.dx += dx / dist * force
+				a.dy += dy / dist * force
+				b.dx -= dx / dist * force
+				b.dy -= dy / dist * force
+			}
+		}
+
+		// Attraction between connected items
+		for _, spring := range springs {
+			dx, dy, dist := layout.itemDistance(spring.a, spring.b)
+			gap := max(dist-spring.a.radius()-spring.b.radius(), 0)
+			force := float64(spring.weight) * gap * gap / ideal
+			spring.a.dx -= dx / dist * force
+			spring.a.dy -= dy / dist * force
+			spring.b.dx += dx / dist * force
+			spring.b.dy += dy / dist * force
+		}
+
+		// Gravity and movement limited by the temperature
+		temp := start_temp * (1 - float64(iter)/FORCE_LAYOUT_ITERATIONS)
+
+		for _, item := range items {
+			item.dx -= (item.x - center_x) * FORCE_LAYOUT_GRAVITY
+			item.dy -= (item.y - center_y) * FORCE_LAYOUT_GRAVITY
+
+			if disp := math.Hypot(item.dx, item.dy); disp > 0 {
+				item.x += item.dx / disp * min(disp, temp)
+				item.y += item.dy / disp * min(disp, temp)
+			}
+		}
+	}
+}
+
+// Get the vector from item "b" to item "a" and its length. Items sharing the same center
+// are separated in a random direction, so the length is never zero
+func (layout *forceLayout) itemDistance(a *forceItem, b *forceItem) (float64, float64,
+	float64) {
+
+	dx, dy := a.x-b.x, a.y-b.y
+
+	if dist := math.Hypot(dx, dy); dist > 0 {
+		return dx, dy, dist
+	}
+
+	angle := layout.rand.Float64() * 2 * math.Pi
+	a.x += math.Cos(angle) / 100
+	a.y += math.Sin(angle) / 100
+
+	return a.x - b.x, a.y - b.y, math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// Move items apart until every two of them are separated by at least "NodeSep"
+func (layout *forceLayout) removeOverlaps(items []*forceItem) {
+	sep := layout.spec.NodeSep
+
+	for {
+		for sweep := 0; sweep < FORCE_LAYOUT_OVERLAP_SWEEPS; sweep++ {
+			if !layout.separateItems(items, sep) {
+				return
+			}
+		}
+
+		// Sweeps didn't help (items may be pushed back and forth in dense areas). Spread
+		// the items. The distances grow exponentially, so the loop always ends
+		center_x, center_y := 0.0, 0.0
+
+		for _, item := range items {
+			center_x += item.x / float64(len(items))
+			center_y += item.y / float64(len(items))
+		}
+
+		for _, item := range items {
+			item.x = center_x + (item.x-center_x)*FORCE_LAYOUT_SPREAD
+			item.y = center_y + (item.y-center_y)*FORCE_LAYOUT_SPREAD
+		}
+	}
+}
+
+// Push apart every two items closer than "sep" to each other. Each of the items is moved
+// by half of the overlap along the axis of the smaller overlap. Returns "true" if any
+// items were moved
+func (layout *forceLayout) separateItems(items []*forceItem, sep float64) bool {
+	moved := false
+
+	for i, a := range items {
+		for _, b := range items[i+1:] {
+			dx, dy, _ := layout.itemDistance(a, b)
+			overlap_x := (a.width+b.width)/2 + sep - math.Abs(dx)
+			overlap_y := (a.height+b.height)/2 + sep - math.Abs(dy)
+
+			if overlap_x <= 0 || overlap_y <= 0 {
+				continue
+			}
+
+			if overlap_x < overlap_y {
+				shift := math.Copysign(overlap_x/2, dx)
+				a.x += shift
+				b.x -= shift
+			} else {
+				shift := math.Copysign(overlap_y/2, dy)
+				a.y += shift
+				b.y -= shift
+			}
+
+			moved = true
+		}
+	}
+
+	return moved
+}
+
+// Compute the final geometry of a nest and of everything inside it. "left" and "top"
+// define the top-left corner of the nest
+func (layout *forceLayout) placeNest(nest *Nest,
+	left float64,
+	top float64,
+	width float64,
+	height float64,
+	result *layoutResult) {
+
+	result.nestRects[nest] = LayoutRect{X: left, Y: top, Width: width, Height: height}
+
+	for _, item := range layout.items[nest] {
+		item_left := left + item.x - item.width/2
+		item_top := top + item.y - item.height/2
+
+		if item.nest != nil {
+			layout.placeNest(item.nest, item_left, item_top, item.width, item.height,
+				result)
+
+			continue
+		}
+
+		result.nodeRects[item.node] = LayoutRect{X: item_left, Y: item_top,
+			Width: item.width, Height: item.height}
+	}
+}
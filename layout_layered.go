@@ -0,0 +1,568 @@
+/*
+  Layered (Sugiyama-style) layout
+
+  The layout is computed in the following steps:
+    1. Cycle breaking. Edges that close cycles (back edges found by depth-first search)
+       are treated as reversed. Loops are ignored
+    2. Layer assignment. Every node is assigned to the layer equal to the length of the
+       longest path leading to it. Layers are placed from top to bottom
+    3. Crossing minimization. Nodes and nests are reordered by the barycenters of their
+       neighbors. The ordering with the least number of crossings (between edges
+       connecting adjacent layers) is kept
+    4. Coordinate assignment. Every nest is laid out as a row of items: the block of
+       nodes directly belonging to the nest and the child nests. Inside the block, nodes
+       of every layer are centered. A nest rectangle encloses its items with padding
+
+  Since the items of a nest never interleave, every nest occupies a separate vertical band
+  inside its parent. So, nest rectangles never overlap unless one of them contains the
+  other, and a node never overlaps a nest it doesn't belong to
+
+  NOTE: edges are not routed. Consumers of the layout are expected to draw them as
+        straight lines between the nodes
+*/
+
+package graph
+
+import (
+	"math"
+	"sort"
+)
+
+// Number of crossing minimization passes
+const LAYERED_LAYOUT_PASSES = 24
+
+// Item laid out inside a nest: either a child nest or the block of nodes directly
+// belonging to the nest
+type layeredItem struct {
+	// Child nest. "nil" for the block of nodes
+	nest *Nest
+	// Nodes of the block grouped by layers. Nodes of every layer are kept in their
+	// current order
+	rows map[int][]*Node
+}
+
+// State of a layered layout computation
+type layeredLayout struct {
+	// Layout specification (with the defaults applied)
+	spec LayoutSpec
+	// Root nest of the graph
+	rootNest *Nest
+	// All graph nodes ordered by their IDs
+	nodes []*Node
+	// Layers of the nodes
+	layer map[*Node]int
+	// Opposite ends of edges incident to the nodes (loops are skipped, parallel edges
+	// produce repeated entries)
+	neighbors map[*Node][]*Node
+	// Items of the nests in their current order
+	items map[*Nest][]*layeredItem
+	// Widths of the nest rectangles
+	nestWidth map[*Nest]float64
+	// X coordinates of the node centers and of the left borders of the nests
+	nodeX map[*Node]float64
+	nestX map[*Nest]float64
+}
+
+// Compute a layered layout of a graph and store it into the attributes defined by the
+// layout specification. Nests are laid out as rectangular clusters
+func LayoutLayered(graph *Graph, layout_spec *LayoutSpec) (err error) {
+	defer recoverInternalError("LayoutLayered", &err)
+
+	if graph == nil {
+		return &ElemError{"LayoutLayered", -1, -1, "Zero reference to the graph",
+			ErrNilGraph}
+	}
+
+	spec, err := checkLayoutSpec("LayoutLayered", graph, layout_spec)
+
+	if err != nil {
+		return err
+	}
+
+	layout := newLayeredLayout(graph, spec)
+	layout.assignLayers()
+	layout.buildItems(layout.rootNest)
+	layout.minimizeCrossings()
+
+	return storeLayout("LayoutLayered", graph, &spec, layout.result())
+}
+
+// Prepare a layered layout computation
+func newLayeredLayout(graph *Graph, spec LayoutSpec) *layeredLayout {
+	layout := &layeredLayout{
+		spec:      spec,
+		rootNest:  graph.nestTree.rootNest,
+		layer:     make(map[*Node]int),
+		neighbors: make(map[*Node][]*Node),
+		items:     make(map[*Nest][]*layeredItem),
+		nestWidth: make(map[*Nest]float64),
+		nodeX:     make(map[*Node]float64),
+		nestX:     make(map[*Nest]float64),
+	}
+
+	for node := range graph.Nodes() {
+		layout.nodes = append(layout.nodes, node)
+	}
+
+	sort.Slice(layout.nodes, func(i, j int) bool {
+		return layout.nodes[i].id < layout.nodes[j].id
+	})
+
+	for _, node := range layout.nodes {
+		for edge := range node.OutEdges() {
+			if edge.dstNode != node {
+				layout.neighbors[node] = append(layout.neighbors[node], edge.dstNode)
+				layout.neighbors[edge.dstNode] = append(layout.neighbors[edge.dstNode], node)
+			}
+		}
+	}
+
+	return layout
+}
+
+// Break cycles and assign nodes to layers
+func (layout *layeredLayout) assignLayers() {
+	// Find back edges by iterative depth-first search. Nodes and edges are visited in a
+	// deterministic order (nodes by IDs, edges in the order of the outcoming edge lists)
+	const (
+		NOT_VISITED = iota
+		ON_STACK    = iota
+		DONE        = iota
+	)
+
+	type dfsFrame struct {
+		node *Node
+		edge *Edge
+	}
+
+	state := make(map[*Node]int)
+	succs := make(map[*Node][]*Node)
+	in_degree := make(map[*Node]int)
+
+	for _, start := range layout.nodes {
+		if state[start] != NOT_VISITED {
+			continue
+		}
+
+		state[start] = ON_STACK
+		stack := []dfsFrame{{start, start.firstOutcomingEdge}}
+
+		for len(stack) > 0 {
+			frame := &stack[len(stack)-1]
+
+			if frame.edge == nil {
+				state[frame.node] = DONE
+				stack = stack[:len(stack)-1]
+
+				continue
+			}
+
+			edge := frame.edge
+			frame.edge = edge.nextOutcomingEdge
+			src, dst := edge.srcNode, edge.dstNode
+
+			switch {
+			case src == dst:
+				// Loops don't affect layers
+				continue
+			case state[dst] == ON_STACK:
+				// The edge closes a cycle. It's treated as reversed
+				src, dst = dst, src
+			case state[dst] == NOT_VISITED:
+				state[dst] = ON_STACK
+				stack = append(stack, dfsFrame{dst, dst.firstOutcomingEdge})
+			}
+
+			succs[src] = append(succs[src], dst)
+			in_degree[dst]++
+		}
+	}
+
+	// Assign layers by the longest path method (in topological order)
+	var queue []*Node
+
+	for _, node := range layout.nodes {
+		if in_degree[node] == 0 {
+			queue = append(queue, node)
+		}
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for _, succ := range succs[node] {
+			layout.layer[succ] = max(layout.layer[succ], layout.layer[node]+1)
+			in_degree[succ]--
+
+			if in_degree[succ] == 0 {
+				queue = append(queue, succ)
+			}
+		}
+	}
+}
+
+// Build the initial items of a nest and of all its descendants
+func (layout *layeredLayout) buildItems(nest *Nest) {
+	var items []*layeredItem
+
+	block := &layeredItem{rows: make(map[int][]*Node)}
+
+	for node := range nest.Nodes() {
+		block.rows[layout.layer[node]] = append(block.rows[layout.layer[node]], node)
+	}
+
+	// Rows are sorted by node IDs, so the initial order doesn't depend on the order of
+	// nodes inside the nest
+	for _, row := range block.rows {
+		sort.Slice(row, func(i, j int) bool {
+			return row[i].id < row[j].id
+		})
+	}
+
+	if len(block.rows) > 0 {
+		items = append(items, block)
+	}
+
+	for child := range nest.Children() {
+		layout.buildItems(child)
+		items = append(items, &layeredItem{nest: child})
+	}
+
+	layout.items[nest] = items
+}
+
+// Reorder nodes and nests to reduce the number of edge crossings
+func (layout *layeredLayout) minimizeCrossings() {
+	layout.place()
+	best_crossings := layout.countCrossings()
+	best_order := layout.saveOrder()
+
+	for pass := 0; pass < LAYERED_LAYOUT_PASSES && best_crossings > 0; pass++ {
+		// Barycenters of the nodes. Nodes without neighbors keep their positions
+		bary := make(map[*Node]float64)
+
+		for _, node := range layout.nodes {
+			bary[node] = layout.nodeX[node]
+
+			if neighbors := layout.neighbors[node]; len(neighbors) > 0 {
+				sum := 0.0
+
+				for _, neighbor := range neighbors {
+					sum += layout.nodeX[neighbor]
+				}
+
+				bary[node] = sum / float64(len(neighbors))
+			}
+		}
+
+		layout.reorder(layout.rootNest, bary)
+		layout.place()
+
+		if crossings := layout.countCrossings(); crossings < best_crossings {
+			best_crossings = crossings
+			best_order = layout.saveOrder()
+		}
+	}
+
+	layout.restoreOrder(best_order)
+	layout.place()
+}
+
+// Reorder items of a nest (and of all its descendants) by barycenters. Returns the sum of
+// the barycenters of all nodes inside the nest and the number of those nodes
+func (layout *layeredLayout) reorder(nest *Nest, bary map[*Node]float64) (float64, int) {
+	items := layout.items[nest]
+	keys := make(map[*layeredItem]float64)
+	nest_sum, nest_count := 0.0, 0
+
+	for _, item := range items {
+		sum, count := 0.0, 0
+
+		if item.nest != nil {
+			sum, count = layout.reorder(item.nest, bary)
+		} else {
+			for _, row := range item.rows {
+				sort.SliceStable(row, func(i, j int) bool {
+					return bary[row[i]] < bary[row[j]]
+				})
+
+				for _, node := range row {
+					sum += bary[node]
+					count++
+				}
+			}
+		}
+
+		// Items without nodes (empty nests) are kept after all other items
+		keys[item] = math.Inf(1)
+
+		if count > 0 {
+			keys[item] = sum / float64(count)
+		}
+
+		nest_sum += sum
+		nest_count += count
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return keys[items[i]] < keys[items[j]]
+	})
+
+	return nest_sum, nest_count
+}
+
+// Count crossings between edges connecting adjacent layers
+func (layout *layeredLayout) countCrossings() int {
+	// Ends of the edges grouped by the upper layer
+	type edgeEnds struct {
+		upper_x float64
+		lower_x float64
+	}
+
+	layers := make(map[int][]edgeEnds)
+
+	for _, node := range layout.nodes {
+		for edge := range node.OutEdges() {
+			upper, lower := edge.srcNode, edge.dstNode
+
+			if layout.layer[upper] > layout.layer[lower] {
+				upper, lower = lower, upper
+			}
+
+			if layout.layer[lower]-layout.layer[upper] == 1 {
+				layers[layout.layer[upper]] = append(layers[layout.layer[upper]],
+					edgeEnds{layout.nodeX[upper], layout.nodeX[lower]})
+			}
+		}
+	}
+
+	crossings := 0
+
+	for _, ends := range layers {
+		// Two edges cross if the order of their upper ends is opposite to the order of
+		// their lower ends. So, the number of crossings is the number of inversions of
+		// the lower ends once the edges are sorted by the upper ends
+		sort.Slice(ends, func(i, j int) bool {
+			if ends[i].upper_x != ends[j].upper_x {
+				return ends[i].upper_x < ends[j].upper_x
+			}
+
+			return ends[i].lower_x < ends[j].lower_x
+		})
+
+		lower := make([]float64, len(ends))
+
+		for i, end := range ends {
+			lower[i] = end.lower_x
+		}
+
+		crossings += countInversions(lower)
+	}
+
+	return crossings
+}
+
+// Count pairs of elements that are out of order (i < j, but vals[i] > vals[j]). The
+// slice gets sorted
+func countInversions(vals []float64) int {
+	if len(vals) < 2 {
+		return 0
+	}
+
+	mid := len(vals) / 2
+	left := append([]float64{}, vals[:mid]...)
+	right := append([]float64{}, vals[mid:]...)
+	inversions := countInversions(left) + countInversions(right)
+
+	for i, l, r := 0, 0, 0; i < len(vals); i++ {
+		if r == len(right) || (l < len(left) && left[l] <= right[r]) {
+			vals[i] = left[l]
+			l++
+		} else {
+			vals[i] = right[r]
+			inversions += len(left) - l
+			r++
+		}
+	}
+
+	return inversions
+}
+
+// Copy of the current ordering of nodes and nests
+type layeredOrder map[*Nest][]*layeredItem
+
+// Save the current ordering
+func (layout *layeredLayout) saveOrder() layeredOrder {
+	order := make(layeredOrder)
+
+	for nest, items := range layout.items {
+		saved := make([]*layeredItem, len(items))
+
+		for i, item := range items {
+			saved[i] = &layeredItem{nest: item.nest}
+
+			if item.nest == nil {
+				saved[i].rows = make(map[int][]*Node)
+
+				for layer, row := range item.rows {
+					saved[i].rows[layer] = append([]*Node{}, row...)
+				}
+			}
+		}
+
+		order[nest] = saved
+	}
+
+	return order
+}
+
+// Restore a saved ordering
+func (layout *layeredLayout) restoreOrder(order layeredOrder) {
+	layout.items = order
+}
+
+// Calculate width of the block of nodes
+func (layout *layeredLayout) blockWidth(item *layeredItem) float64 {
+	width := 0.0
+
+	for _, row := range item.rows {
+		width = max(width, float64(len(row))*(layout.spec.NodeWidth+layout.spec.NodeSep)-
+			layout.spec.NodeSep)
+	}
+
+	return width
+}
+
+// Calculate widths of a nest and of all its descendants
+func (layout *layeredLayout) measure(nest *Nest) float64 {
+	width := 0.0
+
+	for i, item := range layout.items[nest] {
+		if i > 0 {
+			width += layout.spec.NodeSep
+		}
+
+		if item.nest != nil {
+			width += layout.measure(item.nest)
+		} else {
+			width += layout.blockWidth(item)
+		}
+	}
+
+	layout.nestWidth[nest] = width + 2*layout.spec.NestPadding
+
+	return layout.nestWidth[nest]
+}
+
+// Assign X coordinates to nodes and nests according to the current ordering
+func (layout *layeredLayout) place() {
+	layout.measure(layout.rootNest)
+	layout.placeNest(layout.rootNest, 0)
+}
+
+// Assign X coordinates to a nest and to everything inside it. "left" is the left border
+// of the nest
+func (layout *layeredLayout) placeNest(nest *Nest, left float64) {
+	spec := &layout.spec
+	layout.nestX[nest] = left
+	x := left + spec.NestPadding
+
+	for _, item := range layout.items[nest] {
+		if item.nest != nil {
+			layout.placeNest(item.nest, x)
+			x += layout.nestWidth[item.nest] + spec.NodeSep
+
+			continue
+		}
+
+		block_width := layout.blockWidth(item)
+
+		for _, row := range item.rows {
+			row_width := float64(len(row))*(spec.NodeWidth+spec.NodeSep) - spec.NodeSep
+			row_left := x + (block_width-row_width)/2
+
+			for i, node := range row {
+				layout.nodeX[node] = row_left + float64(i)*(spec.NodeWidth+spec.NodeSep) +
+					spec.NodeWidth/2
+			}
+		}
+
+		x += block_width + spec.NodeSep
+	}
+}
+
+// Compute the final geometry of nodes and nests
+func (layout *layeredLayout) result() *layoutResult {
+	result := &layoutResult{
+		nodeRects: make(map[*Node]LayoutRect),
+		nestRects: make(map[*Nest]LayoutRect),
+	}
+
+	spec := &layout.spec
+
+	for _, node := range layout.nodes {
+		result.nodeRects[node] = LayoutRect{
+			X:      layout.nodeX[node] - spec.NodeWidth/2,
+			Y:      float64(layout.layer[node]) * (spec.NodeHeight + spec.RankSep),
+			Width:  spec.NodeWidth,
+			Height: spec.NodeHeight,
+		}
+	}
+
+	layout.nestRect(layout.rootNest, result)
+
+	// Shift the drawing so that the root nest starts at (0, 0)
+	dy := -result.nestRects[layout.rootNest].Y
+
+	for node, rect := range result.nodeRects {
+		rect.Y += dy
+		result.nodeRects[node] = rect
+	}
+
+	for nest, rect := range result.nestRects {
+		rect.Y += dy
+		result.nestRects[nest] = rect
+	}
+
+	return result
+}
+
+// Compute rectangles of a nest and of all its descendants. The nest rectangle encloses
+// the nodes and the child nests with padding. Nests without nodes are placed at the top
+func (layout *layeredLayout) nestRect(nest *Nest, result *layoutResult) LayoutRect {
+	top, bottom := math.Inf(1), math.Inf(-1)
+
+	for _, item := range layout.items[nest] {
+		if item.nest != nil {
+			rect := layout.nestRect(item.nest, result)
+			top = min(top, rect.Y)
+			bottom = max(bottom, rect.Y+rect.Height)
+
+			continue
+		}
+
+		for _, row := range item.rows {
+			for _, node := range row {
+				rect := result.nodeRects[node]
+				top = min(top, rect.Y)
+				bottom = max(bottom, rect.Y+rect.Height)
+			}
+		}
+	}
+
+	if math.IsInf(top, 1) {
+		top, bottom = 0, 0
+	}
+
+	rect := LayoutRect{
+		X:      layout.nestX[nest],
+		Y:      top - layout.spec.NestPadding,
+		Width:  layout.nestWidth[nest],
+		Height: bottom - top + 2*layout.spec.NestPadding,
+	}
+
+	result.nestRects[nest] = rect
+
+	return rect
+}
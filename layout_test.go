@@ -0,0 +1,326 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+)
+
+// Build a graph with nested clusters and a layout specification storing the complete
+// geometry. Nests:
+//
+//	outer {a, b, g, inner {c, d}}, side {f}
+//
+// Node "e" belongs to the root nest. If "reverse" is set, nodes are moved into the nests
+// in the reverse order, which changes the order of nodes inside the nests but not their
+// IDs
+func newLayoutTestGraph(t *testing.T, reverse bool) (*Graph, *LayoutSpec) {
+	t.Helper()
+
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 4, NestStrAttrNum: 4})
+	nest_tree := graph.GetNestTree()
+	spec := &LayoutSpec{}
+	node_attrs := []**NodeStrAttr{&spec.Node.XAttr, &spec.Node.YAttr,
+		&spec.Node.WidthAttr, &spec.Node.HeightAttr}
+	nest_attrs := []**NestStrAttr{&spec.Nest.XAttr, &spec.Nest.YAttr,
+		&spec.Nest.WidthAttr, &spec.Nest.HeightAttr}
+
+	for i := range node_attrs {
+		var err error
+
+		if *node_attrs[i], err = graph.NewNodeStrAttr(); err != nil {
+			t.Fatalf("NewNodeStrAttr: %v", err)
+		}
+
+		if *nest_attrs[i], err = nest_tree.NewNestStrAttr(); err != nil {
+			t.Fatalf("NewNestStrAttr: %v", err)
+		}
+	}
+
+	outer := nest_tree.NewNest()
+	inner, _ := outer.NewChildNest()
+	side := nest_tree.NewNest()
+	nests := []*Nest{outer, outer, inner, inner, nil, side, outer}
+	nodes := make([]*Node, len(nests))
+
+	for i := range nodes {
+		nodes[i] = graph.NewNode()
+	}
+
+	for i := range nodes {
+		if reverse {
+			i = len(nodes) - 1 - i
+		}
+
+		if nests[i] != nil {
+			nodes[i].MoveToNest(nests[i])
+		}
+	}
+
+	// a->b is doubled. g has no edges
+	for _, ends := range [][2]int{{0, 1}, {0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}, {0, 2},
+		{5, 5}} {
+
+		if _, err := graph.NewEdge(nodes[ends[0]], nodes[ends[1]]); err != nil {
+			t.Fatalf("NewEdge: %v", err)
+		}
+	}
+
+	return graph, spec
+}
+
+// Collect the stored layout attribute values. Elements are identified by their IDs
+func layoutAttrVals(graph *Graph, spec *LayoutSpec) map[string]string {
+	vals := make(map[string]string)
+	node_attrs := []*NodeStrAttr{spec.Node.XAttr, spec.Node.YAttr, spec.Node.WidthAttr,
+		spec.Node.HeightAttr}
+	nest_attrs := []*NestStrAttr{spec.Nest.XAttr, spec.Nest.YAttr, spec.Nest.WidthAttr,
+		spec.Nest.HeightAttr}
+
+	for node := range graph.Nodes() {
+		for i, attr := range node_attrs {
+			if val, err := node.GetStrAttrVal(attr); err == nil {
+				vals[fmt.Sprintf("node %d/%d", node.GetID(), i)] = val
+			}
+		}
+	}
+
+	root := graph.GetNestTree().GetRootNest()
+	nests := []*Nest{root}
+
+	for nest := range root.Descendants() {
+		nests = append(nests, nest)
+	}
+
+	for _, nest := range nests {
+		for i, attr := range nest_attrs {
+			if val, err := nest.GetStrAttrVal(attr); err == nil {
+				vals[fmt.Sprintf("nest %d/%d", nest.GetID(), i)] = val
+			}
+		}
+	}
+
+	return vals
+}
+
+// Check whether two rectangles overlap. Touching rectangles don't overlap. The tolerance
+// covers rounding of the stored values
+func layoutRectsOverlap(a LayoutRect, b LayoutRect) bool {
+	const eps = 0.02
+
+	return a.X+eps < b.X+b.Width && b.X+eps < a.X+a.Width && a.Y+eps < b.Y+b.Height &&
+		b.Y+eps < a.Y+a.Height
+}
+
+// Check whether a rectangle lies inside another one
+func layoutRectInside(a LayoutRect, outer LayoutRect) bool {
+	const eps = 0.02
+
+	return a.X+eps >= outer.X && a.Y+eps >= outer.Y &&
+		a.X+a.Width <= outer.X+outer.Width+eps && a.Y+a.Height <= outer.Y+outer.Height+eps
+}
+
+// Check the stored layout: every element has a rectangle of the expected size, elements
+// lie inside their nests and the items of a nest don't overlap each other
+func expectLayoutValid(t *testing.T, graph *Graph, spec *LayoutSpec) {
+	t.Helper()
+
+	nest_rects := make(map[*Nest]LayoutRect)
+	root := graph.GetNestTree().GetRootNest()
+	nests := []*Nest{root}
+
+	for nest := range root.Descendants() {
+		nests = append(nests, nest)
+	}
+
+	for _, nest := range nests {
+		rect, ok, err := readNestLayout(nest, spec)
+
+		if err != nil || !ok {
+			t.Fatalf("nest %d: the layout is not stored (%v)", nest.GetID(), err)
+		}
+
+		nest_rects[nest] = rect
+	}
+
+	if rect := nest_rects[root]; rect.X != 0 || rect.Y != 0 {
+		t.Fatalf("the root nest starts at (%v, %v), want (0, 0)", rect.X, rect.Y)
+	}
+
+	for _, nest := range nests {
+		var items []LayoutRect
+
+		for node := range nest.Nodes() {
+			rect, err := readNodeLayout(node, spec)
+
+			if err != nil {
+				t.Fatalf("node %d: %v", node.GetID(), err)
+			}
+
+			// The sizes are stored even though they are taken from the specification
+			if rect.Width != LAYOUT_DEFAULT_NODE_WIDTH ||
+				rect.Height != LAYOUT_DEFAULT_NODE_HEIGHT {
+
+				t.Fatalf("node %d has size %vx%v, want the default one", node.GetID(),
+					rect.Width, rect.Height)
+			}
+
+			items = append(items, rect)
+		}
+
+		for child := range nest.Children() {
+			items = append(items, nest_rects[child])
+		}
+
+		for i, a := range items {
+			if !layoutRectInside(a, nest_rects[nest]) {
+				t.Fatalf("nest %d: item %+v lies outside the nest %+v", nest.GetID(), a,
+					nest_rects[nest])
+			}
+
+			for _, b := range items[i+1:] {
+				if layoutRectsOverlap(a, b) {
+					t.Fatalf("nest %d: items %+v and %+v overlap", nest.GetID(), a, b)
+				}
+			}
+		}
+	}
+}
+
+// The layered layout stores valid geometry that depends on the graph only
+func TestLayoutLayered(t *testing.T) {
+	graph, spec := newLayoutTestGraph(t, false)
+
+	if err := LayoutLayered(graph, spec); err != nil {
+		t.Fatalf("LayoutLayered: %v", err)
+	}
+
+	expectLayoutValid(t, graph, spec)
+	vals := layoutAttrVals(graph, spec)
+
+	if len(vals) != 4*(graph.NodeCount()+graph.GetNestTree().NestCount()) {
+		t.Fatalf("%d layout values are stored", len(vals))
+	}
+
+	// Repeated layout of the same graph doesn't change anything
+	if err := LayoutLayered(graph, spec); err != nil {
+		t.Fatalf("LayoutLayered: %v", err)
+	}
+
+	if !maps.Equal(layoutAttrVals(graph, spec), vals) {
+		t.Fatalf("repeated layout differs")
+	}
+
+	// The order of nodes inside nests doesn't matter either
+	other, other_spec := newLayoutTestGraph(t, true)
+
+	if err := LayoutLayered(other, other_spec); err != nil {
+		t.Fatalf("LayoutLayered: %v", err)
+	}
+
+	if !maps.Equal(layoutAttrVals(other, other_spec), vals) {
+		t.Fatalf("layout depends on the order of nodes inside nests")
+	}
+}
+
+// Storing a layout is undone as a single transaction
+func TestLayoutJournal(t *testing.T) {
+	graph, spec := newLayoutTestGraph(t, false)
+	journal := graph.EnableJournal()
+
+	if err := LayoutLayered(graph, spec); err != nil {
+		t.Fatalf("LayoutLayered: %v", err)
+	}
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	if vals := layoutAttrVals(graph, spec); len(vals) != 0 || journal.CanUndo() {
+		t.Fatalf("undo left %d layout values", len(vals))
+	}
+
+	// Inside a caller's transaction the layout is a part of that transaction
+	journal.Begin()
+	node := graph.NewNode()
+
+	if err := LayoutLayered(graph, spec); err != nil {
+		t.Fatalf("LayoutLayered: %v", err)
+	}
+
+	journal.Commit()
+	journal.Undo()
+
+	if vals := layoutAttrVals(graph, spec); len(vals) != 0 || node.GetNest() != nil {
+		t.Fatalf("undo of the caller's transaction left %d layout values", len(vals))
+	}
+}
+
+// Invalid layout specifications are rejected before the graph is changed
+func TestLayoutSpecErrors(t *testing.T) {
+	graph, spec := newLayoutTestGraph(t, false)
+	bad_size := *spec
+	bad_size.NodeSep = -1
+
+	cases := []struct {
+		spec *LayoutSpec
+		want error
+	}{
+		{nil, ErrNoLayoutAttrs},
+		{&LayoutSpec{}, ErrNoLayoutAttrs},
+		{&bad_size, ErrLayoutSize},
+	}
+
+	for _, c := range cases {
+		if err := LayoutLayered(graph, c.spec); !errors.Is(err, c.want) {
+			t.Errorf("LayoutLayered: got error %v, want %v", err, c.want)
+		}
+	}
+
+	if vals := layoutAttrVals(graph, spec); len(vals) != 0 {
+		t.Fatalf("rejected layouts stored %d values", len(vals))
+	}
+}
+
+// The force-directed layout stores valid geometry determined by the graph and the seed
+func TestLayoutForceDirected(t *testing.T) {
+	graph, spec := newLayoutTestGraph(t, false)
+
+	if err := LayoutForceDirected(graph, spec, 1); err != nil {
+		t.Fatalf("LayoutForceDirected: %v", err)
+	}
+
+	expectLayoutValid(t, graph, spec)
+	vals := layoutAttrVals(graph, spec)
+
+	if len(vals) != 4*(graph.NodeCount()+graph.GetNestTree().NestCount()) {
+		t.Fatalf("%d layout values are stored", len(vals))
+	}
+
+	// The same graph and the same seed produce the same layout
+	other, other_spec := newLayoutTestGraph(t, false)
+
+	if err := LayoutForceDirected(other, other_spec, 1); err != nil {
+		t.Fatalf("LayoutForceDirected: %v", err)
+	}
+
+	if !maps.Equal(layoutAttrVals(other, other_spec), vals) {
+		t.Fatalf("layouts computed with the same seed differ")
+	}
+
+	// Another seed gives another, but still valid, layout. It's stored as a single
+	// transaction
+	journal := graph.EnableJournal()
+
+	if err := LayoutForceDirected(graph, spec, 2); err != nil {
+		t.Fatalf("LayoutForceDirected: %v", err)
+	}
+
+	expectLayoutValid(t, graph, spec)
+	journal.Undo()
+
+	if !maps.Equal(layoutAttrVals(graph, spec), vals) || journal.CanUndo() {
+		t.Fatalf("undo didn't restore the previous layout")
+	}
+}
@@ -0,0 +1,477 @@
+/*
+  Merge of graphs
+
+  "Merge()" adds the contents of a source graph to a destination graph. The source graph
+  is not modified
+
+  Nodes are identified by keys computed by a key function (see "NodeKeyFunc"). A source
+  node whose key matches a destination node is unified with it: the destination node
+  stays in its nest and gets the attribute values of the source node (see below). Other
+  source nodes are copied. Keys of the copied nodes are not computed in the destination
+  graph, so the attribute holding the keys (if any) is usually one of the copied ones
+
+  Nests are merged by label paths: a source nest is unified with the destination nest
+  having the same label path (see "diff.go"), missing nests are created. If the nest
+  label attributes are not defined by the merge policy, the source nests are copied as
+  new nests. Copied nodes are placed into the nests corresponding to their source nests
+
+  Edges are copied as is. Optionally, a source edge is not copied if the destination graph
+  already has an edge between the same nodes (parallel edges of the source graph get
+  deduplicated as well). The destination graph keeps its directedness
+
+  Values of the attributes listed by the merge policy are copied from the source nodes to
+  the destination nodes. A value is a conflict if both nodes have different values set.
+  Conflicts are resolved by the function set by the policy ("MergeKeepDst",
+  "MergeKeepSrc", "MergeFailOnConflict" or a custom one)
+
+  Everything that can fail (computing keys, reading attributes, resolving conflicts) is
+  done before the destination graph is modified. So, a failed merge leaves the graph
+  intact. If the journal of the destination graph is enabled (and no transaction is open),
+  the merge is recorded as a single transaction
+*/
+
+package graph
+
+import (
+	"errors"
+)
+
+// Pair of node attributes holding the same property in the destination and in the source
+// graph
+type MergeNodeAttr struct {
+	// Name of the property (used to report conflicts)
+	Name string
+	Dst  *NodeStrAttr
+	Src  *NodeStrAttr
+}
+
+// Conflicting values of an attribute of unified nodes
+type MergeConflict struct {
+	Key     string
+	DstNode *Node
+	SrcNode *Node
+	// Name of the attribute (see "MergeNodeAttr")
+	Attr   string
+	DstVal string
+	SrcVal string
+}
+
+// Function resolving a conflict. Returns the value kept by the destination node
+type MergeResolveFunc func(conflict MergeConflict) (string, error)
+
+// Resolve a conflict by keeping the value of the destination node
+func MergeKeepDst(conflict MergeConflict) (string, error) {
+	return conflict.DstVal, nil
+}
+
+// Resolve a conflict by taking the value of the source node
+func MergeKeepSrc(conflict MergeConflict) (string, error) {
+	return conflict.SrcVal, nil
+}
+
+// Fail the merge on a conflict
+func MergeFailOnConflict(conflict MergeConflict) (string, error) {
+	return "", &ElemError{"Merge", conflict.DstNode.id, -1, "Nodes with key \"" +
+		conflict.Key + "\" have different values of attribute \"" + conflict.Attr + "\"",
+		ErrMergeConflict}
+}
+
+// Policy of a merge
+type MergePolicy struct {
+	// Node attributes whose values are copied
+	NodeAttrs []MergeNodeAttr
+	// Attributes holding nest labels in the destination and in the source graph. Nests
+	// are merged by label paths only if both attributes are defined. Labels of the
+	// created nests are set only if both attributes are defined
+	DstNestLabelAttr *NestStrAttr
+	SrcNestLabelAttr *NestStrAttr
+	// Whether a source edge is skipped if the destination graph already has an edge
+	// between the same nodes
+	DedupEdges bool
+	// Function resolving conflicts of attribute values. If "nil", conflicts fail the
+	// merge (see "MergeFailOnConflict")
+	Resolve MergeResolveFunc
+}
+
+// Result of a merge
+type MergeResult struct {
+	// Destination nodes by source nodes
+	Nodes map[*Node]*Node
+	// Destination nests by source nests. The root nests are mapped to each other
+	Nests map[*Nest]*Nest
+	// Elements created in the destination graph
+	AddedNodes []*Node
+	AddedEdges []*Edge
+	AddedNests []*Nest
+	// Resolved conflicts of attribute values
+	Conflicts []MergeConflict
+}
+
+// Value of an attribute to be set for a destination node
+type mergeAttrVal struct {
+	attr *NodeStrAttr
+	val  string
+}
+
+// Planned merge of a source node
+type mergeNode struct {
+	src *Node
+	// Destination node the source node is unified with ("nil" if the node is copied)
+	dst *Node
+	// Attribute values to be set for the destination node
+	vals []mergeAttrVal
+}
+
+// Check that a node attribute is valid and belongs to a graph
+func mergeCheckNodeAttr(attr *NodeStrAttr, graph *Graph) error {
+	if attr == nil || !attr.isValid {
+		return newAttrError("Merge", ATTR_ELEM_NODE, -1, -1, ErrAttrInvalid)
+	}
+
+	if attr.graph != graph {
+		return newAttrError("Merge", ATTR_ELEM_NODE, -1, attr.attrNum, ErrAttrForeign)
+	}
+
+	return nil
+}
+
+// Check that a nest attribute is valid and belongs to the nest tree of a graph
+func mergeCheckNestAttr(attr *NestStrAttr, graph *Graph) error {
+	if !attr.is_valid {
+		return newAttrError("Merge", ATTR_ELEM_NEST, -1, -1, ErrAttrInvalid)
+	}
+
+	if attr.nestTree != graph.nestTree {
+		return newAttrError("Merge", ATTR_ELEM_NEST, -1, attr.attr_num, ErrAttrForeign)
+	}
+
+	return nil
+}
+
+// Get label of a nest. Unset labels are represented by empty strings. The attribute must
+// be checked by "mergeCheckNestAttr()"
+func mergeNestLabel(nest *Nest, attr *NestStrAttr) string {
+	if !nest.strAttrs[attr.attr_num].isSet {
+		return ""
+	}
+
+	return nest.strAttrs[attr.attr_num].data
+}
+
+// Compute keys of all nodes of a graph (see "diffNodeKeys()"). Errors are reported as
+// errors of "Merge"
+func mergeNodeKeys(graph *Graph, key_fn NodeKeyFunc) (map[string]*Node, map[*Node]string,
+	error) {
+
+	nodes, keys, err := diffNodeKeys(graph, key_fn)
+
+	var elem_err *ElemError
+
+	// "diffNodeKeys()" creates a new error each time, so it can be updated in place
+	if errors.As(err, &elem_err) {
+		elem_err.Op = "Merge"
+	}
+
+	return nodes, keys, err
+}
+
+// Plan the merge of the source nodes: match them with the destination nodes and compute
+// the attribute values
+func mergePlanNodes(dst *Graph,
+	src *Graph,
+	key_fn NodeKeyFunc,
+	policy *MergePolicy,
+	result *MergeResult) ([]mergeNode, error) {
+
+	dst_nodes, _, err := mergeNodeKeys(dst, key_fn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, src_keys, err := mergeNodeKeys(src, key_fn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolve := policy.Resolve
+
+	if resolve == nil {
+		resolve = MergeFailOnConflict
+	}
+
+	var plan []mergeNode
+
+	for src_node := range src.Nodes() {
+		key := src_keys[src_node]
+		entry := mergeNode{src: src_node, dst: dst_nodes[key]}
+
+		for _, attr := range policy.NodeAttrs {
+			src_val, src_is_set, err := diffAttrVal(src_node, attr.Src)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if !src_is_set {
+				continue
+			}
+
+			if entry.dst != nil {
+				dst_val, dst_is_set, err := diffAttrVal(entry.dst, attr.Dst)
+
+				if err != nil {
+					return nil, err
+				}
+
+				if dst_is_set && dst_val == src_val {
+					continue
+				}
+
+				if dst_is_set {
+					conflict := MergeConflict{key, entry.dst, src_node, attr.Name, dst_val,
+						src_val}
+
+					if src_val, err = resolve(conflict); err != nil {
+						return nil, err
+					}
+
+					result.Conflicts = append(result.Conflicts, conflict)
+				}
+			}
+
+			entry.vals = append(entry.vals, mergeAttrVal{attr.Dst, src_val})
+		}
+
+		plan = append(plan, entry)
+	}
+
+	return plan, nil
+}
+
+// Map a source nest and its descendants to destination nests. The destination nest of
+// the source nest is already known. Missing nests are created
+func mergeNests(src_nest *Nest,
+	dst_nest *Nest,
+	policy *MergePolicy,
+	match_labels bool,
+	result *MergeResult) error {
+
+	result.Nests[src_nest] = dst_nest
+
+	// A new child nest becomes the first child of its parent. So, the children are
+	// processed in the reverse order to preserve the order of the source graph
+	var children []*Nest
+
+	for child := range src_nest.Children() {
+		children = append(children, child)
+	}
+
+	dst_children := make([]*Nest, len(children))
+
+	for i := len(children) - 1; i >= 0; i-- {
+		if match_labels {
+			label := mergeNestLabel(children[i], policy.SrcNestLabelAttr)
+
+			for dst_child := range dst_nest.Children() {
+				if mergeNestLabel(dst_child, policy.DstNestLabelAttr) == label {
+					dst_children[i] = dst_child
+
+					break
+				}
+			}
+
+			if dst_children[i] != nil {
+				continue
+			}
+		}
+
+		dst_child, err := dst_nest.NewChildNest()
+
+		if err != nil {
+			return err
+		}
+
+		if match_labels {
+			attr := policy.SrcNestLabelAttr
+
+			if children[i].strAttrs[attr.attr_num].isSet {
+				err = dst_child.SetStrAttrVal(policy.DstNestLabelAttr,
+					mergeNestLabel(children[i], attr))
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		dst_children[i] = dst_child
+		result.AddedNests = append(result.AddedNests, dst_child)
+	}
+
+	for i, child := range children {
+		err := mergeNests(child, dst_children[i], policy, match_labels, result)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Merge a source graph into a destination graph (see the description of the file).
+// "policy" can be "nil": then no attributes are copied, nests are copied as new nests,
+// edges are not deduplicated
+//
+// The source graph must not be modified while it's merged
+func Merge(dst *Graph,
+	src *Graph,
+	key_fn NodeKeyFunc,
+	policy *MergePolicy) (result *MergeResult, err error) {
+
+	defer recoverInternalError("Merge", &err)
+
+	if dst == nil || src == nil {
+		return nil, &ElemError{"Merge", -1, -1, "Zero reference to a merged graph",
+			ErrNilGraph}
+	}
+
+	if dst == src {
+		return nil, &ElemError{"Merge", -1, -1, "A graph can't be merged into itself",
+			ErrSameGraph}
+	}
+
+	if key_fn == nil {
+		return nil, &ElemError{"Merge", -1, -1, "Zero reference to the key function",
+			ErrNilKeyFunc}
+	}
+
+	if dst.nestTree == nil || dst.nestTree.rootNest == nil ||
+		src.nestTree == nil || src.nestTree.rootNest == nil {
+
+		return nil, &ElemError{"Merge", -1, -1, "A merged graph doesn't have a nest " +
+			"tree", ErrNoNestTree}
+	}
+
+	// NOTE: here the function parameter "policy" is intentionally re-assigned
+	if policy == nil {
+		policy = &MergePolicy{}
+	}
+
+	for _, attr := range policy.NodeAttrs {
+		if err := mergeCheckNodeAttr(attr.Dst, dst); err != nil {
+			return nil, err
+		}
+
+		if err := mergeCheckNodeAttr(attr.Src, src); err != nil {
+			return nil, err
+		}
+	}
+
+	match_labels := policy.DstNestLabelAttr != nil && policy.SrcNestLabelAttr != nil
+
+	if match_labels {
+		if err := mergeCheckNestAttr(policy.DstNestLabelAttr, dst); err != nil {
+			return nil, err
+		}
+
+		if err := mergeCheckNestAttr(policy.SrcNestLabelAttr, src); err != nil {
+			return nil, err
+		}
+	}
+
+	result = &MergeResult{
+		Nodes: make(map[*Node]*Node, src.NodeCount()),
+		Nests: make(map[*Nest]*Nest, src.nestTree.NestCount()),
+	}
+
+	plan, err := mergePlanNodes(dst, src, key_fn, policy, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Modify the destination graph. The journal transaction (if any) is rolled back if
+	// the modification fails. This deferred function runs before the one registered at
+	// the top of "Merge()", so a panic is recovered here: otherwise the transaction
+	// would be committed while the panic is being propagated
+	if journal := dst.GetJournal(); journal != nil && !journal.InTx() {
+		if err = journal.Begin(); err != nil {
+			return nil, &ElemError{"Merge", -1, -1, "Cannot open a journal transaction",
+				err}
+		}
+
+		defer func() {
+			if panic_val := recover(); panic_val != nil {
+				result, err = nil, &InternalError{Op: "Merge", PanicVal: panic_val}
+			}
+
+			if err != nil {
+				journal.Rollback()
+			} else {
+				journal.Commit()
+			}
+		}()
+	}
+
+	err = mergeNests(src.nestTree.rootNest, dst.nestTree.rootNest, policy, match_labels,
+		result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range plan {
+		dst_node := entry.dst
+
+		if dst_node == nil {
+			if dst_node, err = dst.NewNodeChecked(); err != nil {
+				return nil, err
+			}
+
+			if dst_nest := result.Nests[entry.src.nest]; dst_nest != dst.nestTree.rootNest {
+				if err = dst_node.MoveToNest(dst_nest); err != nil {
+					return nil, err
+				}
+			}
+
+			result.AddedNodes = append(result.AddedNodes, dst_node)
+		}
+
+		for _, val := range entry.vals {
+			if err = dst_node.SetStrAttrVal(val.attr, val.val); err != nil {
+				return nil, err
+			}
+		}
+
+		result.Nodes[entry.src] = dst_node
+	}
+
+	for edge := range src.Edges() {
+		src_node, dst_node := result.Nodes[edge.srcNode], result.Nodes[edge.dstNode]
+
+		if policy.DedupEdges {
+			has_edge, err := dst.HasEdge(src_node, dst_node)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if has_edge {
+				continue
+			}
+		}
+
+		new_edge, err := dst.NewEdge(src_node, dst_node)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.AddedEdges = append(result.AddedEdges, new_edge)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"errors"
+	"testing"
+)
+
+// Build a graph with a "name" attribute and named nodes. Nodes with names from "nested"
+// are placed into a common nest
+func newMergeTestGraph(t *testing.T, names []string, nested []string) (*Graph,
+	*NodeStrAttr, map[string]*Node) {
+
+	t.Helper()
+
+	graph := NewGraph(AttrSpec{NodeStrAttrNum: 1})
+	name_attr, err := graph.NewNodeStrAttr()
+
+	if err != nil {
+		t.Fatalf("NewNodeStrAttr: %v", err)
+	}
+
+	nodes := make(map[string]*Node)
+
+	for _, name := range names {
+		node := graph.NewNode()
+
+		if err := node.SetStrAttrVal(name_attr, name); err != nil {
+			t.Fatalf("SetStrAttrVal: %v", err)
+		}
+
+		nodes[name] = node
+	}
+
+	if len(nested) > 0 {
+		nest := graph.GetNestTree().NewNest()
+
+		for _, name := range nested {
+			if err := nodes[name].MoveToNest(nest); err != nil {
+				t.Fatalf("MoveToNest: %v", err)
+			}
+		}
+	}
+
+	return graph, name_attr, nodes
+}
+
+// A merge is recorded as a single transaction that can be undone
+func TestMergeJournal(t *testing.T) {
+	dst, dst_name, _ := newMergeTestGraph(t, []string{"a"}, nil)
+	src, src_name, src_nodes := newMergeTestGraph(t, []string{"a", "b", "c"},
+		[]string{"b", "c"})
+
+	src.NewEdge(src_nodes["a"], src_nodes["b"])
+	src.NewEdge(src_nodes["b"], src_nodes["c"])
+
+	journal := dst.EnableJournal()
+	policy := &MergePolicy{NodeAttrs: []MergeNodeAttr{{"name", dst_name, src_name}}}
+	result, err := Merge(dst, src, NodeKeyByAttr(dst_name, src_name), policy)
+
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	if len(result.AddedNodes) != 2 || len(result.AddedEdges) != 2 ||
+		len(result.AddedNests) != 1 {
+
+		t.Fatalf("Merge added %d nodes, %d edges, %d nests, want 2, 2, 1",
+			len(result.AddedNodes), len(result.AddedEdges), len(result.AddedNests))
+	}
+
+	expectConsistent(t, dst, "merge")
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	expectConsistent(t, dst, "undo")
+
+	if dst.NodeCount() != 1 || dst.EdgeCount() != 0 || dst.GetNestTree().NestCount() != 1 {
+		t.Fatalf("undo left %d nodes, %d edges, %d nests, want 1, 0, 1", dst.NodeCount(),
+			dst.EdgeCount(), dst.GetNestTree().NestCount())
+	}
+
+	if journal.CanUndo() {
+		t.Fatalf("the merge is recorded as several transactions")
+	}
+
+	// A merge made inside an open transaction becomes a part of that transaction
+	journal.Begin()
+
+	if _, err := Merge(dst, src, NodeKeyByAttr(dst_name, src_name), policy); err != nil {
+		t.Fatalf("Merge in a transaction: %v", err)
+	}
+
+	if !journal.InTx() {
+		t.Fatalf("Merge closed the transaction of the caller")
+	}
+
+	journal.Rollback()
+	expectConsistent(t, dst, "rollback")
+
+	if dst.NodeCount() != 1 {
+		t.Fatalf("rollback left %d nodes, want 1", dst.NodeCount())
+	}
+}
+
+// A merge that fails while modifying the destination graph leaves the graph intact
+func TestMergeRollback(t *testing.T) {
+	dst, dst_name, _ := newMergeTestGraph(t, []string{"a"}, nil)
+	src, src_name, src_nodes := newMergeTestGraph(t, []string{"a", "b", "c"},
+		[]string{"b"})
+
+	// The nest of the last node isn't a part of the source nest tree. So, the merge
+	// fails after some of the elements are already created
+	other, _, _ := newMergeTestGraph(t, nil, nil)
+	src_nodes["c"].nest = other.GetNestTree().NewNest()
+
+	journal := dst.EnableJournal()
+	_, err := Merge(dst, src, NodeKeyByAttr(dst_name, src_name), nil)
+
+	if !errors.Is(err, ErrNilNest) {
+		t.Fatalf("Merge: got error %v, want %v", err, ErrNilNest)
+	}
+
+	expectConsistent(t, dst, "failed merge")
+
+	if dst.NodeCount() != 1 || dst.GetNestTree().NestCount() != 1 {
+		t.Fatalf("failed merge left %d nodes, %d nests, want 1, 1", dst.NodeCount(),
+			dst.GetNestTree().NestCount())
+	}
+
+	if journal.InTx() || journal.CanUndo() {
+		t.Fatalf("failed merge left a transaction in the journal")
+	}
+}
@@ -0,0 +1,253 @@
+/*
+  Ancestry queries on a nest tree
+
+  The lowest common ancestor (LCA) of two nests is the deepest nest that contains both of
+  them. A nest is considered to contain itself. So, the LCA of a nest and its descendant
+  is the nest itself. The LCA of nests "a" and "b" is also the nest to which an edge
+  connecting nodes of "a" and "b" is attributed
+
+  "NestTree.LowestCommonAncestor()" walks the tree up from the nests. Its cost is
+  proportional to the depth of the nests. For deep hierarchies queried many times,
+  an LCA index can be built by "NestTree.NewLCAIndex()". The index answers a query in
+  time proportional to the logarithm of the tree depth
+
+  NOTE: an LCA index describes the nest hierarchy at the moment it was built. Once a nest
+        is attached to or detached from the tree (including undo and redo of nest
+        creation), the index becomes outdated and refuses to answer queries until
+        "Rebuild()" is called
+*/
+
+package graph
+
+import (
+	"errors"
+)
+
+// The LCA index doesn't match the current nest hierarchy
+var ErrStaleLCAIndex = errors.New("The LCA index is outdated")
+
+// Index for fast LCA queries (binary lifting)
+type NestLCAIndex struct {
+	// Nest tree for which the index is built
+	nestTree *NestTree
+	// Version of the nest hierarchy the index describes
+	hierarchyVersion int
+	// Ancestors of nests. "ancestors[k][id]" is the ancestor located "2^k" levels above
+	// the nest with ID "id". "nil" if there is no such ancestor or no such nest
+	ancestors [][]*Nest
+}
+
+// Get depth of a nest in the nest tree. The root nest has depth "0", its children have
+// depth "1" and so on
+func (nest *Nest) Depth() int {
+	return nest.level - NT_ROOT_NEST_LEVEL
+}
+
+// Check whether a nest is a proper ancestor of another nest (i.e. contains it directly or
+// through other nests). A nest is not an ancestor of itself. Returns "false" if the nests
+// belong to different nest trees
+func (nest *Nest) IsAncestorOf(other *Nest) bool {
+	if other == nil || other.nestTree != nest.nestTree || other.level <= nest.level {
+		return false
+	}
+
+	for other != nil && other.level > nest.level {
+		other = other.parentNest
+	}
+
+	return other == nest
+}
+
+// Get the chain of nests leading from the root nest to a nest. The first element is the
+// root nest and the last one is the nest itself
+func (nest *Nest) Path() []*Nest {
+	path := make([]*Nest, nest.Depth()+1)
+
+	for i, ancestor := len(path)-1, nest; i >= 0 && ancestor != nil; i-- {
+		path[i] = ancestor
+		ancestor = ancestor.parentNest
+	}
+
+	return path
+}
+
+// Find the lowest common ancestor of two nests
+func (nt *NestTree) LowestCommonAncestor(a *Nest, b *Nest) (lca *Nest, err error) {
+	defer recoverInternalError("LowestCommonAncestor", &err)
+
+	if err := nt.checkLCAArgs("LowestCommonAncestor", a, b); err != nil {
+		return nil, err
+	}
+
+	return lowestCommonNest(a, b, "Panic while looking for the lowest common ancestor"),
+		nil
+}
+
+// Build an LCA index for a nest tree
+func (nt *NestTree) NewLCAIndex() *NestLCAIndex {
+	index := &NestLCAIndex{nestTree: nt}
+	index.Rebuild()
+
+	return index
+}
+
+// Rebuild an LCA index so that it describes the current nest hierarchy
+func (index *NestLCAIndex) Rebuild() {
+	nt := index.nestTree
+	max_depth := 0
+
+	for _, nest := range nt.nestsByID {
+		if nest != nil && nest.Depth() > max_depth {
+			max_depth = nest.Depth()
+		}
+	}
+
+	// The first row holds parents. Every next row is computed from the previous one:
+	// the "2^k"-th ancestor is the "2^(k-1)"-th ancestor of the "2^(k-1)"-th ancestor
+	parents := make([]*Nest, len(nt.nestsByID))
+
+	for id, nest := range nt.nestsByID {
+		if nest != nil {
+			parents[id] = nest.parentNest
+		}
+	}
+
+	index.ancestors = [][]*Nest{parents}
+
+	for span := 2; span <= max_depth; span *= 2 {
+		prev := index.ancestors[len(index.ancestors)-1]
+		row := make([]*Nest, len(prev))
+
+		for id, ancestor := range prev {
+			if ancestor != nil {
+				row[id] = prev[ancestor.id]
+			}
+		}
+
+		index.ancestors = append(index.ancestors, row)
+	}
+
+	index.hierarchyVersion = nt.hierarchyVersion
+}
+
+// Check whether an LCA index describes the current nest hierarchy
+func (index *NestLCAIndex) IsUpToDate() bool {
+	return index.hierarchyVersion == index.nestTree.hierarchyVersion
+}
+
+// Find the lowest common ancestor of two nests using an LCA index
+func (index *NestLCAIndex) LowestCommonAncestor(a *Nest, b *Nest) (lca *Nest, err error) {
+	defer recoverInternalError("NestLCAIndex.LowestCommonAncestor", &err)
+
+	op := "NestLCAIndex.LowestCommonAncestor"
+
+	if err := index.nestTree.checkLCAArgs(op, a, b); err != nil {
+		return nil, err
+	}
+
+	if !index.IsUpToDate() {
+		return nil, &ElemError{op, -1, -1, "The nest hierarchy changed after the index " +
+			"was built", ErrStaleLCAIndex}
+	}
+
+	if a.level < b.level {
+		a, b = b, a
+	}
+
+	a = index.ancestorAtDepth(a, b.Depth())
+
+	if a == b {
+		return a, nil
+	}
+
+	// Lift both nests by the largest spans that keep them apart. After that their parents
+	// coincide
+	for k := len(index.ancestors) - 1; k >= 0; k-- {
+		if index.ancestors[k][a.id] != index.ancestors[k][b.id] {
+			a = index.ancestors[k][a.id]
+			b = index.ancestors[k][b.id]
+		}
+	}
+
+	if a.parentNest == nil || a.parentNest != b.parentNest {
+		panic("Panic while looking for the lowest common ancestor: the LCA index is " +
+			"inconsistent with the nest tree")
+	}
+
+	return a.parentNest, nil
+}
+
+// Get the ancestor of a nest located at a given depth (which must not exceed the depth of
+// the nest)
+func (index *NestLCAIndex) ancestorAtDepth(nest *Nest, depth int) *Nest {
+	for k, lift := 0, nest.Depth()-depth; lift > 0; k, lift = k+1, lift>>1 {
+		if lift&1 != 0 {
+			nest = index.ancestors[k][nest.id]
+		}
+	}
+
+	return nest
+}
+
+// Check that two nests can be passed to an LCA query on a nest tree
+func (nt *NestTree) checkLCAArgs(op string, a *Nest, b *Nest) error {
+	for _, nest := range []*Nest{a, b} {
+		if nest == nil {
+			return &ElemError{op, -1, -1, "Pointer to a nest cannot be \"nil\"",
+				ErrNilNest}
+		}
+
+		if nest.nestTree != nt {
+			return &ElemError{op, -1, nest.id, "The nest belongs to a different nest tree",
+				ErrForeignNest}
+		}
+
+		if nt.NestByID(nest.id) != nest {
+			return &ElemError{op, -1, nest.id, "The nest is not attached to the nest tree",
+				ErrInconsistent}
+		}
+	}
+
+	return nil
+}
+
+// Find the closest nest that contains both nests. Panics with a message starting with
+// "panic_msg_prefix" if the nest tree is inconsistent
+//
+// This function has an auxiliary purpose. It must be available inside the Graph package
+// only and stay invisible from outside
+func lowestCommonNest(a *Nest, b *Nest, panic_msg_prefix string) *Nest {
+	panic_msg_inconsistent_nt := ": either the nest tree has disconnected components " +
+		"or the nests have inconsistent levels"
+
+	// If "a" is "deeper" than "b" in the nest hierarchy, then find a nest which contains
+	// "a" but belongs to the same level in the nest hierarchy as "b"
+	for a.level > b.level {
+		a = a.parentNest
+
+		if a == nil {
+			panic(panic_msg_prefix + panic_msg_inconsistent_nt)
+		}
+	}
+
+	// If "b" is "deeper" than "a" in the nest hierarchy, then find a nest which contains
+	// "b" but belongs to the same level in the nest hierarchy as "a"
+	for b.level > a.level {
+		b = b.parentNest
+
+		if b == nil {
+			panic(panic_msg_prefix + panic_msg_inconsistent_nt)
+		}
+	}
+
+	for a != b {
+		a = a.parentNest
+		b = b.parentNest
+
+		if a == nil || b == nil {
+			panic(panic_msg_prefix + panic_msg_inconsistent_nt)
+		}
+	}
+
+	return a
+}
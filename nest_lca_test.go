@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// The LCA is found for the root nest, sibling nests and nests related as ancestor and
+// descendant. The plain query and the index agree
+func TestLowestCommonAncestor(t *testing.T) {
+	nest_tree := NewGraph(AttrSpec{}).GetNestTree()
+	root := nest_tree.GetRootNest()
+	p := nest_tree.NewNest()
+	q, _ := p.NewChildNest()
+	r, _ := q.NewChildNest()
+	s, _ := p.NewChildNest()
+	u := nest_tree.NewNest()
+
+	// A long chain makes the index lift nests by several spans
+	chain := []*Nest{r}
+
+	for i := 0; i < 10; i++ {
+		child, _ := chain[len(chain)-1].NewChildNest()
+		chain = append(chain, child)
+	}
+
+	leaf := chain[len(chain)-1]
+	index := nest_tree.NewLCAIndex()
+
+	cases := []struct {
+		a    *Nest
+		b    *Nest
+		want *Nest
+	}{
+		{root, root, root},
+		{root, leaf, root},
+		{q, s, p},
+		{r, s, p},
+		{leaf, s, p},
+		{r, u, root},
+		{p, u, root},
+		{p, r, p},
+		{q, leaf, q},
+		{chain[4], chain[9], chain[4]},
+		{r, r, r},
+	}
+
+	for _, c := range cases {
+		for _, pair := range [][2]*Nest{{c.a, c.b}, {c.b, c.a}} {
+			lca, err := nest_tree.LowestCommonAncestor(pair[0], pair[1])
+
+			if err != nil || lca != c.want {
+				t.Errorf("LCA of %d and %d: got %v (%v), want %d", pair[0].GetID(),
+					pair[1].GetID(), lca, err, c.want.GetID())
+			}
+
+			lca, err = index.LowestCommonAncestor(pair[0], pair[1])
+
+			if err != nil || lca != c.want {
+				t.Errorf("indexed LCA of %d and %d: got %v (%v), want %d",
+					pair[0].GetID(), pair[1].GetID(), lca, err, c.want.GetID())
+			}
+		}
+	}
+
+	if root.Depth() != 0 || r.Depth() != 3 || leaf.Depth() != 13 {
+		t.Errorf("depths: got %d, %d and %d, want 0, 3 and 13", root.Depth(), r.Depth(),
+			leaf.Depth())
+	}
+
+	if got := r.Path(); !slices.Equal(got, []*Nest{root, p, q, r}) {
+		t.Errorf("path of r has %d nests, want 4", len(got))
+	}
+
+	if !root.IsAncestorOf(r) || !p.IsAncestorOf(leaf) || r.IsAncestorOf(r) ||
+		s.IsAncestorOf(r) || r.IsAncestorOf(p) {
+
+		t.Errorf("ancestry is reported wrong")
+	}
+}
+
+// An index refuses to answer queries once the hierarchy changes
+func TestLCAIndexOutdated(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	nest_tree := graph.GetNestTree()
+	a := nest_tree.NewNest()
+	index := nest_tree.NewLCAIndex()
+	journal := graph.EnableJournal()
+	b, _ := a.NewChildNest()
+
+	if _, err := index.LowestCommonAncestor(a, a); !errors.Is(err, ErrStaleLCAIndex) {
+		t.Fatalf("outdated index: got error %v, want %v", err, ErrStaleLCAIndex)
+	}
+
+	index.Rebuild()
+
+	if lca, err := index.LowestCommonAncestor(a, b); err != nil || lca != a {
+		t.Fatalf("rebuilt index: got %v (%v), want %d", lca, err, a.GetID())
+	}
+
+	// Undo detaches the nest
+	journal.Undo()
+
+	if index.IsUpToDate() {
+		t.Fatalf("the index is up to date after undo")
+	}
+
+	if _, err := nest_tree.LowestCommonAncestor(a, b); err == nil {
+		t.Fatalf("LCA of a detached nest is found")
+	}
+
+	other := NewGraph(AttrSpec{}).GetNestTree().NewNest()
+
+	if _, err := nest_tree.LowestCommonAncestor(a, other); !errors.Is(err,
+		ErrForeignNest) {
+
+		t.Errorf("foreign nest: got error %v, want %v", err, ErrForeignNest)
+	}
+
+	if _, err := nest_tree.LowestCommonAncestor(nil, a); !errors.Is(err, ErrNilNest) {
+		t.Errorf("nil nest: got error %v, want %v", err, ErrNilNest)
+	}
+}
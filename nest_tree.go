@@ -7,10 +7,6 @@
 
 package graph
 
-import (
-	"errors"
-)
-
 const NT_ROOT_NEST_LEVEL = 0
 
 // Variables of the below type map printable nest properties to actual nest attributes.
@@ -86,6 +82,16 @@ type NestTree struct {
 	// An element holds TRUE if corresponding attribute is allocated and FALSE
 	// in the opposite case
 	nestStrAttrAllocMap []bool
+	// Nests indexed by their IDs. Deleted nests are represented by "nil"
+	nestsByID []*Nest
+	// Number of live (i.e. not deleted) nests. The root nest is counted as well
+	liveNestCount int
+	// Indexes of nests by values of nest string attributes (see "NewNestAttrIndex()").
+	// Indexed by attribute numbers. "nil" if no index was ever created
+	nestIndexes []*strAttrIndex[*Nest]
+	// Version of the nest hierarchy. Incremented every time a nest is attached to or
+	// detached from its parent. Used to detect outdated LCA indexes (see "NewLCAIndex()")
+	hierarchyVersion int
 }
 
 // Get unique ID of a nest
@@ -181,16 +187,13 @@ func (nest *Nest) GetFirstEdge() *Edge {
 
 // Get value of a nest string attribute
 func (nest *Nest) GetStrAttrVal(attr *NestStrAttr) (string, error) {
-	if !attr.is_valid {
-		return "", errors.New("The attribute is invalid")
-	}
-
-	if attr.nestTree != nest.nestTree {
-		return "", errors.New("The attribute and the nest belong to different nest trees")
+	if err := nest.checkStrAttr("GetStrAttrVal", attr); err != nil {
+		return "", err
 	}
 
 	if !nest.strAttrs[attr.attr_num].isSet {
-		return "", errors.New("The attribute is not set for the nest")
+		return "", newAttrError("GetStrAttrVal", ATTR_ELEM_NEST, nest.id, attr.attr_num,
+			ErrAttrNotSet)
 	}
 
 	return nest.strAttrs[attr.attr_num].data, nil
@@ -198,47 +201,52 @@ func (nest *Nest) GetStrAttrVal(attr *NestStrAttr) (string, error) {
 
 // Set value of a nest string attribute
 func (nest *Nest) SetStrAttrVal(attr *NestStrAttr, val string) error {
-	if attr.is_valid == false {
-		return errors.New("The attribute is invalid")
-	}
-
-	if attr.nestTree != nest.nestTree {
-		return errors.New("The attribute and the nest belong to different nest trees")
+	if err := nest.checkStrAttr("SetStrAttrVal", attr); err != nil {
+		return err
 	}
 
-	nest.strAttrs[attr.attr_num].isSet = true
-	nest.strAttrs[attr.attr_num].data = val
+	nest.nestTree.baseGraph.changeStrAttr(ATTR_ELEM_NEST, nil, nest, attr.attr_num,
+		strAttrVal{true, val})
 
 	return nil
 }
 
 // Remove string attribute from a specific nest
 func (nest *Nest) RemoveStrAttr(attr *NestStrAttr) error {
-	if !attr.is_valid {
-		errors.New("The attribute is invalid")
+	if err := nest.checkStrAttr("RemoveStrAttr", attr); err != nil {
+		return err
 	}
 
-	if attr.nestTree != nest.nestTree {
-		errors.New("The attribute and the nest belong to different nest trees")
-	}
-
-	nest.strAttrs[attr.attr_num].isSet = false
+	nest.nestTree.baseGraph.changeStrAttr(ATTR_ELEM_NEST, nil, nest, attr.attr_num,
+		strAttrVal{})
 
 	return nil
 }
 
 // Check wheter a string attribute is set for a nest
 func (nest *Nest) IsStrAttrSet(attr *NestStrAttr) (bool, error) {
-	if !attr.is_valid {
-		return false, errors.New("The attribute is invalid")
+	if err := nest.checkStrAttr("IsStrAttrSet", attr); err != nil {
+		return false, err
+	}
+
+	return nest.strAttrs[attr.attr_num].isSet, nil
+}
+
+// Check that a string attribute is valid and can be used with a nest
+func (nest *Nest) checkStrAttr(op string, attr *NestStrAttr) error {
+	if nest == nil {
+		return &ElemError{op, -1, -1, "Zero reference to the nest", ErrNilNest}
+	}
+
+	if attr == nil || !attr.is_valid {
+		return newAttrError(op, ATTR_ELEM_NEST, nest.id, -1, ErrAttrInvalid)
 	}
 
 	if attr.nestTree != nest.nestTree {
-		return false, errors.New("The attribute and the nest belong to different nest " +
-			"trees")
+		return newAttrError(op, ATTR_ELEM_NEST, nest.id, attr.attr_num, ErrAttrForeign)
 	}
 
-	return nest.strAttrs[attr.attr_num].isSet, nil
+	return nil
 }
 
 // Add a graph node to a nest
@@ -388,6 +396,113 @@ func (nest *Nest) removeEdge(edge *Edge) {
 
 }
 
+// Add a nest to the list of child nests of a nest. The added nest becomes the first child
+//
+// This method has an auxiliary purpose. It must be available inside the Graph package
+// only and stay invisible from outside
+func (nest *Nest) addChildNest(child *Nest) {
+	first_child := nest.firstChildNest
+
+	if first_child != nil {
+		first_child.prevSiblingNest = child
+	} else {
+		nest.lastChildNest = child
+	}
+
+	child.parentNest = nest
+	child.nextSiblingNest = first_child
+	child.prevSiblingNest = nil
+	nest.firstChildNest = child
+	nest.nestTree.hierarchyVersion++
+
+	return
+}
+
+// Remove a nest from the list of child nests of its parent
+//
+// This method has an auxiliary purpose. It must be available inside the Graph package
+// only and stay invisible from outside
+func (nest *Nest) removeChildNest(child *Nest) {
+	if child.parentNest != nest {
+		panic("Panic while removing a child nest: the nest is not a child of the nest " +
+			"it's being removed from")
+	}
+
+	next_child := child.nextSiblingNest
+	prev_child := child.prevSiblingNest
+
+	if next_child != nil {
+		next_child.prevSiblingNest = prev_child
+	} else {
+		nest.lastChildNest = prev_child
+	}
+
+	if prev_child != nil {
+		prev_child.nextSiblingNest = next_child
+	} else {
+		nest.firstChildNest = next_child
+	}
+
+	child.parentNest = nil
+	child.nextSiblingNest = nil
+	child.prevSiblingNest = nil
+	nest.nestTree.hierarchyVersion++
+
+	return
+}
+
+// Insert a graph node into a nest right after a given node of the same nest. If "prev" is
+// "nil", the node is inserted at the beginning of the list
+//
+// This method has an auxiliary purpose. It's used to restore the exact position of a node
+// when a graph mutation is undone (see "Journal")
+func (nest *Nest) insertNodeAfter(node *Node, prev *Node) {
+	if prev == nil {
+		nest.addNode(node)
+
+		return
+	}
+
+	next := prev.nextNodeInNest
+
+	if next != nil {
+		next.prevNodeInNest = node
+	} else {
+		nest.lastNode = node
+	}
+
+	node.nextNodeInNest = next
+	node.prevNodeInNest = prev
+	prev.nextNodeInNest = node
+
+	return
+}
+
+// Insert a graph edge into a nest right after a given edge of the same nest. If "prev" is
+// "nil", the edge is inserted at the beginning of the list
+//
+// This method has an auxiliary purpose. It's used to restore the exact position of an
+// edge when a graph mutation is undone (see "Journal")
+func (nest *Nest) insertEdgeAfter(edge *Edge, prev *Edge) {
+	if prev == nil {
+		nest.addEdge(edge)
+
+		return
+	}
+
+	next := prev.nextEdgeInNest
+
+	if next != nil {
+		next.prevEdgeInNest = edge
+	}
+
+	edge.nextEdgeInNest = next
+	edge.prevEdgeInNest = prev
+	prev.nextEdgeInNest = edge
+
+	return
+}
+
 // Create a nest tree
 //
 // NOTE: it's expected below that all base graph fields - except "nestTree" - were
@@ -420,38 +535,93 @@ func newNestTree(base_graph *Graph) *NestTree {
 		firstNode:       nil,
 		lastNode:        nil,
 		firstEdge:       nil,
+		strAttrs:        make([]strAttrVal, base_graph.attrSpec.NestStrAttrNum),
 	}
 
 	nt_p.nestCount++
 	nt_p.rootNest = root_nest_p
+	nt_p.registerNest(root_nest_p)
 
 	return nt_p
 }
 
 // Create a new nest in a nest tree
 //
-// Newly created nests have the root nest as a parent. A different parent can be assigned
-// later by calling "SetParentNest()" method of the nest
+// Newly created nests have the root nest as a parent. Nests with a different parent are
+// created by "NewChildNest()" method of the parent
 func (nt *NestTree) NewNest() *Nest {
-	// Some sanity checks first
-	panic_msg_prefix := "Panic while creating a new nest: "
+	nest, err := nt.NewNestChecked()
+
+	if err != nil {
+		panic("Panic while creating a new nest: " + err.Error())
+	}
+
+	return nest
+}
+
+// Create a new nest in a nest tree. In contrast to "NewNest()", an error is returned
+// instead of panicking if the nest tree is inconsistent
+func (nt *NestTree) NewNestChecked() (new_nest *Nest, err error) {
+	defer recoverInternalError("NewNestChecked", &err)
+
+	if nt == nil {
+		return nil, &ElemError{"NewNestChecked", -1, -1, "Zero reference to the nest " +
+			"tree", ErrNoNestTree}
+	}
 
 	if nt.rootNest == nil {
-		panic(panic_msg_prefix + "the tree has zero reference to the root nest")
+		return nil, &ElemError{"NewNestChecked", -1, -1, "The tree has zero reference " +
+			"to the root nest", ErrNoNestTree}
 	}
 
 	if nt.baseGraph == nil {
-		panic(panic_msg_prefix + "the tree has zero reference to the base graph")
+		return nil, &ElemError{"NewNestChecked", -1, -1, "The tree has zero reference " +
+			"to the base graph", ErrInconsistent}
 	}
 
+	return nt.newChildNest(nt.rootNest), nil
+}
+
+// Create a new nest as a child of a nest. The new nest becomes the first child of its
+// parent
+func (nest *Nest) NewChildNest() (child_nest *Nest, err error) {
+	defer recoverInternalError("NewChildNest", &err)
+
+	if nest == nil {
+		return nil, &ElemError{"NewChildNest", -1, -1, "Zero reference to the parent " +
+			"nest", ErrNilNest}
+	}
+
+	nt := nest.nestTree
+
+	if nt == nil || nt.rootNest == nil {
+		return nil, &ElemError{"NewChildNest", -1, nest.id, "The parent nest is not " +
+			"linked to any nest tree", ErrNoNestTree}
+	}
+
+	if nt.baseGraph == nil {
+		return nil, &ElemError{"NewChildNest", -1, nest.id, "The tree has zero " +
+			"reference to the base graph", ErrInconsistent}
+	}
+
+	if nt.NestByID(nest.id) != nest {
+		return nil, &ElemError{"NewChildNest", -1, nest.id, "The parent nest was " +
+			"removed from the nest tree", ErrElemDetached}
+	}
+
+	return nt.newChildNest(nest), nil
+}
+
+// Create a new nest as a child of a given nest of the tree
+func (nt *NestTree) newChildNest(parent *Nest) *Nest {
 	nest_p := &Nest{
 		id:              nt.nestCount,
 		nestTree:        nt,
-		level:           nt.rootNest.level + 1,
-		parentNest:      nt.rootNest,
+		level:           parent.level + 1,
+		parentNest:      nil,
 		firstChildNest:  nil,
 		lastChildNest:   nil,
-		nextSiblingNest: nt.rootNest.firstChildNest,
+		nextSiblingNest: nil,
 		prevSiblingNest: nil,
 		firstNode:       nil,
 		lastNode:        nil,
@@ -459,14 +629,11 @@ func (nt *NestTree) NewNest() *Nest {
 		strAttrs:        make([]strAttrVal, nt.baseGraph.attrSpec.NestStrAttrNum),
 	}
 
-	if sibling := nt.rootNest.firstChildNest; sibling != nil {
-		sibling.prevSiblingNest = nest_p
-	} else {
-		nt.rootNest.lastChildNest = nest_p
-	}
-
-	nt.rootNest.firstChildNest = nest_p
+	parent.addChildNest(nest_p)
 	nt.nestCount++
+	nt.registerNest(nest_p)
+	nt.baseGraph.journalAdd(&journalNewNest{nest_p, parent})
+	nt.baseGraph.notifyElem(EVENT_NEST_NEW, nil, nil, nest_p, nil)
 
 	return nest_p
 }
@@ -483,30 +650,39 @@ func (nt *NestTree) NewNestStrAttr() (*NestStrAttr, error) {
 		}
 	}
 
-	return &nest_str_attr_invalid, errors.New("No available nest string attributes")
+	return &nest_str_attr_invalid, newAttrError("NewNestStrAttr", ATTR_ELEM_NEST, -1, -1,
+		ErrNoAvailableAttrs)
 }
 
 // Release nest string attribute for a nest tree
-func (nt *NestTree) ReleaseNestStrAttr(attr *NestStrAttr) error {
-	if !attr.is_valid {
-		return errors.New("The attribute cannot be released. It's invalid")
+func (nt *NestTree) ReleaseNestStrAttr(attr *NestStrAttr) (err error) {
+	defer recoverInternalError("ReleaseNestStrAttr", &err)
+
+	if attr == nil || !attr.is_valid {
+		return newAttrError("ReleaseNestStrAttr", ATTR_ELEM_NEST, -1, -1, ErrAttrInvalid)
 	}
 
 	if attr.nestTree != nt {
-		return errors.New("The attribute doesn't belong to the nest tree")
+		return newAttrError("ReleaseNestStrAttr", ATTR_ELEM_NEST, -1, attr.attr_num,
+			ErrAttrForeign)
 	}
 
 	attr_num := attr.attr_num
 
-	// Remove the attribute from all existing nests
-	for nest := nt.GetRootNest(); nest != nil; nest = nest.GetNextNest() {
-		// Explicitly ingnore error that may be returned by the below call
-		// (since no error is expected)
-		nest.RemoveStrAttr(attr)
-	}
+	// Remove the attribute from all existing nests. The removals are not recorded into the
+	// journal: the recorded changes of the attribute are dropped below anyway
+	nt.baseGraph.withoutJournal(func() {
+		for nest := nt.GetRootNest(); nest != nil; nest = nest.GetNextNest() {
+			// Explicitly ingnore error that may be returned by the below call
+			// (since no error is expected)
+			nest.RemoveStrAttr(attr)
+		}
+	})
 
 	// Finally, deallocate the attribute (remove it from the attribute allocation map)
 	nt.nestStrAttrAllocMap[attr_num] = false
+	nt.dropNestAttrIndex(attr_num)
+	nt.baseGraph.journalForgetStrAttr(ATTR_ELEM_NEST, attr_num)
 	*attr = nest_str_attr_invalid
 
 	return nil
@@ -521,3 +697,29 @@ func (nt *NestTree) GetBaseGraph() *Graph {
 func (nt *NestTree) GetRootNest() *Nest {
 	return nt.rootNest
 }
+
+// Get nest by its ID. Returns "nil" if there is no such nest (or it was deleted)
+func (nt *NestTree) NestByID(id int) *Nest {
+	if id < 0 || id >= len(nt.nestsByID) {
+		return nil
+	}
+
+	return nt.nestsByID[id]
+}
+
+// Get number of live nests (including the root nest)
+func (nt *NestTree) NestCount() int {
+	return nt.liveNestCount
+}
+
+// Add a nest (newly created or re-attached to the tree) to the ID lookup table
+func (nt *NestTree) registerNest(nest *Nest) {
+	nt.nestsByID = registerByID(nt.nestsByID, nest.id, nest, "nest")
+	nt.liveNestCount++
+}
+
+// Remove a nest (detached from the tree) from the ID lookup table
+func (nt *NestTree) unregisterNest(nest *Nest) {
+	nt.nestsByID[nest.id] = nil
+	nt.liveNestCount--
+}
@@ -0,0 +1,209 @@
+/*
+  Notification of graph mutations
+
+  Package clients that maintain data derived from a graph (indexes, views, etc.) can
+  subscribe to the graph mutations by means of "Graph.Subscribe()". An observer is
+  notified about:
+    - creation and deletion of nodes, edges and nests
+    - moves of nodes across nests
+    - changes of attribute values of the graph, nodes and nests
+
+  The notifications are delivered synchronously - right after the corresponding mutation
+  is made and before the mutating call returns. The notifications are delivered in the
+  order the mutations are made. Observers are called in the order they were subscribed
+
+  Elements are deleted from a graph only when the transaction that created them is undone
+  (see "Journal"). Undo and redo are reported as ordinary mutations: for example, undoing
+  creation of a node is reported as deletion of the node, and undoing a move of a node is
+  reported as a move in the opposite direction
+
+  NOTE: observers MUST NOT mutate the graph. They may subscribe and unsubscribe observers
+        though. An observer subscribed during a notification will receive the subsequent
+        notifications only. An observer unsubscribed during a notification will not
+        receive any further notifications (including the current one, if it was not
+        delivered to the observer yet)
+  NOTE: edges are re-attributed to nests automatically (when their adjacent nodes move).
+        This is not reported separately, since it's a consequence of a node move
+*/
+
+package graph
+
+import (
+	"errors"
+)
+
+// Kinds of graph mutation events
+const (
+	// A node was created
+	EVENT_NODE_NEW = iota
+	// A node was deleted
+	EVENT_NODE_DELETE = iota
+	// An edge was created
+	EVENT_EDGE_NEW = iota
+	// An edge was deleted
+	EVENT_EDGE_DELETE = iota
+	// A nest was created
+	EVENT_NEST_NEW = iota
+	// A nest was deleted
+	EVENT_NEST_DELETE = iota
+	// A node was moved to a different nest
+	EVENT_NODE_MOVE = iota
+	// A string attribute value was set
+	EVENT_ATTR_SET = iota
+	// A string attribute value was removed
+	EVENT_ATTR_REMOVE = iota
+)
+
+// Zero reference to an observer was provided
+var ErrNilObserver = errors.New("Zero reference to an observer")
+
+// Description of a graph mutation
+type Event struct {
+	// Kind of the mutation (one of EVENT_* constants)
+	Kind int
+	// The node that was created, deleted or moved, or whose attribute was changed. "nil"
+	// if no node is involved
+	Node *Node
+	// The edge that was created or deleted. "nil" if no edge is involved
+	Edge *Edge
+	// Depending on the kind of the event:
+	//   - the nest that was created or deleted
+	//   - the nest whose attribute was changed
+	//   - the nest to which a node was moved
+	//   - the nest in which a node or an edge was created or from which it was deleted
+	// "nil" for changes of graph and node attributes
+	Nest *Nest
+	// The nest from which a node was moved. "nil" for all other events
+	SrcNest *Nest
+	// Kind of the element whose attribute was changed (one of ATTR_ELEM_* constants).
+	// Empty for events not related to attributes
+	AttrElem string
+	// Number of the attribute whose value was changed. "-1" for events not related to
+	// attributes. Use "IsFor*StrAttr()" methods to match the event against an attribute
+	AttrNum int
+	// New value of the attribute. Empty if the value was removed
+	Val string
+	// Whether the attribute value was set before the change
+	WasSet bool
+	// Previous value of the attribute. Empty if the value was not set
+	OldVal string
+}
+
+// Check whether an event relates to a specific graph string attribute. "false" is
+// returned for zero and released attributes
+func (event *Event) IsForGraphStrAttr(attr *GraphStrAttr) bool {
+	return event.AttrElem == ATTR_ELEM_GRAPH && attr != nil && attr.isValid &&
+		attr.attrNum == event.AttrNum
+}
+
+// Check whether an event relates to a specific node string attribute. "false" is
+// returned for zero and released attributes
+func (event *Event) IsForNodeStrAttr(attr *NodeStrAttr) bool {
+	return event.AttrElem == ATTR_ELEM_NODE && attr != nil && attr.isValid &&
+		attr.attrNum == event.AttrNum && attr.graph == event.Node.graph
+}
+
+// Check whether an event relates to a specific nest string attribute. "false" is
+// returned for zero and released attributes
+func (event *Event) IsForNestStrAttr(attr *NestStrAttr) bool {
+	return event.AttrElem == ATTR_ELEM_NEST && attr != nil && attr.is_valid &&
+		attr.attr_num == event.AttrNum && attr.nestTree == event.Nest.nestTree
+}
+
+// Function that gets notified about graph mutations
+type Observer func(event Event)
+
+// Subscription of an observer to mutations of a graph
+type Subscription struct {
+	// The observed graph
+	graph *Graph
+	// The observer
+	observer Observer
+	// Whether the subscription is active
+	isActive bool
+}
+
+// Subscribe an observer to the graph mutations
+func (graph *Graph) Subscribe(observer Observer) (*Subscription, error) {
+	if observer == nil {
+		return nil, ErrNilObserver
+	}
+
+	sub := &Subscription{graph: graph, observer: observer, isActive: true}
+
+	// The list is never modified in place. So, subscribing during a notification doesn't
+	// affect the delivery of the current notification
+	observers := make([]*Subscription, 0, len(graph.observers)+1)
+	graph.observers = append(append(observers, graph.observers...), sub)
+
+	return sub, nil
+}
+
+// Unsubscribe the observer. Repeated calls have no effect
+func (sub *Subscription) Cancel() {
+	if !sub.isActive {
+		return
+	}
+
+	sub.isActive = false
+	graph := sub.graph
+	observers := make([]*Subscription, 0, len(graph.observers))
+
+	for _, s := range graph.observers {
+		if s != sub {
+			observers = append(observers, s)
+		}
+	}
+
+	graph.observers = observers
+}
+
+// Check whether the subscription is active
+func (sub *Subscription) IsActive() bool {
+	return sub.isActive
+}
+
+// Deliver an event to all observers of a graph
+func (graph *Graph) notify(event Event) {
+	for _, sub := range graph.observers {
+		// The subscription may get cancelled by one of the preceding observers
+		if sub.isActive {
+			sub.observer(event)
+		}
+	}
+}
+
+// Notify the observers about an event related to a node, an edge or a nest
+func (graph *Graph) notifyElem(kind int, node *Node, edge *Edge, nest *Nest,
+	src_nest *Nest) {
+
+	if len(graph.observers) == 0 {
+		return
+	}
+
+	graph.notify(Event{Kind: kind, Node: node, Edge: edge, Nest: nest, SrcNest: src_nest,
+		AttrNum: -1})
+}
+
+// Notify the observers about a change of an attribute value
+func (graph *Graph) notifyStrAttr(elem string, node *Node, nest *Nest, attr_num int,
+	old_val strAttrVal, new_val strAttrVal) {
+
+	if len(graph.observers) == 0 {
+		return
+	}
+
+	event := Event{Kind: EVENT_ATTR_REMOVE, Node: node, Nest: nest, AttrElem: elem,
+		AttrNum: attr_num, WasSet: old_val.isSet}
+
+	if new_val.isSet {
+		event.Kind = EVENT_ATTR_SET
+		event.Val = new_val.data
+	}
+
+	if old_val.isSet {
+		event.OldVal = old_val.data
+	}
+
+	graph.notify(event)
+}
@@ -0,0 +1,147 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+)
+
+// Subscribe an observer that records all events of a graph
+func recordEvents(t *testing.T, graph *Graph) *[]Event {
+	t.Helper()
+
+	events := &[]Event{}
+
+	if _, err := graph.Subscribe(func(event Event) {
+		*events = append(*events, event)
+	}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	return events
+}
+
+func expectEvents(t *testing.T, events *[]Event, want []Event, step string) {
+	t.Helper()
+
+	if !slices.Equal(*events, want) {
+		t.Fatalf("%s: got events\n%+v\nwant\n%+v", step, *events, want)
+	}
+
+	*events = nil
+}
+
+// Every mutation is reported by a single event describing it
+func TestObserverEvents(t *testing.T) {
+	graph := NewGraph(AttrSpec{GraphStrAttrNum: 1, NodeStrAttrNum: 1, NestStrAttrNum: 1})
+	nest_tree := graph.GetNestTree()
+	root := nest_tree.GetRootNest()
+	graph_attr, _ := graph.NewGraphStrAttr()
+	node_attr, _ := graph.NewNodeStrAttr()
+	nest_attr, _ := nest_tree.NewNestStrAttr()
+	events := recordEvents(t, graph)
+
+	nest := nest_tree.NewNest()
+	a := graph.NewNode()
+	b := graph.NewNode()
+	edge, _ := graph.NewEdge(a, b)
+	a.MoveToNest(nest)
+	expectEvents(t, events, []Event{
+		{Kind: EVENT_NEST_NEW, Nest: nest, AttrNum: -1},
+		{Kind: EVENT_NODE_NEW, Node: a, Nest: root, AttrNum: -1},
+		{Kind: EVENT_NODE_NEW, Node: b, Nest: root, AttrNum: -1},
+		{Kind: EVENT_EDGE_NEW, Edge: edge, Nest: root, AttrNum: -1},
+		{Kind: EVENT_NODE_MOVE, Node: a, Nest: nest, SrcNest: root, AttrNum: -1},
+	}, "structure")
+
+	a.SetStrAttrVal(node_attr, "x")
+	a.SetStrAttrVal(node_attr, "y")
+	a.RemoveStrAttr(node_attr)
+	graph.SetStrAttrVal(graph_attr, "g")
+	nest.SetStrAttrVal(nest_attr, "n")
+	node_num, graph_num, nest_num := node_attr.attrNum, graph_attr.attrNum,
+		nest_attr.attr_num
+
+	want := []Event{
+		{Kind: EVENT_ATTR_SET, Node: a, AttrElem: ATTR_ELEM_NODE, AttrNum: node_num,
+			Val: "x"},
+		{Kind: EVENT_ATTR_SET, Node: a, AttrElem: ATTR_ELEM_NODE, AttrNum: node_num,
+			Val: "y", WasSet: true, OldVal: "x"},
+		{Kind: EVENT_ATTR_REMOVE, Node: a, AttrElem: ATTR_ELEM_NODE, AttrNum: node_num,
+			WasSet: true, OldVal: "y"},
+		{Kind: EVENT_ATTR_SET, AttrElem: ATTR_ELEM_GRAPH, AttrNum: graph_num, Val: "g"},
+		{Kind: EVENT_ATTR_SET, Nest: nest, AttrElem: ATTR_ELEM_NEST, AttrNum: nest_num,
+			Val: "n"},
+	}
+
+	// Events are matched against the attributes they relate to
+	got := *events
+	expectEvents(t, events, want, "attributes")
+
+	if !got[0].IsForNodeStrAttr(node_attr) || got[0].IsForGraphStrAttr(graph_attr) ||
+		!got[3].IsForGraphStrAttr(graph_attr) || !got[4].IsForNestStrAttr(nest_attr) ||
+		got[4].IsForNodeStrAttr(node_attr) {
+
+		t.Fatalf("events are matched against wrong attributes")
+	}
+
+	if got[0].IsForNodeStrAttr(nil) || got[3].IsForGraphStrAttr(nil) ||
+		got[4].IsForNestStrAttr(nil) {
+
+		t.Fatalf("events are matched against zero attributes")
+	}
+}
+
+// Undo and redo are reported as the opposite and the repeated mutations
+func TestObserverUndoRedo(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	root := graph.GetNestTree().GetRootNest()
+	nest := graph.GetNestTree().NewNest()
+	journal := graph.EnableJournal()
+	events := recordEvents(t, graph)
+
+	journal.Begin()
+	node := graph.NewNode()
+	node.MoveToNest(nest)
+	journal.Commit()
+	*events = nil
+
+	if err := journal.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	expectEvents(t, events, []Event{
+		{Kind: EVENT_NODE_MOVE, Node: node, Nest: root, SrcNest: nest, AttrNum: -1},
+		{Kind: EVENT_NODE_DELETE, Node: node, Nest: root, AttrNum: -1},
+	}, "undo")
+
+	if err := journal.Redo(); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+
+	expectEvents(t, events, []Event{
+		{Kind: EVENT_NODE_NEW, Node: node, Nest: root, AttrNum: -1},
+		{Kind: EVENT_NODE_MOVE, Node: node, Nest: nest, SrcNest: root, AttrNum: -1},
+	}, "redo")
+}
+
+// An observer cancelled during a notification gets no further events
+func TestObserverCancel(t *testing.T) {
+	graph := NewGraph(AttrSpec{})
+	count := 0
+
+	var sub *Subscription
+
+	sub, _ = graph.Subscribe(func(event Event) {
+		count++
+		sub.Cancel()
+	})
+
+	events := recordEvents(t, graph)
+	graph.NewNode()
+	graph.NewNode()
+
+	if count != 1 || sub.IsActive() || len(*events) != 2 {
+		t.Fatalf("cancelled observer got %d events, the other one got %d", count,
+			len(*events))
+	}
+}
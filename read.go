@@ -0,0 +1,237 @@
+/*
+  Read graphs in various formats
+
+  Readers build a new graph from a description in one of the supported formats. The
+  structure of the graph (nodes, edges, the nest hierarchy, whether the graph is directed)
+  and labels of the graph, nodes and nests are read. Other attributes (styles, weights,
+  data of other kinds) are skipped
+
+  Labels are stored into string attributes allocated by a reader. A reader returns an emit
+  specification that maps labels to these attributes. So, the graph can be emitted in any
+  format with the same labels. The caller passes the attribute specification of the graph
+  to create: the reader extends it by the attributes it needs. So, the caller can reserve
+  attributes for its own purposes (for example, to store a layout)
+
+  Nodes are numbered in the order they appear in the input (IDs used in the input are not
+  preserved). The order of nodes inside nests and the order of sibling nests are preserved
+*/
+
+package graph
+
+import (
+	"fmt"
+	"os"
+)
+
+// Nest described by the input
+type readNest struct {
+	// Index of the parent nest. "-1" for nests belonging to the root nest
+	parent int
+	// Label of the nest
+	label    string
+	hasLabel bool
+}
+
+// Node described by the input
+type readNode struct {
+	// Index of the nest the node belongs to. "-1" for the root nest
+	nest int
+	// Label of the node
+	label    string
+	hasLabel bool
+}
+
+// Edge described by the input
+type readEdge struct {
+	// Indexes of the connected nodes
+	src int
+	dst int
+}
+
+// Description of a graph extracted from the input. Parsers fill it, then the graph is
+// built from it
+type readDoc struct {
+	directed bool
+	// Label of the graph
+	label    string
+	hasLabel bool
+	// Nests, nodes and edges in the order of appearance. Every nest follows its parent
+	nests []readNest
+	nodes []readNode
+	edges []readEdge
+}
+
+// Read the whole input file
+func readInput(format string, in_path string) ([]byte, error) {
+	data, err := os.ReadFile(in_path)
+
+	if err != nil {
+		return nil, &ReadError{Format: format, Msg: "Cannot read the input path",
+			Err: fmt.Errorf("%w: %w", ErrReadOpen, err)}
+	}
+
+	return data, nil
+}
+
+// Check whether nest "ancestor" contains nest "nest" (at any depth). Index "-1" stands
+// for the root nest
+func (doc *readDoc) isAncestorNest(ancestor int, nest int) bool {
+	for nest >= 0 {
+		nest = doc.nests[nest].parent
+
+		if nest == ancestor {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Build a graph from the description. "attr_spec" is extended by the attributes holding
+// labels
+func (doc *readDoc) build(attr_spec AttrSpec) (*Graph, *GraphEmitSpec, error) {
+	has_node_labels, has_nest_labels := false, false
+
+	for _, node := range doc.nodes {
+		has_node_labels = has_node_labels || node.hasLabel
+	}
+
+	for _, nest := range doc.nests {
+		has_nest_labels = has_nest_labels || nest.hasLabel
+	}
+
+	if doc.hasLabel {
+		attr_spec.GraphStrAttrNum++
+	}
+
+	if has_node_labels {
+		attr_spec.NodeStrAttrNum++
+	}
+
+	if has_nest_labels {
+		attr_spec.NestStrAttrNum++
+	}
+
+	graph := NewGraph(attr_spec)
+	graph.SetDirected(doc.directed)
+	graph_emit_spec := &GraphEmitSpec{}
+	var err error
+
+	if doc.hasLabel {
+		if graph_emit_spec.Graph.LabelAttr, err = graph.NewGraphStrAttr(); err != nil {
+			return nil, nil, err
+		}
+
+		err = graph.SetStrAttrVal(graph_emit_spec.Graph.LabelAttr, doc.label)
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if has_node_labels {
+		if graph_emit_spec.Node.LabelAttr, err = graph.NewNodeStrAttr(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if has_nest_labels {
+		graph_emit_spec.Nest.LabelAttr, err = graph.nestTree.NewNestStrAttr()
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Create nests. A nest becomes the first child of its parent. So, siblings are
+	// created in the reverse order to preserve the order of the input
+	children := make(map[int][]int)
+
+	for nest_idx, nest := range doc.nests {
+		children[nest.parent] = append(children[nest.parent], nest_idx)
+	}
+
+	nests := make([]*Nest, len(doc.nests))
+
+	var create_children func(parent_idx int, parent *Nest) error
+
+	create_children = func(parent_idx int, parent *Nest) error {
+		child_idxs := children[parent_idx]
+
+		for i := len(child_idxs) - 1; i >= 0; i-- {
+			nest, err := parent.NewChildNest()
+
+			if err != nil {
+				return err
+			}
+
+			nests[child_idxs[i]] = nest
+			desc := &doc.nests[child_idxs[i]]
+
+			if desc.hasLabel {
+				err := nest.SetStrAttrVal(graph_emit_spec.Nest.LabelAttr, desc.label)
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, child_idx := range child_idxs {
+			if err := create_children(child_idx, nests[child_idx]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := create_children(-1, graph.nestTree.rootNest); err != nil {
+		return nil, nil, err
+	}
+
+	// Create nodes and edges. Nodes are created in the order of the input, so that their
+	// IDs follow that order
+	nodes := make([]*Node, len(doc.nodes))
+
+	for node_idx, desc := range doc.nodes {
+		node, err := graph.NewNodeChecked()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		nodes[node_idx] = node
+
+		if desc.hasLabel {
+			err := node.SetStrAttrVal(graph_emit_spec.Node.LabelAttr, desc.label)
+
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// A node becomes the first node of the nest it's moved to. So, nodes are moved in the
+	// reverse order to preserve the order of the input. Nodes of the root nest are moved
+	// too: they were put into the root nest in the reverse order when created
+	for node_idx := len(doc.nodes) - 1; node_idx >= 0; node_idx-- {
+		nest := graph.nestTree.rootNest
+
+		if doc.nodes[node_idx].nest >= 0 {
+			nest = nests[doc.nodes[node_idx].nest]
+		}
+
+		if err := nodes[node_idx].MoveToNest(nest); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, edge := range doc.edges {
+		if _, err := graph.NewEdge(nodes[edge.src], nodes[edge.dst]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return graph, graph_emit_spec, nil
+}
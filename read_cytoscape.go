@@ -0,0 +1,293 @@
+/*
+  Read graph described in Cytoscape.js JSON format
+
+  The following documents are accepted:
+    - documents produced by "cy.json()" and by "EmitInCytoscapeFormat()":
+      {"data": {...}, "elements": {"nodes": [...], "edges": [...]}}
+    - documents whose "elements" field is a flat array of elements
+    - flat arrays of elements
+  Elements of flat arrays are told apart by their "group" field ("nodes" or "edges"). If
+  an element has no such field, it's an edge if its data has a "source" field
+
+  Compound nodes (nodes that are parents of other nodes) become nests. Nodes whose data
+  has field "nest" equal to "true" (as emitted by "EmitInCytoscapeFormat()") become nests
+  too, even if they have no children. Edges must connect non-compound nodes
+
+  Labels are read from "label" fields of element data and of the graph data. The graph is
+  directed unless the graph data has field "directed" equal to "false"
+*/
+
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Element of a Cytoscape.js document (as read)
+type cytoscapeReadElem struct {
+	Group string         `json:"group"`
+	Data  map[string]any `json:"data"`
+}
+
+// Get a data field of an element as a string. Numbers are accepted as well
+func cytoscapeDataStr(data map[string]any, field string) (string, bool) {
+	switch val := data[field].(type) {
+	case string:
+		return val, true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case nil:
+		return "", false
+	default:
+		return fmt.Sprint(val), true
+	}
+}
+
+// Decode a JSON value. Syntax errors are reported with the number of the input line
+func cytoscapeDecode(input []byte, raw []byte, val any) error {
+	err := json.Unmarshal(raw, val)
+
+	if err == nil {
+		return nil
+	}
+
+	// Offsets are relative to the decoded value. The value is a part of the input
+	offset := int64(bytes.Index(input, raw))
+	var syntax_err *json.SyntaxError
+	var type_err *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntax_err):
+		offset += syntax_err.Offset
+	case errors.As(err, &type_err):
+		offset += type_err.Offset
+	default:
+		offset = -1
+	}
+
+	line := 0
+
+	if offset >= 0 && offset <= int64(len(input)) {
+		line = bytes.Count(input[:offset], []byte("\n")) + 1
+	}
+
+	return &ReadError{Format: EMIT_FORMAT_CYTOSCAPE, Line: line, Msg: "Malformed " +
+		"document", Err: fmt.Errorf("%w: %w", ErrParse, err)}
+}
+
+// Split the input into the graph data and the elements
+func cytoscapeReadElems(input []byte) (map[string]any, []cytoscapeReadElem, error) {
+	trimmed := bytes.TrimSpace(input)
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var elems []cytoscapeReadElem
+
+		return nil, elems, cytoscapeDecode(input, trimmed, &elems)
+	}
+
+	var doc struct {
+		Data     map[string]any  `json:"data"`
+		Elements json.RawMessage `json:"elements"`
+	}
+
+	if err := cytoscapeDecode(input, trimmed, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	elements := bytes.TrimSpace(doc.Elements)
+
+	if len(elements) > 0 && elements[0] == '[' {
+		var elems []cytoscapeReadElem
+
+		return doc.Data, elems, cytoscapeDecode(input, elements, &elems)
+	}
+
+	var groups struct {
+		Nodes []cytoscapeReadElem `json:"nodes"`
+		Edges []cytoscapeReadElem `json:"edges"`
+	}
+
+	if len(elements) > 0 {
+		if err := cytoscapeDecode(input, elements, &groups); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for i := range groups.Nodes {
+		groups.Nodes[i].Group = "nodes"
+	}
+
+	for i := range groups.Edges {
+		groups.Edges[i].Group = "edges"
+	}
+
+	return doc.Data, append(groups.Nodes, groups.Edges...), nil
+}
+
+// Read a graph described in Cytoscape.js JSON format (see the description of the file for
+// details). "attr_spec" defines attributes of the created graph in addition to the
+// attributes holding labels
+//
+// Input: full path to the input file
+func ReadInCytoscapeFormat(in_path string, attr_spec AttrSpec) (graph *Graph,
+	graph_emit_spec *GraphEmitSpec, err error) {
+
+	defer recoverInternalError("ReadInCytoscapeFormat", &err)
+
+	input, err := readInput(EMIT_FORMAT_CYTOSCAPE, in_path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	graph_data, elems, err := cytoscapeReadElems(input)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc := &readDoc{directed: graph_data["directed"] != false}
+	doc.label, doc.hasLabel = cytoscapeDataStr(graph_data, "label")
+
+	// Split elements into nodes and edges
+	var nodes, edges []cytoscapeReadElem
+
+	node_by_id := make(map[string]cytoscapeReadElem)
+	is_parent := make(map[string]bool)
+
+	for _, elem := range elems {
+		_, has_source := elem.Data["source"]
+
+		if elem.Group == "edges" || (elem.Group == "" && has_source) {
+			edges = append(edges, elem)
+
+			continue
+		}
+
+		id, ok := cytoscapeDataStr(elem.Data, "id")
+
+		if !ok {
+			return nil, nil, newParseError(EMIT_FORMAT_CYTOSCAPE, 0, "A node has no ID")
+		}
+
+		if _, dup := node_by_id[id]; dup {
+			return nil, nil, newParseError(EMIT_FORMAT_CYTOSCAPE, 0, "Duplicate node "+
+				"ID \""+id+"\"")
+		}
+
+		node_by_id[id] = elem
+		nodes = append(nodes, elem)
+
+		if elem.Data["nest"] == true {
+			is_parent[id] = true
+		}
+
+		if parent, ok := cytoscapeDataStr(elem.Data, "parent"); ok {
+			is_parent[parent] = true
+		}
+	}
+
+	// Create nests. Every nest is created after its parent
+	nest_idxs := make(map[string]int)
+	in_progress := make(map[string]bool)
+
+	var add_nest func(id string) (int, error)
+
+	add_nest = func(id string) (int, error) {
+		if nest_idx, ok := nest_idxs[id]; ok {
+			return nest_idx, nil
+		}
+
+		if in_progress[id] {
+			return -1, newParseError(EMIT_FORMAT_CYTOSCAPE, 0, "Compound node \""+id+
+				"\" is its own ancestor")
+		}
+
+		elem, ok := node_by_id[id]
+
+		if !ok {
+			return -1, newParseError(EMIT_FORMAT_CYTOSCAPE, 0, "Unknown parent node \""+
+				id+"\"")
+		}
+
+		in_progress[id] = true
+		parent_idx := -1
+
+		if parent, ok := cytoscapeDataStr(elem.Data, "parent"); ok {
+			var err error
+
+			if parent_idx, err = add_nest(parent); err != nil {
+				return -1, err
+			}
+		}
+
+		nest := readNest{parent: parent_idx}
+		nest.label, nest.hasLabel = cytoscapeDataStr(elem.Data, "label")
+		nest_idxs[id] = len(doc.nests)
+		doc.nests = append(doc.nests, nest)
+
+		return nest_idxs[id], nil
+	}
+
+	for _, elem := range nodes {
+		id, _ := cytoscapeDataStr(elem.Data, "id")
+
+		if is_parent[id] {
+			if _, err := add_nest(id); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// Create regular nodes and edges
+	node_idxs := make(map[string]int)
+
+	for _, elem := range nodes {
+		id, _ := cytoscapeDataStr(elem.Data, "id")
+
+		if is_parent[id] {
+			continue
+		}
+
+		node := readNode{nest: -1}
+		node.label, node.hasLabel = cytoscapeDataStr(elem.Data, "label")
+
+		if parent, ok := cytoscapeDataStr(elem.Data, "parent"); ok {
+			if node.nest, err = add_nest(parent); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		node_idxs[id] = len(doc.nodes)
+		doc.nodes = append(doc.nodes, node)
+	}
+
+	for _, elem := range edges {
+		var ends [2]int
+
+		for i, field := range []string{"source", "target"} {
+			id, _ := cytoscapeDataStr(elem.Data, field)
+			node_idx, ok := node_idxs[id]
+
+			if is_parent[id] {
+				return nil, nil, newParseError(EMIT_FORMAT_CYTOSCAPE, 0, "Edges "+
+					"connecting compound nodes are not supported (node \""+id+"\")")
+			}
+
+			if !ok {
+				return nil, nil, newParseError(EMIT_FORMAT_CYTOSCAPE, 0, "An edge "+
+					"refers to an unknown node \""+id+"\"")
+			}
+
+			ends[i] = node_idx
+		}
+
+		doc.edges = append(doc.edges, readEdge{ends[0], ends[1]})
+	}
+
+	return doc.build(attr_spec)
+}
@@ -0,0 +1,307 @@
+/*
+  Read graph described in GraphML
+
+  Both standard GraphML (as produced by "EmitInGraphMLFormat()") and yFiles GraphML (as
+  produced by "EmitInYFilesFormat()" or by yEd) are accepted
+
+  A GraphML node becomes a nest if it contains a nested graph or if it's a yFiles group
+  node (has "yfiles.foldertype" attribute). Edges must connect regular nodes: edges
+  connecting nests are not supported. Hyperedges are not supported either. If a document
+  describes several graphs, only the first one is read
+
+  Labels are read from "<data>" elements whose keys are named "label" ("attr.name"
+  attribute of the key). If a node has no such data, the text of the first yFiles node
+  label ("<y:NodeLabel>") is used instead. The graph is directed unless its default edge
+  type is "undirected"
+*/
+
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Element of an XML document
+type gMLElem struct {
+	name     string
+	attrs    map[string]string
+	children []*gMLElem
+	text     strings.Builder
+	// Input line of the start tag
+	line int
+}
+
+// Get the first child element with a given (local) name
+func (elem *gMLElem) child(name string) *gMLElem {
+	for _, child := range elem.children {
+		if child.name == name {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// Parse an XML document into a tree of elements. Namespaces are dropped: elements and
+// attributes are identified by their local names
+func gMLParseTree(input []byte) (*gMLElem, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(input))
+	root := &gMLElem{}
+	stack := []*gMLElem{root}
+
+	for {
+		token, err := decoder.Token()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			line, _ := decoder.InputPos()
+
+			return nil, &ReadError{Format: EMIT_FORMAT_GRAPHML, Line: line,
+				Msg: "Malformed XML", Err: fmt.Errorf("%w: %w", ErrParse, err)}
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			line, _ := decoder.InputPos()
+			elem := &gMLElem{name: token.Name.Local, attrs: make(map[string]string),
+				line: line}
+
+			for _, attr := range token.Attr {
+				elem.attrs[attr.Name.Local] = attr.Value
+			}
+
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, elem)
+			stack = append(stack, elem)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(token)
+		}
+	}
+
+	if len(root.children) == 0 || root.children[0].name != "graphml" {
+		return nil, newParseError(EMIT_FORMAT_GRAPHML, 0, "The document is not a "+
+			"GraphML document")
+	}
+
+	return root.children[0], nil
+}
+
+// State of a GraphML reader
+type gMLReader struct {
+	doc *readDoc
+	// IDs of the keys holding labels of graphs and of nodes
+	graphLabelKeys map[string]bool
+	nodeLabelKeys  map[string]bool
+	// Indexes of regular nodes and of nests by their GraphML IDs
+	nodes map[string]int
+	nests map[string]int
+	// Edges described by the document. Their ends are resolved once all nodes are read
+	edges []*gMLElem
+}
+
+// Get the label held by "<data>" children of an element. "label_keys" are the keys
+// holding labels
+func gMLDataLabel(elem *gMLElem, label_keys map[string]bool) (string, bool) {
+	for _, child := range elem.children {
+		if child.name == "data" && label_keys[child.attrs["key"]] {
+			return child.text.String(), true
+		}
+	}
+
+	return "", false
+}
+
+// Get the text of the first yFiles node label found inside "<data>" children of an
+// element
+func gMLYFilesLabel(elem *gMLElem) (string, bool) {
+	var find func(elem *gMLElem) (string, bool)
+
+	find = func(elem *gMLElem) (string, bool) {
+		for _, child := range elem.children {
+			if child.name == "NodeLabel" {
+				return strings.TrimSpace(child.text.String()), true
+			}
+
+			if label, ok := find(child); ok {
+				return label, true
+			}
+		}
+
+		return "", false
+	}
+
+	for _, child := range elem.children {
+		if child.name == "data" {
+			if label, ok := find(child); ok {
+				return label, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Read the contents of a graph element. "nest" is the index of the nest represented by
+// the graph ("-1" for the root nest)
+func (reader *gMLReader) readGraph(graph *gMLElem, nest int) error {
+	for _, elem := range graph.children {
+		switch elem.name {
+		case "node":
+			id, ok := elem.attrs["id"]
+
+			if !ok {
+				return newParseError(EMIT_FORMAT_GRAPHML, elem.line, "A node has no ID")
+			}
+
+			_, is_node_dup := reader.nodes[id]
+			_, is_nest_dup := reader.nests[id]
+
+			if is_node_dup || is_nest_dup {
+				return newParseError(EMIT_FORMAT_GRAPHML, elem.line, "Duplicate node "+
+					"ID \""+id+"\"")
+			}
+
+			label, has_label := gMLDataLabel(elem, reader.nodeLabelKeys)
+
+			if !has_label {
+				label, has_label = gMLYFilesLabel(elem)
+			}
+
+			_, is_group := elem.attrs["foldertype"]
+			nested_graph := elem.child("graph")
+
+			if nested_graph == nil && !is_group {
+				reader.nodes[id] = len(reader.doc.nodes)
+				reader.doc.nodes = append(reader.doc.nodes, readNode{nest, label,
+					has_label})
+
+				continue
+			}
+
+			nest_idx := len(reader.doc.nests)
+			reader.nests[id] = nest_idx
+			reader.doc.nests = append(reader.doc.nests, readNest{nest, label, has_label})
+
+			if nested_graph != nil {
+				if err := reader.readGraph(nested_graph, nest_idx); err != nil {
+					return err
+				}
+			}
+		case "edge":
+			reader.edges = append(reader.edges, elem)
+		case "hyperedge":
+			return newParseError(EMIT_FORMAT_GRAPHML, elem.line, "Hyperedges are not "+
+				"supported")
+		}
+	}
+
+	return nil
+}
+
+// Resolve an end of an edge
+func (reader *gMLReader) resolveEdgeEnd(edge *gMLElem, attr string) (int, error) {
+	id := edge.attrs[attr]
+
+	if node_idx, ok := reader.nodes[id]; ok {
+		return node_idx, nil
+	}
+
+	if _, ok := reader.nests[id]; ok {
+		return -1, newParseError(EMIT_FORMAT_GRAPHML, edge.line, "Edges connecting "+
+			"nests are not supported (node \""+id+"\" contains a nested graph)")
+	}
+
+	return -1, newParseError(EMIT_FORMAT_GRAPHML, edge.line, "An edge refers to an "+
+		"unknown node \""+id+"\"")
+}
+
+// Read a graph described in GraphML (see the description of the file for details).
+// "attr_spec" defines attributes of the created graph in addition to the attributes
+// holding labels
+//
+// Input: full path to the input file
+func ReadInGraphMLFormat(in_path string, attr_spec AttrSpec) (graph *Graph,
+	graph_emit_spec *GraphEmitSpec, err error) {
+
+	defer recoverInternalError("ReadInGraphMLFormat", &err)
+
+	input, err := readInput(EMIT_FORMAT_GRAPHML, in_path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, err := gMLParseTree(input)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := &gMLReader{
+		doc:            &readDoc{},
+		graphLabelKeys: make(map[string]bool),
+		nodeLabelKeys:  make(map[string]bool),
+		nodes:          make(map[string]int),
+		nests:          make(map[string]int),
+	}
+
+	// Find the keys holding labels
+	for _, key := range root.children {
+		if key.name != "key" || key.attrs["attr.name"] != "label" {
+			continue
+		}
+
+		switch key.attrs["for"] {
+		case "graph":
+			reader.graphLabelKeys[key.attrs["id"]] = true
+		case "node":
+			reader.nodeLabelKeys[key.attrs["id"]] = true
+		case "all":
+			reader.graphLabelKeys[key.attrs["id"]] = true
+			reader.nodeLabelKeys[key.attrs["id"]] = true
+		}
+	}
+
+	graph_elem := root.child("graph")
+
+	if graph_elem == nil {
+		return nil, nil, newParseError(EMIT_FORMAT_GRAPHML, root.line, "The document "+
+			"describes no graph")
+	}
+
+	reader.doc.directed = graph_elem.attrs["edgedefault"] != "undirected"
+	reader.doc.label, reader.doc.hasLabel = gMLDataLabel(graph_elem,
+		reader.graphLabelKeys)
+
+	if err := reader.readGraph(graph_elem, -1); err != nil {
+		return nil, nil, err
+	}
+
+	for _, edge := range reader.edges {
+		src, err := reader.resolveEdgeEnd(edge, "source")
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dst, err := reader.resolveEdgeEnd(edge, "target")
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		reader.doc.edges = append(reader.doc.edges, readEdge{src, dst})
+	}
+
+	return reader.doc.build(attr_spec)
+}
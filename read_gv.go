@@ -0,0 +1,751 @@
+/*
+  Read graph described in Graphviz DOT language
+
+  The whole DOT grammar is accepted: "strict" graphs, node, edge and attribute
+  statements, default attributes, subgraphs (including subgraphs used as edge ends),
+  ports, quoted strings (including concatenation by "+"), HTML strings and comments.
+  Descriptions produced by "EmitInGVFormat()" are read back into the same graph
+
+  Clusters (subgraphs whose names start with "cluster") become nests. Other subgraphs only
+  group statements: their nodes belong to the enclosing cluster. A node belongs to the
+  most nested cluster it's mentioned in (if it's mentioned in unrelated clusters, the
+  first one wins). A repeated cluster name refers to the same nest
+
+  Values of "label" attributes become labels of the graph, nodes and nests. Nodes without
+  labels are labeled with their names (as Graphviz does). Escape sequences "\N" and "\G"
+  are replaced by the node name and the graph name, "\n", "\l" and "\r" are replaced by
+  line breaks, "\\" is replaced by a backslash. Other attributes are skipped. If the
+  graph is strict, repeated edges are skipped
+*/
+
+package graph
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of DOT tokens
+const (
+	GV_TOKEN_EOF = iota
+	// Identifier, numeral, quoted string or HTML string
+	GV_TOKEN_ID = iota
+	// Punctuation: "{", "}", "[", "]", ";", ",", "=", ":", "+" and edge operators
+	GV_TOKEN_PUNCT = iota
+)
+
+// DOT token
+type gvToken struct {
+	kind int
+	val  string
+	// Whether the token is a quoted string or an HTML string. Such tokens are never
+	// keywords
+	quoted bool
+	// Input line of the token
+	line int
+}
+
+// Split DOT input into tokens
+func gvTokenize(input string) ([]gvToken, error) {
+	var tokens []gvToken
+
+	line := 1
+	line_start := true
+	pos := 0
+
+	for pos < len(input) {
+		ch := input[pos]
+
+		switch {
+		case ch == '\n':
+			line++
+			line_start = true
+			pos++
+
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\f' || ch == '\v':
+			pos++
+
+			continue
+		case ch == '#' && line_start:
+			// Lines starting with "#" are treated as output of the C preprocessor
+			for pos < len(input) && input[pos] != '\n' {
+				pos++
+			}
+
+			continue
+		case strings.HasPrefix(input[pos:], "//"):
+			for pos < len(input) && input[pos] != '\n' {
+				pos++
+			}
+
+			continue
+		case strings.HasPrefix(input[pos:], "/*"):
+			end := strings.Index(input[pos+2:], "*/")
+
+			if end < 0 {
+				return nil, newParseError(EMIT_FORMAT_GV, line, "Unterminated comment")
+			}
+
+			line += strings.Count(input[pos:pos+2+end], "\n")
+			pos += end + 4
+
+			continue
+		}
+
+		line_start = false
+		token := gvToken{kind: GV_TOKEN_ID, line: line}
+
+		switch {
+		case ch == '"':
+			// Quoted string. Escape sequence "\"" stands for a quote. Escape sequence "\\"
+			// is kept as is (it's expanded along with the other escape sequences of
+			// labels), but its second backslash doesn't escape the following character. A
+			// backslash followed by a line break continues the string on the next line
+			var val strings.Builder
+
+			pos++
+
+			for ; pos < len(input) && input[pos] != '"'; pos++ {
+				switch {
+				case strings.HasPrefix(input[pos:], "\\\""):
+					val.WriteByte('"')
+					pos++
+				case strings.HasPrefix(input[pos:], "\\\\"):
+					val.WriteString("\\\\")
+					pos++
+				case strings.HasPrefix(input[pos:], "\\\r\n"):
+					line++
+					pos += 2
+				case strings.HasPrefix(input[pos:], "\\\n"):
+					line++
+					pos++
+				default:
+					if input[pos] == '\n' {
+						line++
+					}
+
+					val.WriteByte(input[pos])
+				}
+			}
+
+			if pos == len(input) {
+				return nil, newParseError(EMIT_FORMAT_GV, token.line, "Unterminated "+
+					"quoted string")
+			}
+
+			pos++
+			token.val = val.String()
+			token.quoted = true
+		case ch == '<':
+			// HTML string. Angle brackets inside it must be balanced
+			depth := 0
+			start := pos
+
+			for ; pos < len(input); pos++ {
+				if input[pos] == '<' {
+					depth++
+				} else if input[pos] == '>' {
+					depth--
+				} else if input[pos] == '\n' {
+					line++
+				}
+
+				if depth == 0 {
+					break
+				}
+			}
+
+			if pos == len(input) {
+				return nil, newParseError(EMIT_FORMAT_GV, token.line, "Unterminated "+
+					"HTML string")
+			}
+
+			pos++
+			token.val = input[start+1 : pos-1]
+			token.quoted = true
+		case strings.HasPrefix(input[pos:], "->") || strings.HasPrefix(input[pos:], "--"):
+			token.kind = GV_TOKEN_PUNCT
+			token.val = input[pos : pos+2]
+			pos += 2
+		case strings.ContainsRune("{}[];,=:+", rune(ch)):
+			token.kind = GV_TOKEN_PUNCT
+			token.val = input[pos : pos+1]
+			pos++
+		case ch == '-' || ch == '.' || (ch >= '0' && ch <= '9'):
+			// Numeral: [-]?(.[0-9]+ | [0-9]+(.[0-9]*)?)
+			start := pos
+
+			if ch == '-' {
+				pos++
+			}
+
+			digits, has_point := 0, false
+
+			for ; pos < len(input); pos++ {
+				if input[pos] >= '0' && input[pos] <= '9' {
+					digits++
+				} else if input[pos] == '.' && !has_point {
+					has_point = true
+				} else {
+					break
+				}
+			}
+
+			if digits == 0 {
+				return nil, newParseError(EMIT_FORMAT_GV, line, "Malformed numeral \""+
+					input[start:pos]+"\"")
+			}
+
+			token.val = input[start:pos]
+		case ch == '_' || ch >= utf8.RuneSelf || (ch|0x20 >= 'a' && ch|0x20 <= 'z'):
+			// Identifier: letters, digits, underscores and any non-ASCII characters not
+			// starting with a digit
+			start := pos
+
+			for ; pos < len(input); pos++ {
+				ch := input[pos]
+
+				if ch != '_' && ch < utf8.RuneSelf && !(ch >= '0' && ch <= '9') &&
+					!(ch|0x20 >= 'a' && ch|0x20 <= 'z') {
+
+					break
+				}
+			}
+
+			token.val = input[start:pos]
+		default:
+			return nil, newParseError(EMIT_FORMAT_GV, line, "Unexpected character \""+
+				string(ch)+"\"")
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return append(tokens, gvToken{kind: GV_TOKEN_EOF, line: line}), nil
+}
+
+// Scope of DOT statements: the whole graph or a subgraph
+type gvScope struct {
+	// Index of the innermost cluster containing the statements. "-1" for the root nest
+	nest int
+	// Whether the statements belong to the graph itself (not to a subgraph)
+	isGraph bool
+	// Whether the statements belong to a cluster
+	isCluster bool
+	// Default node label (set by "node [label=...]" statements)
+	nodeLabel    string
+	hasNodeLabel bool
+}
+
+// State of a DOT parser
+type gvParser struct {
+	tokens []gvToken
+	pos    int
+	doc    *readDoc
+	strict bool
+	// Name of the graph
+	name string
+	// Indexes of nodes and clusters by their names
+	nodes    map[string]int
+	clusters map[string]int
+	// Edges already added (used for strict graphs)
+	edgeSet map[readEdge]bool
+}
+
+// Get the current token
+func (parser *gvParser) peek() gvToken {
+	return parser.tokens[parser.pos]
+}
+
+// Get the current token and advance to the next one
+func (parser *gvParser) next() gvToken {
+	token := parser.tokens[parser.pos]
+
+	if token.kind != GV_TOKEN_EOF {
+		parser.pos++
+	}
+
+	return token
+}
+
+// Check whether the current token is a given punctuation
+func (parser *gvParser) isPunct(val string) bool {
+	token := parser.peek()
+
+	return token.kind == GV_TOKEN_PUNCT && token.val == val
+}
+
+// Check whether the current token is a given keyword
+func (parser *gvParser) isKeyword(keyword string) bool {
+	token := parser.peek()
+
+	return token.kind == GV_TOKEN_ID && !token.quoted && strings.EqualFold(token.val,
+		keyword)
+}
+
+// Consume a given punctuation
+func (parser *gvParser) expectPunct(val string) error {
+	if !parser.isPunct(val) {
+		return parser.unexpected("\"" + val + "\"")
+	}
+
+	parser.next()
+
+	return nil
+}
+
+// Create an error reporting that the current token is not the expected one
+func (parser *gvParser) unexpected(expected string) error {
+	token := parser.peek()
+	found := "\"" + token.val + "\""
+
+	if token.kind == GV_TOKEN_EOF {
+		found = "the end of the input"
+	}
+
+	return newParseError(EMIT_FORMAT_GV, token.line, "Expected "+expected+", found "+
+		found)
+}
+
+// Parse an ID. Quoted strings joined by "+" are concatenated
+func (parser *gvParser) parseID() (string, error) {
+	if parser.peek().kind != GV_TOKEN_ID {
+		return "", parser.unexpected("an ID")
+	}
+
+	token := parser.next()
+	id := token.val
+
+	for token.quoted && parser.isPunct("+") {
+		parser.next()
+
+		if token = parser.next(); token.kind != GV_TOKEN_ID || !token.quoted {
+			return "", newParseError(EMIT_FORMAT_GV, token.line, "Only quoted strings "+
+				"can be concatenated")
+		}
+
+		id += token.val
+	}
+
+	return id, nil
+}
+
+// Parse attribute lists ("[a=b, c=d][e=f]"). Returns the attributes by names
+func (parser *gvParser) parseAttrLists() (map[string]string, error) {
+	attrs := make(map[string]string)
+
+	for parser.isPunct("[") {
+		parser.next()
+
+		for !parser.isPunct("]") {
+			name, err := parser.parseID()
+
+			if err != nil {
+				return nil, err
+			}
+
+			val := "true"
+
+			if parser.isPunct("=") {
+				parser.next()
+
+				if val, err = parser.parseID(); err != nil {
+					return nil, err
+				}
+			}
+
+			attrs[name] = val
+
+			if parser.isPunct(",") || parser.isPunct(";") {
+				parser.next()
+			}
+		}
+
+		parser.next()
+	}
+
+	return attrs, nil
+}
+
+// Expand escape sequences of a label. "name" is the name of the labeled node (if any)
+func (parser *gvParser) expandLabel(label string, name string) string {
+	var expanded strings.Builder
+
+	for i := 0; i < len(label); i++ {
+		if label[i] != '\\' || i+1 == len(label) {
+			expanded.WriteByte(label[i])
+
+			continue
+		}
+
+		i++
+
+		switch label[i] {
+		case 'N':
+			expanded.WriteString(name)
+		case 'G':
+			expanded.WriteString(parser.name)
+		case 'n', 'l', 'r':
+			expanded.WriteByte('\n')
+		case '\\':
+			expanded.WriteByte('\\')
+		default:
+			expanded.WriteByte('\\')
+			expanded.WriteByte(label[i])
+		}
+	}
+
+	return expanded.String()
+}
+
+// Set the label of the graph or of the cluster a scope belongs to
+func (parser *gvParser) setScopeLabel(scope *gvScope, label string) {
+	if scope.isCluster {
+		nest := &parser.doc.nests[scope.nest]
+		nest.label, nest.hasLabel = parser.expandLabel(label, ""), true
+	} else if scope.isGraph {
+		parser.doc.label, parser.doc.hasLabel = parser.expandLabel(label, ""), true
+	}
+}
+
+// Register a mention of a node inside a scope. Returns the node index
+func (parser *gvParser) mentionNode(name string, scope *gvScope) int {
+	node_idx, ok := parser.nodes[name]
+
+	if !ok {
+		node := readNode{nest: scope.nest, label: parser.expandLabel("\\N", name),
+			hasLabel: true}
+
+		if scope.hasNodeLabel {
+			node.label = parser.expandLabel(scope.nodeLabel, name)
+		}
+
+		node_idx = len(parser.doc.nodes)
+		parser.nodes[name] = node_idx
+		parser.doc.nodes = append(parser.doc.nodes, node)
+
+		return node_idx
+	}
+
+	// A node mentioned in a cluster nested into the node's current cluster is moved there
+	node := &parser.doc.nodes[node_idx]
+
+	if parser.doc.isAncestorNest(node.nest, scope.nest) {
+		node.nest = scope.nest
+	}
+
+	return node_idx
+}
+
+// Parse a node ID ("name" or "name:port" or "name:port:compass") and register a mention
+// of the node
+func (parser *gvParser) parseNodeID(scope *gvScope) (string, int, error) {
+	name, err := parser.parseID()
+
+	if err != nil {
+		return "", -1, err
+	}
+
+	for i := 0; i < 2 && parser.isPunct(":"); i++ {
+		parser.next()
+
+		if _, err := parser.parseID(); err != nil {
+			return "", -1, err
+		}
+	}
+
+	return name, parser.mentionNode(name, scope), nil
+}
+
+// Parse a subgraph. Returns the indexes of all nodes mentioned inside it
+func (parser *gvParser) parseSubgraph(scope *gvScope) ([]int, error) {
+	sub_scope := *scope
+	sub_scope.isGraph = false
+	sub_scope.isCluster = false
+
+	if parser.isKeyword("subgraph") {
+		parser.next()
+
+		if parser.peek().kind == GV_TOKEN_ID {
+			name, err := parser.parseID()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if strings.HasPrefix(name, "cluster") {
+				nest_idx, ok := parser.clusters[name]
+
+				if !ok {
+					nest_idx = len(parser.doc.nests)
+					parser.clusters[name] = nest_idx
+					parser.doc.nests = append(parser.doc.nests, readNest{parent: scope.nest})
+				}
+
+				sub_scope.nest = nest_idx
+				sub_scope.isCluster = true
+			}
+		}
+	}
+
+	if err := parser.expectPunct("{"); err != nil {
+		return nil, err
+	}
+
+	return parser.parseStmtList(&sub_scope)
+}
+
+// Parse an edge end: either a node ID or a subgraph. Returns indexes of the nodes
+func (parser *gvParser) parseEdgeEnd(scope *gvScope) ([]int, error) {
+	if parser.isKeyword("subgraph") || parser.isPunct("{") {
+		return parser.parseSubgraph(scope)
+	}
+
+	_, node_idx, err := parser.parseNodeID(scope)
+
+	return []int{node_idx}, err
+}
+
+// Parse the rest of an edge statement (starting from the first edge operator). "ends"
+// are the nodes of the first edge end
+func (parser *gvParser) parseEdgeStmt(ends []int, scope *gvScope) ([]int, error) {
+	mentioned := append([]int{}, ends...)
+	edge_op := "--"
+
+	if parser.doc.directed {
+		edge_op = "->"
+	}
+
+	for parser.isPunct("->") || parser.isPunct("--") {
+		if token := parser.next(); token.val != edge_op {
+			return nil, newParseError(EMIT_FORMAT_GV, token.line, "Edge operator \""+
+				token.val+"\" doesn't match the graph kind")
+		}
+
+		dst_ends, err := parser.parseEdgeEnd(scope)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, src := range ends {
+			for _, dst := range dst_ends {
+				parser.addEdge(src, dst)
+			}
+		}
+
+		mentioned = append(mentioned, dst_ends...)
+		ends = dst_ends
+	}
+
+	// Edge attributes are skipped
+	_, err := parser.parseAttrLists()
+
+	return mentioned, err
+}
+
+// Add an edge (repeated edges of strict graphs are skipped)
+func (parser *gvParser) addEdge(src int, dst int) {
+	edge := readEdge{src, dst}
+
+	if parser.strict {
+		if !parser.doc.directed && src > dst {
+			edge = readEdge{dst, src}
+		}
+
+		if parser.edgeSet[edge] {
+			return
+		}
+
+		parser.edgeSet[edge] = true
+	}
+
+	parser.doc.edges = append(parser.doc.edges, readEdge{src, dst})
+}
+
+// Parse statements up to the closing brace (which is consumed). Returns indexes of all
+// nodes mentioned by the statements
+func (parser *gvParser) parseStmtList(scope *gvScope) ([]int, error) {
+	var mentioned []int
+
+	for !parser.isPunct("}") {
+		token := parser.peek()
+
+		switch {
+		case token.kind == GV_TOKEN_EOF:
+			return nil, parser.unexpected("\"}\"")
+		case parser.isPunct(";"):
+			parser.next()
+		case parser.isKeyword("graph") || parser.isKeyword("node") ||
+			parser.isKeyword("edge"):
+
+			// Attribute statement
+			parser.next()
+			attrs, err := parser.parseAttrLists()
+
+			if err != nil {
+				return nil, err
+			}
+
+			label, has_label := attrs["label"]
+
+			switch {
+			case !has_label:
+			case strings.EqualFold(token.val, "graph"):
+				parser.setScopeLabel(scope, label)
+			case strings.EqualFold(token.val, "node"):
+				scope.nodeLabel, scope.hasNodeLabel = label, true
+			}
+		case parser.isKeyword("subgraph") || parser.isPunct("{"):
+			nodes, err := parser.parseSubgraph(scope)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if nodes, err = parser.parseEdgeStmt(nodes, scope); err != nil {
+				return nil, err
+			}
+
+			mentioned = append(mentioned, nodes...)
+		case token.kind == GV_TOKEN_ID && parser.tokens[parser.pos+1].val == "=" &&
+			parser.tokens[parser.pos+1].kind == GV_TOKEN_PUNCT:
+
+			// Graph attribute assignment ("name=value")
+			name, err := parser.parseID()
+
+			if err != nil {
+				return nil, err
+			}
+
+			parser.next()
+			val, err := parser.parseID()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if name == "label" {
+				parser.setScopeLabel(scope, val)
+			}
+		case token.kind == GV_TOKEN_ID:
+			name, node_idx, err := parser.parseNodeID(scope)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if parser.isPunct("->") || parser.isPunct("--") {
+				nodes, err := parser.parseEdgeStmt([]int{node_idx}, scope)
+
+				if err != nil {
+					return nil, err
+				}
+
+				mentioned = append(mentioned, nodes...)
+
+				break
+			}
+
+			attrs, err := parser.parseAttrLists()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if label, ok := attrs["label"]; ok {
+				node := &parser.doc.nodes[node_idx]
+				node.label = parser.expandLabel(label, name)
+			}
+
+			mentioned = append(mentioned, node_idx)
+		default:
+			return nil, parser.unexpected("a statement")
+		}
+	}
+
+	parser.next()
+
+	return mentioned, nil
+}
+
+// Parse the whole DOT description
+func (parser *gvParser) parseGraph() error {
+	if parser.isKeyword("strict") {
+		parser.next()
+		parser.strict = true
+	}
+
+	switch {
+	case parser.isKeyword("digraph"):
+		parser.doc.directed = true
+	case parser.isKeyword("graph"):
+		parser.doc.directed = false
+	default:
+		return parser.unexpected("\"graph\" or \"digraph\"")
+	}
+
+	parser.next()
+
+	if parser.peek().kind == GV_TOKEN_ID {
+		name, err := parser.parseID()
+
+		if err != nil {
+			return err
+		}
+
+		parser.name = name
+	}
+
+	if err := parser.expectPunct("{"); err != nil {
+		return err
+	}
+
+	if _, err := parser.parseStmtList(&gvScope{nest: -1, isGraph: true}); err != nil {
+		return err
+	}
+
+	if parser.peek().kind != GV_TOKEN_EOF {
+		return parser.unexpected("the end of the input")
+	}
+
+	return nil
+}
+
+// Read a graph described in Graphviz DOT language (see the description of the file for
+// details). "attr_spec" defines attributes of the created graph in addition to the
+// attributes holding labels
+//
+// Input: full path to the input file
+func ReadInGVFormat(in_path string, attr_spec AttrSpec) (graph *Graph,
+	graph_emit_spec *GraphEmitSpec, err error) {
+
+	defer recoverInternalError("ReadInGVFormat", &err)
+
+	input, err := readInput(EMIT_FORMAT_GV, in_path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := gvTokenize(string(input))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parser := &gvParser{
+		tokens:   tokens,
+		doc:      &readDoc{},
+		nodes:    make(map[string]int),
+		clusters: make(map[string]int),
+		edgeSet:  make(map[readEdge]bool),
+	}
+
+	if err := parser.parseGraph(); err != nil {
+		return nil, nil, err
+	}
+
+	return parser.doc.build(attr_spec)
+}
@@ -0,0 +1,188 @@
+package graph
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Build a graph whose labels contain characters that must be escaped in every format
+func newReadTestGraph(t *testing.T) (*Graph, *GraphEmitSpec) {
+	t.Helper()
+
+	graph := NewGraph(AttrSpec{GraphStrAttrNum: 1, NodeStrAttrNum: 1, NestStrAttrNum: 1})
+	spec := &GraphEmitSpec{}
+
+	var err error
+
+	if spec.Graph.LabelAttr, err = graph.NewGraphStrAttr(); err != nil {
+		t.Fatalf("NewGraphStrAttr: %v", err)
+	}
+
+	if spec.Node.LabelAttr, err = graph.NewNodeStrAttr(); err != nil {
+		t.Fatalf("NewNodeStrAttr: %v", err)
+	}
+
+	if spec.Nest.LabelAttr, err = graph.GetNestTree().NewNestStrAttr(); err != nil {
+		t.Fatalf("NewNestStrAttr: %v", err)
+	}
+
+	outer := graph.GetNestTree().NewNest()
+	inner, err := outer.NewChildNest()
+
+	if err != nil {
+		t.Fatalf("NewChildNest: %v", err)
+	}
+
+	// An empty nest must survive a round trip too
+	empty, err := outer.NewChildNest()
+
+	if err != nil {
+		t.Fatalf("NewChildNest: %v", err)
+	}
+
+	empty.SetStrAttrVal(spec.Nest.LabelAttr, "empty")
+	graph.SetStrAttrVal(spec.Graph.LabelAttr, `graph "g" \N \`)
+	outer.SetStrAttrVal(spec.Nest.LabelAttr, `outer <&> "o"`)
+	inner.SetStrAttrVal(spec.Nest.LabelAttr, `inner \ 'i' \"`)
+
+	var nodes []*Node
+
+	for i, nest := range []*Nest{outer, inner, inner, nil, nil} {
+		node := graph.NewNode()
+
+		if nest != nil {
+			node.MoveToNest(nest)
+		}
+
+		label := []string{`a"b`, `c\d`, `e<f>&g`, "plain", `\"x\`}[i]
+
+		if err := node.SetStrAttrVal(spec.Node.LabelAttr, label); err != nil {
+			t.Fatalf("SetStrAttrVal: %v", err)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	for _, ends := range [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 0}, {4, 3}} {
+		if _, err := graph.NewEdge(nodes[ends[0]], nodes[ends[1]]); err != nil {
+			t.Fatalf("NewEdge: %v", err)
+		}
+	}
+
+	return graph, spec
+}
+
+// Describe a graph by its labels: the graph label (if requested), the label paths of the
+// nests, the nest label path of every node and the labels of the ends of every edge. The
+// description doesn't depend on element IDs, so it can be compared across an emit/read
+// round trip. Nests and nodes are listed in the order of the graph (the pre-order of the
+// nest tree and the order of nodes inside each nest). Edges are sorted: readers don't
+// preserve the order of adjacency lists
+func readTestDescribe(t *testing.T, graph *Graph, spec *GraphEmitSpec,
+	with_graph_label bool) []string {
+
+	t.Helper()
+
+	var desc, edges []string
+
+	if with_graph_label {
+		label, err := graph.GetStrAttrVal(spec.Graph.LabelAttr)
+
+		if err != nil {
+			t.Fatalf("Graph.GetStrAttrVal: %v", err)
+		}
+
+		desc = append(desc, "graph: "+label)
+	}
+
+	node_label := func(node *Node) string {
+		label, err := node.GetStrAttrVal(spec.Node.LabelAttr)
+
+		if err != nil {
+			t.Fatalf("Node.GetStrAttrVal: %v", err)
+		}
+
+		return label
+	}
+
+	nest_path := func(nest *Nest) string {
+		var path []string
+
+		for ; nest.GetParentNest() != nil; nest = nest.GetParentNest() {
+			label, err := nest.GetStrAttrVal(spec.Nest.LabelAttr)
+
+			if err != nil {
+				t.Fatalf("Nest.GetStrAttrVal: %v", err)
+			}
+
+			path = append([]string{label}, path...)
+		}
+
+		return "/" + strings.Join(path, "/")
+	}
+
+	for nest := range graph.GetNestTree().GetRootNest().Descendants() {
+		desc = append(desc, "nest: "+nest_path(nest))
+	}
+
+	for node := graph.GetFirstNode(); node != nil; node = node.GetNextNode() {
+		desc = append(desc, "node: "+nest_path(node.GetNest())+"/"+node_label(node))
+
+		edge := node.GetFirstOutcomingEdge()
+
+		for ; edge != nil; edge = edge.GetNextOutcomingEdge() {
+			edges = append(edges, "edge: "+node_label(node)+" -> "+
+				node_label(edge.GetDstNode()))
+		}
+	}
+
+	slices.Sort(edges)
+
+	return append(desc, edges...)
+}
+
+// Graphs emitted by the package are read back with the same structure and labels
+func TestReadRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		emit func(*Graph, *GraphEmitSpec, string) error
+		read func(string, AttrSpec) (*Graph, *GraphEmitSpec, error)
+		// The yFiles emitter doesn't emit the graph label
+		hasGraphLabel bool
+	}{
+		{"gv", EmitInGVFormat, ReadInGVFormat, true},
+		{"graphml", EmitInGraphMLFormat, ReadInGraphMLFormat, true},
+		{"yfiles", EmitInYFilesFormat, ReadInGraphMLFormat, false},
+		{"cytoscape", EmitInCytoscapeFormat, ReadInCytoscapeFormat, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph, spec := newReadTestGraph(t)
+			path := filepath.Join(t.TempDir(), "graph")
+
+			if err := c.emit(graph, spec, path); err != nil {
+				t.Fatalf("emit: %v", err)
+			}
+
+			read_graph, read_spec, err := c.read(path, AttrSpec{})
+
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+
+			want := readTestDescribe(t, graph, spec, c.hasGraphLabel)
+			got := readTestDescribe(t, read_graph, read_spec, c.hasGraphLabel)
+
+			if !slices.Equal(got, want) {
+				t.Errorf("round trip changed the graph:\ngot  %q\nwant %q", got, want)
+			}
+
+			if violations := read_graph.Validate(); len(violations) != 0 {
+				t.Errorf("read graph is inconsistent: %v", violations)
+			}
+		})
+	}
+}
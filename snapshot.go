@@ -0,0 +1,786 @@
+/*
+  Immutable snapshots of a graph
+
+  "Graph.Freeze()" produces a compact read-only copy of a graph. The copy is array-based:
+  nests are stored in the pre-order of the nest tree, nodes and edges are grouped by the
+  nests they belong to, and the adjacency lists are stored in CSR form (compressed sparse
+  rows: the incoming and outcoming edges of all nodes are packed into two arrays, and each
+  node keeps a range of indices into those arrays)
+
+  A snapshot never changes after it's created. So, any number of goroutines can traverse
+  and emit the same snapshot simultaneously without any synchronization, while the source
+  graph keeps evolving
+
+  Snapshot handles ("*SnapNode", "*SnapEdge", "*SnapNest") expose the same navigation
+  methods as "*Node", "*Edge" and "*Nest". Navigation methods return "nil" at the end of
+  a list, exactly like their counterparts do
+
+  NOTE: attribute values are copied when a snapshot is created. Attribute handles of the
+        source graph can be used to read the values from the snapshot. An attribute that
+        was released after the snapshot had been created cannot be used with the snapshot
+*/
+
+package graph
+
+// Node of a graph snapshot
+type snapNodeData struct {
+	// Node ID (the same as in the source graph)
+	id int
+	// Index of the nest to which the node belongs
+	nest int
+	// Range of the node's outcoming edges in the "outEdges" array
+	outStart int
+	outEnd   int
+	// Range of the node's incoming edges in the "inEdges" array
+	inStart int
+	inEnd   int
+}
+
+// Edge of a graph snapshot
+type snapEdgeData struct {
+	// Edge ID (the same as in the source graph)
+	id int
+	// Indices of the source and destination nodes
+	src int
+	dst int
+	// Index of the nest to which the edge belongs
+	nest int
+	// Position of the edge in the "outEdges" and "inEdges" arrays
+	outPos int
+	inPos  int
+}
+
+// Nest of a graph snapshot
+type snapNestData struct {
+	// Nest ID (the same as in the source graph)
+	id int
+	// Level of the nest relative to the root of the tree
+	level int
+	// Index of the parent nest. "-1" for the root nest
+	parent int
+	// Range of the nest's children in the "nestChildren" array
+	childStart int
+	childEnd   int
+	// Position of the nest in the "nestChildren" array. "-1" for the root nest
+	siblingPos int
+	// Range of the nest's nodes in the array of snapshot nodes
+	nodeStart int
+	nodeEnd   int
+	// Range of the nest's edges in the array of snapshot edges
+	edgeStart int
+	edgeEnd   int
+}
+
+// Immutable snapshot of a graph
+type Snapshot struct {
+	// Graph from which the snapshot was created. It's used only to check that attribute
+	// handles provided by the snapshot clients belong to the right graph. The graph data
+	// is never accessed through this reference
+	graph *Graph
+	// Specification of the graph's attributes at the moment the snapshot was created
+	attrSpec AttrSpec
+	// Whether the graph was directed at the moment the snapshot was created
+	directed bool
+	// Node, edge and nest data
+	nodes []snapNodeData
+	edges []snapEdgeData
+	nests []snapNestData
+	// Handles given out to the snapshot clients. Handles are preallocated, so that the
+	// navigation methods don't allocate memory
+	nodeHandles []SnapNode
+	edgeHandles []SnapEdge
+	nestHandles []SnapNest
+	// Packed adjacency lists (indices of edges)
+	outEdges []int
+	inEdges  []int
+	// Packed lists of child nests (indices of nests)
+	nestChildren []int
+	// Attribute values. Node and nest values are packed: the values of the i-th element
+	// start at index i * <number of attributes of the element kind>
+	graphStrAttrs []strAttrVal
+	nodeStrAttrs  []strAttrVal
+	nestStrAttrs  []strAttrVal
+}
+
+// Handle of a snapshot node
+type SnapNode struct {
+	snap *Snapshot
+	idx  int
+}
+
+// Handle of a snapshot edge
+type SnapEdge struct {
+	snap *Snapshot
+	idx  int
+}
+
+// Handle of a snapshot nest
+type SnapNest struct {
+	snap *Snapshot
+	idx  int
+}
+
+// Create an immutable snapshot of a graph
+func (graph *Graph) Freeze() (snap *Snapshot, err error) {
+	defer recoverInternalError("Freeze", &err)
+
+	if graph == nil {
+		return nil, &ElemError{"Freeze", -1, -1, "Zero reference to the graph",
+			ErrNilGraph}
+	}
+
+	if graph.nestTree == nil || graph.nestTree.rootNest == nil {
+		return nil, &ElemError{"Freeze", -1, -1, "Graph without a nest tree or the " +
+			"nest tree without the root nest", ErrNoNestTree}
+	}
+
+	spec := graph.attrSpec
+	snap = &Snapshot{
+		graph:         graph,
+		attrSpec:      spec,
+		directed:      graph.IsDirected(),
+		graphStrAttrs: append([]strAttrVal(nil), graph.strAttrs...),
+	}
+
+	nest_idx := make(map[*Nest]int)
+	node_idx := make(map[*Node]int)
+	edge_idx := make(map[*Edge]int)
+
+	// Nests are stored in the pre-order of the nest tree. Nodes and edges are grouped by
+	// nests in the same order
+	root := graph.nestTree.rootNest
+
+	for nest := root; nest != nil; nest = nest.GetNextNest() {
+		parent := -1
+
+		if nest.parentNest != nil {
+			parent = nest_idx[nest.parentNest]
+		}
+
+		nest_data := snapNestData{
+			id:         nest.id,
+			level:      nest.level,
+			parent:     parent,
+			siblingPos: -1,
+			nodeStart:  len(snap.nodes),
+			edgeStart:  len(snap.edges),
+		}
+
+		for node := nest.firstNode; node != nil; node = node.nextNodeInNest {
+			node_idx[node] = len(snap.nodes)
+			snap.nodes = append(snap.nodes, snapNodeData{id: node.id,
+				nest: len(snap.nests)})
+			snap.nodeStrAttrs = append(snap.nodeStrAttrs, node.strAttrs...)
+		}
+
+		for edge := nest.firstEdge; edge != nil; edge = edge.nextEdgeInNest {
+			edge_idx[edge] = len(snap.edges)
+			snap.edges = append(snap.edges, snapEdgeData{id: edge.id,
+				nest: len(snap.nests)})
+		}
+
+		nest_data.nodeEnd = len(snap.nodes)
+		nest_data.edgeEnd = len(snap.edges)
+		nest_idx[nest] = len(snap.nests)
+		snap.nests = append(snap.nests, nest_data)
+		snap.nestStrAttrs = append(snap.nestStrAttrs, nest.strAttrs...)
+	}
+
+	// Pack lists of child nests
+	for i := range snap.nests {
+		snap.nests[i].childStart = len(snap.nestChildren)
+
+		for j := i + 1; j < len(snap.nests); j++ {
+			// Descendants of a nest immediately follow it in the pre-order
+			if snap.nests[j].level <= snap.nests[i].level {
+				break
+			}
+
+			if snap.nests[j].parent == i {
+				snap.nests[j].siblingPos = len(snap.nestChildren)
+				snap.nestChildren = append(snap.nestChildren, j)
+			}
+		}
+
+		snap.nests[i].childEnd = len(snap.nestChildren)
+	}
+
+	// Pack adjacency lists. The order of edges in the source lists is preserved
+	for nest := root; nest != nil; nest = nest.GetNextNest() {
+		for node := nest.firstNode; node != nil; node = node.nextNodeInNest {
+			node_data := &snap.nodes[node_idx[node]]
+			node_data.outStart = len(snap.outEdges)
+
+			edge := node.firstOutcomingEdge
+
+			for ; edge != nil; edge = edge.nextOutcomingEdge {
+				e := edge_idx[edge]
+				snap.edges[e].src = node_idx[edge.srcNode]
+				snap.edges[e].dst = node_idx[edge.dstNode]
+				snap.edges[e].outPos = len(snap.outEdges)
+				snap.outEdges = append(snap.outEdges, e)
+			}
+
+			node_data.outEnd = len(snap.outEdges)
+			node_data.inStart = len(snap.inEdges)
+
+			for edge = node.firstIncomingEdge; edge != nil; edge = edge.nextIncomingEdge {
+				e := edge_idx[edge]
+				snap.edges[e].inPos = len(snap.inEdges)
+				snap.inEdges = append(snap.inEdges, e)
+			}
+
+			node_data.inEnd = len(snap.inEdges)
+		}
+	}
+
+	// Create handles
+	snap.nodeHandles = make([]SnapNode, len(snap.nodes))
+	snap.edgeHandles = make([]SnapEdge, len(snap.edges))
+	snap.nestHandles = make([]SnapNest, len(snap.nests))
+
+	for i := range snap.nodeHandles {
+		snap.nodeHandles[i] = SnapNode{snap, i}
+	}
+
+	for i := range snap.edgeHandles {
+		snap.edgeHandles[i] = SnapEdge{snap, i}
+	}
+
+	for i := range snap.nestHandles {
+		snap.nestHandles[i] = SnapNest{snap, i}
+	}
+
+	return snap, nil
+}
+
+// Get a node handle by its index. Returns "nil" if the index is out of range
+func (snap *Snapshot) nodeHandle(idx int) *SnapNode {
+	if idx < 0 || idx >= len(snap.nodeHandles) {
+		return nil
+	}
+
+	return &snap.nodeHandles[idx]
+}
+
+// Get an edge handle by its index. Returns "nil" if the index is out of range
+func (snap *Snapshot) edgeHandle(idx int) *SnapEdge {
+	if idx < 0 || idx >= len(snap.edgeHandles) {
+		return nil
+	}
+
+	return &snap.edgeHandles[idx]
+}
+
+// Get a nest handle by its index. Returns "nil" if the index is out of range
+func (snap *Snapshot) nestHandle(idx int) *SnapNest {
+	if idx < 0 || idx >= len(snap.nestHandles) {
+		return nil
+	}
+
+	return &snap.nestHandles[idx]
+}
+
+// Get the root nest of a snapshot
+func (snap *Snapshot) GetRootNest() *SnapNest {
+	return snap.nestHandle(0)
+}
+
+// Get first node in a list of all snapshot nodes
+func (snap *Snapshot) GetFirstNode() *SnapNode {
+	return snap.nodeHandle(0)
+}
+
+// Get attribute specification of the graph from which a snapshot was created
+func (snap *Snapshot) GetAttrSpec() AttrSpec {
+	return snap.attrSpec
+}
+
+// Check whether the graph of a snapshot is directed
+func (snap *Snapshot) IsDirected() bool {
+	return snap.directed
+}
+
+// Get number of nodes in a snapshot
+func (snap *Snapshot) NodeCount() int {
+	return len(snap.nodes)
+}
+
+// Get number of edges in a snapshot
+func (snap *Snapshot) EdgeCount() int {
+	return len(snap.edges)
+}
+
+// Get number of nests in a snapshot
+func (snap *Snapshot) NestCount() int {
+	return len(snap.nests)
+}
+
+// Check whether a graph string attribute is set in a snapshot
+func (snap *Snapshot) IsStrAttrSet(attr *GraphStrAttr) (bool, error) {
+	if err := snap.graph.checkStrAttr("Snapshot.IsStrAttrSet", attr); err != nil {
+		return false, err
+	}
+
+	return snap.graphStrAttrs[attr.attrNum].isSet, nil
+}
+
+// Get value of a graph string attribute in a snapshot
+func (snap *Snapshot) GetStrAttrVal(attr *GraphStrAttr) (string, error) {
+	if err := snap.graph.checkStrAttr("Snapshot.GetStrAttrVal", attr); err != nil {
+		return "", err
+	}
+
+	if !snap.graphStrAttrs[attr.attrNum].isSet {
+		return "", newAttrError("Snapshot.GetStrAttrVal", ATTR_ELEM_GRAPH, -1,
+			attr.attrNum, ErrAttrNotSet)
+	}
+
+	return snap.graphStrAttrs[attr.attrNum].data, nil
+}
+
+// Get node ID
+func (node *SnapNode) GetID() int {
+	return node.snap.nodes[node.idx].id
+}
+
+// Get nest to which a node belongs
+func (node *SnapNode) GetNest() *SnapNest {
+	return node.snap.nestHandle(node.snap.nodes[node.idx].nest)
+}
+
+// Get snapshot to which a node belongs
+func (node *SnapNode) GetSnapshot() *Snapshot {
+	return node.snap
+}
+
+// Get next node belonging to the same nest
+func (node *SnapNode) GetNextNodeInNest() *SnapNode {
+	nest := &node.snap.nests[node.snap.nodes[node.idx].nest]
+
+	if node.idx+1 >= nest.nodeEnd {
+		return nil
+	}
+
+	return node.snap.nodeHandle(node.idx + 1)
+}
+
+// Get previous node belonging to the same nest
+func (node *SnapNode) GetPrevNodeInNest() *SnapNode {
+	nest := &node.snap.nests[node.snap.nodes[node.idx].nest]
+
+	if node.idx-1 < nest.nodeStart {
+		return nil
+	}
+
+	return node.snap.nodeHandle(node.idx - 1)
+}
+
+// Get next node in a list of all snapshot nodes
+func (node *SnapNode) GetNextNode() *SnapNode {
+	return node.snap.nodeHandle(node.idx + 1)
+}
+
+// Get previous node in a list of all snapshot nodes
+func (node *SnapNode) GetPrevNode() *SnapNode {
+	return node.snap.nodeHandle(node.idx - 1)
+}
+
+// Get first outcoming edge of a node
+func (node *SnapNode) GetFirstOutcomingEdge() *SnapEdge {
+	node_data := &node.snap.nodes[node.idx]
+
+	if node_data.outStart == node_data.outEnd {
+		return nil
+	}
+
+	return node.snap.edgeHandle(node.snap.outEdges[node_data.outStart])
+}
+
+// Get first incoming edge of a node
+func (node *SnapNode) GetFirstIncomingEdge() *SnapEdge {
+	node_data := &node.snap.nodes[node.idx]
+
+	if node_data.inStart == node_data.inEnd {
+		return nil
+	}
+
+	return node.snap.edgeHandle(node.snap.inEdges[node_data.inStart])
+}
+
+// Check that a node string attribute can be used with a snapshot node
+func (node *SnapNode) checkStrAttr(op string, attr *NodeStrAttr) error {
+	if !attr.isValid {
+		return newAttrError(op, ATTR_ELEM_NODE, node.GetID(), -1, ErrAttrInvalid)
+	}
+
+	if attr.graph != node.snap.graph ||
+		attr.attrNum >= node.snap.attrSpec.NodeStrAttrNum {
+
+		return newAttrError(op, ATTR_ELEM_NODE, node.GetID(), attr.attrNum,
+			ErrAttrForeign)
+	}
+
+	return nil
+}
+
+// Check whether a string attribute is set for a node
+func (node *SnapNode) IsStrAttrSet(attr *NodeStrAttr) (bool, error) {
+	if err := node.checkStrAttr("IsStrAttrSet", attr); err != nil {
+		return false, err
+	}
+
+	val_idx := node.idx*node.snap.attrSpec.NodeStrAttrNum + attr.attrNum
+
+	return node.snap.nodeStrAttrs[val_idx].isSet, nil
+}
+
+// Get value of a node string attribute
+func (node *SnapNode) GetStrAttrVal(attr *NodeStrAttr) (string, error) {
+	if err := node.checkStrAttr("GetStrAttrVal", attr); err != nil {
+		return "", err
+	}
+
+	val_idx := node.idx*node.snap.attrSpec.NodeStrAttrNum + attr.attrNum
+	val := node.snap.nodeStrAttrs[val_idx]
+
+	if !val.isSet {
+		return "", newAttrError("GetStrAttrVal", ATTR_ELEM_NODE, node.GetID(),
+			attr.attrNum, ErrAttrNotSet)
+	}
+
+	return val.data, nil
+}
+
+// Get edge ID
+func (edge *SnapEdge) GetID() int {
+	return edge.snap.edges[edge.idx].id
+}
+
+// Get snapshot to which an edge belongs
+func (edge *SnapEdge) GetSnapshot() *Snapshot {
+	return edge.snap
+}
+
+// Get source node of an edge
+func (edge *SnapEdge) GetSrcNode() *SnapNode {
+	return edge.snap.nodeHandle(edge.snap.edges[edge.idx].src)
+}
+
+// Get destination node of an edge
+func (edge *SnapEdge) GetDstNode() *SnapNode {
+	return edge.snap.nodeHandle(edge.snap.edges[edge.idx].dst)
+}
+
+// Get nest to which an edge belongs
+func (edge *SnapEdge) GetNest() *SnapNest {
+	return edge.snap.nestHandle(edge.snap.edges[edge.idx].nest)
+}
+
+// Get next outcoming edge
+func (edge *SnapEdge) GetNextOutcomingEdge() *SnapEdge {
+	edge_data := &edge.snap.edges[edge.idx]
+
+	if edge_data.outPos+1 >= edge.snap.nodes[edge_data.src].outEnd {
+		return nil
+	}
+
+	return edge.snap.edgeHandle(edge.snap.outEdges[edge_data.outPos+1])
+}
+
+// Get previous outcoming edge
+func (edge *SnapEdge) GetPrevOutcomingEdge() *SnapEdge {
+	edge_data := &edge.snap.edges[edge.idx]
+
+	if edge_data.outPos-1 < edge.snap.nodes[edge_data.src].outStart {
+		return nil
+	}
+
+	return edge.snap.edgeHandle(edge.snap.outEdges[edge_data.outPos-1])
+}
+
+// Get next incoming edge
+func (edge *SnapEdge) GetNextIncomingEdge() *SnapEdge {
+	edge_data := &edge.snap.edges[edge.idx]
+
+	if edge_data.inPos+1 >= edge.snap.nodes[edge_data.dst].inEnd {
+		return nil
+	}
+
+	return edge.snap.edgeHandle(edge.snap.inEdges[edge_data.inPos+1])
+}
+
+// Get previous incoming edge
+func (edge *SnapEdge) GetPrevIncomingEdge() *SnapEdge {
+	edge_data := &edge.snap.edges[edge.idx]
+
+	if edge_data.inPos-1 < edge.snap.nodes[edge_data.dst].inStart {
+		return nil
+	}
+
+	return edge.snap.edgeHandle(edge.snap.inEdges[edge_data.inPos-1])
+}
+
+// Get next edge belonging to the same nest
+func (edge *SnapEdge) GetNextEdgeInNest() *SnapEdge {
+	if edge.idx+1 >= edge.snap.nests[edge.snap.edges[edge.idx].nest].edgeEnd {
+		return nil
+	}
+
+	return edge.snap.edgeHandle(edge.idx + 1)
+}
+
+// Get previous edge belonging to the same nest
+func (edge *SnapEdge) GetPrevEdgeInNest() *SnapEdge {
+	if edge.idx-1 < edge.snap.nests[edge.snap.edges[edge.idx].nest].edgeStart {
+		return nil
+	}
+
+	return edge.snap.edgeHandle(edge.idx - 1)
+}
+
+// Get unique ID of a nest
+func (nest *SnapNest) GetID() int {
+	return nest.snap.nests[nest.idx].id
+}
+
+// Get snapshot to which a nest belongs
+func (nest *SnapNest) GetSnapshot() *Snapshot {
+	return nest.snap
+}
+
+// Get level of a nest relative to the root of the nest tree
+func (nest *SnapNest) GetLevel() int {
+	return nest.snap.nests[nest.idx].level
+}
+
+// Get parent (or outer) nest of a nest
+func (nest *SnapNest) GetParentNest() *SnapNest {
+	return nest.snap.nestHandle(nest.snap.nests[nest.idx].parent)
+}
+
+// Get first child (or inner) nest of a nest
+func (nest *SnapNest) GetFirstChildNest() *SnapNest {
+	nest_data := &nest.snap.nests[nest.idx]
+
+	if nest_data.childStart == nest_data.childEnd {
+		return nil
+	}
+
+	return nest.snap.nestHandle(nest.snap.nestChildren[nest_data.childStart])
+}
+
+// Get last child (or inner) nest of a nest
+func (nest *SnapNest) GetLastChildNest() *SnapNest {
+	nest_data := &nest.snap.nests[nest.idx]
+
+	if nest_data.childStart == nest_data.childEnd {
+		return nil
+	}
+
+	return nest.snap.nestHandle(nest.snap.nestChildren[nest_data.childEnd-1])
+}
+
+// Get next nest that belongs to the same parent nest
+func (nest *SnapNest) GetNextSiblingNest() *SnapNest {
+	nest_data := &nest.snap.nests[nest.idx]
+
+	if nest_data.parent < 0 ||
+		nest_data.siblingPos+1 >= nest.snap.nests[nest_data.parent].childEnd {
+
+		return nil
+	}
+
+	return nest.snap.nestHandle(nest.snap.nestChildren[nest_data.siblingPos+1])
+}
+
+// Get previous nest that belongs to the same parent nest
+func (nest *SnapNest) GetPrevSiblingNest() *SnapNest {
+	nest_data := &nest.snap.nests[nest.idx]
+
+	if nest_data.parent < 0 ||
+		nest_data.siblingPos-1 < nest.snap.nests[nest_data.parent].childStart {
+
+		return nil
+	}
+
+	return nest.snap.nestHandle(nest.snap.nestChildren[nest_data.siblingPos-1])
+}
+
+// Get next nest in the entire tree
+func (nest *SnapNest) GetNextNest() *SnapNest {
+	return nest.snap.nestHandle(nest.idx + 1)
+}
+
+// Get previous nest in the entire tree
+func (nest *SnapNest) GetPrevNest() *SnapNest {
+	return nest.snap.nestHandle(nest.idx - 1)
+}
+
+// Get first graph node belonging to a nest
+func (nest *SnapNest) GetFirstNode() *SnapNode {
+	nest_data := &nest.snap.nests[nest.idx]
+
+	if nest_data.nodeStart == nest_data.nodeEnd {
+		return nil
+	}
+
+	return nest.snap.nodeHandle(nest_data.nodeStart)
+}
+
+// Get last graph node belonging to a nest
+func (nest *SnapNest) GetLastNode() *SnapNode {
+	nest_data := &nest.snap.nests[nest.idx]
+
+	if nest_data.nodeStart == nest_data.nodeEnd {
+		return nil
+	}
+
+	return nest.snap.nodeHandle(nest_data.nodeEnd - 1)
+}
+
+// Get first graph edge belonging to a nest
+func (nest *SnapNest) GetFirstEdge() *SnapEdge {
+	nest_data := &nest.snap.nests[nest.idx]
+
+	if nest_data.edgeStart == nest_data.edgeEnd {
+		return nil
+	}
+
+	return nest.snap.edgeHandle(nest_data.edgeStart)
+}
+
+// Check that a nest string attribute can be used with a snapshot nest
+func (nest *SnapNest) checkStrAttr(op string, attr *NestStrAttr) error {
+	if !attr.is_valid {
+		return newAttrError(op, ATTR_ELEM_NEST, nest.GetID(), -1, ErrAttrInvalid)
+	}
+
+	if attr.nestTree != nest.snap.graph.nestTree ||
+		attr.attr_num >= nest.snap.attrSpec.NestStrAttrNum {
+
+		return newAttrError(op, ATTR_ELEM_NEST, nest.GetID(), attr.attr_num,
+			ErrAttrForeign)
+	}
+
+	return nil
+}
+
+// Check whether a string attribute is set for a nest
+func (nest *SnapNest) IsStrAttrSet(attr *NestStrAttr) (bool, error) {
+	if err := nest.checkStrAttr("IsStrAttrSet", attr); err != nil {
+		return false, err
+	}
+
+	val_idx := nest.idx*nest.snap.attrSpec.NestStrAttrNum + attr.attr_num
+
+	return nest.snap.nestStrAttrs[val_idx].isSet, nil
+}
+
+// Get value of a nest string attribute
+func (nest *SnapNest) GetStrAttrVal(attr *NestStrAttr) (string, error) {
+	if err := nest.checkStrAttr("GetStrAttrVal", attr); err != nil {
+		return "", err
+	}
+
+	val_idx := nest.idx*nest.snap.attrSpec.NestStrAttrNum + attr.attr_num
+	val := nest.snap.nestStrAttrs[val_idx]
+
+	if !val.isSet {
+		return "", newAttrError("GetStrAttrVal", ATTR_ELEM_NEST, nest.GetID(),
+			attr.attr_num, ErrAttrNotSet)
+	}
+
+	return val.data, nil
+}
+
+func (node *SnapNode) emitLabel(format string,
+	attr *NodeStrAttr) (label string, has_label bool, err error) {
+
+	if attr == nil {
+		return "", false, nil
+	}
+
+	if has_label, err = node.IsStrAttrSet(attr); err != nil || !has_label {
+		return "", false, err
+	}
+
+	label, err = node.GetStrAttrVal(attr)
+
+	return label, err == nil, err
+}
+
+func (nest *SnapNest) emitLabel(format string,
+	attr *NestStrAttr) (label string, has_label bool, err error) {
+
+	if attr == nil {
+		return "", false, nil
+	}
+
+	if has_label, err = nest.IsStrAttrSet(attr); err != nil || !has_label {
+		return "", false, err
+	}
+
+	label, err = nest.GetStrAttrVal(attr)
+
+	return label, err == nil, err
+}
+
+func (nest *SnapNest) emitEdges(format string) ([]*SnapEdge, error) {
+	var edges []*SnapEdge
+
+	for edge := nest.GetFirstEdge(); edge != nil; edge = edge.GetNextEdgeInNest() {
+		edges = append(edges, edge)
+	}
+
+	return edges, nil
+}
+
+// Snapshots are consistent by construction. So, child nests are not checked
+func (nest *SnapNest) emitCheckChild(format string, child *SnapNest) error {
+	return nil
+}
+
+func (nest *SnapNest) emitErrNest() *Nest {
+	return nil
+}
+
+// Emit a snapshot in Graphviz format. The output is the same as the output of
+// "EmitInGVFormat()" for the source graph at the moment the snapshot was created (both
+// are produced by the same code). Since snapshots are immutable, several snapshots (or
+// the same snapshot) can be emitted from several goroutines simultaneously
+func EmitSnapshotInGVFormat(snap *Snapshot,
+	graph_emit_spec *GraphEmitSpec,
+	out_path string) (err error) {
+
+	defer recoverInternalError("EmitSnapshotInGVFormat", &err)
+
+	if snap == nil {
+		return newEmitError(EMIT_FORMAT_GV, nil, nil, nil, "Zero reference to the "+
+			"snapshot", ErrNilGraph)
+	}
+
+	if graph_emit_spec == nil {
+		graph_emit_spec = &GraphEmitSpec{}
+	}
+
+	graph_label := ""
+	has_graph_label := false
+
+	if label_attr := graph_emit_spec.Graph.LabelAttr; label_attr != nil {
+		if has_graph_label, err = snap.IsStrAttrSet(label_attr); err != nil {
+			return newEmitError(EMIT_FORMAT_GV, nil, nil, nil, "Error checking whether "+
+				"graph label attribute is set", err)
+		}
+
+		if has_graph_label {
+			if graph_label, err = snap.GetStrAttrVal(label_attr); err != nil {
+				return newEmitError(EMIT_FORMAT_GV, nil, nil, nil, "Error getting value "+
+					"of an attribute that keeps the graph label", err)
+			}
+		}
+	}
+
+	return emitGVDocument[*SnapNest, *SnapNode, *SnapEdge](snap.GetRootNest(),
+		graph_label, has_graph_label, graph_emit_spec, snap.directed, out_path)
+}
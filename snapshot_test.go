@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Build a labeled graph with nested nests and parallel edges
+func newSnapshotTestGraph(t *testing.T) (*Graph, *GraphEmitSpec) {
+	t.Helper()
+
+	graph := NewGraph(AttrSpec{GraphStrAttrNum: 1, NodeStrAttrNum: 1, NestStrAttrNum: 1})
+	spec := &GraphEmitSpec{}
+
+	var err error
+
+	if spec.Graph.LabelAttr, err = graph.NewGraphStrAttr(); err != nil {
+		t.Fatalf("NewGraphStrAttr: %v", err)
+	}
+
+	if spec.Node.LabelAttr, err = graph.NewNodeStrAttr(); err != nil {
+		t.Fatalf("NewNodeStrAttr: %v", err)
+	}
+
+	if spec.Nest.LabelAttr, err = graph.GetNestTree().NewNestStrAttr(); err != nil {
+		t.Fatalf("NewNestStrAttr: %v", err)
+	}
+
+	outer := graph.GetNestTree().NewNest()
+	inner, err := outer.NewChildNest()
+
+	if err != nil {
+		t.Fatalf("NewChildNest: %v", err)
+	}
+
+	graph.SetStrAttrVal(spec.Graph.LabelAttr, "snapshot")
+	outer.SetStrAttrVal(spec.Nest.LabelAttr, "outer")
+	inner.SetStrAttrVal(spec.Nest.LabelAttr, "inner")
+
+	var nodes []*Node
+
+	for i, nest := range []*Nest{outer, inner, inner, nil, nil} {
+		node := graph.NewNode()
+
+		if nest != nil {
+			node.MoveToNest(nest)
+		}
+
+		// One node is left without a label
+		if i != 3 {
+			node.SetStrAttrVal(spec.Node.LabelAttr, string(rune('a'+i)))
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	for _, ends := range [][2]int{{0, 1}, {1, 2}, {1, 2}, {3, 4}, {2, 4}} {
+		if _, err := graph.NewEdge(nodes[ends[0]], nodes[ends[1]]); err != nil {
+			t.Fatalf("NewEdge: %v", err)
+		}
+	}
+
+	return graph, spec
+}
+
+// Build the graph of "newSnapshotTestGraph()" for emitter tests. Node "a" gets a label
+// with characters that need escaping in most formats and a line break
+func newEmitTestGraph(t *testing.T) (*Graph, *GraphEmitSpec) {
+	t.Helper()
+
+	graph, spec := newSnapshotTestGraph(t)
+	graph.NodeByID(0).SetStrAttrVal(spec.Node.LabelAttr, "a & \"b\"\n<c>")
+
+	return graph, spec
+}
+
+func readTestFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	return data
+}
+
+// Rewrite golden files instead of comparing outputs with them: go test -run ... -update
+var update_golden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// Compare an output file with a golden file from the "testdata" directory
+func expectGoldenFile(t *testing.T, out_path string, golden_name string) {
+	t.Helper()
+
+	got := readTestFile(t, out_path)
+	golden_path := filepath.Join("testdata", golden_name)
+
+	if *update_golden {
+		if err := os.WriteFile(golden_path, got, 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+
+		return
+	}
+
+	if want := readTestFile(t, golden_path); !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s\n---\n%s", golden_path, got, want)
+	}
+}
+
+// A snapshot is emitted exactly like its source graph
+func TestEmitSnapshotInGVFormat(t *testing.T) {
+	for _, collapse := range []bool{false, true} {
+		graph, spec := newSnapshotTestGraph(t)
+		spec.Edge.CollapseParallel = collapse
+		dir := t.TempDir()
+		graph_path := filepath.Join(dir, "graph.gv")
+		snap_path := filepath.Join(dir, "snap.gv")
+
+		if err := EmitInGVFormat(graph, spec, graph_path); err != nil {
+			t.Fatalf("EmitInGVFormat: %v", err)
+		}
+
+		snap, err := graph.Freeze()
+
+		if err != nil {
+			t.Fatalf("Freeze: %v", err)
+		}
+
+		// The snapshot must not be affected by later changes of the graph
+		graph.NewNode()
+		graph.SetDirected(false)
+
+		if err := EmitSnapshotInGVFormat(snap, spec, snap_path); err != nil {
+			t.Fatalf("EmitSnapshotInGVFormat: %v", err)
+		}
+
+		graph_out := readTestFile(t, graph_path)
+		snap_out := readTestFile(t, snap_path)
+
+		if !bytes.Equal(graph_out, snap_out) {
+			t.Errorf("collapse = %t: snapshot output differs from graph output:\n"+
+				"%s\n---\n%s", collapse, graph_out, snap_out)
+		}
+	}
+}
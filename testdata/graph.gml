@@ -0,0 +1,71 @@
+graph [
+  directed 1
+  multigraph 1
+  label "snapshot"
+  node [
+    id 4
+    label "e"
+    node_attr_0 "e"
+  ]
+  node [
+    id 3
+  ]
+  node [
+    id 6
+    label "outer"
+    isGroup 1
+    nest_attr_0 "outer"
+  ]
+  node [
+    id 0
+    label "a &amp; &quot;b&quot;
+<c>"
+    gid 6
+    node_attr_0 "a &amp; &quot;b&quot;
+<c>"
+  ]
+  node [
+    id 7
+    label "inner"
+    isGroup 1
+    gid 6
+    nest_attr_0 "inner"
+  ]
+  node [
+    id 2
+    label "c"
+    gid 7
+    node_attr_0 "c"
+  ]
+  node [
+    id 1
+    label "b"
+    gid 7
+    node_attr_0 "b"
+  ]
+  edge [
+    id 4
+    source 2
+    target 4
+  ]
+  edge [
+    id 3
+    source 3
+    target 4
+  ]
+  edge [
+    id 0
+    source 0
+    target 1
+  ]
+  edge [
+    id 2
+    source 1
+    target 2
+  ]
+  edge [
+    id 1
+    source 1
+    target 2
+  ]
+]
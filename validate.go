@@ -0,0 +1,600 @@
+/*
+  Consistency validation of a graph and its nest tree
+
+  The package relies on a number of invariants (consistency of doubly linked lists, nest
+  levels, edge attribution to nests, etc.). Most of them are only checked in passing -
+  by means of panics inside the internal methods. The validator below walks the whole
+  graph and reports all detected violations at once, without panicking
+
+  NOTE: the validator never modifies the graph. It is safe to call it at any moment
+        between two graph mutations
+*/
+
+package graph
+
+import (
+	"fmt"
+)
+
+// Kinds of invariant violations reported by the validator
+const (
+	// The graph has no nest tree or the nest tree has no root nest
+	VIOLATION_NO_NEST_TREE = iota
+	// Inconsistent parent/child/sibling links between nests
+	VIOLATION_NEST_LINKS = iota
+	// A nest has a level that doesn't match its position in the nest tree
+	VIOLATION_NEST_LEVEL = iota
+	// Inconsistent links in a list of nodes belonging to a nest
+	VIOLATION_NODE_LIST = iota
+	// Inconsistent links in a list of edges belonging to a nest
+	VIOLATION_EDGE_LIST = iota
+	// Inconsistent links in a list of incoming or outcoming edges of a node
+	VIOLATION_ADJACENCY_LIST = iota
+	// An edge is attributed to a nest that is not the lowest common ancestor of the
+	// nests of its adjacent nodes
+	VIOLATION_EDGE_NEST = iota
+	// An element refers to a different graph or nest tree
+	VIOLATION_FOREIGN_ELEMENT = iota
+	// An element ID is out of range or is not unique
+	VIOLATION_ID = iota
+	// An array of attribute values (or an attribute allocation map) has unexpected size
+	VIOLATION_ATTR_ARRAY_SIZE = iota
+	// An ID lookup table (or a live element count) doesn't match the graph elements
+	VIOLATION_ID_TABLE = iota
+	// An edge is connected to a node that is not reachable in the graph (for example, to
+	// a deleted node or to a node of a different graph)
+	VIOLATION_EDGE_END = iota
+	// Zero reference to a graph was validated
+	VIOLATION_NIL_GRAPH = iota
+)
+
+// Description of a single invariant violation
+type Violation struct {
+	// Kind of the violation (one of VIOLATION_* constants)
+	Kind int
+	// ID of a node the violation relates to. "-1" if the violation doesn't relate to any
+	// specific node
+	NodeID int
+	// ID of an edge the violation relates to. "-1" if the violation doesn't relate to any
+	// specific edge
+	EdgeID int
+	// ID of a nest the violation relates to. "-1" if the violation doesn't relate to any
+	// specific nest
+	NestID int
+	// Human-readable description of the violation
+	Msg string
+}
+
+// Get printable representation of a violation
+func (v Violation) String() string {
+	str := v.Msg
+
+	if v.NestID >= 0 {
+		str += fmt.Sprintf(" [nest ID = %d]", v.NestID)
+	}
+
+	if v.NodeID >= 0 {
+		str += fmt.Sprintf(" [node ID = %d]", v.NodeID)
+	}
+
+	if v.EdgeID >= 0 {
+		str += fmt.Sprintf(" [edge ID = %d]", v.EdgeID)
+	}
+
+	return str
+}
+
+// Auxiliary state accumulated while validating a graph
+type graphValidator struct {
+	graph      *Graph
+	violations []Violation
+	// Nests, nodes and edges already seen by the validator. Used to detect cycles in
+	// linked lists and duplicate IDs
+	seenNests map[*Nest]bool
+	seenNodes map[*Node]bool
+	seenEdges map[*Edge]bool
+	// Nodes and edges in the order they were seen (to report violations in a stable
+	// order)
+	nodes []*Node
+	edges []*Edge
+	// Edges seen in lists of outcoming and incoming edges of the nodes
+	outEdges map[*Edge]bool
+	inEdges  map[*Edge]bool
+	nestIDs  map[int]bool
+	nodeIDs  map[int]bool
+	edgeIDs  map[int]bool
+}
+
+// Record a violation
+func (gv *graphValidator) report(kind int, nest *Nest, node *Node, edge *Edge,
+	msg string) {
+
+	v := Violation{Kind: kind, NodeID: -1, EdgeID: -1, NestID: -1, Msg: msg}
+
+	if nest != nil {
+		v.NestID = nest.id
+	}
+
+	if node != nil {
+		v.NodeID = node.id
+	}
+
+	if edge != nil {
+		v.EdgeID = edge.id
+	}
+
+	gv.violations = append(gv.violations, v)
+}
+
+// Check the whole graph against the invariants the package relies on. Returns the list of
+// detected violations. The returned list is empty if the graph is consistent
+func (graph *Graph) Validate() []Violation {
+	if graph == nil {
+		return []Violation{{Kind: VIOLATION_NIL_GRAPH, NodeID: -1, EdgeID: -1, NestID: -1,
+			Msg: ErrNilGraph.Error()}}
+	}
+
+	gv := &graphValidator{
+		graph:     graph,
+		seenNests: make(map[*Nest]bool),
+		seenNodes: make(map[*Node]bool),
+		seenEdges: make(map[*Edge]bool),
+		outEdges:  make(map[*Edge]bool),
+		inEdges:   make(map[*Edge]bool),
+		nestIDs:   make(map[int]bool),
+		nodeIDs:   make(map[int]bool),
+		edgeIDs:   make(map[int]bool),
+	}
+
+	gv.validateGraphAttrs()
+
+	nt := graph.nestTree
+
+	if nt == nil || nt.rootNest == nil {
+		gv.report(VIOLATION_NO_NEST_TREE, nil, nil, nil,
+			"The graph has no nest tree or the nest tree has no root nest")
+
+		return gv.violations
+	}
+
+	if nt.baseGraph != graph {
+		gv.report(VIOLATION_FOREIGN_ELEMENT, nil, nil, nil,
+			"The nest tree of the graph refers to a different base graph")
+	}
+
+	if len(nt.nestStrAttrAllocMap) != graph.attrSpec.NestStrAttrNum {
+		gv.report(VIOLATION_ATTR_ARRAY_SIZE, nil, nil, nil,
+			"Nest string attribute allocation map has unexpected size")
+	}
+
+	root := nt.rootNest
+
+	if root.parentNest != nil || root.prevSiblingNest != nil ||
+		root.nextSiblingNest != nil {
+
+		gv.report(VIOLATION_NEST_LINKS, root, nil, nil,
+			"The root nest has a parent or sibling nests")
+	}
+
+	if root.level != NT_ROOT_NEST_LEVEL {
+		gv.report(VIOLATION_NEST_LEVEL, root, nil, nil,
+			"The root nest has unexpected level")
+	}
+
+	gv.validateNest(root)
+
+	// Every edge reachable from node adjacency lists must be attributed to some nest
+	for _, node := range gv.nodes {
+		gv.validateAdjacencyLists(node)
+	}
+
+	// Both ends of every edge must be nodes reachable from the root nest, and the edge
+	// must be listed in the adjacency lists of these nodes. The check is done after the
+	// walk: an edge may be seen before its adjacent nodes
+	for _, edge := range gv.edges {
+		gv.validateEdgeEnds(edge)
+	}
+
+	gv.validateIDTables()
+
+	return gv.violations
+}
+
+// Check sizes of the graph-level attribute arrays
+func (gv *graphValidator) validateGraphAttrs() {
+	spec := gv.graph.attrSpec
+
+	if len(gv.graph.graphStrAttrAllocMap) != spec.GraphStrAttrNum {
+		gv.report(VIOLATION_ATTR_ARRAY_SIZE, nil, nil, nil,
+			"Graph string attribute allocation map has unexpected size")
+	}
+
+	if len(gv.graph.nodeStrAttrAllocMap) != spec.NodeStrAttrNum {
+		gv.report(VIOLATION_ATTR_ARRAY_SIZE, nil, nil, nil,
+			"Node string attribute allocation map has unexpected size")
+	}
+
+	if len(gv.graph.strAttrs) != spec.GraphStrAttrNum {
+		gv.report(VIOLATION_ATTR_ARRAY_SIZE, nil, nil, nil,
+			"Array of graph string attribute values has unexpected size")
+	}
+}
+
+// Validate a nest and - recursively - all its descendants
+func (gv *graphValidator) validateNest(nest *Nest) {
+	nt := gv.graph.nestTree
+
+	if gv.seenNests[nest] {
+		gv.report(VIOLATION_NEST_LINKS, nest, nil, nil,
+			"The nest is reachable more than once in the nest tree")
+
+		return
+	}
+
+	gv.seenNests[nest] = true
+
+	if nest.nestTree != nt {
+		gv.report(VIOLATION_FOREIGN_ELEMENT, nest, nil, nil,
+			"The nest belongs to a different nest tree")
+	}
+
+	if nest.id < 0 || nest.id >= nt.nestCount {
+		gv.report(VIOLATION_ID, nest, nil, nil, "Nest ID is out of range")
+	} else if gv.nestIDs[nest.id] {
+		gv.report(VIOLATION_ID, nest, nil, nil, "Nest ID is not unique")
+	}
+
+	gv.nestIDs[nest.id] = true
+
+	if len(nest.strAttrs) != gv.graph.attrSpec.NestStrAttrNum {
+		gv.report(VIOLATION_ATTR_ARRAY_SIZE, nest, nil, nil,
+			"Array of nest string attribute values has unexpected size")
+	}
+
+	gv.validateNestNodes(nest)
+	gv.validateNestEdges(nest)
+
+	// Walk child nests
+	var prev_child *Nest
+
+	for child := nest.firstChildNest; child != nil; child = child.nextSiblingNest {
+		if child.parentNest != nest {
+			gv.report(VIOLATION_NEST_LINKS, child, nil, nil,
+				"The nest doesn't refer to the nest that lists it as a child")
+		}
+
+		if child.prevSiblingNest != prev_child {
+			gv.report(VIOLATION_NEST_LINKS, child, nil, nil,
+				"The nest has inconsistent link to the previous sibling nest")
+		}
+
+		if child.level != nest.level+1 {
+			gv.report(VIOLATION_NEST_LEVEL, child, nil, nil,
+				"The nest level differs from the level of its parent plus one")
+		}
+
+		// Stop walking the list if a cycle is detected. The cycle itself is reported
+		// by the recursive call
+		if gv.seenNests[child] {
+			gv.validateNest(child)
+
+			break
+		}
+
+		gv.validateNest(child)
+		prev_child = child
+	}
+
+	if nest.lastChildNest != prev_child {
+		gv.report(VIOLATION_NEST_LINKS, nest, nil, nil,
+			"The nest has inconsistent link to the last child nest")
+	}
+}
+
+// Validate a list of nodes belonging to a nest
+func (gv *graphValidator) validateNestNodes(nest *Nest) {
+	var prev_node *Node
+
+	for node := nest.firstNode; node != nil; node = node.nextNodeInNest {
+		if gv.seenNodes[node] {
+			gv.report(VIOLATION_NODE_LIST, nest, node, nil,
+				"The node is reachable more than once in lists of nest nodes")
+
+			break
+		}
+
+		gv.seenNodes[node] = true
+		gv.nodes = append(gv.nodes, node)
+
+		if node.prevNodeInNest != prev_node {
+			gv.report(VIOLATION_NODE_LIST, nest, node, nil,
+				"The node has inconsistent link to the previous node in the nest")
+		}
+
+		if node.nest != nest {
+			gv.report(VIOLATION_NODE_LIST, nest, node, nil,
+				"The node doesn't refer to the nest that lists it")
+		}
+
+		if node.graph != gv.graph {
+			gv.report(VIOLATION_FOREIGN_ELEMENT, nest, node, nil,
+				"The node belongs to a different graph")
+		}
+
+		if node.id < 0 || node.id >= gv.graph.nodeCount {
+			gv.report(VIOLATION_ID, nil, node, nil, "Node ID is out of range")
+		} else if gv.nodeIDs[node.id] {
+			gv.report(VIOLATION_ID, nil, node, nil, "Node ID is not unique")
+		}
+
+		gv.nodeIDs[node.id] = true
+
+		if len(node.strAttrs) != gv.graph.attrSpec.NodeStrAttrNum {
+			gv.report(VIOLATION_ATTR_ARRAY_SIZE, nil, node, nil,
+				"Array of node string attribute values has unexpected size")
+		}
+
+		prev_node = node
+	}
+
+	if nest.lastNode != prev_node {
+		gv.report(VIOLATION_NODE_LIST, nest, nil, nil,
+			"The nest has inconsistent link to the last node")
+	}
+}
+
+// Validate a list of edges belonging to a nest
+func (gv *graphValidator) validateNestEdges(nest *Nest) {
+	var prev_edge *Edge
+
+	for edge := nest.firstEdge; edge != nil; edge = edge.nextEdgeInNest {
+		if gv.seenEdges[edge] {
+			gv.report(VIOLATION_EDGE_LIST, nest, nil, edge,
+				"The edge is reachable more than once in lists of nest edges")
+
+			break
+		}
+
+		gv.seenEdges[edge] = true
+		gv.edges = append(gv.edges, edge)
+
+		if edge.prevEdgeInNest != prev_edge {
+			gv.report(VIOLATION_EDGE_LIST, nest, nil, edge,
+				"The edge has inconsistent link to the previous edge in the nest")
+		}
+
+		if edge.nest != nest {
+			gv.report(VIOLATION_EDGE_LIST, nest, nil, edge,
+				"The edge doesn't refer to the nest that lists it")
+		}
+
+		if edge.graph != gv.graph {
+			gv.report(VIOLATION_FOREIGN_ELEMENT, nest, nil, edge,
+				"The edge belongs to a different graph")
+		}
+
+		if edge.id < 0 || edge.id >= gv.graph.edgeCount {
+			gv.report(VIOLATION_ID, nil, nil, edge, "Edge ID is out of range")
+		} else if gv.edgeIDs[edge.id] {
+			gv.report(VIOLATION_ID, nil, nil, edge, "Edge ID is not unique")
+		}
+
+		gv.edgeIDs[edge.id] = true
+
+		if edge.srcNode == nil || edge.dstNode == nil {
+			gv.report(VIOLATION_ADJACENCY_LIST, nest, nil, edge,
+				"The edge is not connected to a node at least at one end")
+		} else if lca := gv.lowestCommonNest(edge.srcNode.nest,
+			edge.dstNode.nest); lca != nest {
+
+			gv.report(VIOLATION_EDGE_NEST, nest, nil, edge,
+				"The edge is not attributed to the lowest common nest of its "+
+					"adjacent nodes")
+		}
+
+		prev_edge = edge
+	}
+}
+
+// Check that the adjacent nodes of an edge were seen while walking the nest tree and that
+// they list the edge in their adjacency lists. Edges having no adjacent node at some end
+// are reported while walking nest edges
+func (gv *graphValidator) validateEdgeEnds(edge *Edge) {
+	if edge.srcNode != nil && !gv.seenNodes[edge.srcNode] {
+		gv.report(VIOLATION_EDGE_END, nil, edge.srcNode, edge,
+			"The source node of the edge is not reachable in the graph")
+	}
+
+	if edge.dstNode != nil && !gv.seenNodes[edge.dstNode] {
+		gv.report(VIOLATION_EDGE_END, nil, edge.dstNode, edge,
+			"The destination node of the edge is not reachable in the graph")
+	}
+
+	if gv.seenNodes[edge.srcNode] && !gv.outEdges[edge] {
+		gv.report(VIOLATION_ADJACENCY_LIST, nil, edge.srcNode, edge,
+			"The edge is not listed among the outcoming edges of its source node")
+	}
+
+	if gv.seenNodes[edge.dstNode] && !gv.inEdges[edge] {
+		gv.report(VIOLATION_ADJACENCY_LIST, nil, edge.dstNode, edge,
+			"The edge is not listed among the incoming edges of its destination node")
+	}
+}
+
+// Find the lowest common ancestor of two nests. Returns "nil" if it cannot be found
+// (due to inconsistent levels or disconnected nest tree)
+//
+// In contrast to the logic used when attributing edges to nests, this function doesn't
+// panic on inconsistent data. The number of steps is bounded to protect the validator
+// from cycles in parent links
+func (gv *graphValidator) lowestCommonNest(a *Nest, b *Nest) *Nest {
+	if a == nil || b == nil {
+		return nil
+	}
+
+	steps_left := 2 * (gv.graph.nestTree.nestCount + 1)
+
+	for a != b {
+		if steps_left == 0 || a == nil || b == nil {
+			return nil
+		}
+
+		if a.level >= b.level {
+			a = a.parentNest
+		} else {
+			b = b.parentNest
+		}
+
+		steps_left--
+	}
+
+	return a
+}
+
+// Check that the ID lookup tables and the live element counts match the elements
+// reachable from the root nest
+func (gv *graphValidator) validateIDTables() {
+	graph := gv.graph
+	nt := graph.nestTree
+	nest_num, node_num, edge_num := 0, 0, 0
+
+	for id, nest := range nt.nestsByID {
+		if nest == nil {
+			continue
+		}
+
+		nest_num++
+
+		if nest.id != id || !gv.seenNests[nest] {
+			gv.report(VIOLATION_ID_TABLE, nest, nil, nil, fmt.Sprintf("Nest ID lookup "+
+				"table refers to an unreachable nest or to a nest with a different ID "+
+				"[table slot = %d]", id))
+		}
+	}
+
+	for id, node := range graph.nodesByID {
+		if node == nil {
+			continue
+		}
+
+		node_num++
+
+		if node.id != id || !gv.seenNodes[node] {
+			gv.report(VIOLATION_ID_TABLE, nil, node, nil, fmt.Sprintf("Node ID lookup "+
+				"table refers to an unreachable node or to a node with a different ID "+
+				"[table slot = %d]", id))
+		}
+	}
+
+	for id, edge := range graph.edgesByID {
+		if edge == nil {
+			continue
+		}
+
+		edge_num++
+
+		if edge.id != id || !gv.seenEdges[edge] {
+			gv.report(VIOLATION_ID_TABLE, nil, nil, edge, fmt.Sprintf("Edge ID lookup "+
+				"table refers to an unreachable edge or to an edge with a different ID "+
+				"[table slot = %d]", id))
+		}
+	}
+
+	if nest_num != len(gv.seenNests) || nt.liveNestCount != len(gv.seenNests) {
+		gv.report(VIOLATION_ID_TABLE, nil, nil, nil, fmt.Sprintf("%d nests are "+
+			"reachable, while the lookup table holds %d nests and the live nest count "+
+			"is %d", len(gv.seenNests), nest_num, nt.liveNestCount))
+	}
+
+	if node_num != len(gv.seenNodes) || graph.liveNodeCount != len(gv.seenNodes) {
+		gv.report(VIOLATION_ID_TABLE, nil, nil, nil, fmt.Sprintf("%d nodes are "+
+			"reachable, while the lookup table holds %d nodes and the live node count "+
+			"is %d", len(gv.seenNodes), node_num, graph.liveNodeCount))
+	}
+
+	if edge_num != len(gv.seenEdges) || graph.liveEdgeCount != len(gv.seenEdges) {
+		gv.report(VIOLATION_ID_TABLE, nil, nil, nil, fmt.Sprintf("%d edges are "+
+			"reachable, while the lookup table holds %d edges and the live edge count "+
+			"is %d", len(gv.seenEdges), edge_num, graph.liveEdgeCount))
+	}
+}
+
+// Validate lists of incoming and outcoming edges of a node
+func (gv *graphValidator) validateAdjacencyLists(node *Node) {
+	var prev_edge *Edge
+
+	seen := make(map[*Edge]bool)
+
+	for edge := node.firstOutcomingEdge; edge != nil; edge = edge.nextOutcomingEdge {
+		if seen[edge] {
+			gv.report(VIOLATION_ADJACENCY_LIST, nil, node, edge,
+				"Cycle detected in the list of outcoming edges of the node")
+
+			break
+		}
+
+		seen[edge] = true
+		gv.outEdges[edge] = true
+
+		if edge.srcNode != node {
+			gv.report(VIOLATION_ADJACENCY_LIST, nil, node, edge,
+				"An outcoming edge of the node has a different source node")
+		}
+
+		if edge.prevOutcomingEdge != prev_edge {
+			gv.report(VIOLATION_ADJACENCY_LIST, nil, node, edge,
+				"An outcoming edge of the node has inconsistent link to the previous "+
+					"outcoming edge")
+		}
+
+		if !gv.seenEdges[edge] {
+			gv.report(VIOLATION_EDGE_NEST, nil, node, edge,
+				"An outcoming edge of the node is not listed in any nest")
+		}
+
+		prev_edge = edge
+	}
+
+	if len(seen) != node.outDegree {
+		gv.report(VIOLATION_ADJACENCY_LIST, nil, node, nil, fmt.Sprintf("The node has "+
+			"%d outcoming edges, but its out-degree is %d", len(seen), node.outDegree))
+	}
+
+	prev_edge = nil
+	seen = make(map[*Edge]bool)
+
+	for edge := node.firstIncomingEdge; edge != nil; edge = edge.nextIncomingEdge {
+		if seen[edge] {
+			gv.report(VIOLATION_ADJACENCY_LIST, nil, node, edge,
+				"Cycle detected in the list of incoming edges of the node")
+
+			break
+		}
+
+		seen[edge] = true
+		gv.inEdges[edge] = true
+
+		if edge.dstNode != node {
+			gv.report(VIOLATION_ADJACENCY_LIST, nil, node, edge,
+				"An incoming edge of the node has a different destination node")
+		}
+
+		if edge.prevIncomingEdge != prev_edge {
+			gv.report(VIOLATION_ADJACENCY_LIST, nil, node, edge,
+				"An incoming edge of the node has inconsistent link to the previous "+
+					"incoming edge")
+		}
+
+		if !gv.seenEdges[edge] {
+			gv.report(VIOLATION_EDGE_NEST, nil, node, edge,
+				"An incoming edge of the node is not listed in any nest")
+		}
+
+		prev_edge = edge
+	}
+
+	if len(seen) != node.inDegree {
+		gv.report(VIOLATION_ADJACENCY_LIST, nil, node, nil, fmt.Sprintf("The node has "+
+			"%d incoming edges, but its in-degree is %d", len(seen), node.inDegree))
+	}
+}
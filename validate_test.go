@@ -0,0 +1,237 @@
+package graph
+
+import (
+	"testing"
+)
+
+// Graph used by the validator tests:
+//
+//	root
+//	  outer: a, b
+//	    inner: c
+//	  d
+//
+// with edges "a -> b", "a -> c" and "c -> d"
+type validateFixture struct {
+	graph *Graph
+	outer *Nest
+	inner *Nest
+	nodes map[string]*Node
+	edges map[string]*Edge
+}
+
+func newValidateFixture(t *testing.T) *validateFixture {
+	t.Helper()
+
+	fx := &validateFixture{
+		graph: NewGraph(DefaultAttrSpec()),
+		nodes: make(map[string]*Node),
+		edges: make(map[string]*Edge),
+	}
+
+	var err error
+
+	fx.outer = fx.graph.GetNestTree().NewNest()
+
+	if fx.inner, err = fx.outer.NewChildNest(); err != nil {
+		t.Fatalf("NewChildNest: %v", err)
+	}
+
+	for name, nest := range map[string]*Nest{"a": fx.outer, "b": fx.outer,
+		"c": fx.inner, "d": nil} {
+
+		node := fx.graph.NewNode()
+
+		if nest != nil {
+			if err = node.MoveToNest(nest); err != nil {
+				t.Fatalf("MoveToNest: %v", err)
+			}
+		}
+
+		fx.nodes[name] = node
+	}
+
+	for _, ends := range []string{"ab", "ac", "cd"} {
+		edge, err := fx.graph.NewEdge(fx.nodes[ends[:1]], fx.nodes[ends[1:]])
+
+		if err != nil {
+			t.Fatalf("NewEdge: %v", err)
+		}
+
+		fx.edges[ends] = edge
+	}
+
+	if violations := fx.graph.Validate(); len(violations) != 0 {
+		t.Fatalf("the fixture graph is inconsistent: %v", violations)
+	}
+
+	return fx
+}
+
+// Check that the violations include a violation of a given kind
+func expectViolation(t *testing.T, violations []Violation, kind int) {
+	t.Helper()
+
+	for _, violation := range violations {
+		if violation.Kind == kind {
+			return
+		}
+	}
+
+	t.Errorf("violation of kind %d is not reported (reported: %v)", kind, violations)
+}
+
+func TestValidateConsistent(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	// Moving nodes across nests re-attributes the edges
+	if err := fx.nodes["c"].MoveToNest(fx.outer); err != nil {
+		t.Fatalf("MoveToNest: %v", err)
+	}
+
+	if err := fx.nodes["d"].MoveToNest(fx.inner); err != nil {
+		t.Fatalf("MoveToNest: %v", err)
+	}
+
+	if violations := fx.graph.Validate(); len(violations) != 0 {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+}
+
+func TestValidateNodeList(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	// "outer" holds two nodes. Break the back link of the second one
+	fx.outer.firstNode.nextNodeInNest.prevNodeInNest = nil
+
+	expectViolation(t, fx.graph.Validate(), VIOLATION_NODE_LIST)
+}
+
+func TestValidateEdgeList(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	fx.outer.firstEdge.nest = fx.inner
+
+	expectViolation(t, fx.graph.Validate(), VIOLATION_EDGE_LIST)
+}
+
+func TestValidateAdjacencyList(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	fx.nodes["a"].outDegree++
+
+	expectViolation(t, fx.graph.Validate(), VIOLATION_ADJACENCY_LIST)
+}
+
+func TestValidateNestLinks(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	fx.inner.parentNest = fx.graph.GetNestTree().GetRootNest()
+
+	expectViolation(t, fx.graph.Validate(), VIOLATION_NEST_LINKS)
+}
+
+func TestValidateNestLevel(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	fx.inner.level = fx.outer.level
+
+	expectViolation(t, fx.graph.Validate(), VIOLATION_NEST_LEVEL)
+}
+
+func TestValidateEdgeNest(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	// The edge "a -> b" belongs to "outer". Place it into "inner" instead
+	edge := fx.edges["ab"]
+
+	edge.nest.removeEdge(edge)
+	fx.inner.addEdge(edge)
+	edge.nest = fx.inner
+
+	violations := fx.graph.Validate()
+
+	expectViolation(t, violations, VIOLATION_EDGE_NEST)
+
+	for _, violation := range violations {
+		if violation.Kind == VIOLATION_EDGE_NEST && violation.EdgeID != edge.id {
+			t.Errorf("unexpected edge in violation: %v", violation)
+		}
+	}
+}
+
+func TestValidateEdgeEnd(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	// Connect the edge "c -> d" to a node of a different graph
+	other := NewGraph(DefaultAttrSpec())
+	foreign_node := other.NewNode()
+	edge := fx.edges["cd"]
+
+	edge.dstNode = foreign_node
+
+	violations := fx.graph.Validate()
+
+	expectViolation(t, violations, VIOLATION_EDGE_END)
+
+	for _, violation := range violations {
+		if violation.Kind == VIOLATION_EDGE_END && (violation.EdgeID != edge.id ||
+			violation.NodeID != foreign_node.id) {
+
+			t.Errorf("unexpected elements in violation: %v", violation)
+		}
+	}
+}
+
+func TestValidateIDTable(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	fx.graph.liveNodeCount++
+
+	expectViolation(t, fx.graph.Validate(), VIOLATION_ID_TABLE)
+}
+
+func TestValidateNoNestTree(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	fx.graph.nestTree = nil
+
+	expectViolation(t, fx.graph.Validate(), VIOLATION_NO_NEST_TREE)
+}
+
+func TestValidateEdgeNotInAdjacencyList(t *testing.T) {
+	fx := newValidateFixture(t)
+
+	// Unlink the edge "a -> b" from the outcoming edges of "a" only. The edge is still
+	// listed in its nest and among the incoming edges of "b"
+	edge := fx.edges["ab"]
+	node := fx.nodes["a"]
+
+	if edge.prevOutcomingEdge != nil {
+		edge.prevOutcomingEdge.nextOutcomingEdge = edge.nextOutcomingEdge
+	} else {
+		node.firstOutcomingEdge = edge.nextOutcomingEdge
+	}
+
+	if edge.nextOutcomingEdge != nil {
+		edge.nextOutcomingEdge.prevOutcomingEdge = edge.prevOutcomingEdge
+	}
+
+	node.outDegree--
+
+	violations := fx.graph.Validate()
+
+	expectViolation(t, violations, VIOLATION_ADJACENCY_LIST)
+
+	for _, violation := range violations {
+		if violation.EdgeID != edge.id || violation.NodeID != node.id {
+			t.Errorf("unexpected elements in violation: %v", violation)
+		}
+	}
+}
+
+func TestValidateNilGraph(t *testing.T) {
+	var graph *Graph
+
+	expectViolation(t, graph.Validate(), VIOLATION_NIL_GRAPH)
+}
@@ -0,0 +1,699 @@
+/*
+  Interactive graph viewer
+
+  "Viewer" is an HTTP handler serving a graph as an interactive page. The page draws the
+  graph from a layout stored in node and nest attributes (see "layout.go") the same way
+  "EmitInSVGFormat()" does, and lets the user:
+    - collapse and expand nests by clicking their titles. A collapsed nest is drawn as a
+      folder box at the top-left corner of the nest (like a yFiles group node folded into
+      a folder node). Edges leading to the contents of the nest are redirected to the box
+    - search nodes and nests by label. Matching elements are highlighted and the nests
+      hiding them are expanded. "Enter" centers the view on the first match
+    - focus on the neighborhood of a node by clicking it: the nodes within the chosen
+      distance from the node (edge directions are ignored) stay highlighted, the rest of
+      the graph is dimmed. A click on the background clears the focus
+    - pan the drawing by dragging it and zoom it with the mouse wheel
+  Parallel edges are drawn as a single edge labeled with their number
+
+  The handler serves two resources:
+    - the page itself, at any path ending with "/"
+    - the graph data fetched by the page, at any path ending with "/graph.json"
+  So, the handler can be mounted at any path ending with "/" (with or without
+  "http.StripPrefix()"). The data is built from the in-memory graph at every request:
+  reloading the page shows the current state of the graph. The layout must be kept up to
+  date by the caller
+
+  The page is self-contained: it doesn't load scripts or styles from other sites
+*/
+
+package graph
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Last element of the path of the graph data
+const VIEWER_DATA_PATH = "graph.json"
+
+// Nest as described by the graph data
+type viewerNest struct {
+	ID int `json:"id"`
+	// ID of the parent nest. Absent for children of the root nest
+	Parent *int `json:"parent,omitempty"`
+	// Absent if the nest has no label
+	Label  *string `json:"label,omitempty"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Node as described by the graph data
+type viewerNode struct {
+	ID int `json:"id"`
+	// ID of the nest the node belongs to. Absent for nodes of the root nest
+	Nest *int `json:"nest,omitempty"`
+	// Absent if the node has no label
+	Label  *string `json:"label,omitempty"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Edge as described by the graph data
+type viewerEdge struct {
+	ID     int `json:"id"`
+	Source int `json:"source"`
+	Target int `json:"target"`
+}
+
+// Graph data fetched by the page. Nests are listed in pre-order (parents before
+// children). The root nest is not listed. Nests that are not drawn (see
+// "EmitInSVGFormat()") are not listed either
+type viewerData struct {
+	Label    *string      `json:"label,omitempty"`
+	Directed bool         `json:"directed"`
+	Nests    []viewerNest `json:"nests"`
+	Nodes    []viewerNode `json:"nodes"`
+	Edges    []viewerEdge `json:"edges"`
+}
+
+// HTTP handler serving a graph as an interactive page (see the description of the file)
+type Viewer struct {
+	graphEmitSpec GraphEmitSpec
+	layoutSpec    LayoutSpec
+	// Function giving access to the graph. HTTP requests are served concurrently, so the
+	// accesses are synchronized by this function
+	read func(f func(graph *Graph) error) error
+}
+
+// Check a layout specification of a viewed graph
+func viewerCheckLayoutSpec(op string,
+	graph *Graph,
+	layout_spec *LayoutSpec) (LayoutSpec, error) {
+
+	spec, err := checkLayoutSpec(op, graph, layout_spec)
+
+	if err != nil {
+		return spec, newEmitError(EMIT_FORMAT_VIEWER, nil, nil, nil, "Invalid layout "+
+			"specification", err)
+	}
+
+	if spec.Node.XAttr == nil || spec.Node.YAttr == nil {
+		return spec, newEmitError(EMIT_FORMAT_VIEWER, nil, nil, nil, "The layout "+
+			"specification doesn't define attributes holding node positions",
+			ErrNoLayoutAttrs)
+	}
+
+	return spec, nil
+}
+
+// Create an interactive viewer of a graph. The layout is read from the attributes defined
+// by the layout specification (see "EmitInSVGFormat()"). The specifications are copied,
+// but the attributes they refer to must stay allocated while the viewer is used
+//
+// The viewer serializes its own accesses to the graph. But the graph must not be modified
+// while the viewer is used. Graphs modified concurrently must be viewed through
+// "ConcurrentGraph.NewViewer()"
+func NewViewer(graph *Graph,
+	graph_emit_spec *GraphEmitSpec,
+	layout_spec *LayoutSpec) (viewer *Viewer, err error) {
+
+	defer recoverInternalError("NewViewer", &err)
+
+	if graph == nil {
+		return nil, newEmitError(EMIT_FORMAT_VIEWER, nil, nil, nil, "Zero reference to "+
+			"the graph", ErrNilGraph)
+	}
+
+	spec, err := viewerCheckLayoutSpec("NewViewer", graph, layout_spec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+
+	viewer = &Viewer{
+		layoutSpec: spec,
+		read: func(f func(graph *Graph) error) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			return f(graph)
+		},
+	}
+
+	if graph_emit_spec != nil {
+		viewer.graphEmitSpec = *graph_emit_spec
+	}
+
+	return viewer, nil
+}
+
+// Build the graph data
+func (viewer *Viewer) buildData(graph *Graph) (data *viewerData, err error) {
+	defer recoverInternalError("Viewer.ServeHTTP", &err)
+
+	// The attributes might have been released since the viewer was created
+	spec, err := viewerCheckLayoutSpec("Viewer.ServeHTTP", graph, &viewer.layoutSpec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	root_nest, err := emitRootNest(EMIT_FORMAT_VIEWER, graph)
+
+	if err != nil {
+		return nil, err
+	}
+
+	layout := &svgLayout{
+		format:    EMIT_FORMAT_VIEWER,
+		spec:      &spec,
+		nodeRects: make(map[*Node]LayoutRect),
+		nestRects: make(map[*Nest]LayoutRect),
+	}
+
+	if _, _, err := layout.readNest(root_nest); err != nil {
+		return nil, err
+	}
+
+	data = &viewerData{
+		Directed: graph.IsDirected(),
+		Nests:    make([]viewerNest, 0, len(layout.nestRects)),
+		Nodes:    make([]viewerNode, 0, graph.NodeCount()),
+		Edges:    make([]viewerEdge, 0, graph.EdgeCount()),
+	}
+
+	label, has_label, err := emitGraphLabel(EMIT_FORMAT_VIEWER, graph,
+		viewer.graphEmitSpec.Graph.LabelAttr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if has_label {
+		data.Label = &label
+	}
+
+	// Returns "nil" for the root nest
+	nest_id := func(nest *Nest) *int {
+		if nest == root_nest {
+			return nil
+		}
+
+		id := nest.GetID()
+
+		return &id
+	}
+
+	for nest := range root_nest.Descendants() {
+		rect, is_drawn := layout.nestRects[nest]
+
+		if !is_drawn {
+			continue
+		}
+
+		label, has_label, err := emitNestLabel(EMIT_FORMAT_VIEWER, nest,
+			viewer.graphEmitSpec.Nest.LabelAttr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		desc := viewerNest{ID: nest.GetID(), Parent: nest_id(nest.GetParentNest()),
+			X: rect.X, Y: rect.Y, Width: rect.Width, Height: rect.Height}
+
+		if has_label {
+			desc.Label = &label
+		}
+
+		data.Nests = append(data.Nests, desc)
+	}
+
+	for node := range graph.Nodes() {
+		rect := layout.nodeRects[node]
+		label, has_label, err := emitNodeLabel(EMIT_FORMAT_VIEWER, node,
+			viewer.graphEmitSpec.Node.LabelAttr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		desc := viewerNode{ID: node.GetID(), Nest: nest_id(node.GetNest()), X: rect.X,
+			Y: rect.Y, Width: rect.Width, Height: rect.Height}
+
+		if has_label {
+			desc.Label = &label
+		}
+
+		data.Nodes = append(data.Nodes, desc)
+	}
+
+	for edge := range graph.Edges() {
+		data.Edges = append(data.Edges, viewerEdge{edge.GetID(),
+			edge.GetSrcNode().GetID(), edge.GetDstNode().GetID()})
+	}
+
+	return data, nil
+}
+
+// Serve the graph data
+func (viewer *Viewer) serveData(writer http.ResponseWriter) {
+	var data *viewerData
+
+	err := viewer.read(func(graph *Graph) error {
+		var err error
+
+		data, err = viewer.buildData(graph)
+
+		return err
+	})
+
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	body, err := json.Marshal(data)
+
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.Write(body)
+}
+
+// Serve an HTTP request (see the description of the file for the served resources)
+func (viewer *Viewer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		writer.Header().Set("Allow", "GET, HEAD")
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	switch path := request.URL.Path; {
+	case strings.HasSuffix(path, "/"+VIEWER_DATA_PATH):
+		viewer.serveData(writer)
+	case strings.HasSuffix(path, "/"):
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(writer, VIEWER_PAGE)
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+// Page served by the viewer. The page fetches the graph data from a relative URL, so it
+// works wherever the viewer is mounted
+const VIEWER_PAGE = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Graph</title>
+<style>
+body { margin: 0; height: 100vh; display: flex; flex-direction: column;
+       font-family: sans-serif; font-size: 12px; }
+#bar { display: flex; gap: 8px; align-items: center; padding: 6px 10px;
+       border-bottom: 1px solid #cccccc; background: #f6f7f9; }
+#title { font-size: 14px; font-weight: bold; }
+#info { margin-left: auto; color: #666666; }
+#view { flex: 1; width: 100%; cursor: grab; user-select: none; }
+.nest > rect { fill: #e8edf5; fill-opacity: 0.5; stroke: #7a8699; }
+.nest > text, .folder text { fill: #3d4654; font-weight: bold; cursor: pointer; }
+.folder rect { fill: #d5deec; stroke: #7a8699; cursor: pointer; }
+.node rect { fill: #ffffff; stroke: #333333; cursor: pointer; }
+.node text { fill: #000000; cursor: pointer; }
+.edge path { fill: none; stroke: #555555; }
+.edge text { fill: #555555; }
+.match > rect { stroke: #d9480f; stroke-width: 3px; }
+.focused > rect { fill: #fff3bf; }
+.dimmed { opacity: 0.15; }
+</style>
+</head>
+<body>
+<div id="bar">
+  <span id="title"></span>
+  <input id="search" type="search" placeholder="Search labels">
+  <label>Neighborhood depth
+    <select id="depth"><option>1</option><option>2</option><option>3</option></select>
+  </label>
+  <button id="expand">Expand all</button>
+  <button id="collapse">Collapse all</button>
+  <button id="fit">Fit</button>
+  <span id="info">Loading...</span>
+</div>
+<svg id="view" xmlns="http://www.w3.org/2000/svg" font-size="12px"></svg>
+<script>
+"use strict";
+
+var SVG_NS = "http://www.w3.org/2000/svg";
+// Geometry of the drawing (matches the SVG renderer)
+var NEST_RADIUS = 8, NODE_RADIUS = 4, LOOP_SIZE = 30, FOLDER_MIN_WIDTH = 100,
+    FOLDER_HEIGHT = 30, MARGIN = 20, DRAG_THRESHOLD = 3;
+
+var svg = document.getElementById("view");
+var info = document.getElementById("info");
+// Graph data and its elements by IDs
+var graph = null, nestsByID = {}, nodesByID = {};
+// IDs of the collapsed nests
+var collapsed = new Set();
+// ID of the node whose neighborhood is focused
+var focusID = null;
+// IDs of the drawn elements matching the search ("n<node ID>", "c<nest ID>")
+var matches = new Set();
+// IDs of the drawn elements representing nodes (the node itself or a folder "f<nest ID>")
+// and rectangles of the drawn elements
+var reps = {}, rects = {};
+// Visible area of the drawing
+var viewBox = null;
+// State of dragging the drawing
+var drag = null, dragged = false;
+
+function elem(name, attrs, parent) {
+  var e = document.createElementNS(SVG_NS, name);
+  for (var attr in attrs) e.setAttribute(attr, attrs[attr]);
+  if (parent) parent.appendChild(e);
+  return e;
+}
+
+function text(x, y, str, attrs, parent) {
+  var t = elem("text", attrs, parent);
+  t.setAttribute("x", x);
+  t.setAttribute("y", y);
+  t.textContent = str;
+  return t;
+}
+
+function rect(r, radius, parent) {
+  return elem("rect", {x: r.x, y: r.y, width: r.width, height: r.height, rx: radius},
+              parent);
+}
+
+// Nodes without labels are labeled with their IDs
+function nodeLabel(node) {
+  return node.label !== undefined ? node.label : String(node.id);
+}
+
+function onClick(target, handler) {
+  target.addEventListener("click", function (ev) {
+    ev.stopPropagation();
+    if (!dragged) handler();
+  });
+}
+
+// Get IDs of a nest and of its ancestors (innermost first)
+function ancestors(nestID) {
+  var ids = [];
+  for (var id = nestID; id !== undefined; id = nestsByID[id].parent) ids.push(id);
+  return ids;
+}
+
+// Get ID of the outermost collapsed nest among a nest and its ancestors
+function outermostCollapsed(nestID) {
+  var found;
+  ancestors(nestID).forEach(function (id) { if (collapsed.has(id)) found = id; });
+  return found;
+}
+
+// Get the point where the segment from the center of a rectangle to point (x, y)
+// crosses the rectangle border
+function clip(r, x, y) {
+  var cx = r.x + r.width / 2, cy = r.y + r.height / 2, dx = x - cx, dy = y - cy;
+  var t = Math.min(dx !== 0 ? r.width / 2 / Math.abs(dx) : Infinity,
+                   dy !== 0 ? r.height / 2 / Math.abs(dy) : Infinity);
+  return t >= 1 ? [x, y] : [cx + dx * t, cy + dy * t];
+}
+
+// Find the focused neighborhood: the drawn elements representing its nodes and the
+// nests containing its nodes
+function focusSet() {
+  if (focusID === null) return null;
+  var depth = Number(document.getElementById("depth").value), adjacent = {};
+  graph.edges.forEach(function (e) {
+    (adjacent[e.source] = adjacent[e.source] || []).push(e.target);
+    (adjacent[e.target] = adjacent[e.target] || []).push(e.source);
+  });
+  var seen = new Set([focusID]), front = [focusID];
+  for (var d = 0; d < depth; d++) {
+    var next = [];
+    front.forEach(function (id) {
+      (adjacent[id] || []).forEach(function (n) {
+        if (!seen.has(n)) { seen.add(n); next.push(n); }
+      });
+    });
+    front = next;
+  }
+  var set = {reps: new Set(), nests: new Set()};
+  seen.forEach(function (id) {
+    set.reps.add(reps[id]);
+    ancestors(nodesByID[id].nest).forEach(function (n) { set.nests.add(n); });
+  });
+  return set;
+}
+
+function draw() {
+  svg.replaceChildren();
+  if (graph.directed) {
+    var marker = elem("marker", {id: "arrow", viewBox: "0 0 10 10", refX: 10, refY: 5,
+                                 markerWidth: 8, markerHeight: 8, orient: "auto"},
+                      elem("defs", {}, svg));
+    elem("path", {d: "M 0 0 L 10 5 L 0 10 z", fill: "#555555"}, marker);
+  }
+  // Nests are drawn first, then edges and then nodes and folders
+  var nestLayer = elem("g", {}, svg), edgeLayer = elem("g", {}, svg),
+      nodeLayer = elem("g", {}, svg);
+  reps = {};
+  rects = {};
+  graph.nests.forEach(function (nest) {
+    if (collapsed.has(nest.id)) {
+      var name = nest.label !== undefined ? nest.label : "nest " + nest.id;
+      rects["f" + nest.id] = {x: nest.x, y: nest.y, height: FOLDER_HEIGHT,
+                              width: Math.max(FOLDER_MIN_WIDTH, 7 * name.length + 30)};
+    }
+  });
+  graph.nodes.forEach(function (node) {
+    var folder = outermostCollapsed(node.nest);
+    reps[node.id] = folder !== undefined ? "f" + folder : "n" + node.id;
+    rects["n" + node.id] = node;
+  });
+  var focused = focusSet();
+  graph.nests.forEach(function (nest) {
+    if (outermostCollapsed(nest.parent) !== undefined) return;
+    var g;
+    if (collapsed.has(nest.id)) {
+      var r = rects["f" + nest.id];
+      g = elem("g", {"class": "folder", id: "f" + nest.id}, nodeLayer);
+      rect(r, NODE_RADIUS, g);
+      text(r.x + 8, r.y + r.height / 2, "▸ " + (nest.label !== undefined ?
+           nest.label : "nest " + nest.id), {"dominant-baseline": "central"}, g);
+      onClick(g, function () { collapsed.delete(nest.id); draw(); });
+      if (focused && !focused.reps.has("f" + nest.id)) g.classList.add("dimmed");
+    } else {
+      g = elem("g", {"class": "nest", id: "c" + nest.id}, nestLayer);
+      rect(nest, NEST_RADIUS, g);
+      onClick(text(nest.x + 8, nest.y + 14, "▾ " + (nest.label !== undefined ?
+                   nest.label : ""), {}, g),
+              function () { collapsed.add(nest.id); draw(); });
+      if (focused && !focused.nests.has(nest.id)) g.classList.add("dimmed");
+    }
+    if (matches.has("c" + nest.id)) g.classList.add("match");
+  });
+  graph.nodes.forEach(function (node) {
+    var id = "n" + node.id;
+    if (reps[node.id] !== id) return;
+    var g = elem("g", {"class": "node", id: id}, nodeLayer);
+    rect(node, NODE_RADIUS, g);
+    text(node.x + node.width / 2, node.y + node.height / 2, nodeLabel(node),
+         {"text-anchor": "middle", "dominant-baseline": "central"}, g);
+    onClick(g, function () { focusID = node.id; draw(); });
+    if (matches.has(id)) g.classList.add("match");
+    if (node.id === focusID) g.classList.add("focused");
+    if (focused && !focused.reps.has(id)) g.classList.add("dimmed");
+  });
+  // Edges between the same drawn elements are drawn as a single edge. Edges inside
+  // folders are not drawn
+  var groups = new Map();
+  graph.edges.forEach(function (e) {
+    var src = reps[e.source], dst = reps[e.target], key = src + " " + dst;
+    if (src === dst && src.charAt(0) === "f") return;
+    if (!graph.directed && !groups.has(key) && groups.has(dst + " " + src)) {
+      key = dst + " " + src;
+    }
+    if (!groups.has(key)) groups.set(key, {src: src, dst: dst, count: 0});
+    groups.get(key).count++;
+  });
+  groups.forEach(function (group) {
+    var g = elem("g", {"class": "edge"}, edgeLayer), d, x, y;
+    var rs = rects[group.src], rd = rects[group.dst];
+    if (group.src === group.dst) {
+      x = rs.x + rs.width;
+      y = rs.y;
+      d = "M " + (x - 10) + " " + y + " C " + (x - 10) + " " + (y - LOOP_SIZE) + " " +
+          (x + LOOP_SIZE) + " " + (y + 10) + " " + x + " " + (y + 10);
+      x += LOOP_SIZE / 2;
+      y -= LOOP_SIZE / 2;
+    } else {
+      var a = clip(rs, rd.x + rd.width / 2, rd.y + rd.height / 2),
+          b = clip(rd, rs.x + rs.width / 2, rs.y + rs.height / 2);
+      d = "M " + a[0] + " " + a[1] + " L " + b[0] + " " + b[1];
+      x = (a[0] + b[0]) / 2;
+      y = (a[1] + b[1]) / 2 - 4;
+    }
+    var path = elem("path", {d: d}, g);
+    if (graph.directed) path.setAttribute("marker-end", "url(#arrow)");
+    if (group.count > 1) text(x, y, String(group.count), {"text-anchor": "middle"}, g);
+    if (focused && !(focused.reps.has(group.src) && focused.reps.has(group.dst))) {
+      g.classList.add("dimmed");
+    }
+  });
+}
+
+function showInfo() {
+  var str = graph.nodes.length + " nodes, " + graph.edges.length + " edges, " +
+            graph.nests.length + " nests";
+  if (document.getElementById("search").value.trim() !== "") {
+    str = matches.size + " matches; " + str;
+  }
+  info.textContent = str;
+}
+
+// Highlight the elements whose labels contain the searched string and expand the nests
+// hiding them
+function search() {
+  var str = document.getElementById("search").value.trim().toLowerCase();
+  var expand = function (id) { collapsed.delete(id); };
+  matches = new Set();
+  if (str !== "") {
+    graph.nodes.forEach(function (node) {
+      if (nodeLabel(node).toLowerCase().indexOf(str) < 0) return;
+      matches.add("n" + node.id);
+      ancestors(node.nest).forEach(expand);
+    });
+    graph.nests.forEach(function (nest) {
+      if (nest.label === undefined || nest.label.toLowerCase().indexOf(str) < 0) return;
+      matches.add("c" + nest.id);
+      ancestors(nest.parent).forEach(expand);
+    });
+  }
+  showInfo();
+  draw();
+}
+
+function setViewBox() {
+  svg.setAttribute("viewBox", [viewBox.x, viewBox.y, viewBox.width,
+                               viewBox.height].join(" "));
+}
+
+function fit() {
+  var left = Infinity, top = Infinity, right = -Infinity, bottom = -Infinity;
+  graph.nodes.concat(graph.nests).forEach(function (r) {
+    left = Math.min(left, r.x);
+    top = Math.min(top, r.y);
+    right = Math.max(right, r.x + r.width);
+    bottom = Math.max(bottom, r.y + r.height);
+  });
+  if (left === Infinity) left = top = right = bottom = 0;
+  viewBox = {x: left - MARGIN, y: top - MARGIN, width: right - left + 2 * MARGIN,
+             height: bottom - top + 2 * MARGIN};
+  setViewBox();
+}
+
+// Center the view on the first match
+function showMatch() {
+  var first = svg.querySelector(".match");
+  if (!first) return;
+  var box = first.getBBox();
+  viewBox.x = box.x + box.width / 2 - viewBox.width / 2;
+  viewBox.y = box.y + box.height / 2 - viewBox.height / 2;
+  setViewBox();
+}
+
+function svgPoint(ev) {
+  var p = svg.createSVGPoint();
+  p.x = ev.clientX;
+  p.y = ev.clientY;
+  return p.matrixTransform(svg.getScreenCTM().inverse());
+}
+
+svg.addEventListener("wheel", function (ev) {
+  ev.preventDefault();
+  var p = svgPoint(ev), k = ev.deltaY > 0 ? 1.2 : 1 / 1.2;
+  viewBox = {x: p.x - (p.x - viewBox.x) * k, y: p.y - (p.y - viewBox.y) * k,
+             width: viewBox.width * k, height: viewBox.height * k};
+  setViewBox();
+}, {passive: false});
+
+svg.addEventListener("mousedown", function (ev) {
+  drag = {point: svgPoint(ev), x: ev.clientX, y: ev.clientY};
+  dragged = false;
+});
+
+window.addEventListener("mousemove", function (ev) {
+  if (!drag) return;
+  if (Math.abs(ev.clientX - drag.x) + Math.abs(ev.clientY - drag.y) > DRAG_THRESHOLD) {
+    dragged = true;
+  }
+  if (!dragged) return;
+  var p = svgPoint(ev);
+  viewBox.x -= p.x - drag.point.x;
+  viewBox.y -= p.y - drag.point.y;
+  setViewBox();
+});
+
+window.addEventListener("mouseup", function () { drag = null; });
+
+svg.addEventListener("click", function () {
+  if (!dragged && focusID !== null) {
+    focusID = null;
+    draw();
+  }
+});
+
+document.getElementById("search").addEventListener("input", search);
+document.getElementById("search").addEventListener("keydown", function (ev) {
+  if (ev.key === "Enter") showMatch();
+});
+document.getElementById("depth").addEventListener("change", function () { draw(); });
+document.getElementById("fit").addEventListener("click", fit);
+document.getElementById("expand").addEventListener("click", function () {
+  collapsed.clear();
+  draw();
+});
+document.getElementById("collapse").addEventListener("click", function () {
+  graph.nests.forEach(function (nest) { collapsed.add(nest.id); });
+  draw();
+});
+
+fetch("graph.json").then(function (resp) {
+  if (!resp.ok) return resp.text().then(function (msg) { throw new Error(msg); });
+  return resp.json();
+}).then(function (data) {
+  graph = data;
+  graph.nests.forEach(function (nest) { nestsByID[nest.id] = nest; });
+  graph.nodes.forEach(function (node) { nodesByID[node.id] = node; });
+  if (graph.label !== undefined) {
+    document.title = graph.label;
+    document.getElementById("title").textContent = graph.label;
+  }
+  fit();
+  showInfo();
+  draw();
+}).catch(function (err) {
+  info.textContent = "Cannot load the graph: " + err.message;
+});
+</script>
+</body>
+</html>
+`
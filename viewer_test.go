@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Fetch a resource from a test server. Returns the response and its body
+func viewerGet(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+
+	return resp, string(body)
+}
+
+// The viewer serves the page and the current graph data under any mount path
+func TestViewer(t *testing.T) {
+	graph, spec, layout_spec := newSVGTestGraph(t)
+	viewer, err := NewViewer(graph, spec, layout_spec)
+
+	if err != nil {
+		t.Fatalf("NewViewer: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/view/", http.StripPrefix("/view", viewer))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, body := viewerGet(t, server.URL+"/view/")
+
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(body, "<!DOCTYPE html>") {
+		t.Fatalf("page: got status %d and body of %d bytes", resp.StatusCode, len(body))
+	}
+
+	resp, body = viewerGet(t, server.URL+"/view/"+VIEWER_DATA_PATH)
+
+	if resp.StatusCode != http.StatusOK ||
+		resp.Header.Get("Content-Type") != "application/json" {
+
+		t.Fatalf("data: got status %d, content type %q", resp.StatusCode,
+			resp.Header.Get("Content-Type"))
+	}
+
+	var data viewerData
+
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatalf("data: %v\n%s", err, body)
+	}
+
+	if data.Label == nil || *data.Label != "svg <test>" || !data.Directed ||
+		len(data.Nests) != 2 || len(data.Nodes) != 4 || len(data.Edges) != 5 {
+
+		t.Fatalf("data: got %s", body)
+	}
+
+	// Nests are listed parents first and are drawn around their contents
+	outer, inner := data.Nests[0], data.Nests[1]
+
+	if outer.Parent != nil || inner.Parent == nil || *inner.Parent != outer.ID ||
+		inner.Label == nil || *inner.Label != "inner & co" ||
+		inner.X != 140 || inner.Y != 40 || inner.Width != 120 || inner.Height != 180 {
+
+		t.Errorf("nests: got %+v and %+v", outer, inner)
+	}
+
+	for _, node := range data.Nodes {
+		if node.ID == 3 && (node.Nest != nil || node.Label != nil || node.X != 360 ||
+			node.Y != 100 || node.Width != LAYOUT_DEFAULT_NODE_WIDTH) {
+
+			t.Errorf("node 3: got %+v", node)
+		}
+
+		if node.ID == 1 && (node.Nest == nil || *node.Nest != inner.ID) {
+			t.Errorf("node 1: got %+v", node)
+		}
+	}
+
+	// The data follows changes of the graph
+	graph.NodeByID(3).SetStrAttrVal(layout_spec.Node.XAttr, "400")
+	_, body = viewerGet(t, server.URL+"/view/"+VIEWER_DATA_PATH)
+
+	if !strings.Contains(body, `"id":3,"x":400,`) {
+		t.Errorf("moved node: got %s", body)
+	}
+
+	// Broken layouts are reported
+	graph.NodeByID(3).RemoveStrAttr(layout_spec.Node.XAttr)
+	resp, _ = viewerGet(t, server.URL+"/view/"+VIEWER_DATA_PATH)
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("broken layout: got status %d", resp.StatusCode)
+	}
+
+	if resp, _ = viewerGet(t, server.URL+"/view/other"); resp.StatusCode !=
+		http.StatusNotFound {
+
+		t.Errorf("unknown path: got status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/view/", "text/plain", strings.NewReader(""))
+
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got status %d", resp.StatusCode)
+	}
+}